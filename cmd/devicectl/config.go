@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/ivofreitas/device-api/pkg/client"
+	"gopkg.in/yaml.v3"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Config - contexts of the devicectl config file, each one a server with its credentials
+type Config struct {
+	CurrentContext string              `yaml:"current-context,omitempty"`
+	Contexts       map[string]*Context `yaml:"contexts,omitempty"`
+
+	path string
+}
+
+// Context - server URL and credentials. The API key and token are sent with every request,
+// and the certificate is presented to servers requiring mutual TLS
+type Context struct {
+	Server       string `yaml:"server"`
+	APIKey       string `yaml:"api-key,omitempty"`
+	APIKeyHeader string `yaml:"api-key-header,omitempty"`
+	Token        string `yaml:"token,omitempty"`
+	CAFile       string `yaml:"ca-file,omitempty"`
+	CertFile     string `yaml:"cert-file,omitempty"`
+	KeyFile      string `yaml:"key-file,omitempty"`
+}
+
+// defaultServer - used without a context, e.g. against make run
+const defaultServer = "http://localhost:8080"
+
+// configPath - DEVICECTL_CONFIG, or devicectl/config.yaml in the user config directory
+func configPath() string {
+	if path := os.Getenv("DEVICECTL_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "devicectl.yaml"
+	}
+	return filepath.Join(dir, "devicectl", "config.yaml")
+}
+
+// loadConfig - a missing file is an empty config
+func loadConfig(path string) (*Config, error) {
+	config := &Config{path: path, Contexts: map[string]*Context{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if config.Contexts == nil {
+		config.Contexts = map[string]*Context{}
+	}
+	return config, nil
+}
+
+// save - the file holds credentials, so only its owner may read it
+func (c *Config) save() error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0o600)
+}
+
+// context - the named context, the current one when name is empty, or the default server without any
+func (c *Config) context(name string) (*Context, error) {
+	if name == "" {
+		name = c.CurrentContext
+	}
+	if name == "" {
+		return &Context{Server: defaultServer}, nil
+	}
+
+	ctx, ok := c.Contexts[name]
+	if !ok {
+		return nil, fmt.Errorf("context %q not found in %s", name, c.path)
+	}
+	return ctx, nil
+}
+
+func (c *Config) names() []string {
+	names := make([]string, 0, len(c.Contexts))
+	for name := range c.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newClient - API client of a context, presenting its client certificate when it has one
+func newClient(ctx *Context, timeout time.Duration) (*client.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if ctx.CAFile != "" || ctx.CertFile != "" {
+		config := &tls.Config{MinVersion: tls.VersionTLS12}
+		if ctx.CAFile != "" {
+			pem, err := os.ReadFile(ctx.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			config.RootCAs = x509.NewCertPool()
+			if !config.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", ctx.CAFile)
+			}
+		}
+		if ctx.CertFile != "" {
+			cert, err := tls.LoadX509KeyPair(ctx.CertFile, ctx.KeyFile)
+			if err != nil {
+				return nil, err
+			}
+			config.Certificates = []tls.Certificate{cert}
+		}
+		transport.TLSClientConfig = config
+	}
+
+	options := []client.Option{
+		client.WithHTTPClient(&http.Client{Transport: transport, Timeout: timeout}),
+		client.WithUserAgent("devicectl"),
+	}
+	switch {
+	case ctx.APIKey != "" && ctx.Token != "":
+		apiKey, token := client.APIKey(ctx.APIKeyHeader, ctx.APIKey), client.BearerToken(ctx.Token)
+		options = append(options, client.WithAuth(client.AuthFunc(func(req *http.Request) error {
+			_ = apiKey.Authenticate(req)
+			return token.Authenticate(req)
+		})))
+	case ctx.APIKey != "":
+		options = append(options, client.WithAuth(client.APIKey(ctx.APIKeyHeader, ctx.APIKey)))
+	case ctx.Token != "":
+		options = append(options, client.WithAuth(client.BearerToken(ctx.Token)))
+	}
+	return client.New(ctx.Server, options...)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"text/tabwriter"
+)
+
+func (c *cli) configCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage the contexts of the config file",
+	}
+	cmd.AddCommand(c.getContextsCmd(), c.useContextCmd(), c.setContextCmd(), c.deleteContextCmd())
+	return cmd
+}
+
+func (c *cli) getContextsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get-contexts",
+		Short: "List the contexts, marking the current one",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tw := tabwriter.NewWriter(c.out, 0, 0, 3, ' ', 0)
+			fmt.Fprintln(tw, "CURRENT\tNAME\tSERVER")
+			for _, name := range c.config.names() {
+				current := ""
+				if name == c.config.CurrentContext {
+					current = "*"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", current, name, c.config.Contexts[name].Server)
+			}
+			return tw.Flush()
+		},
+	}
+}
+
+func (c *cli) useContextCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:               "use-context NAME",
+		Short:             "Make a context the current one",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: c.completeContexts,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, ok := c.config.Contexts[args[0]]; !ok {
+				return fmt.Errorf("context %q not found in %s", args[0], c.config.path)
+			}
+			c.config.CurrentContext = args[0]
+			if err := c.config.save(); err != nil {
+				return err
+			}
+			fmt.Fprintf(c.out, "switched to context %q\n", args[0])
+			return nil
+		},
+	}
+}
+
+// setContextCmd - creates or changes a context, leaving the fields without a flag as they are.
+// The first context created becomes the current one
+func (c *cli) setContextCmd() *cobra.Command {
+	var ctx Context
+	cmd := &cobra.Command{
+		Use:               "set-context NAME",
+		Short:             "Create or change a context",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: c.completeContexts,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			existing, ok := c.config.Contexts[args[0]]
+			if !ok {
+				existing = &Context{Server: defaultServer}
+				c.config.Contexts[args[0]] = existing
+			}
+
+			flags := cmd.Flags()
+			for flag, field := range map[string]*string{
+				"server":         &existing.Server,
+				"api-key":        &existing.APIKey,
+				"api-key-header": &existing.APIKeyHeader,
+				"token":          &existing.Token,
+				"ca-file":        &existing.CAFile,
+				"cert-file":      &existing.CertFile,
+				"key-file":       &existing.KeyFile,
+			} {
+				if flags.Changed(flag) {
+					*field, _ = flags.GetString(flag)
+				}
+			}
+			if c.config.CurrentContext == "" {
+				c.config.CurrentContext = args[0]
+			}
+
+			if err := c.config.save(); err != nil {
+				return err
+			}
+			fmt.Fprintf(c.out, "context %q saved\n", args[0])
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&ctx.Server, "server", "", "server URL, e.g. https://devices.example.com")
+	flags.StringVar(&ctx.APIKey, "api-key", "", "API key sent with every request")
+	flags.StringVar(&ctx.APIKeyHeader, "api-key-header", "", "header of the API key, X-API-Key when empty")
+	flags.StringVar(&ctx.Token, "token", "", "bearer token sent with every request")
+	flags.StringVar(&ctx.CAFile, "ca-file", "", "CA bundle verifying the server certificate")
+	flags.StringVar(&ctx.CertFile, "cert-file", "", "client certificate, for servers requiring mutual TLS")
+	flags.StringVar(&ctx.KeyFile, "key-file", "", "key of the client certificate")
+	return cmd
+}
+
+func (c *cli) deleteContextCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:               "delete-context NAME",
+		Short:             "Delete a context",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: c.completeContexts,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, ok := c.config.Contexts[args[0]]; !ok {
+				return fmt.Errorf("context %q not found in %s", args[0], c.config.path)
+			}
+			delete(c.config.Contexts, args[0])
+			if c.config.CurrentContext == args[0] {
+				c.config.CurrentContext = ""
+			}
+			if err := c.config.save(); err != nil {
+				return err
+			}
+			fmt.Fprintf(c.out, "context %q deleted\n", args[0])
+			return nil
+		},
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const devicesJSON = `[
+	{"id":1,"name":"Galaxy S24","brand":"Samsung","state":"available","creation_time":"2025-01-02T10:00:00Z"},
+	{"id":2,"name":"Galaxy Tab","brand":"Samsung","state":"in-use","creation_time":"2025-01-03T10:00:00Z"}
+]`
+
+type request struct {
+	method, path, query, contentType, apiKey, body string
+}
+
+func fakeServer(t *testing.T) (*httptest.Server, *[]request) {
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, request{r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Get("Content-Type"), r.Header.Get("X-API-Key"), string(body)})
+
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/devices":
+			_, _ = w.Write([]byte(devicesJSON))
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/devices":
+			var devices []map[string]interface{}
+			_ = json.Unmarshal([]byte(devicesJSON), &devices)
+			filtered := []map[string]interface{}{}
+			for _, device := range devices {
+				if state := r.URL.Query().Get("state"); state == "" || device["state"] == state {
+					device["state"] = map[string]interface{}{"value": device["state"]}
+					filtered = append(filtered, device)
+				}
+			}
+			page := map[string]interface{}{"total": len(filtered), "next_offset": nil}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"items": filtered, "page": page})
+		case r.URL.Path == "/v1/devices/1":
+			_, _ = w.Write([]byte(`{"id":1,"name":"Galaxy S24","brand":"Samsung","state":"in-use","creation_time":"2025-01-02T10:00:00Z"}`))
+		case r.Method == http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":3,"name":"Pixel 9","brand":"Google","state":"available","creation_time":"2025-01-04T10:00:00Z"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"type":"not_found","status":404,"detail":"device not found"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("DEVICECTL_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+	return server, &requests
+}
+
+func execute(t *testing.T, stdin string, args ...string) (string, string, int) {
+	var out, errOut bytes.Buffer
+	root := newRootCmd(&out, &errOut)
+	root.SetArgs(args)
+	root.SetIn(strings.NewReader(stdin))
+	code := 0
+	if err := root.Execute(); err != nil {
+		printError(&errOut, err)
+		code = 1
+	}
+	return out.String(), errOut.String(), code
+}
+
+func TestDevices(t *testing.T) {
+	server, requests := fakeServer(t)
+
+	t.Run("Lists As A Table With Filters", func(t *testing.T) {
+		out, _, code := execute(t, "", "--server", server.URL, "list", "--brand", "Samsung", "--state", "in-use")
+		require.Equal(t, 0, code)
+
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		require.Len(t, lines, 2)
+		assert.Regexp(t, `^ID\s+NAME\s+BRAND\s+STATE\s+CREATED$`, lines[0])
+		assert.Regexp(t, `^2\s+Galaxy Tab\s+Samsung\s+in-use\s+`, lines[1])
+		last := (*requests)[len(*requests)-1]
+		assert.Equal(t, "/v2/devices", last.path)
+		assert.Equal(t, "brand=Samsung&limit=100&state=in-use", last.query)
+	})
+
+	t.Run("Prints YAML With The API Field Names", func(t *testing.T) {
+		out, _, code := execute(t, "", "--server", server.URL, "get", "1", "-o", "yaml")
+		require.Equal(t, 0, code)
+		assert.Equal(t, "id: 1\nname: Galaxy S24\nbrand: Samsung\nstate: in-use\ncreation_time: \"2025-01-02T10:00:00Z\"\n", out)
+	})
+
+	t.Run("Renders Error Responses", func(t *testing.T) {
+		_, errOut, code := execute(t, "", "--server", server.URL, "get", "9")
+		assert.Equal(t, 1, code)
+		assert.Equal(t, "Error: device not found\n  type:   not_found\n  status: 404 Not Found\n", errOut)
+	})
+
+	t.Run("Checks Out With A Tested JSON Patch", func(t *testing.T) {
+		_, _, code := execute(t, "", "--server", server.URL, "checkout", "1")
+		require.Equal(t, 0, code)
+
+		last := (*requests)[len(*requests)-1]
+		assert.Equal(t, http.MethodPatch, last.method)
+		assert.Equal(t, "application/json-patch+json", last.contentType)
+		assert.JSONEq(t, `[{"op":"test","path":"/state","value":"available"},{"op":"replace","path":"/state","value":"in-use"}]`, last.body)
+	})
+
+	t.Run("Imports What It Exports", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "devices.yaml")
+		_, _, code := execute(t, "", "--server", server.URL, "export", "-f", file)
+		require.Equal(t, 0, code)
+		exported, err := os.ReadFile(file)
+		require.NoError(t, err)
+		assert.Contains(t, string(exported), "- id: 1\n  name: Galaxy S24\n")
+
+		before := len(*requests)
+		out, _, code := execute(t, string(exported), "--server", server.URL, "import", "-")
+		require.Equal(t, 0, code)
+		assert.Equal(t, "device 3 created\ndevice 3 created\n", out)
+
+		var created map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte((*requests)[before+1].body), &created))
+		assert.Equal(t, map[string]interface{}{"name": "Galaxy Tab", "brand": "Samsung", "state": "in-use"}, created)
+	})
+}
+
+func TestContexts(t *testing.T) {
+	server, requests := fakeServer(t)
+
+	_, _, code := execute(t, "", "config", "set-context", "staging", "--server", server.URL, "--api-key", "secret")
+	require.Equal(t, 0, code)
+	_, _, code = execute(t, "", "config", "set-context", "prod", "--server", "https://devices.example.com")
+	require.Equal(t, 0, code)
+
+	out, _, code := execute(t, "", "config", "get-contexts")
+	require.Equal(t, 0, code)
+	assert.Regexp(t, `\n\s+prod\s+https://devices.example.com\n\*\s+staging\s+`+server.URL, out)
+
+	_, _, code = execute(t, "", "list")
+	require.Equal(t, 0, code)
+	assert.Equal(t, "secret", (*requests)[0].apiKey)
+
+	_, errOut, code := execute(t, "", "--context", "dev", "list")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, `context "dev" not found`)
+
+	info, err := os.Stat(os.Getenv("DEVICECTL_CONFIG"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
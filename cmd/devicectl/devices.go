@@ -0,0 +1,351 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ivofreitas/device-api/pkg/client"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+var states = []string{"available", "in-use", "inactive"}
+
+func (c *cli) listCmd() *cobra.Command {
+	var brand, state, name string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List devices, optionally filtered by brand, state and name",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := client.DeviceQuery{Name: name, Brand: brand}
+			if state != "" {
+				parsed, err := client.ParseState(state)
+				if err != nil {
+					return err
+				}
+				query.States = []client.State{parsed}
+			}
+
+			devices := []client.Device{}
+			for device, err := range c.api.Devices(cmd.Context(), query) {
+				if err != nil {
+					return err
+				}
+				devices = append(devices, device)
+			}
+			return printDevices(c.out, c.output, devices)
+		},
+	}
+	cmd.Flags().StringVar(&brand, "brand", "", "only devices of this brand")
+	cmd.Flags().StringVar(&state, "state", "", "only devices in this state: available, in-use or inactive")
+	cmd.Flags().StringVar(&name, "name", "", "only devices whose name contains this, ignoring case")
+	_ = cmd.RegisterFlagCompletionFunc("state", fixedCompletion(states...))
+	return cmd
+}
+
+func (c *cli) getCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get ID",
+		Short: "Show a device",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := deviceId(args[0])
+			if err != nil {
+				return err
+			}
+			device, err := c.api.GetDevice(cmd.Context(), id)
+			if err != nil {
+				return err
+			}
+			return c.printDevice(device)
+		},
+	}
+}
+
+func (c *cli) createCmd() *cobra.Command {
+	var name, brand, state string
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create a device",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			parsed, err := client.ParseState(state)
+			if err != nil {
+				return err
+			}
+			device, err := c.api.CreateDevice(cmd.Context(), client.NewDevice{Name: name, Brand: brand, State: parsed})
+			if err != nil {
+				return err
+			}
+			return c.printDevice(device)
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "device name")
+	cmd.Flags().StringVar(&brand, "brand", "", "device brand")
+	cmd.Flags().StringVar(&state, "state", "available", "device state: available, in-use or inactive")
+	_ = cmd.MarkFlagRequired("name")
+	_ = cmd.MarkFlagRequired("brand")
+	_ = cmd.RegisterFlagCompletionFunc("state", fixedCompletion(states...))
+	return cmd
+}
+
+func (c *cli) updateCmd() *cobra.Command {
+	var name, brand, state string
+	cmd := &cobra.Command{
+		Use:   "update ID",
+		Short: "Replace the name, brand and state of a device",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := deviceId(args[0])
+			if err != nil {
+				return err
+			}
+			parsed, err := client.ParseState(state)
+			if err != nil {
+				return err
+			}
+			device, err := c.api.UpdateDevice(cmd.Context(), id, client.NewDevice{Name: name, Brand: brand, State: parsed})
+			if err != nil {
+				return err
+			}
+			return c.printDevice(device)
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "device name")
+	cmd.Flags().StringVar(&brand, "brand", "", "device brand")
+	cmd.Flags().StringVar(&state, "state", "", "device state: available, in-use or inactive")
+	for _, flag := range []string{"name", "brand", "state"} {
+		_ = cmd.MarkFlagRequired(flag)
+	}
+	_ = cmd.RegisterFlagCompletionFunc("state", fixedCompletion(states...))
+	return cmd
+}
+
+func (c *cli) patchCmd() *cobra.Command {
+	var name, brand, state, mergeFile, jsonPatchFile string
+	cmd := &cobra.Command{
+		Use:   "patch ID",
+		Short: "Change some fields of a device",
+		Long: "Change some fields of a device, given as flags, as a JSON Merge Patch (RFC 7396) document with --merge\n" +
+			"or as JSON Patch (RFC 6902) operations with --json-patch. Documents are read from a file, or stdin with -",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := deviceId(args[0])
+			if err != nil {
+				return err
+			}
+
+			var device *client.Device
+			switch {
+			case mergeFile != "":
+				document, err := readInput(cmd, mergeFile)
+				if err != nil {
+					return err
+				}
+				device, err = c.api.MergePatchDevice(cmd.Context(), id, document)
+				if err != nil {
+					return err
+				}
+			case jsonPatchFile != "":
+				document, err := readInput(cmd, jsonPatchFile)
+				if err != nil {
+					return err
+				}
+				var operations []client.PatchOperation
+				if err = json.Unmarshal(document, &operations); err != nil {
+					return fmt.Errorf("reading %s: %w", jsonPatchFile, err)
+				}
+				device, err = c.api.JSONPatchDevice(cmd.Context(), id, operations)
+				if err != nil {
+					return err
+				}
+			default:
+				var patch client.DevicePatch
+				if cmd.Flags().Changed("name") {
+					patch.Name = &name
+				}
+				if cmd.Flags().Changed("brand") {
+					patch.Brand = &brand
+				}
+				if cmd.Flags().Changed("state") {
+					parsed, err := client.ParseState(state)
+					if err != nil {
+						return err
+					}
+					patch.State = &parsed
+				}
+				if patch.Name == nil && patch.Brand == nil && patch.State == nil {
+					return fmt.Errorf("nothing to patch, set --name, --brand, --state, --merge or --json-patch")
+				}
+				if device, err = c.api.PatchDevice(cmd.Context(), id, patch); err != nil {
+					return err
+				}
+			}
+			return c.printDevice(device)
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "new device name")
+	cmd.Flags().StringVar(&brand, "brand", "", "new device brand")
+	cmd.Flags().StringVar(&state, "state", "", "new device state: available, in-use or inactive")
+	cmd.Flags().StringVar(&mergeFile, "merge", "", "JSON Merge Patch document file")
+	cmd.Flags().StringVar(&jsonPatchFile, "json-patch", "", "JSON Patch operations file")
+	cmd.MarkFlagsMutuallyExclusive("merge", "json-patch", "name")
+	cmd.MarkFlagsMutuallyExclusive("merge", "json-patch", "brand")
+	cmd.MarkFlagsMutuallyExclusive("merge", "json-patch", "state")
+	_ = cmd.RegisterFlagCompletionFunc("state", fixedCompletion(states...))
+	return cmd
+}
+
+func (c *cli) deleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete ID...",
+		Short: "Delete devices",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, arg := range args {
+				id, err := deviceId(arg)
+				if err != nil {
+					return err
+				}
+				if err = c.api.DeleteDevice(cmd.Context(), id); err != nil {
+					return err
+				}
+				fmt.Fprintf(c.out, "device %d deleted\n", id)
+			}
+			return nil
+		},
+	}
+}
+
+// checkoutCmd - the state is tested and replaced by one JSON Patch, applied in a single transaction,
+// so two people cannot check out the same device
+func (c *cli) checkoutCmd() *cobra.Command {
+	var release bool
+	cmd := &cobra.Command{
+		Use:   "checkout ID",
+		Short: "Take an available device into use, or release it with --release",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := deviceId(args[0])
+			if err != nil {
+				return err
+			}
+
+			from, to := "available", "in-use"
+			if release {
+				from, to = to, from
+			}
+			device, err := c.api.JSONPatchDevice(cmd.Context(), id, []client.PatchOperation{
+				{Op: "test", Path: "/state", Value: from},
+				{Op: "replace", Path: "/state", Value: to},
+			})
+			if err != nil {
+				return err
+			}
+			return c.printDevice(device)
+		},
+	}
+	cmd.Flags().BoolVar(&release, "release", false, "make an in-use device available again")
+	return cmd
+}
+
+// importCmd - every device is created even when some fail, which are reported at the end
+func (c *cli) importCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "import FILE",
+		Short: "Create the devices of a JSON or YAML file, or stdin with -",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := readInput(cmd, args[0])
+			if err != nil {
+				return err
+			}
+			devices, err := decodeDevices(data)
+			if err != nil {
+				return fmt.Errorf("reading %s: %w", args[0], err)
+			}
+
+			var failed int
+			for i := range devices {
+				body := client.NewDevice{Name: devices[i].Name, Brand: devices[i].Brand, State: devices[i].State}
+				device, err := c.api.CreateDevice(cmd.Context(), body)
+				if err != nil {
+					failed++
+					fmt.Fprintf(c.errOut, "device %d (%s): ", i+1, devices[i].Name)
+					printError(c.errOut, err)
+					continue
+				}
+				fmt.Fprintf(c.out, "device %d created\n", device.Id)
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("%d of %d devices failed to import", failed, len(devices))
+			}
+			return nil
+		},
+	}
+}
+
+// exportCmd - writes every device in a format import reads, YAML for .yaml and .yml files and JSON otherwise
+func (c *cli) exportCmd() *cobra.Command {
+	var file string
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write every device as JSON or YAML",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			devices, err := c.api.GetDevices(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			format, out := c.output, c.out
+			if format == formatTable {
+				format = formatJSON
+			}
+			if file != "" {
+				format = formatJSON
+				if ext := filepath.Ext(file); ext == ".yaml" || ext == ".yml" {
+					format = formatYAML
+				}
+				f, err := os.Create(file)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				out = f
+			}
+			if devices == nil {
+				devices = []client.Device{}
+			}
+			return encode(out, format, devices)
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", "", "file to write instead of stdout")
+	return cmd
+}
+
+func (c *cli) printDevice(device *client.Device) error {
+	if c.output != formatTable {
+		return encode(c.out, c.output, device)
+	}
+	return printDevices(c.out, c.output, []client.Device{*device})
+}
+
+func deviceId(arg string) (int, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("device id %q is not a positive number", arg)
+	}
+	return id, nil
+}
+
+func readInput(cmd *cobra.Command, name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(cmd.InOrStdin())
+	}
+	return os.ReadFile(name)
+}
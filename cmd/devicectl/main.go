@@ -0,0 +1,115 @@
+// devicectl - command-line client of the Device API
+package main
+
+import (
+	"github.com/ivofreitas/device-api/pkg/client"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"time"
+)
+
+// cli - state shared by the subcommands, filled from the global flags before any of them runs
+type cli struct {
+	out, errOut io.Writer
+
+	configPath  string
+	contextName string
+	server      string
+	output      string
+	timeout     time.Duration
+
+	config *Config
+	api    *client.Client
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run - errors are printed here rather than by cobra, so error responses are rendered in full
+func run(args []string, out, errOut io.Writer) int {
+	root := newRootCmd(out, errOut)
+	root.SetArgs(args)
+	if err := root.Execute(); err != nil {
+		printError(errOut, err)
+		return 1
+	}
+	return 0
+}
+
+func newRootCmd(out, errOut io.Writer) *cobra.Command {
+	c := &cli{out: out, errOut: errOut}
+
+	root := &cobra.Command{
+		Use:           "devicectl",
+		Short:         "Manage the devices of the Device API",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return c.init(cmd)
+		},
+	}
+	root.SetOut(out)
+	root.SetErr(errOut)
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&c.configPath, "config", configPath(), "config file holding the contexts")
+	flags.StringVar(&c.contextName, "context", "", "context to use instead of the current one")
+	flags.StringVar(&c.server, "server", "", "server URL, overriding the one of the context")
+	flags.StringVarP(&c.output, "output", "o", formatTable, "output format: table, json or yaml")
+	flags.DurationVar(&c.timeout, "timeout", 30*time.Second, "timeout of each request")
+	_ = root.RegisterFlagCompletionFunc("output", fixedCompletion(formats...))
+	_ = root.RegisterFlagCompletionFunc("context", c.completeContexts)
+
+	root.AddCommand(
+		c.listCmd(), c.getCmd(), c.createCmd(), c.updateCmd(), c.patchCmd(), c.deleteCmd(), c.checkoutCmd(),
+		c.importCmd(), c.exportCmd(), c.configCmd(),
+	)
+	return root
+}
+
+// init - loads the config and the client of the selected context. The config and completion commands
+// only need the former
+func (c *cli) init(cmd *cobra.Command) error {
+	if err := checkFormat(c.output); err != nil {
+		return err
+	}
+
+	config, err := loadConfig(c.configPath)
+	if err != nil {
+		return err
+	}
+	c.config = config
+	for parent := cmd; parent != nil; parent = parent.Parent() {
+		if parent.Name() == "config" || parent.Name() == "completion" {
+			return nil
+		}
+	}
+
+	ctx, err := config.context(c.contextName)
+	if err != nil {
+		return err
+	}
+	if c.server != "" {
+		copied := *ctx
+		copied.Server = c.server
+		ctx = &copied
+	}
+	c.api, err = newClient(ctx, c.timeout)
+	return err
+}
+
+func fixedCompletion(values ...string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return values, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func (c *cli) completeContexts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	config, err := loadConfig(c.configPath)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return config.names(), cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net/http"
+	"text/tabwriter"
+	"time"
+)
+
+// Output formats of -o
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+var formats = []string{formatTable, formatJSON, formatYAML}
+
+func checkFormat(format string) error {
+	for _, f := range formats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format %q, expected one of table, json and yaml", format)
+}
+
+// printDevices - JSON and YAML keep the field names of the API, so their output can be imported again
+func printDevices(w io.Writer, format string, devices []domain.Device) error {
+	if format != formatTable {
+		return encode(w, format, devices)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tBRAND\tSTATE\tCREATED")
+	for _, device := range devices {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", device.Id, device.Name, device.Brand, device.State.String(),
+			device.CreationTime.Local().Format(time.DateTime))
+	}
+	return tw.Flush()
+}
+
+// encode - YAML is converted from the JSON encoding, so both formats share the API field names and state strings
+func encode(w io.Writer, format string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if format == formatJSON {
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+
+	var node yaml.Node
+	if err = yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	blockStyle(&node)
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(&node); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// blockStyle - JSON parses as flow style YAML with quoted strings, which is not how YAML is usually written
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// decodeDevices - reads JSON or YAML, a single device or a list of them
+func decodeDevices(data []byte) ([]domain.Device, error) {
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	if _, ok := document.([]interface{}); !ok {
+		document = []interface{}{document}
+	}
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	var devices []domain.Device
+	if err = json.Unmarshal(data, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// printError - error responses are rendered field by field, other errors as they are
+func printError(w io.Writer, err error) {
+	var responseErr *domain.Error
+	if !errors.As(err, &responseErr) {
+		fmt.Fprintln(w, "Error:", err)
+		return
+	}
+
+	fmt.Fprintln(w, "Error:", responseErr.Detail)
+	fmt.Fprintln(w, "  type:  ", responseErr.Type)
+	fmt.Fprintf(w, "  status: %d %s\n", responseErr.Status, http.StatusText(responseErr.Status))
+}
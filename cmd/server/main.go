@@ -1,9 +1,27 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"github.com/ivofreitas/device-api/config"
 	"github.com/ivofreitas/device-api/internal/api"
+	"github.com/spf13/pflag"
+	"os"
 )
 
 func main() {
-	api.NewServer().Run()
+	args, run := os.Args[1:], func() { api.NewServer().Run() }
+	if len(args) > 0 && args[0] == "normalize-brands" {
+		args, run = args[1:], normalizeBrands
+	}
+
+	if err := config.Load(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			os.Exit(0)
+		}
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+
+	run()
 }
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/ivofreitas/device-api/config"
+	"github.com/ivofreitas/device-api/config/db"
+	"github.com/ivofreitas/device-api/internal/adapter/outbox"
+	"github.com/ivofreitas/device-api/internal/api/brand"
+	"os"
+)
+
+// normalizeBrands - the one-off job merging the free-text brands of devices into the brand catalogue
+func normalizeBrands() {
+	ctx := context.Background()
+	primary := db.NewPostgresConnection()
+	defer primary.Close()
+
+	if config.GetEnv().Database.Migrate {
+		if err := db.Migrate(ctx, primary); err != nil {
+			fmt.Fprintf(os.Stderr, "Database migration failed: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	normalization, err := brand.Normalize(ctx, primary, outbox.NewWriter())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Brand normalization failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Brands created: %d\nDevices updated: %d\n", normalization.BrandsCreated, normalization.DevicesUpdated)
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Cluster - primary pool plus optional read replicas with health monitoring
+type Cluster struct {
+	primary  *sql.DB
+	replicas []*replica
+	next     atomic.Uint64
+}
+
+type replica struct {
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// NewCluster - replicas start unhealthy and are enabled by the first health check
+func NewCluster(primary *sql.DB, replicas ...*sql.DB) *Cluster {
+	cluster := &Cluster{primary: primary}
+	for _, db := range replicas {
+		cluster.replicas = append(cluster.replicas, &replica{db: db})
+	}
+	return cluster
+}
+
+// Primary - returns the primary pool
+func (c *Cluster) Primary() *sql.DB {
+	return c.primary
+}
+
+// Writer - returns the primary pool and pins the request to it for read-your-writes
+func (c *Cluster) Writer(ctx context.Context) *sql.DB {
+	Pin(ctx)
+	return c.primary
+}
+
+// Reader - returns a healthy replica in round-robin order, falling back to the primary
+// when the request is pinned or no replica is healthy
+func (c *Cluster) Reader(ctx context.Context) *sql.DB {
+	if len(c.replicas) == 0 || IsPinned(ctx) {
+		return c.primary
+	}
+
+	start := c.next.Add(1)
+	for i := range c.replicas {
+		r := c.replicas[(start+uint64(i))%uint64(len(c.replicas))]
+		if r.healthy.Load() {
+			return r.db
+		}
+	}
+
+	return c.primary
+}
+
+// Monitor - pings every replica on the given interval until ctx is done
+func (c *Cluster) Monitor(ctx context.Context, interval, timeout time.Duration) {
+	if len(c.replicas) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		c.check(ctx, timeout)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Cluster) check(ctx context.Context, timeout time.Duration) {
+	for i, r := range c.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := r.db.PingContext(pingCtx)
+		cancel()
+
+		healthy := err == nil
+		if r.healthy.Swap(healthy) != healthy {
+			if healthy {
+				log.Printf("Database replica %d is healthy", i)
+			} else {
+				log.Printf("Database replica %d is unhealthy, falling back: %v", i, err)
+			}
+		}
+	}
+}
+
+// Close - closes the primary and every replica pool
+func (c *Cluster) Close() error {
+	errs := []error{c.primary.Close()}
+	for _, r := range c.replicas {
+		errs = append(errs, r.db.Close())
+	}
+	return errors.Join(errs...)
+}
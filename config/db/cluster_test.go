@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestClusterRouting(t *testing.T) {
+	open := func() *sql.DB {
+		conn, err := sql.Open("postgres", "host=127.0.0.1 sslmode=disable")
+		assert.NoError(t, err)
+		return conn
+	}
+	primary, first, second := open(), open(), open()
+
+	t.Run("No Replicas", func(t *testing.T) {
+		cluster := NewCluster(primary)
+		assert.Same(t, primary, cluster.Reader(context.Background()))
+	})
+
+	t.Run("Unhealthy Replicas Fall Back To Primary", func(t *testing.T) {
+		cluster := NewCluster(primary, first, second)
+		assert.Same(t, primary, cluster.Reader(context.Background()))
+	})
+
+	t.Run("Round Robin Over Healthy Replicas", func(t *testing.T) {
+		cluster := NewCluster(primary, first, second)
+		cluster.replicas[0].healthy.Store(true)
+		cluster.replicas[1].healthy.Store(true)
+
+		seen := map[*sql.DB]int{}
+		for i := 0; i < 4; i++ {
+			seen[cluster.Reader(context.Background())]++
+		}
+		assert.Equal(t, map[*sql.DB]int{first: 2, second: 2}, seen)
+	})
+
+	t.Run("Skips Unhealthy Replica", func(t *testing.T) {
+		cluster := NewCluster(primary, first, second)
+		cluster.replicas[1].healthy.Store(true)
+
+		for i := 0; i < 3; i++ {
+			assert.Same(t, second, cluster.Reader(context.Background()))
+		}
+	})
+
+	t.Run("Pinned After Write", func(t *testing.T) {
+		cluster := NewCluster(primary, first)
+		cluster.replicas[0].healthy.Store(true)
+
+		ctx := WithPin(context.Background())
+		assert.Same(t, first, cluster.Reader(ctx))
+		assert.Same(t, primary, cluster.Writer(ctx))
+		assert.Same(t, primary, cluster.Reader(ctx))
+
+		assert.Same(t, first, cluster.Reader(context.Background()))
+	})
+}
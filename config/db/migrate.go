@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID - advisory lock key that serializes migrations across instances
+const migrationLockID = 7_245_813
+
+type migration struct {
+	version int
+	name    string
+	query   string
+}
+
+// Migrate applies every embedded migration newer than the database version
+func Migrate(ctx context.Context, db *sql.DB) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	if _, err = conn.ExecContext(ctx, `
+			CREATE SCHEMA IF NOT EXISTS devices_schema;
+			CREATE TABLE IF NOT EXISTS devices_schema.schema_migrations (
+				version INT PRIMARY KEY,
+				name VARCHAR(255) NOT NULL,
+				applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`); err != nil {
+		return err
+	}
+
+	var current int
+	query := `SELECT COALESCE(MAX(version), 0) FROM devices_schema.schema_migrations`
+	if err = conn.QueryRowContext(ctx, query).Scan(&current); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err = apply(ctx, conn, m); err != nil {
+			return fmt.Errorf("migration %04d_%s: %w", m.version, m.name, err)
+		}
+	}
+
+	return nil
+}
+
+// Version returns the version applied to the database and the latest embedded one
+func Version(ctx context.Context, db *sql.DB) (applied int, latest int, err error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].version
+	}
+
+	query := `SELECT COALESCE(MAX(version), 0) FROM devices_schema.schema_migrations`
+	if err = db.QueryRowContext(ctx, query).Scan(&applied); err != nil {
+		return 0, latest, err
+	}
+
+	return applied, latest, nil
+}
+
+func apply(ctx context.Context, conn *sql.Conn, m migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, m.query); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO devices_schema.schema_migrations (version, name) VALUES ($1, $2)`
+	if _, err = tx.ExecContext(ctx, query, m.version, m.name); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func loadMigrations() ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]migration, 0, len(entries))
+	for _, entry := range entries {
+		prefix, name, found := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), "_")
+		if !found {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version: %s", entry.Name())
+		}
+
+		query, err := migrationFiles.ReadFile("migrations/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, migration{version: version, name: name, query: string(query)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+
+	return migrations, nil
+}
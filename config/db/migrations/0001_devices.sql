@@ -1,8 +1,6 @@
 CREATE SCHEMA IF NOT EXISTS devices_schema;
 
-DROP TABLE IF EXISTS devices_schema.devices;
-
-CREATE TABLE devices_schema.devices (
+CREATE TABLE IF NOT EXISTS devices_schema.devices (
     id SERIAL PRIMARY KEY,
     name VARCHAR(255) NULL,
     brand VARCHAR(255) NULL,
@@ -10,6 +8,5 @@ CREATE TABLE devices_schema.devices (
     creation_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
--- Indexes for faster querying
 CREATE INDEX IF NOT EXISTS idx_devices_brand ON devices_schema.devices(brand);
-CREATE INDEX IF NOT EXISTS idx_devices_state ON devices_schema.devices(state);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_devices_state ON devices_schema.devices(state);
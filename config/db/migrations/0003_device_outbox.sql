@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS devices_schema.device_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    device_id INT NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    creation_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    dispatched_at TIMESTAMP NULL
+);
+
+-- Indexes for the relay poll, the per device ordering check and the retention cleanup
+CREATE INDEX IF NOT EXISTS idx_device_outbox_pending ON devices_schema.device_outbox(id) WHERE dispatched_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_device_outbox_device ON devices_schema.device_outbox(device_id, id) WHERE dispatched_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_device_outbox_dispatched ON devices_schema.device_outbox(dispatched_at) WHERE dispatched_at IS NOT NULL;
+
+-- Webhook deliveries are now created by the outbox relay, which may publish an event more than once
+ALTER TABLE devices_schema.webhook_deliveries ADD COLUMN IF NOT EXISTS event_id BIGINT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_deliveries_event ON devices_schema.webhook_deliveries(webhook_id, event_id);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS devices_schema.rate_limits (
+    key VARCHAR(512) PRIMARY KEY,
+    -- Theoretical arrival time of the bucket, it is full again once this has passed
+    tat TIMESTAMPTZ NOT NULL
+);
+
+-- Index for the cleanup of full buckets
+CREATE INDEX IF NOT EXISTS idx_rate_limits_tat ON devices_schema.rate_limits(tat);
@@ -0,0 +1,2 @@
+-- Key/value labels of a device, served from v2 of the API on
+ALTER TABLE devices_schema.devices ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,11 @@
+-- Full-text and fuzzy search over the name and brand of devices
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE devices_schema.devices ADD COLUMN IF NOT EXISTS search TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(brand, '')), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_devices_search ON devices_schema.devices USING GIN (search);
+CREATE INDEX IF NOT EXISTS idx_devices_name_trgm ON devices_schema.devices USING GIN (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_devices_brand_trgm ON devices_schema.devices USING GIN (brand gin_trgm_ops);
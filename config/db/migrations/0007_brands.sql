@@ -0,0 +1,18 @@
+-- Catalogue of the brands devices are made by, with the aliases a brand is also written as
+CREATE TABLE IF NOT EXISTS devices_schema.brands (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    aliases TEXT[] NOT NULL DEFAULT '{}',
+    creation_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The normalised name and aliases of every brand, which keeps them from resolving to two brands
+CREATE TABLE IF NOT EXISTS devices_schema.brand_keys (
+    key VARCHAR(255) PRIMARY KEY,
+    brand_id INT NOT NULL REFERENCES devices_schema.brands(id) ON DELETE CASCADE
+);
+
+ALTER TABLE devices_schema.devices ADD COLUMN IF NOT EXISTS brand_id INT NULL REFERENCES devices_schema.brands(id);
+
+CREATE INDEX IF NOT EXISTS idx_brand_keys_brand ON devices_schema.brand_keys(brand_id);
+CREATE INDEX IF NOT EXISTS idx_devices_brand_id ON devices_schema.devices(brand_id);
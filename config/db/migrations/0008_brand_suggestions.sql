@@ -0,0 +1,2 @@
+-- Brand suggestions of searches resemble the query to the names and aliases of the catalogue
+CREATE INDEX IF NOT EXISTS idx_brand_keys_key_trgm ON devices_schema.brand_keys USING GIN (key gin_trgm_ops);
@@ -0,0 +1,26 @@
+package db
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type pinKey struct{}
+
+// WithPin - returns a context able to record that the request has written to the primary
+func WithPin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, pinKey{}, new(atomic.Bool))
+}
+
+// Pin - routes every following read of the request to the primary
+func Pin(ctx context.Context) {
+	if pinned, ok := ctx.Value(pinKey{}).(*atomic.Bool); ok {
+		pinned.Store(true)
+	}
+}
+
+// IsPinned - reports whether the request has written to the primary
+func IsPinned(ctx context.Context) bool {
+	pinned, ok := ctx.Value(pinKey{}).(*atomic.Bool)
+	return ok && pinned.Load()
+}
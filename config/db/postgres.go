@@ -1,30 +1,131 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/ivofreitas/device-api/config"
+	"github.com/ivofreitas/device-api/internal/adapter/backoff"
+	"github.com/lib/pq"
 	"log"
-
-	_ "github.com/lib/pq"
+	"strings"
+	"time"
 )
 
+// connectBackoff - retry policy used while waiting for the database at startup
+var connectBackoff = backoff.Backoff{Initial: 250 * time.Millisecond, Max: 5 * time.Second, Multiplier: 2, Jitter: true}
+
 func NewPostgresConnection() *sql.DB {
 	env := config.GetEnv()
-	connStr := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		env.Database.Host, env.Database.Port, env.Database.User,
-		env.Database.Password, env.Database.DBName, env.Database.SSLMode,
-	)
 
-	db, err := sql.Open("postgres", connStr)
+	db, err := Open(env.Database, dsn(env.Database))
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	if err := db.Ping(); err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), env.Database.ConnectTimeout)
+	defer cancel()
+
+	if err := pingWithRetry(ctx, db, connectBackoff); err != nil {
 		log.Fatalf("Database ping failed: %v", err)
 	}
 
 	return db
 }
+
+// NewPostgresCluster - connects to the primary and opens a pool for every configured replica
+func NewPostgresCluster() *Cluster {
+	env := config.GetEnv()
+
+	primary := NewPostgresConnection()
+
+	replicas := make([]*sql.DB, 0, len(env.Database.Replicas))
+	for _, replicaDSN := range env.Database.Replicas {
+		replica, err := Open(env.Database, replicaDSN)
+		if err != nil {
+			log.Fatalf("Failed to open database replica: %v", err)
+		}
+		replicas = append(replicas, replica)
+	}
+
+	return NewCluster(primary, replicas...)
+}
+
+// NewListener - a connection of its own to the primary for LISTEN, reconnected when it drops. callback
+// reports its connection events
+func NewListener(callback pq.EventCallbackType) *pq.Listener {
+	return pq.NewListener(dsn(config.GetEnv().Database), time.Second, time.Minute, callback)
+}
+
+// Open - opens a pool for the given DSN using the configured pool settings
+func Open(cfg config.Database, dsn string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	return db, nil
+}
+
+// pingWithRetry - pings until the database answers, backing off exponentially until ctx expires
+func pingWithRetry(ctx context.Context, db *sql.DB, policy backoff.Backoff) error {
+	for attempt := 0; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+
+		delay := policy.Duration(attempt)
+		log.Printf("Database not ready (attempt %d), retrying in %s: %v", attempt+1, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", ctx.Err(), err)
+		case <-time.After(delay):
+		}
+	}
+}
+
+// dsn - builds the connection string, preferring the full URL form when it is set
+func dsn(cfg config.Database) string {
+	if cfg.URL != "" {
+		return cfg.URL
+	}
+
+	params := []struct{ key, value string }{
+		{"host", cfg.Host},
+		{"port", cfg.Port},
+		{"user", cfg.User},
+		{"password", cfg.Password},
+		{"dbname", cfg.DBName},
+		{"sslmode", cfg.SSLMode},
+		{"sslrootcert", cfg.SSLRootCert},
+		{"sslcert", cfg.SSLCert},
+		{"sslkey", cfg.SSLKey},
+	}
+
+	parts := make([]string, 0, len(params))
+	for _, param := range params {
+		if param.value == "" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s=%s", param.key, quote(param.value)))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// quote - escapes a value for the key/value DSN form
+func quote(value string) string {
+	if !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"github.com/ivofreitas/device-api/config"
+	"github.com/ivofreitas/device-api/internal/adapter/backoff"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestDSN(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cfg      config.Database
+		expected string
+	}{
+		{
+			name:     "Key Value Form",
+			cfg:      config.Database{Host: "localhost", Port: "5432", User: "device_user", Password: "device_pass", DBName: "device_db", SSLMode: "disable"},
+			expected: "host=localhost port=5432 user=device_user password=device_pass dbname=device_db sslmode=disable",
+		},
+		{
+			name: "TLS Parameters",
+			cfg: config.Database{Host: "db", User: "device_user", DBName: "device_db", SSLMode: "verify-full",
+				SSLRootCert: "/certs/ca.pem", SSLCert: "/certs/client.pem", SSLKey: "/certs/client.key"},
+			expected: "host=db user=device_user dbname=device_db sslmode=verify-full sslrootcert=/certs/ca.pem sslcert=/certs/client.pem sslkey=/certs/client.key",
+		},
+		{
+			name:     "Quoted Values",
+			cfg:      config.Database{Host: "db", Password: `it's a \secret`},
+			expected: `host=db password='it\'s a \\secret'`,
+		},
+		{
+			name:     "URL Form Takes Precedence",
+			cfg:      config.Database{URL: "postgres://device_user:device_pass@db:5432/device_db?sslmode=verify-full&sslrootcert=/certs/ca.pem", Host: "ignored"},
+			expected: "postgres://device_user:device_pass@db:5432/device_db?sslmode=verify-full&sslrootcert=/certs/ca.pem",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, dsn(tc.cfg))
+		})
+	}
+}
+
+func TestPingWithRetryDeadline(t *testing.T) {
+	conn, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = pingWithRetry(ctx, conn, backoff.Backoff{Initial: 10 * time.Millisecond, Max: 20 * time.Millisecond})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
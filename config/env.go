@@ -1,24 +1,48 @@
 package config
 
 import (
-	"github.com/joho/godotenv"
-	"github.com/labstack/gommon/log"
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
-	"sync"
+	"strings"
+	"time"
 )
 
 // Env values
 type Env struct {
-	Server   Server
-	Log      Log
-	Doc      Doc
-	Database Database
+	Server    Server
+	Log       Log
+	Doc       Doc
+	API       API
+	Database  Database
+	Cache     Cache
+	Brands    Brands
+	Events    Events
+	Webhooks  Webhooks
+	Outbox    Outbox
+	GraphQL   GraphQL
+	RateLimit RateLimit
 }
 
 // Server config
 type Server struct {
-	Host string
-	Port string
+	Host             string
+	Port             string
+	GRPCPort         string
+	ShutdownDelay    time.Duration
+	ReadinessTimeout time.Duration
+	// TLSCertFile - serves HTTPS and gRPC over TLS when set, reloading the files whenever they change
+	TLSCertFile   string
+	TLSKeyFile    string
+	TLSMinVersion string
+	// TLSClientCAFile - bundle verifying client certificates, whose subjects become the request principal
+	TLSClientCAFile string
+	// TLSClientAuth - require or optional client certificates, when TLSClientCAFile is set
+	TLSClientAuth string
+	// DebugVars - serves the expvar metrics at /debug/vars, which include the command line and its flags
+	DebugVars bool
+	// TrustedProxies - CIDRs of the proxies whose X-Forwarded-For names the client IP. Without any, the client
+	// IP is the address of the connection
+	TrustedProxies []string
 }
 
 // Log config
@@ -33,47 +57,230 @@ type Doc struct {
 	Description string
 	Enabled     bool
 	Version     string
+	// ValidateRequests - rejects requests that do not match the OpenAPI spec
+	ValidateRequests bool
+	// ValidateResponses - answers 500 instead of responses that do not match the OpenAPI spec
+	ValidateResponses bool
+}
+
+// API - lifecycle of the versions of the REST API. Zero times are not announced
+type API struct {
+	// V1Deprecation - when v1 was deprecated, announced by the Deprecation header of its responses
+	V1Deprecation time.Time
+	// V1Sunset - when v1 stops being served, announced by the Sunset header of its responses
+	V1Sunset time.Time
 }
 
 // Database - Postgres configuration
 type Database struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
-	SSLMode  string
+	URL             string
+	Host            string
+	Port            string
+	User            string
+	Password        string
+	DBName          string
+	SSLMode         string
+	SSLRootCert     string
+	SSLCert         string
+	SSLKey          string
+	Migrate         bool
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	ConnectTimeout  time.Duration
+	// Replicas - DSNs of read replicas, in key/value or URL form
+	Replicas             []string
+	ReplicaCheckInterval time.Duration
 }
 
-var (
-	env  *Env
-	once sync.Once
-)
+// Cache - device repository cache
+type Cache struct {
+	Enabled bool
+	Size    int
+	TTL     time.Duration
+	// StatsTTL - time device stats stay cached, zero to count on every request
+	StatsTTL time.Duration
+}
 
-// GetEnv returns env values
+// Brands - brand catalogue the brands of devices resolve to
+type Brands struct {
+	// Strict - refuses devices of brands outside the catalogue
+	Strict bool
+}
+
+// Events - device change event stream
+type Events struct {
+	ReplaySize int
+	Heartbeat  time.Duration
+}
+
+// Webhooks - outbound delivery of device events
+type Webhooks struct {
+	MaxAttempts  int
+	BatchSize    int
+	PollInterval time.Duration
+	Timeout      time.Duration
+	RetryInitial time.Duration
+	RetryMax     time.Duration
+}
+
+// Outbox - relay of the device events stored with every write
+type Outbox struct {
+	// Publishers - any of bus, webhook, stdout and file
+	Publishers   []string
+	File         string
+	BatchSize    int
+	PollInterval time.Duration
+	Retention    time.Duration
+}
+
+// GraphQL - limits applied to every operation before it runs
+type GraphQL struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+// RateLimit - token buckets per client and route
+type RateLimit struct {
+	Enabled bool
+	// Store - memory, or postgres to share the buckets between instances
+	Store string
+	// Keys - client identities tried in order, subject and ip
+	Keys []string
+	// Default - limit of the routes without their own, e.g. 100/1s. Empty leaves them unlimited
+	Default string
+	// Routes - limits of single routes, e.g. POST /v1/devices=10/1m
+	Routes []string
+}
+
+// GetEnv returns the current configuration. Without a prior Load it is read from defaults and env vars only
 func GetEnv() *Env {
+	if env := current.Load(); env != nil {
+		return env
+	}
 
 	once.Do(func() {
+		loadDotEnv()
+		v, _ := read("", nil)
+		current.CompareAndSwap(nil, build(v))
+	})
+	return current.Load()
+}
 
-		viper.AutomaticEnv()
-		err := godotenv.Load("./config/.env")
-		if err != nil {
-			log.Warn(err)
-		}
+// Defaults - the configuration of the defaults alone, ignoring the config file, env vars and ./config/.env
+func Defaults() *Env {
+	v := viper.New()
+	for _, s := range settings {
+		v.SetDefault(s.key, s.value)
+	}
+	return build(v)
+}
 
-		env = new(Env)
-		env.Server.Port = viper.GetString("PORT")
+// build - maps the merged sources onto Env
+func build(v *viper.Viper) *Env {
+	env := new(Env)
+	env.Server.Port = v.GetString("server.port")
+	env.Server.GRPCPort = v.GetString("server.grpc_port")
+	env.Server.ShutdownDelay = v.GetDuration("server.shutdown_delay")
+	env.Server.ReadinessTimeout = v.GetDuration("server.readiness_timeout")
+	env.Server.TLSCertFile = v.GetString("server.tls_cert_file")
+	env.Server.TLSKeyFile = v.GetString("server.tls_key_file")
+	env.Server.TLSMinVersion = v.GetString("server.tls_min_version")
+	env.Server.TLSClientCAFile = v.GetString("server.tls_client_ca_file")
+	env.Server.TLSClientAuth = v.GetString("server.tls_client_auth")
+	env.Server.DebugVars = v.GetBool("server.debug_vars")
+	env.Server.TrustedProxies = list(v, "server.trusted_proxies")
 
-		env.Log.Enabled = viper.GetBool("LOG_ENABLED")
-		env.Log.Level = viper.GetString("LOG_LEVEL")
+	env.Log.Enabled = v.GetBool("log.enabled")
+	env.Log.Level = v.GetString("log.level")
 
-		env.Database.Host = viper.GetString("DB_HOST")
-		env.Database.Port = viper.GetString("DB_PORT")
-		env.Database.User = viper.GetString("DB_USER")
-		env.Database.Password = viper.GetString("DB_PASSWORD")
-		env.Database.DBName = viper.GetString("DB_NAME")
-		env.Database.SSLMode = viper.GetString("DB_SSLMODE")
-	})
+	env.Doc.Enabled = v.GetBool("doc.enabled")
+	env.Doc.ValidateRequests = v.GetBool("doc.validate_requests")
+	env.Doc.ValidateResponses = v.GetBool("doc.validate_responses")
+
+	env.API.V1Deprecation = v.GetTime("api.v1_deprecation")
+	env.API.V1Sunset = v.GetTime("api.v1_sunset")
+
+	env.Database.URL = v.GetString("database.url")
+	env.Database.Host = v.GetString("database.host")
+	env.Database.Port = v.GetString("database.port")
+	env.Database.User = v.GetString("database.user")
+	env.Database.Password = v.GetString("database.password")
+	env.Database.DBName = v.GetString("database.name")
+	env.Database.SSLMode = v.GetString("database.sslmode")
+	env.Database.SSLRootCert = v.GetString("database.sslrootcert")
+	env.Database.SSLCert = v.GetString("database.sslcert")
+	env.Database.SSLKey = v.GetString("database.sslkey")
+	env.Database.Migrate = v.GetBool("database.migrate")
+	env.Database.MaxOpenConns = v.GetInt("database.max_open_conns")
+	env.Database.MaxIdleConns = v.GetInt("database.max_idle_conns")
+	env.Database.ConnMaxLifetime = v.GetDuration("database.conn_max_lifetime")
+	env.Database.ConnMaxIdleTime = v.GetDuration("database.conn_max_idle_time")
+	env.Database.ConnectTimeout = v.GetDuration("database.connect_timeout")
+	env.Database.Replicas = list(v, "database.replicas")
+	env.Database.ReplicaCheckInterval = v.GetDuration("database.replica_check_interval")
+
+	env.Cache.Enabled = v.GetBool("cache.enabled")
+	env.Cache.Size = v.GetInt("cache.size")
+	env.Cache.TTL = v.GetDuration("cache.ttl")
+	env.Cache.StatsTTL = v.GetDuration("cache.stats_ttl")
+
+	env.Brands.Strict = v.GetBool("brands.strict")
+
+	env.Events.ReplaySize = v.GetInt("events.replay_size")
+	env.Events.Heartbeat = v.GetDuration("events.heartbeat")
+
+	env.Webhooks.MaxAttempts = v.GetInt("webhooks.max_attempts")
+	env.Webhooks.BatchSize = v.GetInt("webhooks.batch_size")
+	env.Webhooks.PollInterval = v.GetDuration("webhooks.poll_interval")
+	env.Webhooks.Timeout = v.GetDuration("webhooks.timeout")
+	env.Webhooks.RetryInitial = v.GetDuration("webhooks.retry_initial")
+	env.Webhooks.RetryMax = v.GetDuration("webhooks.retry_max")
+
+	env.Outbox.Publishers = list(v, "outbox.publishers")
+	env.Outbox.File = v.GetString("outbox.file")
+	env.Outbox.BatchSize = v.GetInt("outbox.batch_size")
+	env.Outbox.PollInterval = v.GetDuration("outbox.poll_interval")
+	env.Outbox.Retention = v.GetDuration("outbox.retention")
+
+	env.GraphQL.MaxDepth = v.GetInt("graphql.max_depth")
+	env.GraphQL.MaxComplexity = v.GetInt("graphql.max_complexity")
+
+	env.RateLimit.Enabled = v.GetBool("rate_limit.enabled")
+	env.RateLimit.Store = v.GetString("rate_limit.store")
+	env.RateLimit.Keys = list(v, "rate_limit.keys")
+	env.RateLimit.Default = v.GetString("rate_limit.default")
+	env.RateLimit.Routes = list(v, "rate_limit.routes")
 
 	return env
 }
+
+// list - a list is a YAML/TOML array in a file, and comma separated in env vars and flags
+func list(v *viper.Viper, key string) []string {
+	switch value := v.Get(key).(type) {
+	case []interface{}:
+		var items []string
+		for _, item := range value {
+			if item := strings.TrimSpace(cast.ToString(item)); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	case []string:
+		return value
+	default:
+		return splitList(cast.ToString(value))
+	}
+}
+
+// splitList - splits a comma separated env value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,277 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/joho/godotenv"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// setting - a configuration key, nested in files as e.g. database.host, and read from its env var DB_HOST
+// or the flag named after it, --db-host
+type setting struct {
+	key   string
+	env   string
+	value interface{}
+	usage string
+}
+
+// settings - every key with its default. Keys without one default to the zero value of their type,
+// so files, env vars and flags can be checked against it
+var settings = []setting{
+	{"server.port", "PORT", "", "HTTP port"},
+	{"server.grpc_port", "GRPC_PORT", "9090", "gRPC port"},
+	{"server.shutdown_delay", "SHUTDOWN_DELAY", time.Duration(0), "time to keep serving after a stop signal"},
+	{"server.readiness_timeout", "READINESS_TIMEOUT", 2 * time.Second, "timeout of the readiness checks"},
+	{"server.tls_cert_file", "TLS_CERT_FILE", "", "serves TLS with this certificate"},
+	{"server.tls_key_file", "TLS_KEY_FILE", "", "key of the TLS certificate"},
+	{"server.tls_min_version", "TLS_MIN_VERSION", "1.2", "minimum TLS version"},
+	{"server.tls_client_ca_file", "TLS_CLIENT_CA_FILE", "", "CA bundle verifying client certificates"},
+	{"server.tls_client_auth", "TLS_CLIENT_AUTH", "require", "require or optional client certificates"},
+	{"server.debug_vars", "DEBUG_VARS_ENABLED", false, "serves runtime and cache metrics at /debug/vars"},
+	{"server.trusted_proxies", "TRUSTED_PROXIES", "", "comma separated CIDRs of proxies whose X-Forwarded-For is trusted"},
+
+	{"log.enabled", "LOG_ENABLED", false, "logs every request"},
+	{"log.level", "LOG_LEVEL", "", "log level"},
+
+	{"doc.enabled", "DOC_ENABLED", true, "serves Swagger UI and GraphiQL"},
+	{"doc.validate_requests", "DOC_VALIDATE_REQUESTS", false, "rejects requests that do not match the OpenAPI spec"},
+	{"doc.validate_responses", "DOC_VALIDATE_RESPONSES", false, "fails responses that do not match the OpenAPI spec"},
+
+	{"api.v1_deprecation", "API_V1_DEPRECATION", time.Time{}, "deprecation date of v1, empty for none"},
+	{"api.v1_sunset", "API_V1_SUNSET", time.Time{}, "sunset date of v1, empty for none"},
+
+	{"database.url", "DB_URL", "", "primary DSN, instead of the other connection settings"},
+	{"database.host", "DB_HOST", "", "primary host"},
+	{"database.port", "DB_PORT", "", "primary port"},
+	{"database.user", "DB_USER", "", "database user"},
+	{"database.password", "DB_PASSWORD", "", "database password"},
+	{"database.name", "DB_NAME", "", "database name"},
+	{"database.sslmode", "DB_SSLMODE", "", "SSL mode"},
+	{"database.sslrootcert", "DB_SSLROOTCERT", "", "SSL root certificate"},
+	{"database.sslcert", "DB_SSLCERT", "", "SSL client certificate"},
+	{"database.sslkey", "DB_SSLKEY", "", "SSL client key"},
+	{"database.migrate", "DB_MIGRATE", true, "runs the migrations on start, which fails if they do"},
+	{"database.max_open_conns", "DB_MAX_OPEN_CONNS", 25, "maximum open connections"},
+	{"database.max_idle_conns", "DB_MAX_IDLE_CONNS", 25, "maximum idle connections"},
+	{"database.conn_max_lifetime", "DB_CONN_MAX_LIFETIME", 30 * time.Minute, "maximum connection lifetime"},
+	{"database.conn_max_idle_time", "DB_CONN_MAX_IDLE_TIME", 5 * time.Minute, "maximum connection idle time"},
+	{"database.connect_timeout", "DB_CONNECT_TIMEOUT", time.Minute, "time to wait for the database on start"},
+	{"database.replicas", "DB_REPLICAS", "", "comma separated read replica DSNs"},
+	{"database.replica_check_interval", "DB_REPLICA_CHECK_INTERVAL", 5 * time.Second, "replica health check interval"},
+
+	{"cache.enabled", "CACHE_ENABLED", false, "caches devices in memory"},
+	{"cache.size", "CACHE_SIZE", 10000, "cached devices"},
+	{"cache.ttl", "CACHE_TTL", 30 * time.Second, "time devices stay cached"},
+	{"cache.stats_ttl", "CACHE_STATS_TTL", 5 * time.Second, "time device stats stay cached, 0 to disable"},
+
+	{"brands.strict", "BRANDS_STRICT", false, "refuses devices of brands outside the catalogue"},
+
+	{"events.replay_size", "EVENTS_REPLAY_SIZE", 1000, "events kept for reconnecting streams"},
+	{"events.heartbeat", "EVENTS_HEARTBEAT", 15 * time.Second, "event stream heartbeat interval"},
+
+	{"webhooks.max_attempts", "WEBHOOK_MAX_ATTEMPTS", 10, "delivery attempts"},
+	{"webhooks.batch_size", "WEBHOOK_BATCH_SIZE", 50, "deliveries per poll"},
+	{"webhooks.poll_interval", "WEBHOOK_POLL_INTERVAL", time.Second, "delivery poll interval"},
+	{"webhooks.timeout", "WEBHOOK_TIMEOUT", 10 * time.Second, "delivery timeout"},
+	{"webhooks.retry_initial", "WEBHOOK_RETRY_INITIAL", 5 * time.Second, "first retry delay"},
+	{"webhooks.retry_max", "WEBHOOK_RETRY_MAX", time.Hour, "maximum retry delay"},
+
+	{"outbox.publishers", "OUTBOX_PUBLISHERS", "bus,webhook", "comma separated bus, webhook, stdout and file"},
+	{"outbox.file", "OUTBOX_FILE", "", "file of the file publisher"},
+	{"outbox.batch_size", "OUTBOX_BATCH_SIZE", 100, "events per poll"},
+	{"outbox.poll_interval", "OUTBOX_POLL_INTERVAL", 500 * time.Millisecond, "outbox poll interval"},
+	{"outbox.retention", "OUTBOX_RETENTION", 24 * time.Hour, "time published events are kept"},
+
+	{"graphql.max_depth", "GRAPHQL_MAX_DEPTH", 8, "maximum query depth"},
+	{"graphql.max_complexity", "GRAPHQL_MAX_COMPLEXITY", 1000, "maximum query complexity"},
+
+	{"rate_limit.enabled", "RATE_LIMIT_ENABLED", false, "rate limits the API routes"},
+	{"rate_limit.store", "RATE_LIMIT_STORE", "memory", "memory or postgres"},
+	{"rate_limit.keys", "RATE_LIMIT_KEYS", "subject,ip", "comma separated client identities"},
+	{"rate_limit.default", "RATE_LIMIT_DEFAULT", "", "limit of routes without their own, e.g. 100/1s"},
+	{"rate_limit.routes", "RATE_LIMIT_ROUTES", "", "comma separated route limits, e.g. POST /v1/devices=10/1m"},
+}
+
+var (
+	current atomic.Pointer[Env]
+	once    sync.Once
+
+	// sources - file and flags of the last Load, read again on every Reload
+	mu      sync.Mutex
+	file    string
+	flags   *pflag.FlagSet
+	reloads []func(*Env)
+)
+
+// Load - reads the configuration from defaults, then the file given by --config, then env vars, then flags,
+// each overriding the previous ones. Every invalid value is reported in the returned error
+func Load(args []string) error {
+	fs := flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	path, _ := fs.GetString("config")
+
+	loadDotEnv()
+	env, err := load(path, fs)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	file, flags = path, fs
+	mu.Unlock()
+	current.Store(env)
+	return nil
+}
+
+// Reload - reads the sources of Load again, applying only the keys that are safe to change at runtime:
+// the log level, the rate limits and the feature flags. Changes to other keys are returned as restart,
+// and take effect on the next start. An invalid configuration leaves the current one in place
+func Reload() (restart []string, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	candidate, err := load(file, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	next := *GetEnv()
+	next.Log.Level = candidate.Log.Level
+	next.Doc.Enabled = candidate.Doc.Enabled
+	next.RateLimit.Enabled = candidate.RateLimit.Enabled
+	next.RateLimit.Keys = candidate.RateLimit.Keys
+	next.RateLimit.Default = candidate.RateLimit.Default
+	next.RateLimit.Routes = candidate.RateLimit.Routes
+	restart = changed(reflect.ValueOf(next), reflect.ValueOf(*candidate), "")
+
+	current.Store(&next)
+	for _, fn := range reloads {
+		fn(&next)
+	}
+	return restart, nil
+}
+
+// OnReload - fn is called with the new configuration after every successful Reload
+func OnReload(fn func(*Env)) {
+	mu.Lock()
+	defer mu.Unlock()
+	reloads = append(reloads, fn)
+}
+
+// File - the file given by --config, empty without one
+func File() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return file
+}
+
+// load - reads and validates the configuration
+func load(path string, fs *pflag.FlagSet) (*Env, error) {
+	v, err := read(path, fs)
+	if err != nil {
+		return nil, err
+	}
+	if err = types(v); err != nil {
+		return nil, err
+	}
+
+	env := build(v)
+	if err = Validate(env); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// read - merges the sources into a fresh viper instance. A nil flag set reads defaults and env vars only
+func read(path string, fs *pflag.FlagSet) (*viper.Viper, error) {
+	v := viper.New()
+	for _, s := range settings {
+		v.SetDefault(s.key, s.value)
+		_ = v.BindEnv(s.key, s.env)
+		if fs != nil {
+			if flag := fs.Lookup(flagName(s.env)); flag != nil {
+				_ = v.BindPFlag(s.key, flag)
+			}
+		}
+	}
+
+	if path != "" {
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("reading the config file %s: %w", path, err)
+		}
+		if err := unknownKeys(v); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// flagSet - --config plus one flag per setting. Flags are strings, so their values are checked like env vars
+func flagSet() *pflag.FlagSet {
+	fs := pflag.NewFlagSet(os.Args[0], pflag.ContinueOnError)
+	fs.String("config", "", "YAML, TOML or JSON config file")
+	for _, s := range settings {
+		fs.String(flagName(s.env), "", s.usage+" ("+s.env+")")
+	}
+	return fs
+}
+
+func flagName(env string) string {
+	return strings.ToLower(strings.ReplaceAll(env, "_", "-"))
+}
+
+// loadDotEnv - a missing ./config/.env is fine, its variables are only a convenience for local runs
+func loadDotEnv() {
+	if err := godotenv.Load("./config/.env"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintln(os.Stderr, "Reading ./config/.env failed:", err)
+	}
+}
+
+// unknownKeys - catches typos in the config file, which would otherwise be ignored silently
+func unknownKeys(v *viper.Viper) error {
+	known := make(map[string]bool, len(settings))
+	for _, s := range settings {
+		known[s.key] = true
+	}
+
+	var errs []error
+	for _, key := range v.AllKeys() {
+		if !known[key] {
+			errs = append(errs, fmt.Errorf("%s: unknown key", key))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// changed - dotted names of the fields differing between a and b. Times are compared as values
+func changed(a, b reflect.Value, prefix string) []string {
+	if t, ok := a.Interface().(time.Time); ok {
+		if t.Equal(b.Interface().(time.Time)) {
+			return nil
+		}
+		return []string{strings.TrimPrefix(prefix, ".")}
+	}
+	if a.Kind() != reflect.Struct {
+		if reflect.DeepEqual(a.Interface(), b.Interface()) {
+			return nil
+		}
+		return []string{strings.TrimPrefix(prefix, ".")}
+	}
+
+	var names []string
+	for i := 0; i < a.NumField(); i++ {
+		names = append(names, changed(a.Field(i), b.Field(i), prefix+"."+a.Type().Field(i).Name)...)
+	}
+	return names
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("Later Sources Override Earlier Ones", func(t *testing.T) {
+		path := filepath.Join(dir, "config.yaml")
+		writeFile(t, path, `
+server:
+  port: "8080"
+  grpc_port: "9191"
+database:
+  host: file-host
+  user: devices
+  name: devices
+  replicas: [replica-1, replica-2]
+cache:
+  ttl: 1m
+log:
+  level: debug
+`)
+		t.Setenv("DB_HOST", "env-host")
+		t.Setenv("LOG_LEVEL", "warn")
+
+		require.NoError(t, Load([]string{"--config", path, "--log-level", "error"}))
+		env := GetEnv()
+
+		assert.Equal(t, "8080", env.Server.Port)
+		assert.Equal(t, "9191", env.Server.GRPCPort)
+		assert.Equal(t, "env-host", env.Database.Host)
+		assert.Equal(t, []string{"replica-1", "replica-2"}, env.Database.Replicas)
+		assert.Equal(t, time.Minute, env.Cache.TTL)
+		assert.Equal(t, "error", env.Log.Level)
+		assert.Equal(t, 25, env.Database.MaxOpenConns)
+		assert.Equal(t, []string{"bus", "webhook"}, env.Outbox.Publishers)
+		assert.True(t, env.API.V1Sunset.IsZero(), "announced by deployments only")
+	})
+
+	t.Run("Reads TOML", func(t *testing.T) {
+		path := filepath.Join(dir, "config.toml")
+		writeFile(t, path, `
+[server]
+port = "8081"
+
+[database]
+url = "postgres://devices@localhost/devices"
+
+[rate_limit]
+enabled = true
+routes = ["POST /v1/devices=10/1m"]
+`)
+
+		require.NoError(t, Load([]string{"--config", path}))
+		env := GetEnv()
+
+		assert.Equal(t, "8081", env.Server.Port)
+		assert.True(t, env.RateLimit.Enabled)
+		assert.Equal(t, []string{"POST /v1/devices=10/1m"}, env.RateLimit.Routes)
+	})
+
+	t.Run("Reports Every Value Of The Wrong Type", func(t *testing.T) {
+		t.Setenv("CACHE_SIZE", "many")
+		t.Setenv("OUTBOX_POLL_INTERVAL", "soon")
+		err := Load(nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cache.size (CACHE_SIZE)")
+		assert.Contains(t, err.Error(), "outbox.poll_interval (OUTBOX_POLL_INTERVAL)")
+	})
+
+	t.Run("Reports Every Missing Or Invalid Value", func(t *testing.T) {
+		err := Load([]string{"--outbox-publishers", "bus,kafka", "--rate-limit-default", "fast", "--rate-limit-keys",
+			"api_key,ip", "--log-level", "loud"})
+		require.Error(t, err)
+		for _, key := range []string{"server.port", "database.host", "database.user", "database.name",
+			"outbox.publishers", "rate_limit.default", "rate_limit.keys", "log.level"} {
+			assert.Contains(t, err.Error(), key)
+		}
+	})
+
+	t.Run("Reads Dates Of The API Versions", func(t *testing.T) {
+		t.Setenv("DB_HOST", "localhost")
+		t.Setenv("DB_USER", "devices")
+		t.Setenv("DB_NAME", "devices")
+		t.Setenv("API_V1_SUNSET", "2027-01-01T00:00:00Z")
+
+		require.NoError(t, Load([]string{"--port", "8080", "--api-v1-deprecation", ""}))
+		env := GetEnv()
+		assert.True(t, env.API.V1Deprecation.IsZero())
+		assert.Equal(t, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), env.API.V1Sunset)
+
+		t.Setenv("API_V1_DEPRECATION", "2027-06-01T00:00:00Z")
+		err := Load([]string{"--port", "8080"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "api.v1_sunset (API_V1_SUNSET)")
+	})
+
+	t.Run("Rejects Unknown Keys In The File", func(t *testing.T) {
+		path := filepath.Join(dir, "typo.yaml")
+		writeFile(t, path, "server:\n  prot: \"8080\"\n")
+
+		err := Load([]string{"--config", path})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "server.prot: unknown key")
+	})
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, `
+server:
+  port: "8080"
+database:
+  url: postgres://devices@localhost/devices
+log:
+  level: info
+`)
+	require.NoError(t, Load([]string{"--config", path}))
+
+	var reloaded *Env
+	OnReload(func(env *Env) { reloaded = env })
+
+	writeFile(t, path, `
+server:
+  port: "9000"
+database:
+  url: postgres://devices@localhost/devices
+log:
+  level: debug
+doc:
+  enabled: false
+rate_limit:
+  enabled: true
+  default: 5/1s
+`)
+	restart, err := Reload()
+	require.NoError(t, err)
+
+	env := GetEnv()
+	assert.Same(t, env, reloaded)
+	assert.Equal(t, "debug", env.Log.Level)
+	assert.False(t, env.Doc.Enabled)
+	assert.True(t, env.RateLimit.Enabled)
+	assert.Equal(t, "5/1s", env.RateLimit.Default)
+	assert.Equal(t, "8080", env.Server.Port)
+	assert.Equal(t, []string{"Server.Port"}, restart)
+
+	t.Run("Keeps The Current Config When Invalid", func(t *testing.T) {
+		writeFile(t, path, "log:\n  level: loud\n")
+		_, err := Reload()
+		assert.Error(t, err)
+		assert.Same(t, env, GetEnv())
+	})
+}
+
+func TestDefaults(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("EVENTS_REPLAY_SIZE", "5")
+
+	env := Defaults()
+	assert.Empty(t, env.Log.Level)
+	assert.Equal(t, 1000, env.Events.ReplaySize)
+	assert.True(t, env.Doc.Enabled)
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ivofreitas/device-api/internal/adapter/certs"
+	"github.com/ivofreitas/device-api/internal/adapter/ratelimit"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+	"net"
+	"time"
+)
+
+// types - checks the keys with typed defaults hold values of that type, as viper reads invalid ones as zero
+func types(v *viper.Viper) error {
+	var errs []error
+	for _, s := range settings {
+		var err error
+		switch s.value.(type) {
+		case bool:
+			_, err = cast.ToBoolE(v.Get(s.key))
+		case int:
+			_, err = cast.ToIntE(v.Get(s.key))
+		case time.Duration:
+			_, err = cast.ToDurationE(v.Get(s.key))
+		case time.Time:
+			// Empty leaves the time out
+			if value := v.Get(s.key); value != "" {
+				_, err = cast.ToTimeE(value)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s (%s): %w", s.key, s.env, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate - reports every missing or invalid value at once, so a broken deployment is fixed in one go
+func Validate(env *Env) error {
+	var errs []error
+	check := func(ok bool, key, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(key+": "+format, args...))
+		}
+	}
+	checkErr := func(key string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+
+	check(env.Server.Port != "", "server.port (PORT)", "is required")
+	check(env.Server.GRPCPort != "", "server.grpc_port (GRPC_PORT)", "is required")
+	check(env.Server.ShutdownDelay >= 0, "server.shutdown_delay (SHUTDOWN_DELAY)", "must not be negative")
+	check(env.Server.ReadinessTimeout > 0, "server.readiness_timeout (READINESS_TIMEOUT)", "must be positive")
+	check((env.Server.TLSCertFile == "") == (env.Server.TLSKeyFile == ""), "server.tls_key_file (TLS_KEY_FILE)",
+		"must be set together with server.tls_cert_file (TLS_CERT_FILE)")
+	if env.Server.TLSCertFile != "" {
+		_, err := certs.ParseVersion(env.Server.TLSMinVersion)
+		checkErr("server.tls_min_version (TLS_MIN_VERSION)", err)
+		_, err = certs.ParseClientAuth(env.Server.TLSClientAuth)
+		checkErr("server.tls_client_auth (TLS_CLIENT_AUTH)", err)
+	}
+
+	for _, proxy := range env.Server.TrustedProxies {
+		_, _, err := net.ParseCIDR(proxy)
+		checkErr("server.trusted_proxies (TRUSTED_PROXIES)", err)
+	}
+
+	if !env.API.V1Deprecation.IsZero() && !env.API.V1Sunset.IsZero() {
+		check(env.API.V1Sunset.After(env.API.V1Deprecation), "api.v1_sunset (API_V1_SUNSET)",
+			"must be after api.v1_deprecation (API_V1_DEPRECATION)")
+	}
+
+	if env.Log.Level != "" {
+		_, err := logrus.ParseLevel(env.Log.Level)
+		checkErr("log.level (LOG_LEVEL)", err)
+	}
+
+	if env.Database.URL == "" {
+		check(env.Database.Host != "", "database.host (DB_HOST)", "is required without database.url (DB_URL)")
+		check(env.Database.User != "", "database.user (DB_USER)", "is required without database.url (DB_URL)")
+		check(env.Database.DBName != "", "database.name (DB_NAME)", "is required without database.url (DB_URL)")
+	}
+	check(env.Database.MaxOpenConns > 0, "database.max_open_conns (DB_MAX_OPEN_CONNS)", "must be positive")
+	check(env.Database.MaxIdleConns >= 0, "database.max_idle_conns (DB_MAX_IDLE_CONNS)", "must not be negative")
+	check(env.Database.ConnectTimeout > 0, "database.connect_timeout (DB_CONNECT_TIMEOUT)", "must be positive")
+	check(env.Database.ReplicaCheckInterval > 0, "database.replica_check_interval (DB_REPLICA_CHECK_INTERVAL)",
+		"must be positive")
+
+	if env.Cache.Enabled {
+		check(env.Cache.Size > 0, "cache.size (CACHE_SIZE)", "must be positive")
+		check(env.Cache.TTL > 0, "cache.ttl (CACHE_TTL)", "must be positive")
+		check(env.Cache.StatsTTL >= 0, "cache.stats_ttl (CACHE_STATS_TTL)", "must not be negative")
+	}
+
+	check(env.Events.ReplaySize >= 0, "events.replay_size (EVENTS_REPLAY_SIZE)", "must not be negative")
+	check(env.Events.Heartbeat > 0, "events.heartbeat (EVENTS_HEARTBEAT)", "must be positive")
+
+	check(env.Webhooks.MaxAttempts > 0, "webhooks.max_attempts (WEBHOOK_MAX_ATTEMPTS)", "must be positive")
+	check(env.Webhooks.BatchSize > 0, "webhooks.batch_size (WEBHOOK_BATCH_SIZE)", "must be positive")
+	check(env.Webhooks.PollInterval > 0, "webhooks.poll_interval (WEBHOOK_POLL_INTERVAL)", "must be positive")
+	check(env.Webhooks.Timeout > 0, "webhooks.timeout (WEBHOOK_TIMEOUT)", "must be positive")
+	check(env.Webhooks.RetryInitial > 0, "webhooks.retry_initial (WEBHOOK_RETRY_INITIAL)", "must be positive")
+	check(env.Webhooks.RetryMax >= env.Webhooks.RetryInitial, "webhooks.retry_max (WEBHOOK_RETRY_MAX)",
+		"must not be less than webhooks.retry_initial (WEBHOOK_RETRY_INITIAL)")
+
+	for _, publisher := range env.Outbox.Publishers {
+		switch publisher {
+		case "bus", "webhook", "stdout":
+		case "file":
+			check(env.Outbox.File != "", "outbox.file (OUTBOX_FILE)", "is required by the file publisher")
+		default:
+			check(false, "outbox.publishers (OUTBOX_PUBLISHERS)", "unknown publisher %q", publisher)
+		}
+	}
+	check(env.Outbox.BatchSize > 0, "outbox.batch_size (OUTBOX_BATCH_SIZE)", "must be positive")
+	check(env.Outbox.PollInterval > 0, "outbox.poll_interval (OUTBOX_POLL_INTERVAL)", "must be positive")
+	check(env.Outbox.Retention > 0, "outbox.retention (OUTBOX_RETENTION)", "must be positive")
+
+	check(env.GraphQL.MaxDepth > 0, "graphql.max_depth (GRAPHQL_MAX_DEPTH)", "must be positive")
+	check(env.GraphQL.MaxComplexity > 0, "graphql.max_complexity (GRAPHQL_MAX_COMPLEXITY)", "must be positive")
+
+	check(env.RateLimit.Store == "memory" || env.RateLimit.Store == "postgres", "rate_limit.store (RATE_LIMIT_STORE)",
+		"unknown store %q", env.RateLimit.Store)
+	for _, key := range env.RateLimit.Keys {
+		check(key == "subject" || key == "ip", "rate_limit.keys (RATE_LIMIT_KEYS)",
+			"unknown key %q", key)
+	}
+	if env.RateLimit.Default != "" {
+		_, err := ratelimit.ParseLimit(env.RateLimit.Default)
+		checkErr("rate_limit.default (RATE_LIMIT_DEFAULT)", err)
+	}
+	_, err := ratelimit.ParseRoutes(env.RateLimit.Routes)
+	checkErr("rate_limit.routes (RATE_LIMIT_ROUTES)", err)
+
+	return errors.Join(errs...)
+}
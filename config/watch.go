@@ -0,0 +1,69 @@
+package config
+
+import (
+	"context"
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// debounce - quiet time after the last file event before reloading, as editors write files in several steps
+const debounce = 100 * time.Millisecond
+
+// Watch - reloads on SIGHUP, and on changes of the config file when one was loaded, until ctx is done.
+// The directory is watched rather than the file, since mounted config maps are replaced by swapping symlinks
+func Watch(ctx context.Context, logger *logrus.Entry) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var events chan fsnotify.Event
+	var errs chan error
+	if path := File(); path != "" {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return err
+		}
+		defer watcher.Close()
+		if err = watcher.Add(filepath.Dir(path)); err != nil {
+			return err
+		}
+		events, errs = watcher.Events, watcher.Errors
+	}
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+			reload(logger)
+		case <-events:
+			timer.Reset(debounce)
+		case err := <-errs:
+			logger.WithError(err).Warn("Watching the config file failed")
+		case <-timer.C:
+			reload(logger)
+		}
+	}
+}
+
+func reload(logger *logrus.Entry) {
+	restart, err := Reload()
+	if err != nil {
+		logger.WithError(err).Error("Reloading the config failed, keeping the current one")
+		return
+	}
+	if len(restart) > 0 {
+		logger.WithField("keys", restart).Warn("Config reloaded, changes to these keys take effect on restart")
+		return
+	}
+	logger.Info("Config reloaded")
+}
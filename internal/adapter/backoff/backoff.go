@@ -0,0 +1,37 @@
+package backoff
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff - exponential backoff policy with optional full jitter
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     bool
+}
+
+// Default - 100ms doubling up to 30s, jittered
+var Default = Backoff{Initial: 100 * time.Millisecond, Max: 30 * time.Second, Multiplier: 2, Jitter: true}
+
+// Duration - returns how long to wait before the given attempt, starting at 0
+func (b Backoff) Duration(attempt int) time.Duration {
+	multiplier := b.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	delay := float64(b.Initial) * math.Pow(multiplier, float64(attempt))
+	if b.Max > 0 && delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+
+	if b.Jitter && delay > 0 {
+		delay = rand.Float64() * delay
+	}
+
+	return time.Duration(delay)
+}
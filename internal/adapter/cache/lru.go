@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// LRU - in-process cache bounded by number of entries, evicting the least recently used
+type LRU struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+	metrics *Metrics
+	now     func() time.Time
+}
+
+// NewLRU - metrics is optional
+func NewLRU(size int, metrics *Metrics) *LRU {
+	return &LRU{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+		metrics: metrics,
+		now:     time.Now,
+	}
+}
+
+func (l *LRU) Get(_ context.Context, key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	element, ok := l.entries[key]
+	if !ok {
+		l.metrics.miss()
+		return nil, false
+	}
+
+	e := element.Value.(*entry)
+	if !e.expiresAt.IsZero() && !l.now().Before(e.expiresAt) {
+		l.remove(element)
+		l.metrics.miss()
+		return nil, false
+	}
+
+	l.order.MoveToFront(element)
+	l.metrics.hit()
+	return e.value, true
+}
+
+func (l *LRU) Set(_ context.Context, key string, value []byte, ttl time.Duration) {
+	if l.size <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = l.now().Add(ttl)
+	}
+
+	if element, ok := l.entries[key]; ok {
+		e := element.Value.(*entry)
+		e.value, e.expiresAt = value, expiresAt
+		l.order.MoveToFront(element)
+		return
+	}
+
+	l.entries[key] = l.order.PushFront(&entry{key: key, value: value, expiresAt: expiresAt})
+	for l.order.Len() > l.size {
+		l.remove(l.order.Back())
+		l.metrics.evict()
+	}
+}
+
+func (l *LRU) Delete(_ context.Context, keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		if element, ok := l.entries[key]; ok {
+			l.remove(element)
+		}
+	}
+}
+
+// Len - number of entries currently held, including expired ones not yet collected
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+func (l *LRU) remove(element *list.Element) {
+	l.order.Remove(element)
+	delete(l.entries, element.Value.(*entry).key)
+}
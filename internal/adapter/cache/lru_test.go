@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Evicts Least Recently Used", func(t *testing.T) {
+		lru := NewLRU(2, new(Metrics))
+		lru.Set(ctx, "a", []byte("1"), 0)
+		lru.Set(ctx, "b", []byte("2"), 0)
+		lru.Get(ctx, "a")
+		lru.Set(ctx, "c", []byte("3"), 0)
+
+		_, ok := lru.Get(ctx, "b")
+		assert.False(t, ok)
+		value, ok := lru.Get(ctx, "a")
+		assert.True(t, ok)
+		assert.Equal(t, []byte("1"), value)
+		assert.Equal(t, 2, lru.Len())
+		assert.Equal(t, Stats{Hits: 2, Misses: 1, Evictions: 1}, lru.metrics.Stats())
+	})
+
+	t.Run("Expires Entries After TTL", func(t *testing.T) {
+		now := time.Now()
+		lru := NewLRU(2, nil)
+		lru.now = func() time.Time { return now }
+		lru.Set(ctx, "a", []byte("1"), time.Second)
+
+		_, ok := lru.Get(ctx, "a")
+		assert.True(t, ok)
+
+		now = now.Add(time.Second)
+		_, ok = lru.Get(ctx, "a")
+		assert.False(t, ok)
+		assert.Equal(t, 0, lru.Len())
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		lru := NewLRU(2, nil)
+		lru.Set(ctx, "a", []byte("1"), 0)
+		lru.Set(ctx, "b", []byte("2"), 0)
+		lru.Delete(ctx, "a", "b", "missing")
+		assert.Equal(t, 0, lru.Len())
+	})
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"expvar"
+)
+
+// Metrics - hit, miss and eviction counters published as an expvar map
+type Metrics struct {
+	hits      expvar.Int
+	misses    expvar.Int
+	evictions expvar.Int
+}
+
+type Stats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+}
+
+// NewMetrics - publishes the counters under name in /debug/vars. It must be called once per name
+func NewMetrics(name string) *Metrics {
+	m := new(Metrics)
+	published := expvar.NewMap(name)
+	published.Set("hits", &m.hits)
+	published.Set("misses", &m.misses)
+	published.Set("evictions", &m.evictions)
+	return m
+}
+
+func (m *Metrics) hit() {
+	if m != nil {
+		m.hits.Add(1)
+	}
+}
+
+func (m *Metrics) miss() {
+	if m != nil {
+		m.misses.Add(1)
+	}
+}
+
+func (m *Metrics) evict() {
+	if m != nil {
+		m.evictions.Add(1)
+	}
+}
+
+func (m *Metrics) Stats() Stats {
+	return Stats{Hits: m.hits.Value(), Misses: m.misses.Value(), Evictions: m.evictions.Value()}
+}
@@ -0,0 +1,163 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// debounce - quiet time after the last file event before reloading, as a renewal touches several files
+const debounce = 100 * time.Millisecond
+
+// Reloader - serves the certificate and client CAs last loaded from disk, and reloads them when their
+// files change. A failed reload keeps the previous ones, so a half written renewal does not break TLS
+type Reloader struct {
+	certFile, keyFile, caFile string
+	logger                    *logrus.Entry
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// NewReloader - caFile is optional, without it clients are not asked for certificates
+func NewReloader(certFile, keyFile, caFile string, logger *logrus.Entry) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, caFile: caFile, logger: logger}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading the server certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("reading the client CA bundle: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in the client CA bundle %s", r.caFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.mu.Unlock()
+	return nil
+}
+
+// Config - TLS configuration reading the current certificate and client CAs on every handshake.
+// With client CAs, clientAuth decides whether clients must present a certificate
+func (r *Reloader) Config(minVersion uint16, clientAuth tls.ClientAuthType) *tls.Config {
+	return &tls.Config{
+		MinVersion: minVersion,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+
+			config := &tls.Config{
+				MinVersion:   minVersion,
+				Certificates: []tls.Certificate{*r.cert},
+				NextProtos:   []string{"h2", "http/1.1"},
+			}
+			if r.clientCAs != nil {
+				config.ClientCAs = r.clientCAs
+				config.ClientAuth = clientAuth
+			}
+			return config, nil
+		},
+	}
+}
+
+// Watch - reloads on changes in the directories of the files until ctx is done. Directories are watched
+// rather than files, since mounted secrets are replaced by swapping symlinks
+func (r *Reloader) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	for _, file := range []string{r.certFile, r.keyFile, r.caFile} {
+		if file == "" {
+			continue
+		}
+		if err = watcher.Add(filepath.Dir(file)); err != nil {
+			return err
+		}
+	}
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-watcher.Events:
+			timer.Reset(debounce)
+		case err = <-watcher.Errors:
+			r.logger.WithError(err).Warn("Watching the TLS certificates failed")
+		case <-timer.C:
+			if err = r.Reload(); err != nil {
+				r.logger.WithError(err).Error("Reloading the TLS certificates failed, keeping the previous ones")
+				continue
+			}
+			r.logger.Info("TLS certificates reloaded")
+		}
+	}
+}
+
+// Subject - subject of the leaf of the first verified chain. Certificates presented without a client CA
+// bundle configured are not verified, and do not count
+func Subject(state *tls.ConnectionState) (string, bool) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	return state.VerifiedChains[0][0].Subject.String(), true
+}
+
+// ParseVersion - reads minimum versions such as 1.2
+func ParseVersion(version string) (uint16, error) {
+	switch version {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unknown TLS version %q", version)
+	}
+}
+
+// ParseClientAuth - require rejects clients without a valid certificate, optional only verifies the ones presented
+func ParseClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch mode {
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	case "optional":
+		return tls.VerifyClientCertIfGiven, nil
+	default:
+		return 0, fmt.Errorf("unknown client auth mode %q", mode)
+	}
+}
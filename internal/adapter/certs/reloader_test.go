@@ -0,0 +1,161 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type issued struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func issue(t *testing.T, cn string, parent *issued, usage x509.ExtKeyUsage) *issued {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn, Organization: []string{"Devices"}},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+		template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+	} else {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &issued{cert: cert, key: key}
+}
+
+func (i *issued) write(t *testing.T, certFile, keyFile string) {
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: i.cert.Raw}), 0o600))
+	if keyFile != "" {
+		der, err := x509.MarshalECPrivateKey(i.key)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600))
+	}
+}
+
+func (i *issued) tls() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{i.cert.Raw}, PrivateKey: i.key}
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+
+	ca := issue(t, "Device CA", nil, 0)
+	ca.write(t, caFile, "")
+	issue(t, "server-1", ca, x509.ExtKeyUsageServerAuth).write(t, certFile, keyFile)
+	agent := issue(t, "agent-7", ca, x509.ExtKeyUsageClientAuth)
+
+	reloader, err := NewReloader(certFile, keyFile, caFile, logrus.NewEntry(logrus.New()))
+	require.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject, _ := Subject(r.TLS)
+		_, _ = w.Write([]byte(subject))
+	}))
+	server.TLS = reloader.Config(tls.VersionTLS12, tls.RequireAndVerifyClientCert)
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+	}
+	serverName := func(res *http.Response) string { return res.TLS.PeerCertificates[0].Subject.CommonName }
+
+	t.Run("Client Certificate Subject Is The Principal", func(t *testing.T) {
+		res, err := client(agent.tls()).Get(server.URL)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		assert.Equal(t, "server-1", serverName(res))
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "CN=agent-7,O=Devices", string(body))
+	})
+
+	t.Run("Rejects Clients Without A Certificate", func(t *testing.T) {
+		_, err := client().Get(server.URL)
+		assert.Error(t, err)
+	})
+
+	t.Run("Reloads Changed Files", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() { _ = reloader.Watch(ctx) }()
+		time.Sleep(50 * time.Millisecond)
+
+		issue(t, "server-2", ca, x509.ExtKeyUsageServerAuth).write(t, certFile, keyFile)
+
+		assert.Eventually(t, func() bool {
+			c := client(agent.tls())
+			res, err := c.Get(server.URL)
+			if err != nil {
+				return false
+			}
+			defer res.Body.Close()
+			c.CloseIdleConnections()
+			return serverName(res) == "server-2"
+		}, 5*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("Keeps The Previous Certificate When A Reload Fails", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(keyFile, []byte("garbage"), 0o600))
+		assert.Error(t, reloader.Reload())
+
+		res, err := client(agent.tls()).Get(server.URL)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, "server-2", serverName(res))
+	})
+}
+
+func TestParse(t *testing.T) {
+	version, err := ParseVersion("1.3")
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), version)
+	_, err = ParseVersion("3")
+	assert.Error(t, err)
+
+	auth, err := ParseClientAuth("optional")
+	require.NoError(t, err)
+	assert.Equal(t, tls.VerifyClientCertIfGiven, auth)
+	_, err = ParseClientAuth("maybe")
+	assert.Error(t, err)
+}
@@ -0,0 +1,146 @@
+package codec
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/vmihailenco/msgpack/v5"
+	"io"
+	"reflect"
+)
+
+// Codec - reads and writes bodies of one representation. Field names follow the json tags in every representation
+type Codec interface {
+	// ContentType - value of the Content-Type header of encoded responses
+	ContentType() string
+	// MediaTypes - media types matched against Accept and Content-Type, without parameters
+	MediaTypes() []string
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+var (
+	JSON    Codec = jsonCodec{}
+	XML     Codec = xmlCodec{}
+	MsgPack Codec = msgpackCodec{}
+	CBOR    Codec = newCBORCodec()
+)
+
+// registry - codecs in order of preference, the first one answers requests that accept anything
+var registry = []Codec{JSON, XML, MsgPack, CBOR}
+
+// Register - adds a codec for further media types. It is meant to be called before the server starts
+func Register(c Codec) {
+	registry = append(registry, c)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return echo.MIMEApplicationJSONCharsetUTF8
+}
+
+func (jsonCodec) MediaTypes() []string {
+	return []string{echo.MIMEApplicationJSON}
+}
+
+func (jsonCodec) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string {
+	return echo.MIMEApplicationXMLCharsetUTF8
+}
+
+func (xmlCodec) MediaTypes() []string {
+	return []string{echo.MIMEApplicationXML, echo.MIMETextXML}
+}
+
+// Encode - XML needs a single root, so lists are wrapped in an items element
+func (xmlCodec) Encode(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Slice || value.Type().Elem().Kind() == reflect.Uint8 {
+		return enc.Encode(v)
+	}
+
+	items := xml.StartElement{Name: xml.Name{Local: "items"}}
+	if err := enc.EncodeToken(items); err != nil {
+		return err
+	}
+	for i := 0; i < value.Len(); i++ {
+		if err := enc.Encode(value.Index(i).Addr().Interface()); err != nil {
+			return err
+		}
+	}
+	if err := enc.EncodeToken(items.End()); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+func (xmlCodec) Decode(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string {
+	return echo.MIMEApplicationMsgpack
+}
+
+func (msgpackCodec) MediaTypes() []string {
+	return []string{echo.MIMEApplicationMsgpack, "application/x-msgpack", "application/vnd.msgpack"}
+}
+
+func (msgpackCodec) Encode(w io.Writer, v interface{}) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
+
+func (msgpackCodec) Decode(r io.Reader, v interface{}) error {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// cborCodec - times are tagged RFC 3339 strings, so they keep their nanoseconds and zone like in JSON
+type cborCodec struct {
+	enc cbor.EncMode
+}
+
+func newCBORCodec() Codec {
+	enc, err := cbor.EncOptions{Time: cbor.TimeRFC3339Nano, TimeTag: cbor.EncTagRequired}.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	return cborCodec{enc: enc}
+}
+
+func (cborCodec) ContentType() string {
+	return "application/cbor"
+}
+
+func (cborCodec) MediaTypes() []string {
+	return []string{"application/cbor"}
+}
+
+func (c cborCodec) Encode(w io.Writer, v interface{}) error {
+	return c.enc.NewEncoder(w).Encode(v)
+}
+
+func (cborCodec) Decode(r io.Reader, v interface{}) error {
+	return cbor.NewDecoder(r).Decode(v)
+}
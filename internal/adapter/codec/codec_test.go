@@ -0,0 +1,89 @@
+package codec
+
+import (
+	"bytes"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		want   Codec
+	}{
+		{"Missing Header", "", JSON},
+		{"Anything", "*/*", JSON},
+		{"Exact Type", "application/cbor", CBOR},
+		{"Alias", "application/x-msgpack", MsgPack},
+		{"Highest Quality Wins", "application/json;q=0.5, application/xml", XML},
+		{"Exact Type Beats Wildcard On Ties", "*/*, application/msgpack", MsgPack},
+		{"Type Wildcard", "text/*", XML},
+		{"Unsupported Types Are Skipped", "text/html, application/cbor;q=0.1", CBOR},
+		{"Refused Type Is Not Picked By Wildcard", "application/json;q=0, */*;q=0.1", XML},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Negotiate(tt.accept)
+			require.True(t, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("Nothing Acceptable", func(t *testing.T) {
+		_, ok := Negotiate("text/html, application/json;q=0")
+		assert.False(t, ok)
+	})
+}
+
+func TestForContentType(t *testing.T) {
+	c, ok := ForContentType("application/xml; charset=UTF-8")
+	require.True(t, ok)
+	assert.Equal(t, XML, c)
+
+	c, ok = ForContentType("")
+	require.True(t, ok)
+	assert.Equal(t, JSON, c)
+
+	c, ok = ForContentType("application/merge-patch+json")
+	require.True(t, ok)
+	assert.Equal(t, JSON, c)
+
+	_, ok = ForContentType("application/x-www-form-urlencoded")
+	assert.False(t, ok)
+}
+
+func TestRoundTrip(t *testing.T) {
+	device := domain.Device{
+		Id:           1,
+		Name:         "Phone",
+		Brand:        "Apple",
+		State:        domain.InUseState,
+		CreationTime: time.Date(2025, 1, 2, 3, 4, 5, 6, time.UTC),
+	}
+
+	for _, c := range []Codec{JSON, XML, MsgPack, CBOR} {
+		t.Run(c.MediaTypes()[0], func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, c.Encode(&buf, device))
+			assert.Contains(t, buf.String(), "in-use")
+
+			var decoded domain.Device
+			require.NoError(t, c.Decode(&buf, &decoded))
+			assert.Equal(t, device.Id, decoded.Id)
+			assert.Equal(t, device.State, decoded.State)
+			assert.True(t, device.CreationTime.Equal(decoded.CreationTime))
+		})
+	}
+}
+
+func TestXMLWrapsLists(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, XML.Encode(&buf, []domain.Device{{Id: 1}, {Id: 2}}))
+	assert.Contains(t, buf.String(), "<items><Device><id>1</id>")
+	assert.Contains(t, buf.String(), "<Device><id>2</id>")
+}
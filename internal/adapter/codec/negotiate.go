@@ -0,0 +1,145 @@
+package codec
+
+import (
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// mediaRange - one entry of an Accept header
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// specificity - exact types win over type/* and */* when q-values tie
+func (r mediaRange) specificity() int {
+	switch {
+	case r.typ == "*":
+		return 0
+	case r.subtype == "*":
+		return 1
+	default:
+		return 2
+	}
+}
+
+// Negotiate - picks the codec for an Accept header, honouring q-values and wildcards.
+// A missing header accepts anything. It returns false when no registered codec is acceptable
+func Negotiate(accept string) (Codec, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return registry[0], true
+	}
+
+	ranges := parseAccept(accept)
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+
+	for _, r := range ranges {
+		if r.q <= 0 {
+			break
+		}
+		for _, c := range registry {
+			if matches(r, c) && !excluded(ranges, c) {
+				return c, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// ForContentType - codec decoding a request body. A missing Content-Type is read as JSON
+func ForContentType(contentType string) (Codec, bool) {
+	if strings.TrimSpace(contentType) == "" {
+		return JSON, true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	for _, c := range registry {
+		for _, t := range c.MediaTypes() {
+			if t == mediaType {
+				return c, true
+			}
+		}
+	}
+
+	// structured syntax suffixes (RFC 6839), e.g. application/merge-patch+json is read as JSON
+	if i := strings.LastIndex(mediaType, "+"); i >= 0 {
+		suffix := mediaType[i+1:]
+		for _, c := range registry {
+			if _, subtype, _ := strings.Cut(c.MediaTypes()[0], "/"); subtype == suffix {
+				return c, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		typ, subtype, ok := strings.Cut(mediaType, "/")
+		if !ok {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{typ: typ, subtype: subtype, q: q})
+	}
+	return ranges
+}
+
+func matches(r mediaRange, c Codec) bool {
+	for _, t := range c.MediaTypes() {
+		typ, subtype, _ := strings.Cut(t, "/")
+		if (r.typ == "*" || r.typ == typ) && (r.subtype == "*" || r.subtype == subtype) {
+			return true
+		}
+	}
+	return false
+}
+
+// excluded - a codec whose exact media type carries q=0 is refused even when a wildcard would accept it
+func excluded(ranges []mediaRange, c Codec) bool {
+	for _, r := range ranges {
+		if r.q <= 0 && r.specificity() == 2 && matches(r, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// MediaTypes - every media type the registered codecs understand, in order of preference
+func MediaTypes() []string {
+	var types []string
+	for _, c := range registry {
+		types = append(types, c.MediaTypes()...)
+	}
+	return types
+}
+
+// Preferred - the first media type of every registered codec, in order of preference
+func Preferred() []string {
+	types := make([]string, 0, len(registry))
+	for _, c := range registry {
+		types = append(types, c.MediaTypes()[0])
+	}
+	return types
+}
@@ -0,0 +1,18 @@
+package context
+
+import (
+	"context"
+)
+
+type principalKey struct{}
+
+// WithPrincipal - records the authenticated subject a request is made by
+func WithPrincipal(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, principalKey{}, subject)
+}
+
+// Principal - authenticated subject of the request, if any
+func Principal(ctx context.Context) (string, bool) {
+	subject, ok := ctx.Value(principalKey{}).(string)
+	return subject, ok && subject != ""
+}
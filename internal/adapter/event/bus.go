@@ -0,0 +1,143 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"sync"
+)
+
+var ErrClosed = errors.New("event bus closed")
+
+// Publisher - destination of device events. Delivery is at least once: when one publisher of the outbox relay
+// fails, the event is published again to all of them, so publishers drop events whose id they already took
+type Publisher interface {
+	Publish(ctx context.Context, event domain.Event) error
+}
+
+// Bus - in-process fan-out of device events, keeping the latest ones for replay
+type Bus struct {
+	mu          sync.Mutex
+	seq         uint64
+	replay      []domain.Event
+	size        int
+	subscribers map[chan domain.Event]struct{}
+	closed      bool
+}
+
+// NewBus - size bounds how many past events can be replayed to resuming subscribers
+func NewBus(size int) *Bus {
+	return &Bus{
+		size:        size,
+		replay:      make([]domain.Event, 0, size),
+		subscribers: make(map[chan domain.Event]struct{}),
+	}
+}
+
+// Publish - events without an id are numbered by the bus, and events whose id is still buffered
+// are dropped as redeliveries. Subscribers that cannot keep up are disconnected rather than
+// blocking the publisher
+func (b *Bus) Publish(_ context.Context, event domain.Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrClosed
+	}
+
+	if event.Id != 0 && b.position(event.Id) >= 0 {
+		return nil
+	}
+
+	if event.Id == 0 {
+		event.Id = b.seq + 1
+	}
+	if event.Id > b.seq {
+		b.seq = event.Id
+	}
+
+	if b.size > 0 {
+		if len(b.replay) == b.size {
+			copy(b.replay, b.replay[1:])
+			b.replay = b.replay[:len(b.replay)-1]
+		}
+		b.replay = append(b.replay, event)
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return nil
+}
+
+// Subscribe - returns the buffered events published after the event lastId, or with a greater id
+// when that event is no longer buffered, followed by a channel of live events. The channel is
+// closed when the bus closes or the subscriber falls behind
+func (b *Bus) Subscribe(lastId uint64, buffer int) ([]domain.Event, <-chan domain.Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []domain.Event
+	if position := b.position(lastId); position >= 0 {
+		replay = append(replay, b.replay[position+1:]...)
+	} else if lastId > 0 {
+		for _, event := range b.replay {
+			if event.Id > lastId {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	ch := make(chan domain.Event, buffer)
+	if b.closed {
+		close(ch)
+		return replay, ch, func() {}
+	}
+	b.subscribers[ch] = struct{}{}
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return replay, ch, cancel
+}
+
+// position - index of the buffered event with the given id, or -1
+func (b *Bus) position(id uint64) int {
+	if id == 0 {
+		return -1
+	}
+	for i := len(b.replay) - 1; i >= 0; i-- {
+		if b.replay[i].Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Close - disconnects every subscriber
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
@@ -0,0 +1,100 @@
+package event
+
+import (
+	"context"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBus(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Fan Out With Sequential Ids", func(t *testing.T) {
+		bus := NewBus(10)
+		_, first, cancel := bus.Subscribe(0, 4)
+		defer cancel()
+		_, second, cancelSecond := bus.Subscribe(0, 4)
+		defer cancelSecond()
+
+		assert.NoError(t, bus.Publish(ctx, domain.Event{Type: domain.DeviceCreated}))
+		assert.NoError(t, bus.Publish(ctx, domain.Event{Type: domain.DeviceDeleted}))
+
+		for _, ch := range []<-chan domain.Event{first, second} {
+			assert.Equal(t, uint64(1), (<-ch).Id)
+			assert.Equal(t, uint64(2), (<-ch).Id)
+		}
+	})
+
+	t.Run("Replays Bounded Buffer After Last Event Id", func(t *testing.T) {
+		bus := NewBus(3)
+		for i := 0; i < 5; i++ {
+			assert.NoError(t, bus.Publish(ctx, domain.Event{Type: domain.DeviceUpdated}))
+		}
+
+		replay, _, cancel := bus.Subscribe(3, 1)
+		defer cancel()
+		assert.Equal(t, []uint64{4, 5}, ids(replay))
+
+		replay, _, cancel = bus.Subscribe(1, 1)
+		defer cancel()
+		assert.Equal(t, []uint64{3, 4, 5}, ids(replay))
+
+		replay, _, cancel = bus.Subscribe(0, 1)
+		defer cancel()
+		assert.Empty(t, replay)
+	})
+
+	t.Run("Keeps Explicit Ids", func(t *testing.T) {
+		bus := NewBus(3)
+		assert.NoError(t, bus.Publish(ctx, domain.Event{Id: 40}))
+		assert.NoError(t, bus.Publish(ctx, domain.Event{}))
+
+		replay, _, cancel := bus.Subscribe(39, 1)
+		defer cancel()
+		assert.Equal(t, []uint64{40, 41}, ids(replay))
+	})
+
+	t.Run("Replays In Publish Order And Drops Redeliveries", func(t *testing.T) {
+		bus := NewBus(5)
+		for _, id := range []uint64{1, 3, 2, 3, 4} {
+			assert.NoError(t, bus.Publish(ctx, domain.Event{Id: id}))
+		}
+
+		replay, _, cancel := bus.Subscribe(3, 1)
+		defer cancel()
+		assert.Equal(t, []uint64{2, 4}, ids(replay))
+	})
+
+	t.Run("Disconnects Slow Subscriber", func(t *testing.T) {
+		bus := NewBus(0)
+		_, events, cancel := bus.Subscribe(0, 1)
+		defer cancel()
+
+		assert.NoError(t, bus.Publish(ctx, domain.Event{}))
+		assert.NoError(t, bus.Publish(ctx, domain.Event{}))
+
+		<-events
+		_, ok := <-events
+		assert.False(t, ok)
+	})
+
+	t.Run("Close Ends Subscriptions", func(t *testing.T) {
+		bus := NewBus(0)
+		_, events, cancel := bus.Subscribe(0, 1)
+		defer cancel()
+
+		bus.Close()
+		_, ok := <-events
+		assert.False(t, ok)
+		assert.ErrorIs(t, bus.Publish(ctx, domain.Event{}), ErrClosed)
+	})
+}
+
+func ids(events []domain.Event) []uint64 {
+	result := make([]uint64, 0, len(events))
+	for _, e := range events {
+		result = append(result, e.Id)
+	}
+	return result
+}
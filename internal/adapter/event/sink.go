@@ -0,0 +1,69 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"io"
+	"os"
+	"sync"
+)
+
+// sinkWindow - ids of the latest events a Sink remembers to drop redeliveries
+const sinkWindow = 1024
+
+// Sink - writes every event as a JSON line, e.g. to stdout or an append-only file. Redeliveries of the
+// latest events are dropped, as the outbox relay publishes an event again when another publisher fails
+type Sink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	seen   map[uint64]struct{}
+	recent []uint64
+}
+
+func NewSink(w io.Writer) *Sink {
+	return &Sink{w: w, seen: make(map[uint64]struct{}, sinkWindow), recent: make([]uint64, 0, sinkWindow)}
+}
+
+// NewFileSink - appends to path, creating the file when needed
+func NewFileSink(path string) (*Sink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return NewSink(f), nil
+}
+
+func (s *Sink) Publish(_ context.Context, event domain.Event) error {
+	line, err := json.Marshal(&event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.seen[event.Id]; ok && event.Id != 0 {
+		return nil
+	}
+	if _, err = s.w.Write(append(line, '\n')); err != nil || event.Id == 0 {
+		return err
+	}
+
+	if len(s.recent) == sinkWindow {
+		delete(s.seen, s.recent[0])
+		copy(s.recent, s.recent[1:])
+		s.recent = s.recent[:sinkWindow-1]
+	}
+	s.recent = append(s.recent, event.Id)
+	s.seen[event.Id] = struct{}{}
+	return nil
+}
+
+// Close - closes the underlying writer when it is closable and not stdout
+func (s *Sink) Close() error {
+	if closer, ok := s.w.(io.Closer); ok && s.w != os.Stdout {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package event
+
+import (
+	"bytes"
+	"context"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewSink(&buf)
+
+	assert.NoError(t, sink.Publish(context.Background(), domain.Event{Id: 1, Type: domain.DeviceCreated,
+		Device: domain.Device{Id: 7, State: domain.InUseState}}))
+	assert.NoError(t, sink.Publish(context.Background(), domain.Event{Id: 2, Type: domain.DeviceDeleted}))
+
+	// Redelivered by the outbox relay after another publisher failed
+	assert.NoError(t, sink.Publish(context.Background(), domain.Event{Id: 1, Type: domain.DeviceCreated}))
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	assert.Len(t, lines, 2)
+	assert.Contains(t, string(lines[0]), `"type":"device.created"`)
+	assert.Contains(t, string(lines[0]), `"state":"in-use"`)
+	assert.NoError(t, sink.Close())
+}
@@ -16,10 +16,11 @@ type HTTP struct {
 }
 
 type Request struct {
-	Host   string      `json:"host"`
-	Route  string      `json:"route"`
-	Header http.Header `json:"header"`
-	Param  string      `json:"param"`
+	Host      string      `json:"host"`
+	Route     string      `json:"route"`
+	Header    http.Header `json:"header"`
+	Param     string      `json:"param"`
+	Principal string      `json:"principal,omitempty"`
 }
 
 type Response struct {
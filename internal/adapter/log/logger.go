@@ -2,8 +2,8 @@ package log
 
 import (
 	"context"
-	"github.com/ivofreitas/device-api/config"
 	"github.com/sirupsen/logrus"
+	"io"
 	"sync"
 	"time"
 )
@@ -19,14 +19,14 @@ var (
 	once sync.Once
 )
 
-func Init() {
+// Init - creates the logger at level, the default info level when it is not a valid one. Later calls are no-ops
+func Init(level string) {
 	once.Do(func() {
-		logConfig := config.GetEnv().Log
 		log = logrus.New()
 		log.SetFormatter(&logrus.JSONFormatter{
 			TimestampFormat: time.RFC3339,
 		})
-		if level, err := logrus.ParseLevel(logConfig.Level); err != nil {
+		if level, err := logrus.ParseLevel(level); err != nil {
 			logrus.SetLevel(logrus.WarnLevel)
 		} else {
 			log.SetLevel(level)
@@ -51,3 +51,26 @@ func NewEntry() *logrus.Entry {
 		"type":  "json",
 	})
 }
+
+// SetLevel - changes the level at runtime, an empty level being the default info level
+func SetLevel(value string) error {
+	level := logrus.InfoLevel
+	if value != "" {
+		var err error
+		if level, err = logrus.ParseLevel(value); err != nil {
+			return err
+		}
+	}
+	log.SetLevel(level)
+	return nil
+}
+
+// Output - where entries are written
+func Output() io.Writer {
+	return log.Out
+}
+
+// SetOutput - where entries are written, stderr by default
+func SetOutput(w io.Writer) {
+	log.SetOutput(w)
+}
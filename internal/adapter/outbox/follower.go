@@ -0,0 +1,160 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/ivofreitas/device-api/internal/adapter/event"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Channel - notified by the relays with the ids of the events they dispatched
+const Channel = "device_outbox"
+
+// pingInterval - how often an idle listener checks its connection, which it would not notice dropping otherwise
+const pingInterval = 90 * time.Second
+
+// Listener - the notifications of a dedicated connection, as received by a *pq.Listener
+type Listener interface {
+	Listen(channel string) error
+	NotificationChannel() <-chan *pq.Notification
+	Ping() error
+	Close() error
+}
+
+// Follower - publishes every dispatched event to the publishers of this instance, whichever instance's relay
+// dispatched it. It backs the in-process bus and cache, which a relay could only feed on the instance that claimed
+// the event
+type Follower struct {
+	db         *sql.DB
+	listener   Listener
+	logger     *logrus.Entry
+	publishers []event.Publisher
+	// last - the latest event followed, after which events are replayed when the listener reconnects
+	last int64
+}
+
+func NewFollower(db *sql.DB, listener Listener, logger *logrus.Entry, publishers ...event.Publisher) *Follower {
+	return &Follower{db: db, listener: listener, logger: logger, publishers: publishers}
+}
+
+// Run - follows until ctx is done. Events dispatched while the listener reconnects are replayed from the table
+func (f *Follower) Run(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		_ = f.listener.Close()
+	}()
+
+	if err := f.listener.Listen(Channel); err != nil {
+		if ctx.Err() == nil {
+			f.logger.WithError(err).Error("Listening for outbox events failed")
+		}
+		return
+	}
+
+	query := `SELECT COALESCE(MAX(id), 0) FROM devices_schema.device_outbox WHERE dispatched_at IS NOT NULL`
+	if err := f.db.QueryRowContext(ctx, query).Scan(&f.last); err != nil {
+		if ctx.Err() == nil {
+			f.logger.WithError(err).Error("Reading the latest outbox event failed")
+		}
+		return
+	}
+
+	notifications := f.listener.NotificationChannel()
+	for {
+		select {
+		case n, ok := <-notifications:
+			if !ok {
+				return
+			}
+			var err error
+			if n == nil {
+				f.logger.Warn("Outbox listener reconnected, replaying the events dispatched meanwhile")
+				err = f.replay(ctx)
+			} else {
+				err = f.follow(ctx, n.Extra)
+			}
+			if err != nil && ctx.Err() == nil {
+				f.logger.WithError(err).Error("Following outbox events failed")
+			}
+		case <-time.After(pingInterval):
+			go func() { _ = f.listener.Ping() }()
+		}
+	}
+}
+
+// follow - publishes the events of a notification in the order they were dispatched
+func (f *Follower) follow(ctx context.Context, payload string) error {
+	ids, err := parseIds(payload)
+	if err != nil {
+		return err
+	}
+
+	query := `
+			SELECT id, payload
+			FROM devices_schema.device_outbox
+			WHERE id = ANY($1::bigint[])
+			ORDER BY array_position($1::bigint[], id)`
+	rows, err := f.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	return f.publish(ctx, rows)
+}
+
+// replay - publishes the events dispatched after the latest one followed, which were notified while the listener
+// was not connected. Events notified since are published twice, and dropped by the publishers as repeats
+func (f *Follower) replay(ctx context.Context) error {
+	query := `
+			SELECT id, payload
+			FROM devices_schema.device_outbox
+			WHERE id > $1 AND dispatched_at IS NOT NULL
+			ORDER BY dispatched_at, id`
+	rows, err := f.db.QueryContext(ctx, query, f.last)
+	if err != nil {
+		return err
+	}
+	return f.publish(ctx, rows)
+}
+
+// publish - hands the events of the rows to every publisher, noting the latest one
+func (f *Follower) publish(ctx context.Context, rows *sql.Rows) error {
+	defer rows.Close()
+
+	for rows.Next() {
+		var rec record
+		var payload []byte
+		if err := rows.Scan(&rec.id, &payload); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(payload, &rec.event); err != nil {
+			return err
+		}
+		rec.event.Id = uint64(rec.id)
+		f.last = max(f.last, rec.id)
+		for _, publisher := range f.publishers {
+			if err := publisher.Publish(ctx, rec.event); err != nil {
+				f.logger.WithError(err).Warnf("Publishing outbox event %d failed", rec.id)
+			}
+		}
+	}
+	return rows.Err()
+}
+
+// parseIds - the comma separated ids of a notification
+func parseIds(payload string) ([]int64, error) {
+	parts := strings.Split(payload, ",")
+	ids := make([]int64, 0, len(parts))
+	for _, part := range parts {
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
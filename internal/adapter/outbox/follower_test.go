@@ -0,0 +1,92 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"github.com/ivofreitas/device-api/config/db/dbtest"
+	"github.com/ivofreitas/device-api/internal/adapter/event"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// fakeListener - hands out the notifications sent on it
+type fakeListener struct {
+	notifications chan *pq.Notification
+}
+
+func (l *fakeListener) Listen(string) error                          { return nil }
+func (l *fakeListener) NotificationChannel() <-chan *pq.Notification { return l.notifications }
+func (l *fakeListener) Ping() error                                  { return nil }
+func (l *fakeListener) Close() error                                 { return nil }
+
+func TestParseIds(t *testing.T) {
+	ids, err := parseIds("3,1,2")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3, 1, 2}, ids)
+
+	_, err = parseIds("3,x")
+	assert.Error(t, err)
+}
+
+// TestFollower - every case opens the database itself, as it is locked to one test at a time
+func TestFollower(t *testing.T) {
+	// follow - runs a follower until the notifications are sent, handing the events to the publishers
+	follow := func(t *testing.T, conn *sql.DB, notify func(chan<- *pq.Notification), publishers ...event.Publisher) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		listener := &fakeListener{notifications: make(chan *pq.Notification)}
+		done := make(chan struct{})
+		go func() {
+			NewFollower(conn, listener, logrus.NewEntry(logrus.New()), publishers...).Run(ctx)
+			close(done)
+		}()
+
+		notify(listener.notifications)
+		close(listener.notifications)
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the follower did not stop")
+		}
+	}
+
+	t.Run("Publishes The Notified Events", func(t *testing.T) {
+		conn := dbtest.Open(t)
+		enqueue(t, conn, 1, 2, 1)
+
+		publisher, other := &recorder{}, &recorder{}
+		follow(t, conn, func(notifications chan<- *pq.Notification) {
+			notifications <- &pq.Notification{Channel: Channel, Extra: "2,1"}
+			notifications <- &pq.Notification{Channel: Channel, Extra: "3"}
+		}, publisher, other)
+
+		require.Len(t, publisher.events, 3)
+		assert.Equal(t, []uint64{2, 1, 3}, publisher.published(), "in the order of the notifications")
+		assert.Equal(t, 2, publisher.events[0].Device.Id)
+		assert.Equal(t, publisher.published(), other.published(), "every publisher is handed every event")
+	})
+
+	t.Run("Replays The Events Dispatched While Reconnecting", func(t *testing.T) {
+		conn := dbtest.Open(t)
+		enqueue(t, conn, 1, 2, 1, 3)
+		_, err := conn.Exec(`UPDATE devices_schema.device_outbox SET dispatched_at = CURRENT_TIMESTAMP WHERE id = 1`)
+		require.NoError(t, err)
+
+		publisher := &recorder{}
+		follow(t, conn, func(notifications chan<- *pq.Notification) {
+			notifications <- &pq.Notification{Channel: Channel, Extra: "2"}
+
+			// Dispatched by another instance while the connection is down, and never notified here
+			_, err := conn.Exec(`UPDATE devices_schema.device_outbox SET dispatched_at = CURRENT_TIMESTAMP WHERE id IN (3, 4)`)
+			require.NoError(t, err)
+			notifications <- nil
+		}, publisher)
+
+		assert.Equal(t, []uint64{2, 3, 4}, publisher.published(), "the events before the latest one followed are not replayed")
+	})
+}
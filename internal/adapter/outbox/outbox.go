@@ -0,0 +1,29 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/ivofreitas/device-api/internal/domain"
+)
+
+// Writer - stores device events in device_outbox, inside the transaction of the device write
+type Writer struct{}
+
+func NewWriter() *Writer {
+	return &Writer{}
+}
+
+func (w *Writer) Enqueue(ctx context.Context, tx *sql.Tx, events ...domain.Event) error {
+	query := `INSERT INTO devices_schema.device_outbox (device_id, event_type, payload) VALUES ($1, $2, $3)`
+	for _, e := range events {
+		payload, err := json.Marshal(&e)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, query, e.Device.Id, e.Type, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/ivofreitas/device-api/internal/adapter/event"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// notifyLimit - ids per notification, keeping its payload well below the 8000 bytes Postgres allows
+const notifyLimit = 500
+
+// Relay - publishes stored device events and marks them dispatched. Several relays can run
+// against the same table: rows are claimed with FOR UPDATE SKIP LOCKED, and a device's events
+// are held back while an older event of the same device is claimed by another relay
+type Relay struct {
+	db         *sql.DB
+	publishers []event.Publisher
+	batchSize  int
+	interval   time.Duration
+	retention  time.Duration
+	logger     *logrus.Entry
+}
+
+func NewRelay(db *sql.DB, batchSize int, interval, retention time.Duration, logger *logrus.Entry,
+	publishers ...event.Publisher) *Relay {
+	return &Relay{
+		db:         db,
+		publishers: publishers,
+		batchSize:  batchSize,
+		interval:   interval,
+		retention:  retention,
+		logger:     logger,
+	}
+}
+
+type record struct {
+	id       int64
+	deviceId int
+	event    domain.Event
+}
+
+// Run - polls until ctx is done. Full batches are followed immediately by the next poll
+func (r *Relay) Run(ctx context.Context) {
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+
+	for {
+		dispatched, err := r.relay(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.logger.WithError(err).Error("Outbox relay failed")
+		}
+
+		if dispatched == r.batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-cleanup.C:
+			if err = r.cleanup(ctx); err != nil && ctx.Err() == nil {
+				r.logger.WithError(err).Error("Outbox cleanup failed")
+			}
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+func (r *Relay) relay(ctx context.Context) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	records, err := r.claim(ctx, tx)
+	if err != nil || len(records) == 0 {
+		return 0, err
+	}
+
+	blocked, err := r.blocked(ctx, tx, records)
+	if err != nil {
+		return 0, err
+	}
+
+	dispatched := make([]int64, 0, len(records))
+	for _, rec := range records {
+		if blocked[rec.deviceId] {
+			continue
+		}
+		if err = r.publish(ctx, rec.event); err != nil {
+			r.logger.WithError(err).Warnf("Publishing outbox event %d failed, holding back device %d", rec.id, rec.deviceId)
+			blocked[rec.deviceId] = true
+			continue
+		}
+		dispatched = append(dispatched, rec.id)
+	}
+
+	query := `UPDATE devices_schema.device_outbox SET dispatched_at = CURRENT_TIMESTAMP WHERE id = ANY($1)`
+	if _, err = tx.ExecContext(ctx, query, pq.Array(dispatched)); err != nil {
+		return 0, err
+	}
+	if err = notify(ctx, tx, dispatched); err != nil {
+		return 0, err
+	}
+
+	return len(dispatched), tx.Commit()
+}
+
+// notify - tells the followers of every instance which events were dispatched. Postgres delivers the
+// notification on commit, in commit order
+func notify(ctx context.Context, tx *sql.Tx, ids []int64) error {
+	for start := 0; start < len(ids); start += notifyLimit {
+		batch := ids[start:min(start+notifyLimit, len(ids))]
+		payload := make([]string, len(batch))
+		for i, id := range batch {
+			payload[i] = strconv.FormatInt(id, 10)
+		}
+		if _, err := tx.ExecContext(ctx, `SELECT pg_notify($1, $2)`, Channel, strings.Join(payload, ",")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Relay) claim(ctx context.Context, tx *sql.Tx) ([]record, error) {
+	query := `
+			SELECT id, device_id, payload
+			FROM devices_schema.device_outbox
+			WHERE dispatched_at IS NULL
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED`
+	rows, err := tx.QueryContext(ctx, query, r.batchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []record
+	for rows.Next() {
+		var rec record
+		var payload []byte
+		if err = rows.Scan(&rec.id, &rec.deviceId, &payload); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(payload, &rec.event); err != nil {
+			return nil, err
+		}
+		rec.event.Id = uint64(rec.id)
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// blocked - devices with an older pending event outside the batch, i.e. claimed by another relay
+func (r *Relay) blocked(ctx context.Context, tx *sql.Tx, records []record) (map[int]bool, error) {
+	oldest := make(map[int]int64, len(records))
+	for _, rec := range records {
+		if _, ok := oldest[rec.deviceId]; !ok {
+			oldest[rec.deviceId] = rec.id
+		}
+	}
+
+	deviceIds := make([]int64, 0, len(oldest))
+	minIds := make([]int64, 0, len(oldest))
+	for deviceId, id := range oldest {
+		deviceIds = append(deviceIds, int64(deviceId))
+		minIds = append(minIds, id)
+	}
+
+	query := `
+			SELECT b.device_id
+			FROM unnest($1::int[], $2::bigint[]) AS b(device_id, min_id)
+			WHERE EXISTS (
+				SELECT 1 FROM devices_schema.device_outbox o
+				WHERE o.device_id = b.device_id AND o.dispatched_at IS NULL AND o.id < b.min_id
+			)`
+	rows, err := tx.QueryContext(ctx, query, pq.Array(deviceIds), pq.Array(minIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocked := make(map[int]bool)
+	for rows.Next() {
+		var deviceId int
+		if err = rows.Scan(&deviceId); err != nil {
+			return nil, err
+		}
+		blocked[deviceId] = true
+	}
+	return blocked, rows.Err()
+}
+
+// publish - stops at the first failing publisher. The event is published to all of them again on a later
+// poll, and those that already took it drop it by its id
+func (r *Relay) publish(ctx context.Context, e domain.Event) error {
+	for _, publisher := range r.publishers {
+		if err := publisher.Publish(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Relay) cleanup(ctx context.Context) error {
+	query := `
+			DELETE FROM devices_schema.device_outbox
+			WHERE dispatched_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 second'`
+	_, err := r.db.ExecContext(ctx, query, r.retention.Seconds())
+	return err
+}
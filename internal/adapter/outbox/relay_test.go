@@ -0,0 +1,184 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/ivofreitas/device-api/config/db/dbtest"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder - a publisher keeping what it was handed, failing for the devices in fail
+type recorder struct {
+	mu     sync.Mutex
+	events []domain.Event
+	fail   map[int]bool
+}
+
+func (r *recorder) Publish(_ context.Context, e domain.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail[e.Device.Id] {
+		return errors.New("unavailable")
+	}
+	r.events = append(r.events, e)
+	return nil
+}
+
+// published - the ids of the events published so far
+func (r *recorder) published() []uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]uint64, len(r.events))
+	for i, e := range r.events {
+		ids[i] = e.Id
+	}
+	return ids
+}
+
+// enqueue - stores an event per device id, in order
+func enqueue(t *testing.T, conn *sql.DB, deviceIds ...int) {
+	t.Helper()
+	ctx := context.Background()
+	tx, err := conn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	for _, deviceId := range deviceIds {
+		e := domain.Event{Type: domain.DeviceUpdated, Device: domain.Device{Id: deviceId}, Time: time.Now().UTC()}
+		require.NoError(t, NewWriter().Enqueue(ctx, tx, e))
+	}
+	require.NoError(t, tx.Commit())
+}
+
+func pending(t *testing.T, conn *sql.DB) int {
+	t.Helper()
+	var total int
+	require.NoError(t, conn.QueryRow(`SELECT count(*) FROM devices_schema.device_outbox WHERE dispatched_at IS NULL`).Scan(&total))
+	return total
+}
+
+// TestRelay - every case opens the database itself, as it is locked to one test at a time
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	t.Run("Publishes The Events Of Each Device In Order", func(t *testing.T) {
+		conn := dbtest.Open(t)
+		enqueue(t, conn, 1, 2, 1, 2, 1)
+		publisher := &recorder{}
+
+		dispatched, err := NewRelay(conn, 10, time.Second, time.Hour, logger, publisher).relay(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 5, dispatched)
+		assert.Equal(t, []uint64{1, 2, 3, 4, 5}, publisher.published())
+		assert.Zero(t, pending(t, conn))
+	})
+
+	t.Run("Publishes Batches In Order", func(t *testing.T) {
+		conn := dbtest.Open(t)
+		enqueue(t, conn, 1, 1, 1)
+		publisher := &recorder{}
+		relay := NewRelay(conn, 2, time.Second, time.Hour, logger, publisher)
+
+		dispatched, err := relay.relay(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, dispatched)
+		dispatched, err = relay.relay(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, dispatched)
+		assert.Equal(t, []uint64{1, 2, 3}, publisher.published())
+	})
+
+	t.Run("Holds Back A Device Whose Older Event Is Claimed Elsewhere", func(t *testing.T) {
+		conn := dbtest.Open(t)
+		enqueue(t, conn, 1, 2, 1)
+		publisher := &recorder{}
+		relay := NewRelay(conn, 10, time.Second, time.Hour, logger, publisher)
+
+		other, err := conn.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer other.Rollback()
+		_, err = other.ExecContext(ctx, `SELECT id FROM devices_schema.device_outbox WHERE id = 1 FOR UPDATE`)
+		require.NoError(t, err)
+
+		dispatched, err := relay.relay(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, dispatched)
+		assert.Equal(t, []uint64{2}, publisher.published(), "event 3 waits for event 1 of the same device")
+
+		require.NoError(t, other.Rollback())
+		dispatched, err = relay.relay(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, dispatched)
+		assert.Equal(t, []uint64{2, 1, 3}, publisher.published())
+	})
+
+	t.Run("Holds Back A Device Whose Publisher Failed", func(t *testing.T) {
+		conn := dbtest.Open(t)
+		enqueue(t, conn, 1, 2, 1)
+		publisher := &recorder{fail: map[int]bool{1: true}}
+		relay := NewRelay(conn, 10, time.Second, time.Hour, logger, publisher)
+
+		dispatched, err := relay.relay(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, dispatched)
+		assert.Equal(t, []uint64{2}, publisher.published())
+		assert.Equal(t, 2, pending(t, conn))
+
+		publisher.fail = nil
+		_, err = relay.relay(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{2, 1, 3}, publisher.published())
+	})
+
+	t.Run("Notifies The Dispatched Events", func(t *testing.T) {
+		conn := dbtest.Open(t)
+		listener := pq.NewListener(os.Getenv("TEST_DATABASE_URL"), time.Second, time.Second, nil)
+		defer listener.Close()
+		require.NoError(t, listener.Listen(Channel))
+
+		enqueue(t, conn, 1, 2)
+		_, err := NewRelay(conn, 10, time.Second, time.Hour, logger).relay(ctx)
+		require.NoError(t, err)
+
+		select {
+		case n := <-listener.NotificationChannel():
+			require.NotNil(t, n)
+			assert.Equal(t, "1,2", n.Extra)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no notification")
+		}
+	})
+
+	t.Run("Deletes Dispatched Events After The Retention", func(t *testing.T) {
+		conn := dbtest.Open(t)
+		enqueue(t, conn, 1, 1, 1)
+		_, err := conn.Exec(`
+			UPDATE devices_schema.device_outbox
+			SET dispatched_at = CASE id WHEN 1 THEN CURRENT_TIMESTAMP - INTERVAL '2 hours' ELSE CURRENT_TIMESTAMP END
+			WHERE id < 3`)
+		require.NoError(t, err)
+
+		require.NoError(t, NewRelay(conn, 10, time.Second, time.Hour, logger).cleanup(ctx))
+
+		var ids []int64
+		rows, err := conn.Query(`SELECT id FROM devices_schema.device_outbox ORDER BY id`)
+		require.NoError(t, err)
+		defer rows.Close()
+		for rows.Next() {
+			var id int64
+			require.NoError(t, rows.Scan(&id))
+			ids = append(ids, id)
+		}
+		assert.Equal(t, []int64{2, 3}, ids, "recent and pending events are kept")
+	})
+}
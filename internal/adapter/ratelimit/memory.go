@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval - how often full buckets are dropped from a Memory store
+const sweepInterval = time.Minute
+
+// Memory - buckets of a single instance
+type Memory struct {
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{tats: make(map[string]time.Time), now: time.Now}
+}
+
+func (m *Memory) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	tat := m.tats[key]
+	if tat.Before(now) {
+		tat = now
+	}
+
+	next := tat.Add(limit.interval())
+	if next.Sub(now) > limit.Period {
+		return result(limit, tat.Sub(now), false), nil
+	}
+
+	m.tats[key] = next
+	return result(limit, next.Sub(now), true), nil
+}
+
+// sweep - a bucket whose TAT has passed is full, which is the same as not having one
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+
+	for key, tat := range m.tats {
+		if tat.Before(now) {
+			delete(m.tats, key)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Postgres - buckets shared by every instance using the same database. Each take is a single upsert,
+// timed by the database clock so the instances need not agree on the time
+type Postgres struct {
+	db *sql.DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+// Take - the conditional upsert returns no row when the bucket is empty
+func (p *Postgres) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	p.sweep(ctx)
+
+	var ahead float64
+	err := p.db.QueryRowContext(ctx, `
+		INSERT INTO devices_schema.rate_limits AS r (key, tat)
+		VALUES ($1, now() + $2::float8 * interval '1 microsecond')
+		ON CONFLICT (key) DO UPDATE SET tat = GREATEST(r.tat, now()) + $2::float8 * interval '1 microsecond'
+		WHERE GREATEST(r.tat, now()) + $2::float8 * interval '1 microsecond' <= now() + $3::float8 * interval '1 microsecond'
+		RETURNING EXTRACT(EPOCH FROM r.tat - now())`,
+		key, limit.interval().Microseconds(), limit.Period.Microseconds()).Scan(&ahead)
+	if err == nil {
+		return result(limit, seconds(ahead), true), nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return Result{}, err
+	}
+
+	err = p.db.QueryRowContext(ctx,
+		`SELECT EXTRACT(EPOCH FROM GREATEST(tat, now()) - now()) FROM devices_schema.rate_limits WHERE key = $1`,
+		key).Scan(&ahead)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Result{}, err
+	}
+	return result(limit, seconds(ahead), false), nil
+}
+
+// sweep - drops full buckets at most once per sweepInterval, from whichever instance gets there first
+func (p *Postgres) sweep(ctx context.Context) {
+	p.mu.Lock()
+	now := time.Now()
+	due := now.Sub(p.lastSweep) >= sweepInterval
+	if due {
+		p.lastSweep = now
+	}
+	p.mu.Unlock()
+
+	if due {
+		_, _ = p.db.ExecContext(ctx, `DELETE FROM devices_schema.rate_limits WHERE tat < now()`)
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit - token bucket holding Requests tokens, refilled at Requests per Period
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// interval - time it takes to refill one token
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Requests)
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// ParseLimit - reads limits such as 10/1m, ten requests a minute with bursts of up to ten
+func ParseLimit(value string) (Limit, error) {
+	requests, period, ok := strings.Cut(strings.TrimSpace(value), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("limit %q is not in the form requests/period", value)
+	}
+
+	n, err := strconv.Atoi(requests)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("limit %q needs a positive number of requests", value)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d < time.Duration(n) {
+		return Limit{}, fmt.Errorf("limit %q needs a period of at least one nanosecond per request", value)
+	}
+	return Limit{Requests: n, Period: d}, nil
+}
+
+// Result - state of a bucket after taking a token from it
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// Reset - time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter - time until a token is available, zero when allowed
+	RetryAfter time.Duration
+}
+
+// Store - pluggable bucket backend. Buckets are created full on first use.
+//
+// A bucket is kept as the theoretical arrival time (TAT) of the generic cell rate algorithm, the time at
+// which it will be full again. Taking a token moves it one interval ahead, and is refused when that would
+// put it more than a period ahead of now, so a single timestamp per key is enough to share buckets
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// result - ahead is how far the TAT is ahead of now, after taking the token when allowed
+func result(limit Limit, ahead time.Duration, allowed bool) Result {
+	if !allowed {
+		return Result{Reset: ahead, RetryAfter: ahead + limit.interval() - limit.Period}
+	}
+	return Result{Allowed: true, Remaining: int((limit.Period - ahead) / limit.interval()), Reset: ahead}
+}
+
+// ParseRoutes - reads per route limits such as "POST /v1/devices=10/1m", keyed by method and route pattern
+func ParseRoutes(entries []string) (map[string]Limit, error) {
+	routes := make(map[string]Limit, len(entries))
+	for _, entry := range entries {
+		route, value, ok := strings.Cut(entry, "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok || !hasPath {
+			return nil, fmt.Errorf("route limit %q is not in the form METHOD /path=requests/period", entry)
+		}
+
+		limit, err := ParseLimit(value)
+		if err != nil {
+			return nil, err
+		}
+		routes[strings.ToUpper(method)+" "+strings.TrimSpace(path)] = limit
+	}
+	return routes, nil
+}
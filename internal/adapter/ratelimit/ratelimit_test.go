@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemory()
+	store.now = func() time.Time { return now }
+	limit := Limit{Requests: 3, Period: 3 * time.Second}
+	ctx := context.Background()
+
+	t.Run("Bursts Up To The Bucket Size", func(t *testing.T) {
+		for remaining := 2; remaining >= 0; remaining-- {
+			res, err := store.Take(ctx, "a", limit)
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, remaining, res.Remaining)
+		}
+
+		res, err := store.Take(ctx, "a", limit)
+		require.NoError(t, err)
+		assert.False(t, res.Allowed)
+		assert.Equal(t, time.Second, res.RetryAfter)
+		assert.Equal(t, 3*time.Second, res.Reset)
+	})
+
+	t.Run("Keys Have Their Own Buckets", func(t *testing.T) {
+		res, err := store.Take(ctx, "b", limit)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+	})
+
+	t.Run("Refills One Token Per Interval", func(t *testing.T) {
+		now = now.Add(time.Second)
+		res, err := store.Take(ctx, "a", limit)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 0, res.Remaining)
+
+		res, err = store.Take(ctx, "a", limit)
+		require.NoError(t, err)
+		assert.False(t, res.Allowed)
+	})
+
+	t.Run("Sweeps Full Buckets", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		_, err := store.Take(ctx, "c", limit)
+		require.NoError(t, err)
+		assert.Len(t, store.tats, 1)
+	})
+}
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ParseLimit("10/1m")
+	require.NoError(t, err)
+	assert.Equal(t, Limit{Requests: 10, Period: time.Minute}, limit)
+
+	for _, value := range []string{"10", "0/1s", "ten/1s", "10/soon", "10/5ns"} {
+		_, err = ParseLimit(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := ParseRoutes([]string{"post /v1/devices=10/1m", "* /v1/devices/:id=5/1s"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Limit{
+		"POST /v1/devices":  {Requests: 10, Period: time.Minute},
+		"* /v1/devices/:id": {Requests: 5, Period: time.Second},
+	}, routes)
+
+	_, err = ParseRoutes([]string{"/v1/devices=10/1m"})
+	assert.Error(t, err)
+}
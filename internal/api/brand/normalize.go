@@ -0,0 +1,173 @@
+package brand
+
+import (
+	"context"
+	"database/sql"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/lib/pq"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Normalize - merges the free-text brands of the devices outside the catalogue into it, in one transaction. Brands
+// matching a name or alias resolve to that brand, and the others are added to the catalogue, one brand for every
+// spelling up to case and spacing. Devices take the canonical name of their brand, recording their device.updated
+// events, which is how running servers learn to drop them from their caches. Run once when the catalogue is
+// introduced, or after adding aliases
+func Normalize(ctx context.Context, db *sql.DB, outbox Outbox) (*domain.BrandNormalization, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	spellings, err := unresolvedBrands(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	known, err := brandKeys(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	normalization := &domain.BrandNormalization{}
+	for _, brand := range plan(spellings, known) {
+		createQuery := `INSERT INTO devices_schema.brands (name, aliases) VALUES ($1, $2) RETURNING ` + brandColumns
+		createdBrand, err := scanBrand(tx.QueryRowContext(ctx, createQuery, brand.Name, pq.Array(brand.Aliases)))
+		if err != nil {
+			return nil, err
+		}
+		if err = insertKeys(ctx, tx, createdBrand); err != nil {
+			return nil, err
+		}
+		known[domain.BrandKey(createdBrand.Name)] = *createdBrand
+		normalization.BrandsCreated++
+	}
+
+	var updated []domain.Event
+	now := time.Now().UTC()
+	for spelling := range spellings {
+		brand, ok := known[domain.BrandKey(spelling)]
+		if !ok {
+			continue
+		}
+		events, err := moveDevices(ctx, tx, spelling, brand, now)
+		if err != nil {
+			return nil, err
+		}
+		updated = append(updated, events...)
+	}
+	if len(updated) > 0 {
+		if err = outbox.Enqueue(ctx, tx, updated...); err != nil {
+			return nil, err
+		}
+	}
+	normalization.DevicesUpdated = len(updated)
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return normalization, nil
+}
+
+// moveDevices - moves the devices outside the catalogue written as the spelling to the brand, with their
+// device.updated events
+func moveDevices(ctx context.Context, tx *sql.Tx, spelling string, brand domain.Brand, now time.Time) ([]domain.Event, error) {
+	query := `
+			UPDATE devices_schema.devices SET brand_id = $1, brand = $2
+			WHERE brand_id IS NULL AND brand = $3
+			RETURNING id, name, brand, state, creation_time, labels, brand_id`
+	rows, err := tx.QueryContext(ctx, query, brand.Id, brand.Name, spelling)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []domain.Event
+	for rows.Next() {
+		var device domain.Device
+		if err = rows.Scan(&device.Id, &device.Name, &device.Brand, &device.State, &device.CreationTime,
+			&device.Labels, &device.BrandId); err != nil {
+			return nil, err
+		}
+		events = append(events, domain.Event{Type: domain.DeviceUpdated, Device: device, Time: now})
+	}
+	return events, rows.Err()
+}
+
+// plan - the brands to add for the spellings, with their number of devices, whose key is not known. A brand is
+// named after its most common spelling once trimmed, the alphabetically first on ties. Blank spellings are left out
+func plan(spellings map[string]int, known map[string]domain.Brand) []domain.Brand {
+	counts := map[string]map[string]int{}
+	for spelling, count := range spellings {
+		key := domain.BrandKey(spelling)
+		if _, ok := known[key]; ok || key == "" {
+			continue
+		}
+		if counts[key] == nil {
+			counts[key] = map[string]int{}
+		}
+		counts[key][strings.TrimSpace(spelling)] += count
+	}
+
+	brands := make([]domain.Brand, 0, len(counts))
+	for _, names := range counts {
+		var name string
+		for candidate, count := range names {
+			if name == "" || count > names[name] || (count == names[name] && candidate < name) {
+				name = candidate
+			}
+		}
+		brands = append(brands, domain.Brand{Name: name, Aliases: []string{}})
+	}
+	sort.Slice(brands, func(i, j int) bool { return brands[i].Name < brands[j].Name })
+	return brands
+}
+
+// unresolvedBrands - the brands of the devices outside the catalogue as written, with their number of devices
+func unresolvedBrands(ctx context.Context, tx *sql.Tx) (map[string]int, error) {
+	query := `
+			SELECT brand, count(*) FROM devices_schema.devices
+			WHERE brand_id IS NULL AND brand IS NOT NULL
+			GROUP BY brand`
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	spellings := map[string]int{}
+	for rows.Next() {
+		var spelling string
+		var count int
+		if err = rows.Scan(&spelling, &count); err != nil {
+			return nil, err
+		}
+		spellings[spelling] = count
+	}
+	return spellings, rows.Err()
+}
+
+// brandKeys - the brands of the catalogue by the keys of their names and aliases
+func brandKeys(ctx context.Context, tx *sql.Tx) (map[string]domain.Brand, error) {
+	query := `
+			SELECT k.key, b.id, b.name, b.aliases, b.creation_time
+			FROM devices_schema.brand_keys k JOIN devices_schema.brands b ON b.id = k.brand_id`
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	known := map[string]domain.Brand{}
+	for rows.Next() {
+		var key string
+		var brand domain.Brand
+		if err = rows.Scan(&key, &brand.Id, &brand.Name, pq.Array(&brand.Aliases), &brand.CreationTime); err != nil {
+			return nil, err
+		}
+		known[key] = brand
+	}
+	return known, rows.Err()
+}
@@ -0,0 +1,29 @@
+package brand
+
+import (
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	known := map[string]domain.Brand{"apple": {Id: 1, Name: "Apple"}, "hp": {Id: 2, Name: "HP"}}
+
+	t.Run("Adds A Brand For Every Spelling Outside The Catalogue", func(t *testing.T) {
+		brands := plan(map[string]int{"samsung": 1, "Samsung ": 3, "SAMSUNG": 2, "Google": 1}, known)
+		assert.Equal(t, []domain.Brand{{Name: "Google", Aliases: []string{}}, {Name: "Samsung", Aliases: []string{}}}, brands)
+	})
+
+	t.Run("Leaves Out Brands Of The Catalogue", func(t *testing.T) {
+		assert.Empty(t, plan(map[string]int{"apple": 4, " hp": 1}, known))
+	})
+
+	t.Run("Names Brands Alphabetically On Ties", func(t *testing.T) {
+		brands := plan(map[string]int{"sony": 2, "Sony": 2}, known)
+		assert.Equal(t, []domain.Brand{{Name: "Sony", Aliases: []string{}}}, brands)
+	})
+
+	t.Run("Leaves Out Blank Spellings", func(t *testing.T) {
+		assert.Empty(t, plan(map[string]int{"": 3, "  ": 1}, known))
+	})
+}
@@ -0,0 +1,215 @@
+package brand
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/lib/pq"
+	"slices"
+	"time"
+)
+
+type Repository interface {
+	Create(ctx context.Context, brand *domain.Brand) (*domain.Brand, error)
+	// Update - renames the devices of the brand along with it, recording their device.updated events and dropping
+	// them from the cache
+	Update(ctx context.Context, brand *domain.Brand) (*domain.Brand, error)
+	GetAll(ctx context.Context) ([]domain.Brand, error)
+	GetById(ctx context.Context, id int) (*domain.Brand, error)
+	Delete(ctx context.Context, id int) error
+	// Resolve - the brand with the name or alias, in any case and spacing, sql.ErrNoRows when there is none
+	Resolve(ctx context.Context, brand string) (*domain.Brand, error)
+}
+
+// Outbox - records the events of a write in the same transaction as the write
+type Outbox interface {
+	Enqueue(ctx context.Context, tx *sql.Tx, events ...domain.Event) error
+}
+
+// Cache - drops the devices renamed along with a brand, given before and after the rename, once the rename commits
+type Cache interface {
+	Invalidate(ctx context.Context, devices ...*domain.Device)
+}
+
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+type repository struct {
+	db     *sql.DB
+	outbox Outbox
+	// cache - nil when devices are not cached
+	cache Cache
+}
+
+func NewRepository(db *sql.DB, outbox Outbox, cache Cache) Repository {
+	return &repository{db: db, outbox: outbox, cache: cache}
+}
+
+const brandColumns = `id, name, aliases, creation_time`
+
+func (r *repository) Create(ctx context.Context, brand *domain.Brand) (*domain.Brand, error) {
+	query := `
+			INSERT INTO devices_schema.brands (name, aliases)
+			VALUES ($1, $2)
+			RETURNING ` + brandColumns
+	var createdBrand *domain.Brand
+	err := r.transaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		createdBrand, err = scanBrand(tx.QueryRowContext(ctx, query, brand.Name, pq.Array(brand.Aliases)))
+		if err != nil {
+			return err
+		}
+		return insertKeys(ctx, tx, createdBrand)
+	})
+	return createdBrand, err
+}
+
+func (r *repository) Update(ctx context.Context, brand *domain.Brand) (*domain.Brand, error) {
+	query := `
+			UPDATE devices_schema.brands SET name = $1, aliases = $2
+			WHERE id = $3
+			RETURNING ` + brandColumns
+	renameQuery := `
+			UPDATE devices_schema.devices d SET brand = $1
+			FROM devices_schema.devices previous
+			WHERE d.id = previous.id AND d.brand_id = $2 AND d.brand <> $1
+			RETURNING d.id, d.name, d.brand, d.state, d.creation_time, d.labels, d.brand_id, previous.brand`
+	var updatedBrand *domain.Brand
+	var versions []*domain.Device
+	err := r.transaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		updatedBrand, err = scanBrand(tx.QueryRowContext(ctx, query, brand.Name, pq.Array(brand.Aliases), brand.Id))
+		if err != nil {
+			return err
+		}
+
+		deleteKeys := `DELETE FROM devices_schema.brand_keys WHERE brand_id = $1`
+		if _, err = tx.ExecContext(ctx, deleteKeys, brand.Id); err != nil {
+			return err
+		}
+		if err = insertKeys(ctx, tx, updatedBrand); err != nil {
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, renameQuery, updatedBrand.Name, updatedBrand.Id)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var renamed []domain.Event
+		now := time.Now().UTC()
+		for rows.Next() {
+			var device domain.Device
+			var previousBrand string
+			if err = rows.Scan(&device.Id, &device.Name, &device.Brand, &device.State, &device.CreationTime,
+				&device.Labels, &device.BrandId, &previousBrand); err != nil {
+				return err
+			}
+			renamed = append(renamed, domain.Event{Type: domain.DeviceUpdated, Device: device, Time: now})
+
+			previous := device
+			previous.Brand = previousBrand
+			versions = append(versions, &previous, &device)
+		}
+		if err = rows.Err(); err != nil || len(renamed) == 0 {
+			return err
+		}
+		return r.outbox.Enqueue(ctx, tx, renamed...)
+	})
+	if err == nil && r.cache != nil && len(versions) > 0 {
+		r.cache.Invalidate(ctx, versions...)
+	}
+	return updatedBrand, err
+}
+
+func (r *repository) GetAll(ctx context.Context) ([]domain.Brand, error) {
+	query := `SELECT ` + brandColumns + ` FROM devices_schema.brands ORDER BY name, id`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	brands := []domain.Brand{}
+	for rows.Next() {
+		brand, err := scanBrand(rows)
+		if err != nil {
+			return nil, err
+		}
+		brands = append(brands, *brand)
+	}
+	return brands, rows.Err()
+}
+
+func (r *repository) GetById(ctx context.Context, id int) (*domain.Brand, error) {
+	query := `SELECT ` + brandColumns + ` FROM devices_schema.brands WHERE id = $1`
+	return scanBrand(r.db.QueryRowContext(ctx, query, id))
+}
+
+func (r *repository) Delete(ctx context.Context, id int) error {
+	query := `DELETE FROM devices_schema.brands WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (r *repository) Resolve(ctx context.Context, brand string) (*domain.Brand, error) {
+	query := `
+			SELECT b.id, b.name, b.aliases, b.creation_time
+			FROM devices_schema.brand_keys k JOIN devices_schema.brands b ON b.id = k.brand_id
+			WHERE k.key = $1`
+	return scanBrand(r.db.QueryRowContext(ctx, query, domain.BrandKey(brand)))
+}
+
+func (r *repository) transaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertKeys - a name or alias already resolving to another brand fails with a unique violation
+func insertKeys(ctx context.Context, tx *sql.Tx, brand *domain.Brand) error {
+	query := `INSERT INTO devices_schema.brand_keys (key, brand_id) SELECT unnest($1::text[]), $2`
+	_, err := tx.ExecContext(ctx, query, pq.Array(keys(brand)), brand.Id)
+	return err
+}
+
+// keys - the distinct keys of the name and aliases of a brand
+func keys(brand *domain.Brand) []string {
+	result := []string{domain.BrandKey(brand.Name)}
+	for _, alias := range brand.Aliases {
+		key := domain.BrandKey(alias)
+		if !slices.Contains(result, key) {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+// violates - whether err is the database refusing a write for the constraint violation of the code
+func violates(err error, code pq.ErrorCode) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == code
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanBrand(row scanner) (*domain.Brand, error) {
+	var brand domain.Brand
+	if err := row.Scan(&brand.Id, &brand.Name, pq.Array(&brand.Aliases), &brand.CreationTime); err != nil {
+		return nil, err
+	}
+	return &brand, nil
+}
@@ -0,0 +1,74 @@
+package brand
+
+import (
+	"context"
+	"database/sql"
+	"github.com/ivofreitas/device-api/config/db/dbtest"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// recorder - an outbox and a cache keeping what they were handed
+type recorder struct {
+	events      []domain.Event
+	invalidated []string
+}
+
+func (r *recorder) Enqueue(_ context.Context, _ *sql.Tx, events ...domain.Event) error {
+	r.events = append(r.events, events...)
+	return nil
+}
+
+func (r *recorder) Invalidate(_ context.Context, devices ...*domain.Device) {
+	for _, device := range devices {
+		r.invalidated = append(r.invalidated, device.Brand)
+	}
+}
+
+// insertDevice - a device written as the brand, in the catalogue under brandId unless nil
+func insertDevice(t *testing.T, conn *sql.DB, brand string, brandId *int) {
+	t.Helper()
+	query := `INSERT INTO devices_schema.devices (name, brand, state, brand_id) VALUES ('Phone', $1, 0, $2)`
+	_, err := conn.Exec(query, brand, brandId)
+	require.NoError(t, err)
+}
+
+func TestRepositoryUpdate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Drops Renamed Devices From The Cache As Before And After", func(t *testing.T) {
+		conn := dbtest.Open(t)
+		recorder := &recorder{}
+		repository := NewRepository(conn, recorder, recorder)
+		created, err := repository.Create(ctx, &domain.Brand{Name: "Apple", Aliases: []string{}})
+		require.NoError(t, err)
+		insertDevice(t, conn, "Apple", &created.Id)
+
+		_, err = repository.Update(ctx, &domain.Brand{Id: created.Id, Name: "Apple Inc.", Aliases: []string{"Apple"}})
+		require.NoError(t, err)
+		require.Len(t, recorder.events, 1)
+		assert.Equal(t, "Apple Inc.", recorder.events[0].Device.Brand)
+		assert.Equal(t, []string{"Apple", "Apple Inc."}, recorder.invalidated)
+	})
+}
+
+func TestNormalize(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Records The Events Of The Devices Moved Into The Catalogue", func(t *testing.T) {
+		conn := dbtest.Open(t)
+		recorder := &recorder{}
+		_, err := NewRepository(conn, recorder, nil).Create(ctx, &domain.Brand{Name: "Apple", Aliases: []string{}})
+		require.NoError(t, err)
+		insertDevice(t, conn, "apple", nil)
+
+		normalization, err := Normalize(ctx, conn, recorder)
+		require.NoError(t, err)
+		assert.Equal(t, 1, normalization.DevicesUpdated)
+		require.Len(t, recorder.events, 1)
+		assert.Equal(t, domain.DeviceUpdated, recorder.events[0].Type)
+		assert.Equal(t, "Apple", recorder.events[0].Device.Brand)
+	})
+}
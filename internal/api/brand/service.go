@@ -0,0 +1,118 @@
+package brand
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"net/http"
+	"strings"
+)
+
+type Service struct {
+	repository Repository
+}
+
+func NewService(repository Repository) *Service {
+	return &Service{repository}
+}
+
+var (
+	errNotFound  = &domain.Error{Type: "not_found", Status: http.StatusNotFound, Detail: "brand not found"}
+	errBlankName = &domain.Error{Type: "validate_error", Status: http.StatusBadRequest, Detail: "brand name is blank"}
+)
+
+// Create - adds a brand with the aliases it is also written as. Names and aliases match in any case and spacing, and
+// must not match another brand
+func (s *Service) Create(ctx context.Context, create *domain.CreateBrand) (*domain.Brand, error) {
+	brand := newBrand(0, create.Name, create.Aliases)
+	if brand.Name == "" {
+		return nil, errBlankName
+	}
+
+	createdBrand, err := s.repository.Create(ctx, brand)
+	if err != nil {
+		return nil, writeError("create_error", err)
+	}
+	return createdBrand, nil
+}
+
+// Update - replaces the name and aliases of a brand. Devices of the brand are renamed along with it
+func (s *Service) Update(ctx context.Context, update *domain.UpdateBrand) (*domain.Brand, error) {
+	brand := newBrand(update.Id, update.Name, update.Aliases)
+	if brand.Name == "" {
+		return nil, errBlankName
+	}
+
+	updatedBrand, err := s.repository.Update(ctx, brand)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errNotFound
+		}
+		return nil, writeError("update_error", err)
+	}
+	return updatedBrand, nil
+}
+
+// GetAll - retrieves every brand with its aliases, ordered by name
+func (s *Service) GetAll(ctx context.Context) ([]domain.Brand, error) {
+	brands, err := s.repository.GetAll(ctx)
+	if err != nil {
+		return nil, &domain.Error{Type: "fetch_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+	return brands, nil
+}
+
+// GetById - retrieves a single brand with its aliases
+func (s *Service) GetById(ctx context.Context, idParam *domain.GetBrand) (*domain.Brand, error) {
+	brand, err := s.repository.GetById(ctx, idParam.Id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errNotFound
+		}
+		return nil, &domain.Error{Type: "get_by_id_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+	return brand, nil
+}
+
+// Delete - removes a brand no device resolves to
+func (s *Service) Delete(ctx context.Context, deleteParam *domain.DeleteBrand) error {
+	if _, err := s.repository.GetById(ctx, deleteParam.Id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errNotFound
+		}
+		return &domain.Error{Type: "delete_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+
+	if err := s.repository.Delete(ctx, deleteParam.Id); err != nil {
+		if violates(err, foreignKeyViolation) {
+			return &domain.Error{Type: "brand_in_use", Status: http.StatusConflict, Detail: "brand of devices cannot be deleted"}
+		}
+		return &domain.Error{Type: "delete_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+	return nil
+}
+
+// newBrand - trims the name and aliases, leaving out blank aliases and those matching the name or an earlier alias
+func newBrand(id int, name string, aliases []string) *domain.Brand {
+	brand := &domain.Brand{Id: id, Name: strings.TrimSpace(name), Aliases: []string{}}
+	seen := map[string]bool{"": true, domain.BrandKey(name): true}
+	for _, alias := range aliases {
+		if key := domain.BrandKey(alias); !seen[key] {
+			seen[key] = true
+			brand.Aliases = append(brand.Aliases, strings.TrimSpace(alias))
+		}
+	}
+	return brand
+}
+
+// writeError - a name or alias taken by another brand is a conflict
+func writeError(errType string, err error) error {
+	if violates(err, uniqueViolation) {
+		return &domain.Error{
+			Type:   "brand_conflict",
+			Status: http.StatusConflict,
+			Detail: "name or alias of another brand"}
+	}
+	return &domain.Error{Type: errType, Status: http.StatusInternalServerError, Detail: err.Error()}
+}
@@ -0,0 +1,81 @@
+package brand
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Trims The Name And Drops Repeated Aliases", func(t *testing.T) {
+		repository := &fakeRepository{}
+		_, err := NewService(repository).Create(ctx, &domain.CreateBrand{
+			Name:    " Hewlett-Packard ",
+			Aliases: []string{"HP", "hewlett-packard", " hp", "  ", "Hewlett  Packard"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, &domain.Brand{Name: "Hewlett-Packard", Aliases: []string{"HP", "Hewlett  Packard"}}, repository.written)
+	})
+
+	t.Run("Refuses Blank Names", func(t *testing.T) {
+		_, err := NewService(&fakeRepository{}).Update(ctx, &domain.UpdateBrand{Id: 1, Name: "  "})
+		assert.Equal(t, errBlankName, err)
+	})
+
+	t.Run("Refuses Names And Aliases Of Another Brand", func(t *testing.T) {
+		repository := &fakeRepository{err: &pq.Error{Code: uniqueViolation}}
+		_, err := NewService(repository).Create(ctx, &domain.CreateBrand{Name: "Apple"})
+		assert.Equal(t, http.StatusConflict, err.(*domain.Error).Status)
+		assert.Equal(t, "brand_conflict", err.(*domain.Error).Type)
+	})
+
+	t.Run("Answers Not Found For Missing Brands", func(t *testing.T) {
+		repository := &fakeRepository{err: sql.ErrNoRows}
+		_, err := NewService(repository).Update(ctx, &domain.UpdateBrand{Id: 9, Name: "Apple"})
+		assert.Equal(t, errNotFound, err)
+		assert.Equal(t, errNotFound, NewService(repository).Delete(ctx, &domain.DeleteBrand{Id: 9}))
+	})
+
+	t.Run("Refuses To Delete Brands Of Devices", func(t *testing.T) {
+		repository := &fakeRepository{deleteErr: &pq.Error{Code: foreignKeyViolation}}
+		err := NewService(repository).Delete(ctx, &domain.DeleteBrand{Id: 1})
+		assert.Equal(t, "brand_in_use", err.(*domain.Error).Type)
+
+		repository.deleteErr = errors.New("connection reset")
+		err = NewService(repository).Delete(ctx, &domain.DeleteBrand{Id: 1})
+		assert.Equal(t, http.StatusInternalServerError, err.(*domain.Error).Status)
+	})
+}
+
+// fakeRepository - keeps the brand written last, and fails with err, or deleteErr on Delete
+type fakeRepository struct {
+	Repository
+	written   *domain.Brand
+	err       error
+	deleteErr error
+}
+
+func (r *fakeRepository) Create(_ context.Context, brand *domain.Brand) (*domain.Brand, error) {
+	r.written = brand
+	return brand, r.err
+}
+
+func (r *fakeRepository) Update(_ context.Context, brand *domain.Brand) (*domain.Brand, error) {
+	r.written = brand
+	return brand, r.err
+}
+
+func (r *fakeRepository) GetById(_ context.Context, id int) (*domain.Brand, error) {
+	return &domain.Brand{Id: id}, r.err
+}
+
+func (r *fakeRepository) Delete(context.Context, int) error {
+	return r.deleteErr
+}
@@ -0,0 +1,371 @@
+package device
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ivofreitas/device-api/internal/adapter/cache"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"time"
+)
+
+// Invalidator - a repository caching devices, told about the devices written around it
+type Invalidator interface {
+	// Invalidate - drops every given version of the devices, and the version cached of each
+	Invalidate(ctx context.Context, devices ...*domain.Device)
+	// Publish - drops the device of an event, which another instance or process may have written
+	Publish(ctx context.Context, e domain.Event) error
+}
+
+type cachedRepository struct {
+	repository Repository
+	cache      cache.Cache
+	ttl        time.Duration
+	// statsTTL - time stats stay cached, zero to count on every request
+	statsTTL time.Duration
+}
+
+// NewCachedRepository - caches devices by id and the brand/state listings of the wrapped repository.
+// Writes invalidate exactly the entries the written device belongs to, before and after the change. Stats
+// depend on every device, so they are not invalidated but kept for the short statsTTL instead
+func NewCachedRepository(repository Repository, cache cache.Cache, ttl, statsTTL time.Duration) Repository {
+	return &cachedRepository{repository: repository, cache: cache, ttl: ttl, statsTTL: statsTTL}
+}
+
+func (r *cachedRepository) Create(ctx context.Context, device *domain.Device) (*domain.Device, error) {
+	createdDevice, err := r.repository.Create(ctx, device)
+	if err != nil {
+		return nil, err
+	}
+
+	r.invalidate(ctx, createdDevice)
+	return createdDevice, nil
+}
+
+func (r *cachedRepository) Update(ctx context.Context, device *domain.Device) error {
+	previous, err := r.previous(ctx, device.Id)
+	if err != nil {
+		return err
+	}
+
+	if err = r.repository.Update(ctx, device); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, append(previous, device)...)
+	return nil
+}
+
+func (r *cachedRepository) Delete(ctx context.Context, id int) error {
+	previous, err := r.previous(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err = r.repository.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, previous...)
+	return nil
+}
+
+// WithTx - reads within the transaction bypass the cache, and the devices it wrote are invalidated once it commits
+func (r *cachedRepository) WithTx(ctx context.Context, fn func(Repository) error) error {
+	var written []*domain.Device
+	err := r.repository.WithTx(ctx, func(repository Repository) error {
+		recorder := &txRecorder{repository: repository}
+		if err := fn(recorder); err != nil {
+			return err
+		}
+		written = recorder.written
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, written...)
+	return nil
+}
+
+// GetAll - not cached, as any write changes it
+func (r *cachedRepository) GetAll(ctx context.Context) ([]domain.Device, error) {
+	return r.repository.GetAll(ctx)
+}
+
+func (r *cachedRepository) GetById(ctx context.Context, id int) (*domain.Device, error) {
+	var cached *cachedDevice
+	if r.get(ctx, idKey(id), &cached) {
+		return cached.device(), nil
+	}
+
+	device, err := r.repository.GetById(ctx, id)
+	if err != nil {
+		return device, err
+	}
+
+	r.set(ctx, idKey(id), cachedDevice{*device, device.Labels})
+	return device, nil
+}
+
+// GetByIdForUpdate - locks the stored row, so it never reads the cache
+func (r *cachedRepository) GetByIdForUpdate(ctx context.Context, id int) (*domain.Device, error) {
+	return r.repository.GetByIdForUpdate(ctx, id)
+}
+
+func (r *cachedRepository) GetByBrand(ctx context.Context, brand string) ([]domain.Device, error) {
+	var cached []cachedDevice
+	if r.get(ctx, brandKey(brand), &cached) {
+		return devicesOf(cached), nil
+	}
+
+	devices, err := r.repository.GetByBrand(ctx, brand)
+	if err != nil {
+		return nil, err
+	}
+
+	r.set(ctx, brandKey(brand), cachedDevices(devices))
+	return devices, nil
+}
+
+func (r *cachedRepository) GetByState(ctx context.Context, state domain.State) ([]domain.Device, error) {
+	var cached []cachedDevice
+	if r.get(ctx, stateKey(state), &cached) {
+		return devicesOf(cached), nil
+	}
+
+	devices, err := r.repository.GetByState(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+
+	r.set(ctx, stateKey(state), cachedDevices(devices))
+	return devices, nil
+}
+
+// List - not cached, as filters and pages are too many to invalidate
+func (r *cachedRepository) List(ctx context.Context, filter *domain.ListDevices) (*domain.DevicePage, error) {
+	return r.repository.List(ctx, filter)
+}
+
+func (r *cachedRepository) Stats(ctx context.Context, count *domain.CountDevices) ([]domain.DeviceCount, error) {
+	if r.statsTTL <= 0 {
+		return r.repository.Stats(ctx, count)
+	}
+
+	key, err := statsKey(count)
+	if err != nil {
+		return nil, err
+	}
+	var cached []domain.DeviceCount
+	if r.get(ctx, key, &cached) {
+		return cached, nil
+	}
+
+	groups, err := r.repository.Stats(ctx, count)
+	if err != nil {
+		return nil, err
+	}
+
+	if b, err := json.Marshal(groups); err == nil {
+		r.cache.Set(ctx, key, b, r.statsTTL)
+	}
+	return groups, nil
+}
+
+// Search - not cached, like List
+func (r *cachedRepository) Search(ctx context.Context, search *domain.SearchDevices) (*domain.DeviceSearch, error) {
+	return r.repository.Search(ctx, search)
+}
+
+func (r *cachedRepository) Invalidate(ctx context.Context, devices ...*domain.Device) {
+	versions := devices
+	for _, device := range devices {
+		var cached *cachedDevice
+		if r.get(ctx, idKey(device.Id), &cached) {
+			versions = append(versions, cached.device())
+		}
+	}
+	r.invalidate(ctx, versions...)
+}
+
+func (r *cachedRepository) Publish(ctx context.Context, e domain.Event) error {
+	r.Invalidate(ctx, &e.Device)
+	return nil
+}
+
+// previous - the stored version of a device, whose listings a write must invalidate
+func (r *cachedRepository) previous(ctx context.Context, id int) ([]*domain.Device, error) {
+	device, err := r.GetById(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []*domain.Device{device}, nil
+}
+
+// invalidate - drops the id entry and the brand/state listings of every given version of a device
+func (r *cachedRepository) invalidate(ctx context.Context, devices ...*domain.Device) {
+	keys := make([]string, 0, 3*len(devices))
+	for _, device := range devices {
+		keys = append(keys, idKey(device.Id), brandKey(device.Brand), stateKey(device.State))
+	}
+	r.cache.Delete(ctx, keys...)
+}
+
+// get - cached values are stored encoded, so callers never share an instance with the cache
+func (r *cachedRepository) get(ctx context.Context, key string, value interface{}) bool {
+	b, ok := r.cache.Get(ctx, key)
+	if !ok {
+		return false
+	}
+	return json.Unmarshal(b, value) == nil
+}
+
+func (r *cachedRepository) set(ctx context.Context, key string, value interface{}) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	r.cache.Set(ctx, key, b, r.ttl)
+}
+
+// cachedDevice - a device as cached, with the labels its JSON leaves out
+type cachedDevice struct {
+	domain.Device
+	Labels domain.Labels `json:"labels"`
+}
+
+func (c *cachedDevice) device() *domain.Device {
+	device := c.Device
+	device.Labels = c.Labels
+	return &device
+}
+
+func cachedDevices(devices []domain.Device) []cachedDevice {
+	cached := make([]cachedDevice, 0, len(devices))
+	for _, device := range devices {
+		cached = append(cached, cachedDevice{device, device.Labels})
+	}
+	return cached
+}
+
+func devicesOf(cached []cachedDevice) []domain.Device {
+	devices := make([]domain.Device, 0, len(cached))
+	for i := range cached {
+		devices = append(devices, *cached[i].device())
+	}
+	return devices
+}
+
+// txRecorder - collects every version of the devices written within a transaction
+type txRecorder struct {
+	repository Repository
+	written    []*domain.Device
+}
+
+func (r *txRecorder) Create(ctx context.Context, device *domain.Device) (*domain.Device, error) {
+	createdDevice, err := r.repository.Create(ctx, device)
+	if err != nil {
+		return nil, err
+	}
+
+	r.written = append(r.written, createdDevice)
+	return createdDevice, nil
+}
+
+func (r *txRecorder) Update(ctx context.Context, device *domain.Device) error {
+	if err := r.previous(ctx, device.Id); err != nil {
+		return err
+	}
+
+	if err := r.repository.Update(ctx, device); err != nil {
+		return err
+	}
+
+	updatedDevice := *device
+	r.written = append(r.written, &updatedDevice)
+	return nil
+}
+
+func (r *txRecorder) Delete(ctx context.Context, id int) error {
+	if err := r.previous(ctx, id); err != nil {
+		return err
+	}
+	return r.repository.Delete(ctx, id)
+}
+
+func (r *txRecorder) GetAll(ctx context.Context) ([]domain.Device, error) {
+	return r.repository.GetAll(ctx)
+}
+
+func (r *txRecorder) GetById(ctx context.Context, id int) (*domain.Device, error) {
+	return r.repository.GetById(ctx, id)
+}
+
+func (r *txRecorder) GetByIdForUpdate(ctx context.Context, id int) (*domain.Device, error) {
+	return r.repository.GetByIdForUpdate(ctx, id)
+}
+
+func (r *txRecorder) GetByBrand(ctx context.Context, brand string) ([]domain.Device, error) {
+	return r.repository.GetByBrand(ctx, brand)
+}
+
+func (r *txRecorder) GetByState(ctx context.Context, state domain.State) ([]domain.Device, error) {
+	return r.repository.GetByState(ctx, state)
+}
+
+func (r *txRecorder) List(ctx context.Context, filter *domain.ListDevices) (*domain.DevicePage, error) {
+	return r.repository.List(ctx, filter)
+}
+
+func (r *txRecorder) Stats(ctx context.Context, count *domain.CountDevices) ([]domain.DeviceCount, error) {
+	return r.repository.Stats(ctx, count)
+}
+
+func (r *txRecorder) Search(ctx context.Context, search *domain.SearchDevices) (*domain.DeviceSearch, error) {
+	return r.repository.Search(ctx, search)
+}
+
+// WithTx - nested units of work join the transaction being recorded
+func (r *txRecorder) WithTx(_ context.Context, fn func(Repository) error) error {
+	return fn(r)
+}
+
+func (r *txRecorder) previous(ctx context.Context, id int) error {
+	device, err := r.repository.GetById(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	r.written = append(r.written, device)
+	return nil
+}
+
+func idKey(id int) string {
+	return fmt.Sprintf("device:id:%d", id)
+}
+
+func brandKey(brand string) string {
+	return fmt.Sprintf("device:brand:%s", brand)
+}
+
+func stateKey(state domain.State) string {
+	return fmt.Sprintf("device:state:%d", state)
+}
+
+func statsKey(count *domain.CountDevices) (string, error) {
+	b, err := json.Marshal(count)
+	if err != nil {
+		return "", err
+	}
+	return "device:stats:" + string(b), nil
+}
@@ -0,0 +1,208 @@
+package device_test
+
+import (
+	"context"
+	"github.com/ivofreitas/device-api/internal/adapter/cache"
+	"github.com/ivofreitas/device-api/internal/api/device"
+	mocks "github.com/ivofreitas/device-api/internal/api/device/mock"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+func TestCachedRepository(t *testing.T) {
+	ctx := context.Background()
+	stored := &domain.Device{Id: 1, Name: "Phone", Brand: "Apple", State: domain.AvailableState,
+		Labels: domain.Labels{"team": "mobile"}}
+
+	t.Run("Serves Reads From Cache", func(t *testing.T) {
+		mockRepo := new(mocks.Repository)
+		mockRepo.On("GetById", ctx, 1).Return(stored, nil).Once()
+		mockRepo.On("GetByBrand", ctx, "Apple").Return([]domain.Device{*stored}, nil).Once()
+		repository := device.NewCachedRepository(mockRepo, cache.NewLRU(10, nil), time.Minute, 0)
+
+		for i := 0; i < 2; i++ {
+			cached, err := repository.GetById(ctx, 1)
+			assert.NoError(t, err)
+			assert.Equal(t, stored, cached)
+
+			devices, err := repository.GetByBrand(ctx, "Apple")
+			assert.NoError(t, err)
+			assert.Equal(t, []domain.Device{*stored}, devices)
+		}
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Cached Values Are Not Shared", func(t *testing.T) {
+		mockRepo := new(mocks.Repository)
+		mockRepo.On("GetById", ctx, 1).Return(&domain.Device{Id: 1, Name: "Phone"}, nil).Once()
+		repository := device.NewCachedRepository(mockRepo, cache.NewLRU(10, nil), time.Minute, 0)
+
+		cached, _ := repository.GetById(ctx, 1)
+		cached.Name = "Changed"
+
+		cached, _ = repository.GetById(ctx, 1)
+		assert.Equal(t, "Phone", cached.Name)
+	})
+
+	t.Run("Update Invalidates Old And New Listings", func(t *testing.T) {
+		updated := &domain.Device{Id: 1, Name: "Phone", Brand: "Samsung", State: domain.InUseState}
+
+		mockRepo := new(mocks.Repository)
+		mockRepo.On("GetById", ctx, 1).Return(stored, nil).Once()
+		mockRepo.On("GetByBrand", ctx, "Apple").Return([]domain.Device{*stored}, nil).Twice()
+		mockRepo.On("GetByBrand", ctx, "Samsung").Return([]domain.Device{}, nil).Once()
+		mockRepo.On("GetByBrand", ctx, "Samsung").Return([]domain.Device{*updated}, nil).Once()
+		mockRepo.On("GetByBrand", ctx, "Google").Return([]domain.Device{}, nil).Once()
+		mockRepo.On("Update", ctx, updated).Return(nil).Once()
+		mockRepo.On("GetById", ctx, 1).Return(updated, nil).Once()
+		repository := device.NewCachedRepository(mockRepo, cache.NewLRU(10, nil), time.Minute, 0)
+
+		for _, brand := range []string{"Apple", "Samsung", "Google"} {
+			_, err := repository.GetByBrand(ctx, brand)
+			assert.NoError(t, err)
+		}
+
+		assert.NoError(t, repository.Update(ctx, updated))
+
+		for _, brand := range []string{"Apple", "Samsung", "Google"} {
+			_, err := repository.GetByBrand(ctx, brand)
+			assert.NoError(t, err)
+		}
+		cached, err := repository.GetById(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, updated, cached)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Create And Delete Invalidate Listings", func(t *testing.T) {
+		mockRepo := new(mocks.Repository)
+		mockRepo.On("GetByState", ctx, domain.AvailableState).Return([]domain.Device{}, nil).Once()
+		mockRepo.On("Create", ctx, stored).Return(stored, nil).Once()
+		mockRepo.On("GetByState", ctx, domain.AvailableState).Return([]domain.Device{*stored}, nil).Once()
+		mockRepo.On("GetById", ctx, 1).Return(stored, nil).Once()
+		mockRepo.On("Delete", ctx, 1).Return(nil).Once()
+		mockRepo.On("GetByState", ctx, domain.AvailableState).Return([]domain.Device{}, nil).Once()
+		repository := device.NewCachedRepository(mockRepo, cache.NewLRU(10, nil), time.Minute, 0)
+
+		_, _ = repository.GetByState(ctx, domain.AvailableState)
+		_, err := repository.Create(ctx, stored)
+		assert.NoError(t, err)
+
+		devices, _ := repository.GetByState(ctx, domain.AvailableState)
+		assert.Len(t, devices, 1)
+
+		assert.NoError(t, repository.Delete(ctx, 1))
+
+		devices, _ = repository.GetByState(ctx, domain.AvailableState)
+		assert.Len(t, devices, 0)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Transaction Invalidates After Commit", func(t *testing.T) {
+		updated := &domain.Device{Id: 1, Name: "Phone", Brand: "Samsung", State: domain.InUseState}
+
+		mockRepo := new(mocks.Repository)
+		mockRepo.On("GetById", ctx, 1).Return(stored, nil).Twice()
+		mockRepo.On("GetByBrand", ctx, "Samsung").Return([]domain.Device{}, nil).Once()
+		mockRepo.On("GetByBrand", ctx, "Samsung").Return([]domain.Device{*updated}, nil).Once()
+		mockRepo.On("Update", ctx, updated).Return(nil).Once()
+		mockRepo.On("GetById", ctx, 1).Return(updated, nil).Once()
+		repository := device.NewCachedRepository(mockRepo, cache.NewLRU(10, nil), time.Minute, 0)
+
+		_, _ = repository.GetById(ctx, 1)
+		_, _ = repository.GetByBrand(ctx, "Samsung")
+
+		var committed bool
+		mockRepo.On("WithTx", ctx, mock.Anything).Return(func(_ context.Context, fn func(device.Repository) error) error {
+			if err := fn(mockRepo); err != nil {
+				return err
+			}
+			committed = true
+			return nil
+		}).Once()
+
+		assert.NoError(t, repository.WithTx(ctx, func(tx device.Repository) error {
+			if err := tx.Update(ctx, updated); err != nil {
+				return err
+			}
+
+			// Within the transaction nothing is invalidated yet
+			cached, err := repository.GetById(ctx, 1)
+			assert.NoError(t, err)
+			assert.Equal(t, stored, cached)
+			return nil
+		}))
+		assert.True(t, committed)
+
+		cached, err := repository.GetById(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, updated, cached)
+
+		devices, _ := repository.GetByBrand(ctx, "Samsung")
+		assert.Len(t, devices, 1)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Events Invalidate Their Device As Cached And As Written", func(t *testing.T) {
+		renamed := domain.Device{Id: 1, Name: "Phone", Brand: "Apple Inc.", State: domain.AvailableState}
+
+		mockRepo := new(mocks.Repository)
+		mockRepo.On("GetById", ctx, 1).Return(stored, nil).Once()
+		mockRepo.On("GetById", ctx, 1).Return(&renamed, nil).Once()
+		mockRepo.On("GetByBrand", ctx, "Apple").Return([]domain.Device{*stored}, nil).Once()
+		mockRepo.On("GetByBrand", ctx, "Apple").Return([]domain.Device{}, nil).Once()
+		mockRepo.On("GetByBrand", ctx, "Apple Inc.").Return([]domain.Device{}, nil).Once()
+		mockRepo.On("GetByBrand", ctx, "Apple Inc.").Return([]domain.Device{renamed}, nil).Once()
+		repository := device.NewCachedRepository(mockRepo, cache.NewLRU(10, nil), time.Minute, 0)
+
+		_, _ = repository.GetById(ctx, 1)
+		_, _ = repository.GetByBrand(ctx, "Apple")
+		_, _ = repository.GetByBrand(ctx, "Apple Inc.")
+
+		// Written around the repository, as by a brand rename
+		invalidator, ok := repository.(device.Invalidator)
+		assert.True(t, ok)
+		assert.NoError(t, invalidator.Publish(ctx, domain.Event{Type: domain.DeviceUpdated, Device: renamed}))
+
+		cached, err := repository.GetById(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, &renamed, cached)
+
+		devices, _ := repository.GetByBrand(ctx, "Apple")
+		assert.Len(t, devices, 0)
+		devices, _ = repository.GetByBrand(ctx, "Apple Inc.")
+		assert.Len(t, devices, 1)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Caches Stats For Their TTL", func(t *testing.T) {
+		count := &domain.CountDevices{GroupBy: []string{domain.ByBrand}}
+		groups := []domain.DeviceCount{{Brand: ptr("Apple"), Count: 1}}
+		mockRepo := new(mocks.Repository)
+		mockRepo.On("Stats", ctx, count).Return(groups, nil).Twice()
+		mockRepo.On("Create", ctx, mock.Anything).Return(stored, nil).Once()
+		repository := device.NewCachedRepository(mockRepo, cache.NewLRU(10, nil), time.Minute, 50*time.Millisecond)
+
+		for i := 0; i < 2; i++ {
+			cached, err := repository.Stats(ctx, count)
+			assert.NoError(t, err)
+			assert.Equal(t, groups, cached)
+		}
+
+		// Writes leave stats to expire
+		_, _ = repository.Create(ctx, stored)
+		_, _ = repository.Stats(ctx, count)
+		time.Sleep(60 * time.Millisecond)
+		_, _ = repository.Stats(ctx, count)
+
+		mockRepo.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,349 @@
+package device
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"github.com/ivofreitas/device-api/internal/adapter/event"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryStore - devices of the in-memory repository. The mutex is held for whole transactions, which makes
+// them serializable without ever conflicting
+type memoryStore struct {
+	mu         sync.Mutex
+	devices    map[int]domain.Device
+	lastId     int
+	publishers []event.Publisher
+}
+
+type memoryRepository struct {
+	store *memoryStore
+	// events - set on the repositories handed out by WithTx, which run with the mutex held and publish
+	// their events on commit
+	events *[]domain.Event
+}
+
+// NewMemoryRepository - a repository keeping devices in memory, behaving like the Postgres one down to the
+// errors of missing devices. Events of committed writes are published right away rather than through the outbox
+func NewMemoryRepository(publishers ...event.Publisher) Repository {
+	return &memoryRepository{store: &memoryStore{devices: map[int]domain.Device{}, publishers: publishers}}
+}
+
+func (r *memoryRepository) Create(ctx context.Context, device *domain.Device) (*domain.Device, error) {
+	var createdDevice domain.Device
+	err := r.write(ctx, func(devices map[int]domain.Device) []domain.Event {
+		r.store.lastId++
+		createdDevice = domain.Device{Id: r.store.lastId, Name: device.Name, Brand: device.Brand, State: device.State,
+			CreationTime: time.Now().UTC().Truncate(time.Microsecond), Labels: labelsOf(device)}
+		devices[createdDevice.Id] = createdDevice
+		return events(createdDevice, domain.DeviceCreated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &createdDevice, nil
+}
+
+func (r *memoryRepository) Update(ctx context.Context, device *domain.Device) error {
+	return r.write(ctx, func(devices map[int]domain.Device) []domain.Event {
+		previous, ok := devices[device.Id]
+		if !ok {
+			return nil
+		}
+
+		updatedDevice := domain.Device{Id: device.Id, Name: device.Name, Brand: device.Brand, State: device.State,
+			CreationTime: device.CreationTime.UTC().Truncate(time.Microsecond), Labels: labelsOf(device)}
+		devices[device.Id] = updatedDevice
+		if updatedDevice.State != previous.State {
+			return events(updatedDevice, domain.DeviceUpdated, domain.DeviceStateChanged)
+		}
+		return events(updatedDevice, domain.DeviceUpdated)
+	})
+}
+
+func (r *memoryRepository) GetAll(ctx context.Context) ([]domain.Device, error) {
+	return r.filter(func(domain.Device) bool { return true }), nil
+}
+
+func (r *memoryRepository) GetById(ctx context.Context, id int) (*domain.Device, error) {
+	var device domain.Device
+	var ok bool
+	r.read(func(devices map[int]domain.Device) {
+		device, ok = devices[id]
+	})
+	if !ok {
+		return &device, sql.ErrNoRows
+	}
+	return &device, nil
+}
+
+// GetByIdForUpdate - the device is locked anyway within WithTx
+func (r *memoryRepository) GetByIdForUpdate(ctx context.Context, id int) (*domain.Device, error) {
+	return r.GetById(ctx, id)
+}
+
+func (r *memoryRepository) GetByBrand(ctx context.Context, brand string) ([]domain.Device, error) {
+	return r.filter(func(device domain.Device) bool { return device.Brand == brand }), nil
+}
+
+func (r *memoryRepository) GetByState(ctx context.Context, state domain.State) ([]domain.Device, error) {
+	return r.filter(func(device domain.Device) bool { return device.State == state }), nil
+}
+
+func (r *memoryRepository) List(ctx context.Context, filter *domain.ListDevices) (*domain.DevicePage, error) {
+	matches := r.matching(filter)
+
+	slices.SortStableFunc(matches, func(a, b domain.Device) int {
+		order := compareDevices(a, b, filter.SortBy)
+		if order == 0 {
+			order = a.Id - b.Id
+		}
+		if filter.Desc {
+			return -order
+		}
+		return order
+	})
+
+	page := &domain.DevicePage{Items: []domain.Device{}, TotalCount: len(matches)}
+	if filter.Offset < len(matches) {
+		matches = matches[filter.Offset:]
+		page.Items = append(page.Items, matches[:min(filter.Limit, len(matches))]...)
+	}
+	return page, nil
+}
+
+func (r *memoryRepository) Stats(ctx context.Context, count *domain.CountDevices) ([]domain.DeviceCount, error) {
+	type key struct {
+		brand  string
+		state  domain.State
+		period time.Time
+	}
+	counts := map[key]int{}
+	for _, device := range r.matching(&count.Filter) {
+		var k key
+		for _, dimension := range count.GroupBy {
+			switch dimension {
+			case domain.ByBrand:
+				k.brand = device.Brand
+			case domain.ByState:
+				k.state = device.State
+			default:
+				k.period = bucket(device.CreationTime, dimension)
+			}
+		}
+		counts[k]++
+	}
+	if len(count.GroupBy) == 0 {
+		return []domain.DeviceCount{{Count: counts[key{}]}}, nil
+	}
+
+	groups := make([]domain.DeviceCount, 0, len(counts))
+	for k, n := range counts {
+		group := domain.DeviceCount{Count: n}
+		for _, dimension := range count.GroupBy {
+			switch dimension {
+			case domain.ByBrand:
+				group.Brand = &k.brand
+			case domain.ByState:
+				group.State = &k.state
+			default:
+				group.Period = &k.period
+			}
+		}
+		groups = append(groups, group)
+	}
+	slices.SortFunc(groups, func(a, b domain.DeviceCount) int {
+		for _, dimension := range count.GroupBy {
+			var order int
+			switch dimension {
+			case domain.ByBrand:
+				order = strings.Compare(*a.Brand, *b.Brand)
+			case domain.ByState:
+				order = int(*a.State - *b.State)
+			default:
+				order = a.Period.Compare(*b.Period)
+			}
+			if order != 0 {
+				return order
+			}
+		}
+		return 0
+	})
+	return groups, nil
+}
+
+// Search - scored like the Postgres repository, by the words of the query found and its similarity. Without a brand
+// catalogue, the brands of the stored devices are suggested
+func (r *memoryRepository) Search(ctx context.Context, search *domain.SearchDevices) (*domain.DeviceSearch, error) {
+	terms := words(search.Q)
+	result := &domain.DeviceSearch{Results: []domain.DeviceMatch{}, Suggestions: []string{}}
+	brands := map[string]float64{}
+	for _, device := range r.filter(func(domain.Device) bool { return true }) {
+		textRank := rank(terms, device.Name, device.Brand)
+		nameSimilarity := wordSimilarity(search.Q, device.Name)
+		brandSimilarity := wordSimilarity(search.Q, device.Brand)
+		if brandSimilarity >= similarityThreshold {
+			brands[device.Brand] = brandSimilarity
+		}
+		if textRank == 0 && nameSimilarity < similarityThreshold && brandSimilarity < similarityThreshold {
+			continue
+		}
+
+		result.Results = append(result.Results, domain.DeviceMatch{
+			Device: device,
+			Score:  textRank + max(nameSimilarity, brandSimilarity),
+			Highlights: domain.Highlights{
+				Name:  highlight(device.Name, terms),
+				Brand: highlight(device.Brand, terms),
+			},
+		})
+	}
+
+	slices.SortStableFunc(result.Results, func(a, b domain.DeviceMatch) int {
+		if order := cmp.Compare(b.Score, a.Score); order != 0 {
+			return order
+		}
+		return a.Device.Id - b.Device.Id
+	})
+	result.Results = result.Results[:min(search.Limit, len(result.Results))]
+
+	for brand := range brands {
+		result.Suggestions = append(result.Suggestions, brand)
+	}
+	slices.SortFunc(result.Suggestions, func(a, b string) int {
+		if order := cmp.Compare(brands[b], brands[a]); order != 0 {
+			return order
+		}
+		return strings.Compare(a, b)
+	})
+	result.Suggestions = result.Suggestions[:min(maxSuggestions, len(result.Suggestions))]
+	return result, nil
+}
+
+func (r *memoryRepository) Delete(ctx context.Context, id int) error {
+	return r.write(ctx, func(devices map[int]domain.Device) []domain.Event {
+		deletedDevice, ok := devices[id]
+		if !ok {
+			return nil
+		}
+		delete(devices, id)
+		return events(deletedDevice, domain.DeviceDeleted)
+	})
+}
+
+// WithTx - fn sees a copy of the devices, which replaces them when fn returns nil
+func (r *memoryRepository) WithTx(ctx context.Context, fn func(Repository) error) error {
+	if r.events != nil {
+		return fn(r)
+	}
+
+	r.store.mu.Lock()
+	saved, lastId := maps.Clone(r.store.devices), r.store.lastId
+	var recorded []domain.Event
+	if err := fn(&memoryRepository{store: r.store, events: &recorded}); err != nil {
+		r.store.devices, r.store.lastId = saved, lastId
+		r.store.mu.Unlock()
+		return err
+	}
+	r.store.mu.Unlock()
+
+	r.publish(ctx, recorded)
+	return nil
+}
+
+// write - runs a mutation and publishes its events, or records them for the end of the transaction
+func (r *memoryRepository) write(ctx context.Context, mutation func(devices map[int]domain.Device) []domain.Event) error {
+	if r.events != nil {
+		*r.events = append(*r.events, mutation(r.store.devices)...)
+		return nil
+	}
+
+	r.store.mu.Lock()
+	recorded := mutation(r.store.devices)
+	r.store.mu.Unlock()
+
+	r.publish(ctx, recorded)
+	return nil
+}
+
+func (r *memoryRepository) read(fn func(devices map[int]domain.Device)) {
+	if r.events == nil {
+		r.store.mu.Lock()
+		defer r.store.mu.Unlock()
+	}
+	fn(r.store.devices)
+}
+
+// filter - matching devices ordered by id, nil without any like the rows of an empty query
+func (r *memoryRepository) filter(match func(domain.Device) bool) []domain.Device {
+	var devices []domain.Device
+	r.read(func(stored map[int]domain.Device) {
+		for _, id := range slices.Sorted(maps.Keys(stored)) {
+			if match(stored[id]) {
+				devices = append(devices, stored[id])
+			}
+		}
+	})
+	return devices
+}
+
+// publish - like the outbox relay, a publisher failing does not fail the write
+func (r *memoryRepository) publish(ctx context.Context, recorded []domain.Event) {
+	for _, e := range recorded {
+		for _, publisher := range r.store.publishers {
+			_ = publisher.Publish(ctx, e)
+		}
+	}
+}
+
+// matching - the devices a filter matches, as the Postgres repository does
+func (r *memoryRepository) matching(filter *domain.ListDevices) []domain.Device {
+	name := strings.ToLower(filter.Name)
+	return r.filter(func(device domain.Device) bool {
+		return (len(filter.Ids) == 0 || slices.Contains(filter.Ids, device.Id)) &&
+			strings.Contains(strings.ToLower(device.Name), name) &&
+			(filter.Brand == "" || device.Brand == filter.Brand) &&
+			(len(filter.States) == 0 || slices.Contains(filter.States, device.State)) &&
+			(filter.CreatedAfter == nil || device.CreationTime.After(*filter.CreatedAfter)) &&
+			(filter.CreatedBefore == nil || device.CreationTime.Before(*filter.CreatedBefore))
+	})
+}
+
+// bucket - the start of the day, ISO week or month of t, like date_trunc in UTC
+func bucket(t time.Time, dimension string) time.Time {
+	year, month, day := t.UTC().Date()
+	switch dimension {
+	case domain.ByWeek:
+		return time.Date(year, month, day-(int(t.UTC().Weekday())+6)%7, 0, 0, 0, 0, time.UTC)
+	case domain.ByMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// labelsOf - a copy of the labels of a device. Stored labels are never changed in place, so readers may share them
+func labelsOf(device *domain.Device) domain.Labels {
+	return maps.Clone(device.Labels)
+}
+
+func compareDevices(a, b domain.Device, sortBy string) int {
+	switch sortBy {
+	case "name":
+		return strings.Compare(a.Name, b.Name)
+	case "brand":
+		return strings.Compare(a.Brand, b.Brand)
+	case "state":
+		return int(a.State) - int(b.State)
+	case "creation_time":
+		return a.CreationTime.Compare(b.CreationTime)
+	default:
+		return a.Id - b.Id
+	}
+}
@@ -0,0 +1,155 @@
+package device_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/ivofreitas/device-api/internal/adapter/event"
+	"github.com/ivofreitas/device-api/internal/api/device"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	ctx := context.Background()
+
+	seed := func(t *testing.T, repository device.Repository) {
+		for _, d := range []domain.Device{
+			{Name: "Galaxy S24", Brand: "Samsung", State: domain.InUseState},
+			{Name: "Pixel 9", Brand: "Google", State: domain.AvailableState},
+			{Name: "Galaxy Tab", Brand: "Samsung", State: domain.AvailableState},
+		} {
+			_, err := repository.Create(ctx, &d)
+			require.NoError(t, err)
+		}
+	}
+
+	t.Run("Lists Like The Postgres Repository", func(t *testing.T) {
+		repository := device.NewMemoryRepository()
+		seed(t, repository)
+
+		page, err := repository.List(ctx, &domain.ListDevices{Name: "GALAXY", SortBy: "name", Desc: true, Limit: 1})
+		require.NoError(t, err)
+		assert.Equal(t, 2, page.TotalCount)
+		require.Len(t, page.Items, 1)
+		assert.Equal(t, "Galaxy Tab", page.Items[0].Name)
+
+		page, err = repository.List(ctx, &domain.ListDevices{States: []domain.State{domain.AvailableState}, Limit: 10, Offset: 1})
+		require.NoError(t, err)
+		assert.Equal(t, 2, page.TotalCount)
+		require.Len(t, page.Items, 1)
+		assert.Equal(t, 3, page.Items[0].Id)
+
+		_, err = repository.GetById(ctx, 42)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("Counts Like The Postgres Repository", func(t *testing.T) {
+		repository := device.NewMemoryRepository()
+		seed(t, repository)
+		for id, created := range map[int]time.Time{
+			1: time.Date(2025, 1, 5, 23, 0, 0, 0, time.UTC),
+			2: time.Date(2025, 1, 6, 1, 0, 0, 0, time.UTC),
+			3: time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC),
+		} {
+			d, err := repository.GetById(ctx, id)
+			require.NoError(t, err)
+			d.CreationTime = created
+			require.NoError(t, repository.Update(ctx, d))
+		}
+
+		groups, err := repository.Stats(ctx, &domain.CountDevices{GroupBy: []string{domain.ByWeek, domain.ByBrand}})
+		require.NoError(t, err)
+		firstWeek, secondWeek := time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+		assert.Equal(t, []domain.DeviceCount{
+			{Period: &firstWeek, Brand: ptr("Samsung"), Count: 1},
+			{Period: &secondWeek, Brand: ptr("Google"), Count: 1},
+			{Period: &secondWeek, Brand: ptr("Samsung"), Count: 1},
+		}, groups)
+
+		groups, err = repository.Stats(ctx, &domain.CountDevices{Filter: domain.ListDevices{Brand: "Apple"}})
+		require.NoError(t, err)
+		assert.Equal(t, []domain.DeviceCount{{Count: 0}}, groups)
+	})
+
+	t.Run("Searches Like The Postgres Repository", func(t *testing.T) {
+		repository := device.NewMemoryRepository()
+		seed(t, repository)
+
+		result, err := repository.Search(ctx, &domain.SearchDevices{Q: "galaxy", Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, result.Results, 2)
+		assert.Equal(t, "<mark>Galaxy</mark> S24", result.Results[0].Highlights.Name)
+		assert.Equal(t, 3, result.Results[1].Device.Id)
+		assert.Equal(t, []string{}, result.Suggestions)
+
+		result, err = repository.Search(ctx, &domain.SearchDevices{Q: "gogle", Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, result.Results, 1)
+		assert.Equal(t, "Pixel 9", result.Results[0].Device.Name)
+		assert.Equal(t, "Google", result.Results[0].Highlights.Brand)
+		assert.Equal(t, []string{"Google"}, result.Suggestions)
+
+		result, err = repository.Search(ctx, &domain.SearchDevices{Q: "tablet", Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, result.Results)
+	})
+
+	t.Run("Escapes The Highlights", func(t *testing.T) {
+		repository := device.NewMemoryRepository()
+		_, err := repository.Create(ctx, &domain.Device{Name: `<img src=x onerror="alert(1)">Pad`, Brand: "AT&T",
+			State: domain.AvailableState})
+		require.NoError(t, err)
+
+		result, err := repository.Search(ctx, &domain.SearchDevices{Q: "pad", Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, result.Results, 1)
+		assert.Equal(t, `&lt;img src=x onerror="alert(1)"&gt;<mark>Pad</mark>`, result.Results[0].Highlights.Name)
+		assert.Equal(t, "AT&amp;T", result.Results[0].Highlights.Brand)
+	})
+
+	t.Run("Rolls Back Failed Transactions", func(t *testing.T) {
+		bus := event.NewBus(10)
+		repository := device.NewMemoryRepository(bus)
+		seed(t, repository)
+		_, live, unsubscribe := bus.Subscribe(3, 10)
+		defer unsubscribe()
+
+		failed := errors.New("failed")
+		err := repository.WithTx(ctx, func(tx device.Repository) error {
+			require.NoError(t, tx.Delete(ctx, 1))
+			_, err := tx.GetById(ctx, 1)
+			assert.ErrorIs(t, err, sql.ErrNoRows)
+			return failed
+		})
+		assert.Equal(t, failed, err)
+
+		_, err = repository.GetById(ctx, 1)
+		assert.NoError(t, err)
+		assert.Empty(t, live)
+	})
+
+	t.Run("Publishes The Events Of Committed Writes", func(t *testing.T) {
+		bus := event.NewBus(10)
+		repository := device.NewMemoryRepository(bus)
+		seed(t, repository)
+
+		err := repository.WithTx(ctx, func(tx device.Repository) error {
+			existing, err := tx.GetByIdForUpdate(ctx, 2)
+			require.NoError(t, err)
+			existing.State = domain.InUseState
+			return tx.Update(ctx, existing)
+		})
+		require.NoError(t, err)
+
+		replayed, _, unsubscribe := bus.Subscribe(3, 10)
+		defer unsubscribe()
+		require.Len(t, replayed, 2)
+		assert.Equal(t, domain.DeviceUpdated, replayed[0].Type)
+		assert.Equal(t, domain.DeviceStateChanged, replayed[1].Type)
+		assert.Equal(t, domain.InUseState, replayed[1].Device.State)
+	})
+}
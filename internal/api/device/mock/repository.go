@@ -5,6 +5,8 @@ package mocks
 import (
 	context "context"
 
+	device "github.com/ivofreitas/device-api/internal/api/device"
+
 	domain "github.com/ivofreitas/device-api/internal/domain"
 
 	mock "github.com/stretchr/testify/mock"
@@ -153,6 +155,36 @@ func (_m *Repository) GetById(ctx context.Context, id int) (*domain.Device, erro
 	return r0, r1
 }
 
+// GetByIdForUpdate provides a mock function with given fields: ctx, id
+func (_m *Repository) GetByIdForUpdate(ctx context.Context, id int) (*domain.Device, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetByIdForUpdate")
+	}
+
+	var r0 *domain.Device
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) (*domain.Device, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) *domain.Device); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Device)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetByState provides a mock function with given fields: ctx, state
 func (_m *Repository) GetByState(ctx context.Context, state domain.State) ([]domain.Device, error) {
 	ret := _m.Called(ctx, state)
@@ -183,6 +215,96 @@ func (_m *Repository) GetByState(ctx context.Context, state domain.State) ([]dom
 	return r0, r1
 }
 
+// List provides a mock function with given fields: ctx, filter
+func (_m *Repository) List(ctx context.Context, filter *domain.ListDevices) (*domain.DevicePage, error) {
+	ret := _m.Called(ctx, filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for List")
+	}
+
+	var r0 *domain.DevicePage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.ListDevices) (*domain.DevicePage, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.ListDevices) *domain.DevicePage); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.DevicePage)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *domain.ListDevices) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Search provides a mock function with given fields: ctx, search
+func (_m *Repository) Search(ctx context.Context, search *domain.SearchDevices) (*domain.DeviceSearch, error) {
+	ret := _m.Called(ctx, search)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Search")
+	}
+
+	var r0 *domain.DeviceSearch
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.SearchDevices) (*domain.DeviceSearch, error)); ok {
+		return rf(ctx, search)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.SearchDevices) *domain.DeviceSearch); ok {
+		r0 = rf(ctx, search)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.DeviceSearch)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *domain.SearchDevices) error); ok {
+		r1 = rf(ctx, search)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Stats provides a mock function with given fields: ctx, count
+func (_m *Repository) Stats(ctx context.Context, count *domain.CountDevices) ([]domain.DeviceCount, error) {
+	ret := _m.Called(ctx, count)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Stats")
+	}
+
+	var r0 []domain.DeviceCount
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.CountDevices) ([]domain.DeviceCount, error)); ok {
+		return rf(ctx, count)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.CountDevices) []domain.DeviceCount); ok {
+		r0 = rf(ctx, count)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.DeviceCount)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *domain.CountDevices) error); ok {
+		r1 = rf(ctx, count)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Update provides a mock function with given fields: ctx, _a1
 func (_m *Repository) Update(ctx context.Context, _a1 *domain.Device) error {
 	ret := _m.Called(ctx, _a1)
@@ -201,6 +323,24 @@ func (_m *Repository) Update(ctx context.Context, _a1 *domain.Device) error {
 	return r0
 }
 
+// WithTx provides a mock function with given fields: ctx, fn
+func (_m *Repository) WithTx(ctx context.Context, fn func(device.Repository) error) error {
+	ret := _m.Called(ctx, fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WithTx")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, func(device.Repository) error) error); ok {
+		r0 = rf(ctx, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewRepository creates a new instance of Repository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewRepository(t interface {
@@ -3,7 +3,14 @@ package device
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"github.com/ivofreitas/device-api/config/db"
+	"github.com/ivofreitas/device-api/internal/adapter/backoff"
 	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/lib/pq"
+	"slices"
+	"strings"
+	"time"
 )
 
 type Repository interface {
@@ -11,27 +18,76 @@ type Repository interface {
 	Update(ctx context.Context, device *domain.Device) error
 	GetAll(ctx context.Context) ([]domain.Device, error)
 	GetById(ctx context.Context, id int) (*domain.Device, error)
+	// GetByIdForUpdate - like GetById, locking the row until the transaction ends. Only meaningful within WithTx
+	GetByIdForUpdate(ctx context.Context, id int) (*domain.Device, error)
 	GetByBrand(ctx context.Context, brand string) ([]domain.Device, error)
 	GetByState(ctx context.Context, state domain.State) ([]domain.Device, error)
+	// List - one page of the devices matching the filter, with the number of matches across all pages
+	List(ctx context.Context, filter *domain.ListDevices) (*domain.DevicePage, error)
+	// Stats - the number of devices matching the filter in every group of the dimensions, ordered by them.
+	// Without dimensions there is a single group, even with no matches
+	Stats(ctx context.Context, count *domain.CountDevices) ([]domain.DeviceCount, error)
+	// Search - up to search.Limit devices matching the query as words or by similarity, best first, and up to
+	// maxSuggestions brands of the catalogue whose name or an alias resembles it
+	Search(ctx context.Context, search *domain.SearchDevices) (*domain.DeviceSearch, error)
 	Delete(ctx context.Context, id int) error
+	// WithTx - runs fn against a repository bound to a single serializable transaction, committed when fn
+	// returns nil. fn is run again when the transaction fails to serialize, so it must not have other side effects
+	WithTx(ctx context.Context, fn func(Repository) error) error
 }
 
+// Outbox - records the events of a write in the same transaction as the write
+type Outbox interface {
+	Enqueue(ctx context.Context, tx *sql.Tx, events ...domain.Event) error
+}
+
+// maxSuggestions - brands suggested by a search
+const maxSuggestions = 5
+
+const (
+	// maxTxAttempts - tries of a transaction failing with a serialization failure or deadlock
+	maxTxAttempts = 5
+
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
+// txBackoff - wait before retrying a transaction, jittered to keep conflicting requests apart
+var txBackoff = backoff.Backoff{Initial: 10 * time.Millisecond, Max: 500 * time.Millisecond, Multiplier: 2, Jitter: true}
+
 type repository struct {
-	db *sql.DB
+	cluster *db.Cluster
+	outbox  Outbox
+	// tx - set on the repositories handed out by WithTx
+	tx *sql.Tx
+}
+
+// querier - what reads need from a pool or a transaction
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
 
-func NewRepository(db *sql.DB) Repository {
-	return &repository{db: db}
+// NewRepository - writes go to the primary, reads to a healthy replica unless the request already wrote
+func NewRepository(cluster *db.Cluster, outbox Outbox) Repository {
+	return &repository{cluster: cluster, outbox: outbox}
 }
 
 func (r *repository) Create(ctx context.Context, device *domain.Device) (*domain.Device, error) {
 	query := `
-			INSERT INTO devices_schema.devices (name, brand, state) 
-			VALUES ($1, $2, $3) 
-			RETURNING id, name, brand, state, creation_time`
+			INSERT INTO devices_schema.devices (name, brand, state, labels, brand_id) 
+			VALUES ($1, $2, $3, $4, $5) 
+			RETURNING id, name, brand, state, creation_time, labels, brand_id`
 	var createdDevice domain.Device
-	err := r.db.QueryRowContext(ctx, query, device.Name, device.Brand, device.State).
-		Scan(&createdDevice.Id, &createdDevice.Name, &createdDevice.Brand, &createdDevice.State, &createdDevice.CreationTime)
+	err := r.write(ctx, func(tx *sql.Tx) ([]domain.Event, error) {
+		err := tx.QueryRowContext(ctx, query, device.Name, device.Brand, device.State, device.Labels, device.BrandId).
+			Scan(&createdDevice.Id, &createdDevice.Name, &createdDevice.Brand, &createdDevice.State, &createdDevice.CreationTime,
+				&createdDevice.Labels, &createdDevice.BrandId)
+		if err != nil {
+			return nil, err
+		}
+		return events(createdDevice, domain.DeviceCreated), nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -39,14 +95,34 @@ func (r *repository) Create(ctx context.Context, device *domain.Device) (*domain
 }
 
 func (r *repository) Update(ctx context.Context, device *domain.Device) error {
-	query := `UPDATE devices_schema.devices SET name = $1, brand = $2, state = $3, creation_time = $4 WHERE id = $5`
-	_, err := r.db.ExecContext(ctx, query, device.Name, device.Brand, device.State, device.CreationTime, device.Id)
-	return err
+	query := `
+			WITH previous AS (SELECT state FROM devices_schema.devices WHERE id = $5 FOR UPDATE)
+			UPDATE devices_schema.devices SET name = $1, brand = $2, state = $3, creation_time = $4, labels = $6, brand_id = $7
+			FROM previous WHERE id = $5
+			RETURNING id, name, brand, devices.state, creation_time, labels, brand_id, previous.state`
+	return r.write(ctx, func(tx *sql.Tx) ([]domain.Event, error) {
+		var updatedDevice domain.Device
+		var previousState domain.State
+		err := tx.QueryRowContext(ctx, query, device.Name, device.Brand, device.State, device.CreationTime, device.Id, device.Labels,
+			device.BrandId).
+			Scan(&updatedDevice.Id, &updatedDevice.Name, &updatedDevice.Brand, &updatedDevice.State, &updatedDevice.CreationTime,
+				&updatedDevice.Labels, &updatedDevice.BrandId, &previousState)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if updatedDevice.State != previousState {
+			return events(updatedDevice, domain.DeviceUpdated, domain.DeviceStateChanged), nil
+		}
+		return events(updatedDevice, domain.DeviceUpdated), nil
+	})
 }
 
 func (r *repository) GetAll(ctx context.Context) ([]domain.Device, error) {
-	query := `SELECT id, name, brand, state, creation_time FROM devices_schema.devices`
-	rows, err := r.db.QueryContext(ctx, query)
+	query := `SELECT id, name, brand, state, creation_time, labels, brand_id FROM devices_schema.devices`
+	rows, err := r.reader(ctx).QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -55,7 +131,8 @@ func (r *repository) GetAll(ctx context.Context) ([]domain.Device, error) {
 	var devices []domain.Device
 	for rows.Next() {
 		var device domain.Device
-		if err := rows.Scan(&device.Id, &device.Name, &device.Brand, &device.State, &device.CreationTime); err != nil {
+		if err := rows.Scan(&device.Id, &device.Name, &device.Brand, &device.State, &device.CreationTime,
+			&device.Labels, &device.BrandId); err != nil {
 			return nil, err
 		}
 		devices = append(devices, device)
@@ -64,15 +141,24 @@ func (r *repository) GetAll(ctx context.Context) ([]domain.Device, error) {
 }
 
 func (r *repository) GetById(ctx context.Context, id int) (*domain.Device, error) {
-	query := `SELECT id, name, brand, state, creation_time FROM devices_schema.devices WHERE id = $1`
+	query := `SELECT id, name, brand, state, creation_time, labels, brand_id FROM devices_schema.devices WHERE id = $1`
 	var device domain.Device
-	err := r.db.QueryRowContext(ctx, query, id).Scan(&device.Id, &device.Name, &device.Brand, &device.State, &device.CreationTime)
+	err := r.reader(ctx).QueryRowContext(ctx, query, id).Scan(&device.Id, &device.Name, &device.Brand, &device.State, &device.CreationTime,
+		&device.Labels, &device.BrandId)
+	return &device, err
+}
+
+func (r *repository) GetByIdForUpdate(ctx context.Context, id int) (*domain.Device, error) {
+	query := `SELECT id, name, brand, state, creation_time, labels, brand_id FROM devices_schema.devices WHERE id = $1 FOR UPDATE`
+	var device domain.Device
+	err := r.writer(ctx).QueryRowContext(ctx, query, id).Scan(&device.Id, &device.Name, &device.Brand, &device.State, &device.CreationTime,
+		&device.Labels, &device.BrandId)
 	return &device, err
 }
 
 func (r *repository) GetByBrand(ctx context.Context, brand string) ([]domain.Device, error) {
-	query := `SELECT id, name, brand, state, creation_time FROM devices_schema.devices WHERE brand = $1`
-	rows, err := r.db.QueryContext(ctx, query, brand)
+	query := `SELECT id, name, brand, state, creation_time, labels, brand_id FROM devices_schema.devices WHERE brand = $1`
+	rows, err := r.reader(ctx).QueryContext(ctx, query, brand)
 	if err != nil {
 		return nil, err
 	}
@@ -81,7 +167,8 @@ func (r *repository) GetByBrand(ctx context.Context, brand string) ([]domain.Dev
 	var devices []domain.Device
 	for rows.Next() {
 		var device domain.Device
-		if err = rows.Scan(&device.Id, &device.Name, &device.Brand, &device.State, &device.CreationTime); err != nil {
+		if err = rows.Scan(&device.Id, &device.Name, &device.Brand, &device.State, &device.CreationTime,
+			&device.Labels, &device.BrandId); err != nil {
 			return nil, err
 		}
 		devices = append(devices, device)
@@ -90,8 +177,8 @@ func (r *repository) GetByBrand(ctx context.Context, brand string) ([]domain.Dev
 }
 
 func (r *repository) GetByState(ctx context.Context, state domain.State) ([]domain.Device, error) {
-	query := `SELECT id, name, brand, state, creation_time FROM devices_schema.devices WHERE state = $1`
-	rows, err := r.db.QueryContext(ctx, query, state)
+	query := `SELECT id, name, brand, state, creation_time, labels, brand_id FROM devices_schema.devices WHERE state = $1`
+	rows, err := r.reader(ctx).QueryContext(ctx, query, state)
 	if err != nil {
 		return nil, err
 	}
@@ -100,7 +187,8 @@ func (r *repository) GetByState(ctx context.Context, state domain.State) ([]doma
 	var devices []domain.Device
 	for rows.Next() {
 		var device domain.Device
-		if err = rows.Scan(&device.Id, &device.Name, &device.Brand, &device.State, &device.CreationTime); err != nil {
+		if err = rows.Scan(&device.Id, &device.Name, &device.Brand, &device.State, &device.CreationTime,
+			&device.Labels, &device.BrandId); err != nil {
 			return nil, err
 		}
 		devices = append(devices, device)
@@ -108,8 +196,295 @@ func (r *repository) GetByState(ctx context.Context, state domain.State) ([]doma
 	return devices, nil
 }
 
+// sortColumns - the only columns a listing may be ordered by
+var sortColumns = map[string]string{
+	"":              "id",
+	"id":            "id",
+	"name":          "name",
+	"brand":         "brand",
+	"state":         "state",
+	"creation_time": "creation_time",
+}
+
+// listWhere - the conditions of a filter, whose args are listArgs
+const listWhere = `
+			WHERE (cardinality($1::int[]) = 0 OR id = ANY($1))
+				AND ($2::text = '' OR name ILIKE '%' || $2 || '%')
+				AND ($3::text = '' OR brand = $3)
+				AND (cardinality($4::int[]) = 0 OR state = ANY($4))
+				AND ($5::timestamp IS NULL OR creation_time > $5)
+				AND ($6::timestamp IS NULL OR creation_time < $6)`
+
+func listArgs(filter *domain.ListDevices) []interface{} {
+	states := make([]int64, 0, len(filter.States))
+	for _, state := range filter.States {
+		states = append(states, int64(state))
+	}
+	return []interface{}{pq.Array(ids(filter.Ids)), escapeLike(filter.Name), filter.Brand, pq.Array(states),
+		filter.CreatedAfter, filter.CreatedBefore}
+}
+
+func (r *repository) List(ctx context.Context, filter *domain.ListDevices) (*domain.DevicePage, error) {
+	where, args := listWhere, listArgs(filter)
+	reader := r.reader(ctx)
+
+	page := &domain.DevicePage{Items: []domain.Device{}}
+	if err := reader.QueryRowContext(ctx, `SELECT COUNT(*) FROM devices_schema.devices`+where, args...).
+		Scan(&page.TotalCount); err != nil {
+		return nil, err
+	}
+
+	direction := "ASC"
+	if filter.Desc {
+		direction = "DESC"
+	}
+	query := `SELECT id, name, brand, state, creation_time, labels, brand_id FROM devices_schema.devices` + where +
+		` ORDER BY ` + sortColumns[filter.SortBy] + ` ` + direction + `, id ` + direction + ` LIMIT $7 OFFSET $8`
+	rows, err := reader.QueryContext(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var device domain.Device
+		if err = rows.Scan(&device.Id, &device.Name, &device.Brand, &device.State, &device.CreationTime,
+			&device.Labels, &device.BrandId); err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, device)
+	}
+	return page, rows.Err()
+}
+
+// statsColumns - the expression of every dimension devices are counted by
+var statsColumns = map[string]string{
+	domain.ByBrand: "brand",
+	domain.ByState: "state",
+	domain.ByDay:   "date_trunc('day', creation_time)",
+	domain.ByWeek:  "date_trunc('week', creation_time)",
+	domain.ByMonth: "date_trunc('month', creation_time)",
+}
+
+func (r *repository) Stats(ctx context.Context, count *domain.CountDevices) ([]domain.DeviceCount, error) {
+	columns := make([]string, 0, len(count.GroupBy))
+	for _, dimension := range count.GroupBy {
+		columns = append(columns, statsColumns[dimension])
+	}
+
+	query := `SELECT ` + strings.Join(append(slices.Clone(columns), "COUNT(*)"), ", ") +
+		` FROM devices_schema.devices` + listWhere
+	if len(columns) > 0 {
+		query += ` GROUP BY ` + strings.Join(columns, ", ") + ` ORDER BY ` + strings.Join(columns, ", ")
+	}
+	rows, err := r.reader(ctx).QueryContext(ctx, query, listArgs(&count.Filter)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []domain.DeviceCount{}
+	for rows.Next() {
+		var group domain.DeviceCount
+		dest := make([]interface{}, 0, len(columns)+1)
+		for _, dimension := range count.GroupBy {
+			switch dimension {
+			case domain.ByBrand:
+				group.Brand = new(string)
+				dest = append(dest, group.Brand)
+			case domain.ByState:
+				group.State = new(domain.State)
+				dest = append(dest, group.State)
+			default:
+				group.Period = new(time.Time)
+				dest = append(dest, group.Period)
+			}
+		}
+		if err = rows.Scan(append(dest, &group.Count)...); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+// Search - words are matched with the generated search column, name weighted over brand, and typos with the
+// word similarity of pg_trgm, whose <% operator is true above pg_trgm.word_similarity_threshold, 0.6 by default.
+// Every condition is served by an index, the query on the left of <% for the trigram ones, so they are combined by a
+// bitmap OR instead of scanning the devices. Brands are suggested by their canonical name, resembling the query by
+// the key of the name or of an alias
+func (r *repository) Search(ctx context.Context, search *domain.SearchDevices) (*domain.DeviceSearch, error) {
+	query := `
+			SELECT id, name, brand, state, creation_time, labels, brand_id,
+				ts_rank(search, query) + greatest(word_similarity($1, name), word_similarity($1, brand)) AS score,
+				ts_headline('simple', replace(replace(replace(name, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), query,
+					'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+				ts_headline('simple', replace(replace(replace(brand, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), query,
+					'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')
+			FROM devices_schema.devices, websearch_to_tsquery('simple', $1) query
+			WHERE search @@ query OR $1 <% name OR $1 <% brand
+			ORDER BY score DESC, id
+			LIMIT $2`
+	reader := r.reader(ctx)
+	rows, err := reader.QueryContext(ctx, query, search.Q, search.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := &domain.DeviceSearch{Results: []domain.DeviceMatch{}, Suggestions: []string{}}
+	for rows.Next() {
+		var match domain.DeviceMatch
+		device := &match.Device
+		if err = rows.Scan(&device.Id, &device.Name, &device.Brand, &device.State, &device.CreationTime,
+			&device.Labels, &device.BrandId,
+			&match.Score, &match.Highlights.Name, &match.Highlights.Brand); err != nil {
+			return nil, err
+		}
+		result.Results = append(result.Results, match)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	suggestions := `
+			SELECT b.name FROM devices_schema.brand_keys k
+			JOIN devices_schema.brands b ON b.id = k.brand_id
+			WHERE $1 <% k.key
+			GROUP BY b.id, b.name
+			ORDER BY max(word_similarity($1, k.key)) DESC, b.name
+			LIMIT $2`
+	rows, err = reader.QueryContext(ctx, suggestions, search.Q, maxSuggestions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var brand string
+		if err = rows.Scan(&brand); err != nil {
+			return nil, err
+		}
+		result.Suggestions = append(result.Suggestions, brand)
+	}
+	return result, rows.Err()
+}
+
 func (r *repository) Delete(ctx context.Context, id int) error {
-	query := `DELETE FROM devices_schema.devices WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
-	return err
+	query := `DELETE FROM devices_schema.devices WHERE id = $1 RETURNING id, name, brand, state, creation_time, labels, brand_id`
+	return r.write(ctx, func(tx *sql.Tx) ([]domain.Event, error) {
+		var deletedDevice domain.Device
+		err := tx.QueryRowContext(ctx, query, id).
+			Scan(&deletedDevice.Id, &deletedDevice.Name, &deletedDevice.Brand, &deletedDevice.State, &deletedDevice.CreationTime,
+				&deletedDevice.Labels, &deletedDevice.BrandId)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return events(deletedDevice, domain.DeviceDeleted), nil
+	})
+}
+
+// WithTx - a repository that is already bound to a transaction runs fn within it
+func (r *repository) WithTx(ctx context.Context, fn func(Repository) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+
+	return retry(ctx, func() error {
+		return r.transaction(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable}, func(tx *sql.Tx) error {
+			return fn(&repository{cluster: r.cluster, outbox: r.outbox, tx: tx})
+		})
+	})
+}
+
+// write - runs a mutation on the primary and records its events in the same transaction
+func (r *repository) write(ctx context.Context, mutation func(tx *sql.Tx) ([]domain.Event, error)) error {
+	record := func(tx *sql.Tx) error {
+		recorded, err := mutation(tx)
+		if err != nil || len(recorded) == 0 {
+			return err
+		}
+		return r.outbox.Enqueue(ctx, tx, recorded...)
+	}
+
+	if r.tx != nil {
+		return record(r.tx)
+	}
+	return r.transaction(ctx, nil, record)
+}
+
+func (r *repository) transaction(ctx context.Context, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	tx, err := r.cluster.Writer(ctx).BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *repository) reader(ctx context.Context) querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.cluster.Reader(ctx)
+}
+
+func (r *repository) writer(ctx context.Context) querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.cluster.Writer(ctx)
+}
+
+// retry - reruns fn while it fails with a serialization failure or deadlock, up to maxTxAttempts
+func retry(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if !retryable(err) || attempt == maxTxAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(txBackoff.Duration(attempt - 1)):
+		}
+	}
+}
+
+func retryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == serializationFailure || pqErr.Code == deadlockDetected
+}
+
+func ids(values []int) []int64 {
+	result := make([]int64, 0, len(values))
+	for _, value := range values {
+		result = append(result, int64(value))
+	}
+	return result
+}
+
+// escapeLike - matches the wildcards of a LIKE pattern literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func events(device domain.Device, eventTypes ...string) []domain.Event {
+	now := time.Now().UTC()
+	result := make([]domain.Event, 0, len(eventTypes))
+	for _, eventType := range eventTypes {
+		result = append(result, domain.Event{Type: eventType, Device: device, Time: now})
+	}
+	return result
 }
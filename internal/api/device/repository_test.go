@@ -0,0 +1,184 @@
+package device
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/ivofreitas/device-api/config/db"
+	"github.com/ivofreitas/device-api/config/db/dbtest"
+	"github.com/ivofreitas/device-api/internal/adapter/outbox"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Retries Serialization Failures", func(t *testing.T) {
+		attempts := 0
+		err := retry(ctx, func() error {
+			attempts++
+			if attempts < 3 {
+				return &pq.Error{Code: serializationFailure}
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("Gives Up After Max Attempts", func(t *testing.T) {
+		attempts := 0
+		err := retry(ctx, func() error {
+			attempts++
+			return &pq.Error{Code: deadlockDetected}
+		})
+		assert.Error(t, err)
+		assert.Equal(t, maxTxAttempts, attempts)
+	})
+
+	t.Run("Does Not Retry Other Errors", func(t *testing.T) {
+		attempts := 0
+		err := retry(ctx, func() error {
+			attempts++
+			return errors.New("constraint violated")
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, attempts)
+	})
+}
+
+// txDriver - a database/sql driver supporting nothing but transactions, counting how they end
+type txDriver struct {
+	mu        sync.Mutex
+	isolation driver.IsolationLevel
+	commits   int
+	rollbacks int
+}
+
+func (d *txDriver) Open(string) (driver.Conn, error) { return &txConn{driver: d}, nil }
+
+// connector - opens connections of a txDriver
+type connector struct{ driver *txDriver }
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return c.driver.Open("") }
+func (c connector) Driver() driver.Driver                        { return c.driver }
+
+type txConn struct{ driver *txDriver }
+
+func (c *txConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *txConn) Close() error                        { return nil }
+func (c *txConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *txConn) BeginTx(_ context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	c.driver.isolation = opts.Isolation
+	return c, nil
+}
+
+func (c *txConn) Commit() error {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	c.driver.commits++
+	return nil
+}
+
+func (c *txConn) Rollback() error {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	c.driver.rollbacks++
+	return nil
+}
+
+func TestWithTx(t *testing.T) {
+	ctx := context.Background()
+	open := func(t *testing.T) (*txDriver, Repository) {
+		d := &txDriver{}
+		conn := sql.OpenDB(connector{d})
+		t.Cleanup(func() { _ = conn.Close() })
+		return d, NewRepository(db.NewCluster(conn), nil)
+	}
+
+	t.Run("Reruns The Transaction After A Serialization Failure", func(t *testing.T) {
+		d, repository := open(t)
+		calls := 0
+		err := repository.WithTx(ctx, func(Repository) error {
+			calls++
+			if calls == 1 {
+				return &pq.Error{Code: serializationFailure}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, 1, d.commits)
+		assert.Equal(t, 1, d.rollbacks)
+		assert.Equal(t, driver.IsolationLevel(sql.LevelSerializable), d.isolation)
+	})
+
+	t.Run("Gives Up After Max Attempts", func(t *testing.T) {
+		d, repository := open(t)
+		calls := 0
+		err := repository.WithTx(ctx, func(Repository) error {
+			calls++
+			return &pq.Error{Code: deadlockDetected}
+		})
+		assert.Equal(t, deadlockDetected, string(err.(*pq.Error).Code))
+		assert.Equal(t, maxTxAttempts, calls)
+		assert.Zero(t, d.commits)
+		assert.Equal(t, maxTxAttempts, d.rollbacks)
+	})
+
+	t.Run("Runs Nested Transactions Once Within The Outer One", func(t *testing.T) {
+		d, repository := open(t)
+		calls := 0
+		err := repository.WithTx(ctx, func(tx Repository) error {
+			return tx.WithTx(ctx, func(Repository) error {
+				calls++
+				return nil
+			})
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, 1, d.commits)
+	})
+}
+
+func TestRepositorySearch(t *testing.T) {
+	conn := dbtest.Open(t)
+	ctx := context.Background()
+	repository := NewRepository(db.NewCluster(conn), outbox.NewWriter())
+
+	_, err := repository.Create(ctx, &domain.Device{Name: `<img src=x onerror="alert(1)">Pad`, Brand: "AT&T",
+		State: domain.AvailableState})
+	require.NoError(t, err)
+
+	t.Run("Escapes The Highlights", func(t *testing.T) {
+		result, err := repository.Search(ctx, &domain.SearchDevices{Q: "pad", Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, result.Results, 1)
+		assert.Equal(t, `&lt;img src=x onerror="alert(1)"&gt;<mark>Pad</mark>`, result.Results[0].Highlights.Name)
+		assert.Equal(t, "AT&amp;T", result.Results[0].Highlights.Brand)
+	})
+	t.Run("Suggests The Brands Of The Catalogue By Their Aliases", func(t *testing.T) {
+		var brandId int
+		require.NoError(t, conn.QueryRowContext(ctx,
+			`INSERT INTO devices_schema.brands (name, aliases) VALUES ('Alphabet', '{Google}') RETURNING id`).Scan(&brandId))
+		_, err := conn.ExecContext(ctx,
+			`INSERT INTO devices_schema.brand_keys (key, brand_id) VALUES ('alphabet', $1), ('google', $1)`, brandId)
+		require.NoError(t, err)
+
+		result, err := repository.Search(ctx, &domain.SearchDevices{Q: "gogle", Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, result.Results)
+		assert.Equal(t, []string{"Alphabet"}, result.Suggestions)
+	})
+}
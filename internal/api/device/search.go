@@ -0,0 +1,107 @@
+package device
+
+import (
+	"strings"
+	"unicode"
+)
+
+// defaultSearchLimit - results of a search without a limit
+const defaultSearchLimit = 20
+
+// similarityThreshold - the word similarity above which a query matches a typo, like pg_trgm.word_similarity_threshold
+const similarityThreshold = 0.6
+
+// words - the lower case words of a text, split like the simple text search configuration does
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// trigrams - the trigrams of the words of a text, padded like pg_trgm with two spaces before and one after
+func trigrams(text string) map[string]struct{} {
+	set := map[string]struct{}{}
+	for _, word := range words(text) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = struct{}{}
+		}
+	}
+	return set
+}
+
+// wordSimilarity - the share of the trigrams of a found in b, an upper bound of word_similarity(a, b) of pg_trgm
+func wordSimilarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 {
+		return 0
+	}
+
+	common := 0
+	for trigram := range ta {
+		if _, ok := tb[trigram]; ok {
+			common++
+		}
+	}
+	return float64(common) / float64(len(ta))
+}
+
+// rank - like ts_rank of the search column, every term of the query found in the name weighs more than in the
+// brand. Zero unless every term is found, as websearch_to_tsquery requires
+func rank(terms []string, name, brand string) float64 {
+	nameWords, brandWords := set(words(name)), set(words(brand))
+	weight := 0.0
+	for _, term := range terms {
+		_, inName := nameWords[term]
+		_, inBrand := brandWords[term]
+		switch {
+		case inName:
+			weight += 1
+		case inBrand:
+			weight += 0.4
+		default:
+			return 0
+		}
+	}
+	if len(terms) == 0 {
+		return 0
+	}
+	return 0.1 * weight / float64(len(terms))
+}
+
+// escapeHTML - escapes text like the Postgres search does before ts_headline, which leaves the escapes unmarked
+var escapeHTML = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// highlight - text HTML-escaped, with the words among the terms wrapped in <mark> tags, like ts_headline with
+// HighlightAll
+func highlight(text string, terms []string) string {
+	marked := set(terms)
+	var b strings.Builder
+	word := []rune{}
+	flush := func() {
+		if _, ok := marked[strings.ToLower(string(word))]; ok && len(word) > 0 {
+			b.WriteString("<mark>" + string(word) + "</mark>")
+		} else {
+			b.WriteString(string(word))
+		}
+		word = word[:0]
+	}
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			word = append(word, r)
+			continue
+		}
+		flush()
+		_, _ = escapeHTML.WriteString(&b, string(r))
+	}
+	flush()
+	return b.String()
+}
+
+func set(values []string) map[string]struct{} {
+	s := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		s[value] = struct{}{}
+	}
+	return s
+}
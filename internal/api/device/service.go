@@ -1,34 +1,62 @@
 package device
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	jsonpatch "github.com/evanphx/json-patch/v5"
 	"github.com/ivofreitas/device-api/internal/domain"
 	"net/http"
+	"slices"
 	"time"
 )
 
 type Service struct {
 	repository Repository
+	brands     Brands
+	strict     bool
 }
 
-func NewService(repository Repository) *Service {
-	return &Service{repository}
+// Brands - the brand catalogue the brands of devices resolve to
+type Brands interface {
+	// Resolve - the brand with the name or alias, sql.ErrNoRows when there is none
+	Resolve(ctx context.Context, brand string) (*domain.Brand, error)
 }
 
-// Create
-// @Summary Create a new device
-// @Description Adds a new device to the inventory
-// @Tags Device
-// @Accept  json
-// @Produce  json
-// @Param request body domain.Device true "Device details"
-// @Success 201 {object} domain.Device "Created device"
-// @Failure 500 {object} map[string]string "Internal server error"
-// @Router /v1/devices [post]
-func (s *Service) Create(ctx context.Context, param interface{}) (interface{}, error) {
-	device := param.(*domain.Device)
+type Option func(*Service)
+
+// WithBrands - resolves the brands of written devices to the catalogue, which stores them under their canonical
+// name. Brands outside of it are kept as given, or refused when strict
+func WithBrands(brands Brands, strict bool) Option {
+	return func(s *Service) {
+		s.brands, s.strict = brands, strict
+	}
+}
+
+func NewService(repository Repository, options ...Option) *Service {
+	s := &Service{repository: repository}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+var errCreationTime = &domain.Error{
+	Type:   "update_error",
+	Status: http.StatusForbidden,
+	Detail: "cannot update creation time of a device"}
+
+// Create - adds a new device to the inventory
+func (s *Service) Create(ctx context.Context, device *domain.Device) (*domain.Device, error) {
+	brand, brandId, err := s.resolveBrand(ctx, device.Brand)
+	if err != nil {
+		return nil, err
+	}
+	device.Brand, device.BrandId = brand, brandId
+
 	createdDevice, err := s.repository.Create(ctx, device)
 	if err != nil {
 		return nil, &domain.Error{Type: "create_error", Status: http.StatusInternalServerError, Detail: err.Error()}
@@ -37,126 +65,266 @@ func (s *Service) Create(ctx context.Context, param interface{}) (interface{}, e
 	return createdDevice, nil
 }
 
-// Update
-// @Summary Update an existing device
-// @Description Updates device details if allowed. `PUT` requires a full update, while `PATCH` allows partial updates.
-// @Tags Device
-// @Accept  json
-// @Produce  json
-// @Param id path int true "Device ID"
-// @Param request body domain.Update true "Device update details"
-// @Success 200 {object} domain.Device "Updated device"
-// @Failure 400 {object} map[string]string "Invalid request body"
-// @Failure 403 {object} map[string]string "Forbidden update"
-// @Failure 404 {object} map[string]string "Device not found"
-// @Failure 500 {object} map[string]string "Internal server error"
-// @Router /v1/devices/{id} [put]
-// @Router /v1/devices/{id} [patch]
-func (s *Service) Update(ctx context.Context, param interface{}) (interface{}, error) {
-	update := param.(*domain.Update)
-
+// Update - updates device details if allowed. `PUT` requires a full update, while `PATCH` allows partial updates
+func (s *Service) Update(ctx context.Context, update *domain.Update) (*domain.Device, error) {
 	if update.CreationTime != (time.Time{}) {
-		return nil, &domain.Error{
-			Type:   "update_error",
-			Status: http.StatusForbidden,
-			Detail: "cannot update creation time of a device"}
+		return nil, errCreationTime
 	}
 
-	existingDevice, err := s.repository.GetById(ctx, update.Id)
+	brand, brandId, err := s.resolveBrand(ctx, *update.Brand)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, &domain.Error{Type: "not_found", Status: http.StatusNotFound, Detail: "device not found"}
+		return nil, err
+	}
+
+	var updatedDevice *domain.Device
+	err = s.repository.WithTx(ctx, func(repository Repository) error {
+		existingDevice, err := repository.GetByIdForUpdate(ctx, update.Id)
+		if err != nil {
+			return err
+		}
+
+		if existingDevice.State == domain.InUseState &&
+			(*update.Name != existingDevice.Name || !sameBrand(existingDevice, *update.Brand, brandId)) {
+			return &domain.Error{
+				Type:   "update_error",
+				Status: http.StatusForbidden,
+				Detail: "cannot update name or brand of a device in use"}
+		}
+
+		existingDevice.Name = *update.Name
+		existingDevice.Brand, existingDevice.BrandId = brand, brandId
+		existingDevice.State = *update.State
+		if update.Labels != nil {
+			existingDevice.Labels = update.Labels
+		}
+
+		if err = repository.Update(ctx, existingDevice); err != nil {
+			return err
 		}
-		return nil, &domain.Error{Type: "update_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+
+		updatedDevice = existingDevice
+		return nil
+	})
+	if err != nil {
+		return nil, txError("update_error", err)
 	}
 
-	if existingDevice.State == domain.InUseState &&
-		(*update.Name != existingDevice.Name || *update.Brand != existingDevice.Brand) {
-		return nil, &domain.Error{
-			Type:   "update_error",
-			Status: http.StatusForbidden,
-			Detail: "cannot update name or brand of a device in use"}
+	return updatedDevice, nil
+}
+
+// Patch - allows partial updates to a device. Only provided fields are modified. With `Content-Type:
+// application/merge-patch+json` the body is an RFC 7396 merge patch, and with `application/json-patch+json` a list of
+// RFC 6902 operations, applied to the current device
+func (s *Service) Patch(ctx context.Context, patch *domain.Patch) (*domain.Device, error) {
+	if patch.CreationTime != (time.Time{}) {
+		return nil, errCreationTime
 	}
 
-	existingDevice.Name = *update.Name
-	existingDevice.Brand = *update.Brand
-	existingDevice.State = *update.State
+	var brand string
+	var brandId *int
+	if patch.Brand != nil {
+		var err error
+		if brand, brandId, err = s.resolveBrand(ctx, *patch.Brand); err != nil {
+			return nil, err
+		}
+	}
+
+	var patchedDevice *domain.Device
+	err := s.repository.WithTx(ctx, func(repository Repository) error {
+		existingDevice, err := repository.GetByIdForUpdate(ctx, patch.Id)
+		if err != nil {
+			return err
+		}
+
+		if err = checkInUse(existingDevice, patch.Name, patch.Brand, brandId); err != nil {
+			return err
+		}
+
+		if patch.Name != nil {
+			existingDevice.Name = *patch.Name
+		}
+		if patch.Brand != nil {
+			existingDevice.Brand, existingDevice.BrandId = brand, brandId
+		}
+		if patch.State != nil {
+			existingDevice.State = *patch.State
+		}
+		if patch.Labels != nil {
+			existingDevice.Labels = patch.Labels
+		}
 
-	if err = s.repository.Update(ctx, existingDevice); err != nil {
-		return nil, &domain.Error{Type: "update_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+		if err = repository.Update(ctx, existingDevice); err != nil {
+			return err
+		}
+
+		patchedDevice = existingDevice
+		return nil
+	})
+	if err != nil {
+		return nil, txError("patch_error", err)
 	}
 
-	return existingDevice, nil
+	return patchedDevice, nil
 }
 
-// Patch (PATCH)
-// @Summary Partially update an existing device
-// @Description Allows partial updates to a device. Only provided fields are modified.
-// @Tags Device
-// @Accept  json
-// @Produce  json
-// @Param id path int true "Device ID"
-// @Param request body domain.Patch true "Partial device update details"
-// @Success 200 {object} domain.Device "Updated device"
-// @Failure 400 {object} map[string]string "Invalid request body"
-// @Failure 403 {object} map[string]string "Forbidden update"
-// @Failure 404 {object} map[string]string "Device not found"
-// @Failure 500 {object} map[string]string "Internal server error"
-// @Router /v1/devices/{id} [patch]
-func (s *Service) Patch(ctx context.Context, param interface{}) (interface{}, error) {
-	patch := param.(*domain.Patch)
+// MergePatch - merges an RFC 7396 document into the device. Members set to null are removed, so the result must keep
+// name, brand and state. Documented with Patch, which shares its route
+func (s *Service) MergePatch(ctx context.Context, patch *domain.MergePatch) (*domain.Device, error) {
+	return s.applyDocument(ctx, patch.Id, func(document []byte) ([]byte, error) {
+		patched, err := jsonpatch.MergePatch(document, patch.Document)
+		if err != nil {
+			return nil, &domain.Error{Type: "invalid_patch", Status: http.StatusBadRequest, Detail: err.Error()}
+		}
+		return patched, nil
+	})
+}
 
-	if patch.CreationTime != (time.Time{}) {
-		return nil, &domain.Error{
-			Type:   "update_error",
-			Status: http.StatusForbidden,
-			Detail: "cannot update creation time of a device"}
+// JSONPatch - applies RFC 6902 operations to the device, all or nothing. A failing test operation answers 409
+func (s *Service) JSONPatch(ctx context.Context, patch *domain.JSONPatch) (*domain.Device, error) {
+	operations, err := jsonpatch.DecodePatch(patch.Operations)
+	if err != nil {
+		return nil, &domain.Error{Type: "invalid_patch", Status: http.StatusBadRequest, Detail: err.Error()}
 	}
 
-	existingDevice, err := s.repository.GetById(ctx, patch.Id)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, &domain.Error{Type: "not_found", Status: http.StatusNotFound, Detail: "device not found"}
+	return s.applyDocument(ctx, patch.Id, func(document []byte) ([]byte, error) {
+		patched, err := operations.Apply(document)
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			return nil, &domain.Error{Type: "test_failed", Status: http.StatusConflict, Detail: err.Error()}
+		}
+		if err != nil {
+			return nil, &domain.Error{Type: "patch_error", Status: http.StatusUnprocessableEntity, Detail: err.Error()}
+		}
+		return patched, nil
+	})
+}
+
+// applyDocument - patches the JSON representation of the locked device and stores the result,
+// provided it is still a complete device and the same rules as Patch allow the change
+func (s *Service) applyDocument(ctx context.Context, id int, apply func(document []byte) ([]byte, error)) (*domain.Device, error) {
+	var patchedDevice *domain.Device
+	err := s.repository.WithTx(ctx, func(repository Repository) error {
+		existingDevice, err := repository.GetByIdForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		document, err := json.Marshal(existingDevice)
+		if err != nil {
+			return err
+		}
+		patchedDocument, err := apply(document)
+		if err != nil {
+			return err
+		}
+
+		patched, err := decodeDevice(patchedDocument)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case patched.Id != existingDevice.Id:
+			return &domain.Error{Type: "patch_error", Status: http.StatusForbidden, Detail: "cannot update id of a device"}
+		case !patched.CreationTime.Equal(existingDevice.CreationTime):
+			return errCreationTime
+		}
+
+		given := patched.Brand
+		patched.BrandId = existingDevice.BrandId
+		if patched.Brand != existingDevice.Brand {
+			if patched.Brand, patched.BrandId, err = s.resolveBrand(ctx, patched.Brand); err != nil {
+				return err
+			}
+		}
+		if err = checkInUse(existingDevice, &patched.Name, &given, patched.BrandId); err != nil {
+			return err
+		}
+
+		// Labels are not part of the document, so they are kept
+		patched.CreationTime, patched.Labels = existingDevice.CreationTime, existingDevice.Labels
+		if err = repository.Update(ctx, patched); err != nil {
+			return err
 		}
-		return nil, &domain.Error{Type: "patch_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+
+		patchedDevice = patched
+		return nil
+	})
+	if err != nil {
+		return nil, txError("patch_error", err)
 	}
 
-	if existingDevice.State == domain.InUseState &&
-		((patch.Name != nil && *patch.Name != existingDevice.Name) ||
-			(patch.Brand != nil && *patch.Brand != existingDevice.Brand)) {
-		return nil, &domain.Error{
-			Type:   "patch_error",
-			Status: http.StatusForbidden,
-			Detail: "cannot update name or brand of a device in use"}
+	return patchedDevice, nil
+}
 
+// decodeDevice - reads a patched device document, which must keep every member of a device
+func decodeDevice(document []byte) (*domain.Device, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(document, &fields); err != nil {
+		return nil, &domain.Error{Type: "patch_error", Status: http.StatusUnprocessableEntity, Detail: "patched document is not an object"}
+	}
+	for _, name := range []string{"id", "name", "brand", "state", "creation_time"} {
+		if _, ok := fields[name]; !ok {
+			return nil, &domain.Error{Type: "patch_error", Status: http.StatusUnprocessableEntity, Detail: "patched document lacks " + name}
+		}
 	}
 
-	if patch.Name != nil {
-		existingDevice.Name = *patch.Name
+	dec := json.NewDecoder(bytes.NewReader(document))
+	dec.DisallowUnknownFields()
+	device := new(domain.Device)
+	if err := dec.Decode(device); err != nil {
+		return nil, &domain.Error{Type: "patch_error", Status: http.StatusUnprocessableEntity, Detail: err.Error()}
 	}
-	if patch.Brand != nil {
-		existingDevice.Brand = *patch.Brand
+	return device, nil
+}
+
+// resolveBrand - the canonical name and id of a brand of the catalogue, or the brand as given when it is not in it
+func (s *Service) resolveBrand(ctx context.Context, brand string) (string, *int, error) {
+	if s.brands == nil {
+		return brand, nil, nil
 	}
-	if patch.State != nil {
-		existingDevice.State = *patch.State
+
+	resolved, err := s.brands.Resolve(ctx, brand)
+	switch {
+	case err == nil:
+		return resolved.Name, &resolved.Id, nil
+	case !errors.Is(err, sql.ErrNoRows):
+		return "", nil, &domain.Error{Type: "brand_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+	case s.strict:
+		return "", nil, &domain.Error{
+			Type:   "unknown_brand",
+			Status: http.StatusUnprocessableEntity,
+			Detail: fmt.Sprintf("brand %q is not in the catalogue", brand)}
+	default:
+		return brand, nil, nil
 	}
+}
 
-	if err = s.repository.Update(ctx, existingDevice); err != nil {
-		return nil, &domain.Error{Type: "update_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+// checkInUse - a device in use keeps its name and brand, nil values are left unchanged. The brand is compared as
+// given, with the id it resolved to
+func checkInUse(existingDevice *domain.Device, name, brand *string, brandId *int) error {
+	if existingDevice.State == domain.InUseState &&
+		((name != nil && *name != existingDevice.Name) ||
+			(brand != nil && !sameBrand(existingDevice, *brand, brandId))) {
+		return &domain.Error{
+			Type:   "patch_error",
+			Status: http.StatusForbidden,
+			Detail: "cannot update name or brand of a device in use"}
 	}
+	return nil
+}
 
-	return existingDevice, nil
+// sameBrand - whether a brand, as given, is the brand of the device. Brands of the catalogue are the same when their
+// ids are, others when they are spelled the same up to case and spacing, as the catalogue would resolve them. So a
+// device written before the catalogue, or before its brand was added, keeps its brand however it was spelled
+func sameBrand(device *domain.Device, brand string, brandId *int) bool {
+	if brandId != nil && device.BrandId != nil {
+		return *brandId == *device.BrandId
+	}
+	return domain.BrandKey(brand) == domain.BrandKey(device.Brand)
 }
 
-// GetAll
-// @Summary Get all devices
-// @Description Retrieves a list of all devices
-// @Tags Device
-// @Produce json
-// @Success 200 {array} domain.Device "List of devices"
-// @Failure 500 {object} map[string]string "Internal server error"
-// @Router /v1/devices [get]
-func (s *Service) GetAll(ctx context.Context, param interface{}) (interface{}, error) {
+// GetAll - retrieves a list of all devices
+func (s *Service) GetAll(ctx context.Context) ([]domain.Device, error) {
 	devices, err := s.repository.GetAll(ctx)
 	if err != nil {
 		return nil, &domain.Error{Type: "fetch_error", Status: http.StatusInternalServerError, Detail: err.Error()}
@@ -164,18 +332,8 @@ func (s *Service) GetAll(ctx context.Context, param interface{}) (interface{}, e
 	return devices, nil
 }
 
-// GetById
-// @Summary Get a device by ID
-// @Description Retrieves a single device by its ID
-// @Tags Device
-// @Produce json
-// @Param id path int true "Device ID"
-// @Success 200 {object} domain.Device "Device details"
-// @Failure 404 {object} map[string]string "Device not found"
-// @Failure 500 {object} map[string]string "Internal server error"
-// @Router /v1/devices/{id} [get]
-func (s *Service) GetById(ctx context.Context, param interface{}) (interface{}, error) {
-	idParam := param.(*domain.GetById)
+// GetById - retrieves a single device by its ID
+func (s *Service) GetById(ctx context.Context, idParam *domain.GetById) (*domain.Device, error) {
 	device, err := s.repository.GetById(ctx, idParam.Id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -186,17 +344,8 @@ func (s *Service) GetById(ctx context.Context, param interface{}) (interface{},
 	return device, nil
 }
 
-// GetByBrand
-// @Summary Get a device by brand
-// @Description Retrieves a single device by its brand
-// @Tags Device
-// @Produce json
-// @Param brand path int true "Device Brand"
-// @Success 200 {object} domain.Device "Device details"
-// @Failure 500 {object} map[string]string "Internal server error"
-// @Router /v1/devices/brand/{brand} [get]
-func (s *Service) GetByBrand(ctx context.Context, param interface{}) (interface{}, error) {
-	brandParam := param.(*domain.GetByBrand)
+// GetByBrand - retrieves the devices of a brand
+func (s *Service) GetByBrand(ctx context.Context, brandParam *domain.GetByBrand) ([]domain.Device, error) {
 	devices, err := s.repository.GetByBrand(ctx, brandParam.Brand)
 	if err != nil {
 		return nil, &domain.Error{Type: "fetch_error", Status: http.StatusInternalServerError, Detail: err.Error()}
@@ -204,17 +353,8 @@ func (s *Service) GetByBrand(ctx context.Context, param interface{}) (interface{
 	return devices, nil
 }
 
-// GetByState
-// @Summary Get a device by state
-// @Description Retrieves a single device by its state
-// @Tags Device
-// @Produce json
-// @Param brand path int true "Device State"
-// @Success 200 {object} domain.Device "Device details"
-// @Failure 500 {object} map[string]string "Internal server error"
-// @Router /v1/devices/state/{state} [get]
-func (s *Service) GetByState(ctx context.Context, param interface{}) (interface{}, error) {
-	stateParam := param.(*domain.GetByState)
+// GetByState - retrieves the devices in a state
+func (s *Service) GetByState(ctx context.Context, stateParam *domain.GetByState) ([]domain.Device, error) {
 	devices, err := s.repository.GetByState(ctx, stateParam.State)
 	if err != nil {
 		return nil, &domain.Error{Type: "fetch_error", Status: http.StatusInternalServerError, Detail: err.Error()}
@@ -222,35 +362,112 @@ func (s *Service) GetByState(ctx context.Context, param interface{}) (interface{
 	return devices, nil
 }
 
-// Delete
-// @Summary Delete a device
-// @Description Removes a device from the inventory
-// @Tags Device
-// @Param id path int true "Device ID"
-// @Success 204 "No content"
-// @Failure 403 {object} map[string]string "Cannot delete device in use"
-// @Failure 404 {object} map[string]string "Device not found"
-// @Failure 500 {object} map[string]string "Internal server error"
-// @Router /v1/devices/{id} [delete]
-func (s *Service) Delete(ctx context.Context, param interface{}) (interface{}, error) {
-	deleteParam := param.(*domain.Delete)
-	existingDevice, err := s.repository.GetById(ctx, deleteParam.Id)
+// List - one page of the devices matching a filter
+func (s *Service) List(ctx context.Context, filter *domain.ListDevices) (*domain.DevicePage, error) {
+	page, err := s.repository.List(ctx, filter)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, &domain.Error{Type: "not_found", Status: http.StatusNotFound, Detail: "device not found"}
+		return nil, &domain.Error{Type: "fetch_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+
+	page.HasNextPage = filter.Offset+len(page.Items) < page.TotalCount
+	return page, nil
+}
+
+// Stats - counts the devices matching the filters of the list, grouped by any combination of brand, state and one time
+// bucket of the creation time. Counts may lag writes by a few seconds when cached
+func (s *Service) Stats(ctx context.Context, query *domain.GetStats) (*domain.DeviceStats, error) {
+	buckets := 0
+	for _, dimension := range query.GroupBy {
+		if dimension == domain.ByDay || dimension == domain.ByWeek || dimension == domain.ByMonth {
+			buckets++
 		}
-		return nil, &domain.Error{Type: "delete_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+	if buckets > 1 {
+		return nil, &domain.Error{Type: "validate_error", Status: http.StatusBadRequest,
+			Detail: "group_by takes only one of day, week and month"}
 	}
 
-	if existingDevice.State == domain.InUseState {
-		return nil, &domain.Error{
-			Type:   "delete_error",
-			Status: http.StatusForbidden,
-			Detail: "cannot delete a device that is in use"}
+	count := &domain.CountDevices{
+		Filter: domain.ListDevices{
+			Name:          query.Name,
+			Brand:         query.Brand,
+			States:        query.States,
+			CreatedAfter:  query.CreatedAfter,
+			CreatedBefore: query.CreatedBefore,
+		},
+		GroupBy: query.GroupBy,
+	}
+	groups, err := s.repository.Stats(ctx, count)
+	if err != nil {
+		return nil, &domain.Error{Type: "fetch_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+
+	stats := &domain.DeviceStats{GroupBy: query.GroupBy, Groups: groups}
+	if stats.GroupBy == nil {
+		stats.GroupBy = []string{}
+	}
+	for _, group := range groups {
+		stats.Total += group.Count
+	}
+	return stats, nil
+}
+
+// Search - finds the devices whose name or brand match the words of the query, or resemble it to tolerate typos, best
+// first. Matched words are highlighted, and brands resembling the query are suggested
+func (s *Service) Search(ctx context.Context, search *domain.SearchDevices) (*domain.DeviceSearch, error) {
+	if search.Limit == 0 {
+		search.Limit = defaultSearchLimit
 	}
 
-	if err = s.repository.Delete(ctx, deleteParam.Id); err != nil {
-		return nil, &domain.Error{Type: "delete_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+	result, err := s.repository.Search(ctx, search)
+	if err != nil {
+		return nil, &domain.Error{Type: "search_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+
+	// Brands the query already names are no suggestion
+	terms := set(words(search.Q))
+	result.Suggestions = slices.DeleteFunc(result.Suggestions, func(brand string) bool {
+		for _, word := range words(brand) {
+			if _, ok := terms[word]; !ok {
+				return false
+			}
+		}
+		return true
+	})
+	return result, nil
+}
+
+// Delete - removes a device from the inventory
+func (s *Service) Delete(ctx context.Context, deleteParam *domain.Delete) error {
+	err := s.repository.WithTx(ctx, func(repository Repository) error {
+		existingDevice, err := repository.GetByIdForUpdate(ctx, deleteParam.Id)
+		if err != nil {
+			return err
+		}
+
+		if existingDevice.State == domain.InUseState {
+			return &domain.Error{
+				Type:   "delete_error",
+				Status: http.StatusForbidden,
+				Detail: "cannot delete a device that is in use"}
+		}
+
+		return repository.Delete(ctx, deleteParam.Id)
+	})
+	if err != nil {
+		return txError("delete_error", err)
+	}
+	return nil
+}
+
+// txError - passes on the errors raised by the checks of a unit of work and maps the repository ones
+func txError(errType string, err error) error {
+	var domainErr *domain.Error
+	if errors.As(err, &domainErr) {
+		return domainErr
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return &domain.Error{Type: "not_found", Status: http.StatusNotFound, Detail: "device not found"}
 	}
-	return nil, nil
+	return &domain.Error{Type: errType, Status: http.StatusInternalServerError, Detail: err.Error()}
 }
@@ -1,15 +1,18 @@
-package device
+package device_test
 
 import (
 	"context"
 	"database/sql"
 	"errors"
+	"github.com/ivofreitas/device-api/internal/adapter/event"
+	"github.com/ivofreitas/device-api/internal/api/device"
 	mocks "github.com/ivofreitas/device-api/internal/api/device/mock"
 	"github.com/ivofreitas/device-api/internal/domain"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"net/http"
 	"testing"
+	"time"
 )
 
 type testCase struct {
@@ -21,6 +24,7 @@ type testCase struct {
 }
 
 func TestServiceMethods(t *testing.T) {
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
 	testCases := []testCase{
 		{
 			name:     "Create Device - Success",
@@ -43,7 +47,8 @@ func TestServiceMethods(t *testing.T) {
 			input:    &domain.Update{Id: 1, Name: ptr("Updated Name"), Brand: ptr("Same Brand"), State: ptr(domain.AvailableState)},
 			expected: &domain.Device{Id: 1, Name: "Updated Name", Brand: "Same Brand"},
 			mockSetup: func(m *mocks.Repository, ctx context.Context) {
-				m.On("GetById", ctx, 1).Return(&domain.Device{Id: 1, Name: "Old Name", Brand: "Same Brand"}, nil)
+				inTx(m, ctx)
+				m.On("GetByIdForUpdate", ctx, 1).Return(&domain.Device{Id: 1, Name: "Old Name", Brand: "Same Brand"}, nil)
 				m.On("Update", ctx, mock.Anything).Return(nil)
 			},
 		},
@@ -52,7 +57,8 @@ func TestServiceMethods(t *testing.T) {
 			input:       &domain.Update{Id: 1, Name: ptr("Updated Name"), Brand: ptr("Same Brand"), State: ptr(domain.AvailableState)},
 			expectedErr: &domain.Error{Type: "not_found", Status: http.StatusNotFound},
 			mockSetup: func(m *mocks.Repository, ctx context.Context) {
-				m.On("GetById", ctx, 1).Return((*domain.Device)(nil), sql.ErrNoRows)
+				inTx(m, ctx)
+				m.On("GetByIdForUpdate", ctx, 1).Return((*domain.Device)(nil), sql.ErrNoRows)
 			},
 		},
 		{
@@ -60,7 +66,8 @@ func TestServiceMethods(t *testing.T) {
 			input:       &domain.Update{Id: 1, Name: ptr("New Name"), Brand: ptr("New Brand"), State: ptr(domain.AvailableState)},
 			expectedErr: &domain.Error{Type: "update_error", Status: http.StatusForbidden},
 			mockSetup: func(m *mocks.Repository, ctx context.Context) {
-				m.On("GetById", ctx, 1).Return(&domain.Device{Id: 1, State: domain.InUseState, Name: "Old Name", Brand: "Old Brand"}, nil)
+				inTx(m, ctx)
+				m.On("GetByIdForUpdate", ctx, 1).Return(&domain.Device{Id: 1, State: domain.InUseState, Name: "Old Name", Brand: "Old Brand"}, nil)
 			},
 		},
 		{
@@ -77,7 +84,8 @@ func TestServiceMethods(t *testing.T) {
 				State: domain.AvailableState,
 			},
 			mockSetup: func(m *mocks.Repository, ctx context.Context) {
-				m.On("GetById", ctx, 1).Return(&domain.Device{Id: 1, Name: "Old Name", Brand: "Old Brand", State: domain.AvailableState}, nil)
+				inTx(m, ctx)
+				m.On("GetByIdForUpdate", ctx, 1).Return(&domain.Device{Id: 1, Name: "Old Name", Brand: "Old Brand", State: domain.AvailableState}, nil)
 				m.On("Update", ctx, mock.Anything).Return(nil)
 			},
 		},
@@ -88,7 +96,8 @@ func TestServiceMethods(t *testing.T) {
 			},
 			expectedErr: &domain.Error{Type: "not_found", Status: http.StatusNotFound},
 			mockSetup: func(m *mocks.Repository, ctx context.Context) {
-				m.On("GetById", ctx, 1).Return((*domain.Device)(nil), sql.ErrNoRows)
+				inTx(m, ctx)
+				m.On("GetByIdForUpdate", ctx, 1).Return((*domain.Device)(nil), sql.ErrNoRows)
 			},
 		},
 		{
@@ -99,7 +108,8 @@ func TestServiceMethods(t *testing.T) {
 			},
 			expectedErr: &domain.Error{Type: "patch_error", Status: http.StatusForbidden},
 			mockSetup: func(m *mocks.Repository, ctx context.Context) {
-				m.On("GetById", ctx, 1).Return(&domain.Device{Id: 1, Name: "Old Name", Brand: "Old Brand", State: domain.InUseState}, nil)
+				inTx(m, ctx)
+				m.On("GetByIdForUpdate", ctx, 1).Return(&domain.Device{Id: 1, Name: "Old Name", Brand: "Old Brand", State: domain.InUseState}, nil)
 			},
 		},
 		{
@@ -110,7 +120,8 @@ func TestServiceMethods(t *testing.T) {
 			},
 			expectedErr: &domain.Error{Type: "patch_error", Status: http.StatusForbidden},
 			mockSetup: func(m *mocks.Repository, ctx context.Context) {
-				m.On("GetById", ctx, 1).Return(&domain.Device{Id: 1, Name: "Old Name", Brand: "Old Brand", State: domain.InUseState}, nil)
+				inTx(m, ctx)
+				m.On("GetByIdForUpdate", ctx, 1).Return(&domain.Device{Id: 1, Name: "Old Name", Brand: "Old Brand", State: domain.InUseState}, nil)
 			},
 		},
 		{
@@ -126,7 +137,8 @@ func TestServiceMethods(t *testing.T) {
 				State: domain.InactiveState,
 			},
 			mockSetup: func(m *mocks.Repository, ctx context.Context) {
-				m.On("GetById", ctx, 1).Return(&domain.Device{Id: 1, Name: "Old Name", Brand: "Old Brand", State: domain.InUseState}, nil)
+				inTx(m, ctx)
+				m.On("GetByIdForUpdate", ctx, 1).Return(&domain.Device{Id: 1, Name: "Old Name", Brand: "Old Brand", State: domain.InUseState}, nil)
 				m.On("Update", ctx, mock.Anything).Return(nil)
 			},
 		},
@@ -137,7 +149,8 @@ func TestServiceMethods(t *testing.T) {
 			},
 			expectedErr: &domain.Error{Type: "patch_error", Status: http.StatusInternalServerError},
 			mockSetup: func(m *mocks.Repository, ctx context.Context) {
-				m.On("GetById", ctx, 1).Return((*domain.Device)(nil), errors.New("database error"))
+				inTx(m, ctx)
+				m.On("GetByIdForUpdate", ctx, 1).Return((*domain.Device)(nil), errors.New("database error"))
 			},
 		},
 		{
@@ -146,12 +159,89 @@ func TestServiceMethods(t *testing.T) {
 				Id:    1,
 				State: ptr(domain.InactiveState),
 			},
-			expectedErr: &domain.Error{Type: "update_error", Status: http.StatusInternalServerError},
+			expectedErr: &domain.Error{Type: "patch_error", Status: http.StatusInternalServerError},
 			mockSetup: func(m *mocks.Repository, ctx context.Context) {
-				m.On("GetById", ctx, 1).Return(&domain.Device{Id: 1, Name: "Old Name", Brand: "Old Brand", State: domain.AvailableState}, nil)
+				inTx(m, ctx)
+				m.On("GetByIdForUpdate", ctx, 1).Return(&domain.Device{Id: 1, Name: "Old Name", Brand: "Old Brand", State: domain.AvailableState}, nil)
 				m.On("Update", ctx, mock.Anything).Return(errors.New("update error"))
 			},
 		},
+		{
+			name:     "Merge Patch Device - Success",
+			input:    &domain.MergePatch{Id: 1, Document: []byte(`{"name": "New Name", "state": "inactive"}`)},
+			expected: &domain.Device{Id: 1, Name: "New Name", Brand: "Old Brand", State: domain.InactiveState, CreationTime: created},
+			mockSetup: func(m *mocks.Repository, ctx context.Context) {
+				inTx(m, ctx)
+				m.On("GetByIdForUpdate", ctx, 1).Return(&domain.Device{Id: 1, Name: "Old Name", Brand: "Old Brand", State: domain.AvailableState, CreationTime: created}, nil)
+				m.On("Update", ctx, mock.Anything).Return(nil)
+			},
+		},
+		{
+			name:        "Merge Patch Device - Removed Member",
+			input:       &domain.MergePatch{Id: 1, Document: []byte(`{"brand": null}`)},
+			expectedErr: &domain.Error{Type: "patch_error", Status: http.StatusUnprocessableEntity},
+			mockSetup: func(m *mocks.Repository, ctx context.Context) {
+				inTx(m, ctx)
+				m.On("GetByIdForUpdate", ctx, 1).Return(&domain.Device{Id: 1, Name: "Old Name", Brand: "Old Brand", CreationTime: created}, nil)
+			},
+		},
+		{
+			name:        "Merge Patch Device - Creation Time",
+			input:       &domain.MergePatch{Id: 1, Document: []byte(`{"creation_time": "2020-01-01T00:00:00Z"}`)},
+			expectedErr: &domain.Error{Type: "update_error", Status: http.StatusForbidden},
+			mockSetup: func(m *mocks.Repository, ctx context.Context) {
+				inTx(m, ctx)
+				m.On("GetByIdForUpdate", ctx, 1).Return(&domain.Device{Id: 1, Name: "Old Name", Brand: "Old Brand", CreationTime: created}, nil)
+			},
+		},
+		{
+			name: "JSON Patch Device - Success",
+			input: &domain.JSONPatch{Id: 1, Operations: []byte(`[
+				{"op": "test", "path": "/state", "value": "in-use"},
+				{"op": "replace", "path": "/state", "value": "available"}
+			]`)},
+			expected: &domain.Device{Id: 1, Name: "Old Name", Brand: "Old Brand", State: domain.AvailableState, CreationTime: created},
+			mockSetup: func(m *mocks.Repository, ctx context.Context) {
+				inTx(m, ctx)
+				m.On("GetByIdForUpdate", ctx, 1).Return(&domain.Device{Id: 1, Name: "Old Name", Brand: "Old Brand", State: domain.InUseState, CreationTime: created}, nil)
+				m.On("Update", ctx, mock.Anything).Return(nil)
+			},
+		},
+		{
+			name: "JSON Patch Device - Failed Test",
+			input: &domain.JSONPatch{Id: 1, Operations: []byte(`[
+				{"op": "test", "path": "/state", "value": "available"},
+				{"op": "replace", "path": "/name", "value": "New Name"}
+			]`)},
+			expectedErr: &domain.Error{Type: "test_failed", Status: http.StatusConflict},
+			mockSetup: func(m *mocks.Repository, ctx context.Context) {
+				inTx(m, ctx)
+				m.On("GetByIdForUpdate", ctx, 1).Return(&domain.Device{Id: 1, Name: "Old Name", Brand: "Old Brand", State: domain.InUseState, CreationTime: created}, nil)
+			},
+		},
+		{
+			name:        "JSON Patch Device - In Use",
+			input:       &domain.JSONPatch{Id: 1, Operations: []byte(`[{"op": "replace", "path": "/brand", "value": "New Brand"}]`)},
+			expectedErr: &domain.Error{Type: "patch_error", Status: http.StatusForbidden},
+			mockSetup: func(m *mocks.Repository, ctx context.Context) {
+				inTx(m, ctx)
+				m.On("GetByIdForUpdate", ctx, 1).Return(&domain.Device{Id: 1, Name: "Old Name", Brand: "Old Brand", State: domain.InUseState, CreationTime: created}, nil)
+			},
+		},
+		{
+			name:        "JSON Patch Device - Unknown Member",
+			input:       &domain.JSONPatch{Id: 1, Operations: []byte(`[{"op": "add", "path": "/color", "value": "red"}]`)},
+			expectedErr: &domain.Error{Type: "patch_error", Status: http.StatusUnprocessableEntity},
+			mockSetup: func(m *mocks.Repository, ctx context.Context) {
+				inTx(m, ctx)
+				m.On("GetByIdForUpdate", ctx, 1).Return(&domain.Device{Id: 1, Name: "Old Name", Brand: "Old Brand", CreationTime: created}, nil)
+			},
+		},
+		{
+			name:        "JSON Patch Device - Invalid Operations",
+			input:       &domain.JSONPatch{Id: 1, Operations: []byte(`{"op": "replace"}`)},
+			expectedErr: &domain.Error{Type: "invalid_patch", Status: http.StatusBadRequest},
+		},
 		{
 			name:  "GetAll - Success",
 			input: nil,
@@ -243,7 +333,8 @@ func TestServiceMethods(t *testing.T) {
 			input:    &domain.Delete{Id: 1},
 			expected: nil,
 			mockSetup: func(m *mocks.Repository, ctx context.Context) {
-				m.On("GetById", ctx, 1).Return(&domain.Device{Id: 1, State: domain.AvailableState}, nil)
+				inTx(m, ctx)
+				m.On("GetByIdForUpdate", ctx, 1).Return(&domain.Device{Id: 1, State: domain.AvailableState}, nil)
 				m.On("Delete", ctx, 1).Return(nil)
 			},
 		},
@@ -252,7 +343,8 @@ func TestServiceMethods(t *testing.T) {
 			input:       &domain.Delete{Id: 999},
 			expectedErr: &domain.Error{Type: "not_found", Status: http.StatusNotFound},
 			mockSetup: func(m *mocks.Repository, ctx context.Context) {
-				m.On("GetById", ctx, 999).Return(nil, sql.ErrNoRows)
+				inTx(m, ctx)
+				m.On("GetByIdForUpdate", ctx, 999).Return(nil, sql.ErrNoRows)
 			},
 		},
 		{
@@ -260,7 +352,51 @@ func TestServiceMethods(t *testing.T) {
 			input:       &domain.Delete{Id: 2},
 			expectedErr: &domain.Error{Type: "delete_error", Status: http.StatusForbidden},
 			mockSetup: func(m *mocks.Repository, ctx context.Context) {
-				m.On("GetById", ctx, 2).Return(&domain.Device{Id: 2, State: domain.InUseState}, nil)
+				inTx(m, ctx)
+				m.On("GetByIdForUpdate", ctx, 2).Return(&domain.Device{Id: 2, State: domain.InUseState}, nil)
+			},
+		},
+		{
+			name:  "Device Stats - Success",
+			input: &domain.GetStats{Brand: "Samsung", GroupBy: []string{domain.ByState}},
+			expected: &domain.DeviceStats{Total: 3, GroupBy: []string{domain.ByState}, Groups: []domain.DeviceCount{
+				{State: ptr(domain.AvailableState), Count: 1}, {State: ptr(domain.InUseState), Count: 2}}},
+			mockSetup: func(m *mocks.Repository, ctx context.Context) {
+				m.On("Stats", ctx, &domain.CountDevices{Filter: domain.ListDevices{Brand: "Samsung"}, GroupBy: []string{domain.ByState}}).
+					Return([]domain.DeviceCount{{State: ptr(domain.AvailableState), Count: 1}, {State: ptr(domain.InUseState), Count: 2}}, nil)
+			},
+		},
+		{
+			name:        "Device Stats - Two Time Buckets",
+			input:       &domain.GetStats{GroupBy: []string{domain.ByDay, domain.ByMonth}},
+			expectedErr: &domain.Error{Type: "validate_error", Status: http.StatusBadRequest},
+		},
+		{
+			name:        "Device Stats - Failure",
+			input:       &domain.GetStats{},
+			expectedErr: &domain.Error{Type: "fetch_error", Status: http.StatusInternalServerError},
+			mockSetup: func(m *mocks.Repository, ctx context.Context) {
+				m.On("Stats", ctx, mock.Anything).Return(nil, errors.New("DB error"))
+			},
+		},
+		{
+			name:  "Search Devices - Suggests Brands Not In The Query",
+			input: &domain.SearchDevices{Q: "samsung galaxi"},
+			expected: &domain.DeviceSearch{Results: []domain.DeviceMatch{{Device: domain.Device{Id: 1}, Score: 1}},
+				Suggestions: []string{"Samsung Mobile"}},
+			mockSetup: func(m *mocks.Repository, ctx context.Context) {
+				m.On("Search", ctx, &domain.SearchDevices{Q: "samsung galaxi", Limit: 20}).Return(&domain.DeviceSearch{
+					Results:     []domain.DeviceMatch{{Device: domain.Device{Id: 1}, Score: 1}},
+					Suggestions: []string{"Samsung", "Samsung Mobile"},
+				}, nil)
+			},
+		},
+		{
+			name:        "Search Devices - Failure",
+			input:       &domain.SearchDevices{Q: "galaxy"},
+			expectedErr: &domain.Error{Type: "search_error", Status: http.StatusInternalServerError},
+			mockSetup: func(m *mocks.Repository, ctx context.Context) {
+				m.On("Search", ctx, mock.Anything).Return(nil, errors.New("DB error"))
 			},
 		},
 		{
@@ -268,7 +404,8 @@ func TestServiceMethods(t *testing.T) {
 			input:       &domain.Delete{Id: 3},
 			expectedErr: &domain.Error{Type: "delete_error", Status: http.StatusInternalServerError},
 			mockSetup: func(m *mocks.Repository, ctx context.Context) {
-				m.On("GetById", ctx, 3).Return(&domain.Device{Id: 3, State: domain.AvailableState}, nil)
+				inTx(m, ctx)
+				m.On("GetByIdForUpdate", ctx, 3).Return(&domain.Device{Id: 3, State: domain.AvailableState}, nil)
 				m.On("Delete", ctx, 3).Return(errors.New("DB error"))
 			},
 		},
@@ -277,7 +414,7 @@ func TestServiceMethods(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			mockRepo := new(mocks.Repository)
-			service := NewService(mockRepo)
+			service := device.NewService(mockRepo)
 			ctx := context.Background()
 
 			if tc.mockSetup != nil {
@@ -293,6 +430,10 @@ func TestServiceMethods(t *testing.T) {
 				result, err = service.Update(ctx, v)
 			case *domain.Patch:
 				result, err = service.Patch(ctx, v)
+			case *domain.MergePatch:
+				result, err = service.MergePatch(ctx, v)
+			case *domain.JSONPatch:
+				result, err = service.JSONPatch(ctx, v)
 			case *domain.GetById:
 				result, err = service.GetById(ctx, v)
 			case *domain.GetByState:
@@ -300,9 +441,13 @@ func TestServiceMethods(t *testing.T) {
 			case *domain.GetByBrand:
 				result, err = service.GetByBrand(ctx, v)
 			case nil:
-				result, err = service.GetAll(ctx, v)
+				result, err = service.GetAll(ctx)
+			case *domain.SearchDevices:
+				result, err = service.Search(ctx, v)
+			case *domain.GetStats:
+				result, err = service.Stats(ctx, v)
 			case *domain.Delete:
-				result, err = service.Delete(ctx, v)
+				err = service.Delete(ctx, v)
 			}
 
 			if tc.expectedErr != nil {
@@ -318,6 +463,148 @@ func TestServiceMethods(t *testing.T) {
 	}
 }
 
+func TestBrandResolution(t *testing.T) {
+	ctx := context.Background()
+	catalogue := catalogue{"apple": {Id: 3, Name: "Apple"}, "apple inc": {Id: 3, Name: "Apple"}}
+
+	t.Run("Stores Brands Of The Catalogue Under Their Name", func(t *testing.T) {
+		m := new(mocks.Repository)
+		m.On("Create", ctx, &domain.Device{Name: "iPhone", Brand: "Apple", BrandId: ptr(3)}).
+			Return(&domain.Device{Id: 1, Name: "iPhone", Brand: "Apple", BrandId: ptr(3)}, nil)
+
+		created, err := device.NewService(m, device.WithBrands(catalogue, true)).
+			Create(ctx, &domain.Device{Name: "iPhone", Brand: " APPLE"})
+		assert.NoError(t, err)
+		assert.Equal(t, "Apple", created.Brand)
+		m.AssertExpectations(t)
+	})
+
+	t.Run("Keeps Other Brands As Given", func(t *testing.T) {
+		m := new(mocks.Repository)
+		m.On("Create", ctx, &domain.Device{Name: "Pixel", Brand: "Google"}).
+			Return(&domain.Device{Id: 2, Name: "Pixel", Brand: "Google"}, nil)
+
+		_, err := device.NewService(m, device.WithBrands(catalogue, false)).
+			Create(ctx, &domain.Device{Name: "Pixel", Brand: "Google"})
+		assert.NoError(t, err)
+		m.AssertExpectations(t)
+	})
+
+	t.Run("Refuses Other Brands When Strict", func(t *testing.T) {
+		m := new(mocks.Repository)
+		service := device.NewService(m, device.WithBrands(catalogue, true))
+
+		_, err := service.Create(ctx, &domain.Device{Name: "Pixel", Brand: "Google"})
+		assert.Equal(t, &domain.Error{Type: "unknown_brand", Status: http.StatusUnprocessableEntity,
+			Detail: `brand "Google" is not in the catalogue`}, err)
+
+		_, err = service.Patch(ctx, &domain.Patch{Id: 1, Brand: ptr("Google")})
+		assert.Equal(t, "unknown_brand", err.(*domain.Error).Type)
+		m.AssertExpectations(t)
+	})
+
+	t.Run("Lets Devices In Use Be Written As Another Spelling", func(t *testing.T) {
+		m := new(mocks.Repository)
+		inTx(m, ctx)
+		m.On("GetByIdForUpdate", ctx, 1).
+			Return(&domain.Device{Id: 1, Name: "iPhone", Brand: "Apple", State: domain.InUseState, BrandId: ptr(3)}, nil)
+		m.On("Update", ctx, &domain.Device{Id: 1, Name: "iPhone", Brand: "Apple", State: domain.InUseState, BrandId: ptr(3)}).
+			Return(nil)
+
+		_, err := device.NewService(m, device.WithBrands(catalogue, true)).
+			Update(ctx, &domain.Update{Id: 1, Name: ptr("iPhone"), Brand: ptr("apple"), State: ptr(domain.InUseState)})
+		assert.NoError(t, err)
+		m.AssertExpectations(t)
+	})
+
+	t.Run("Lets Devices In Use Keep A Spelling From Before The Catalogue", func(t *testing.T) {
+		m := new(mocks.Repository)
+		inTx(m, ctx)
+		m.On("GetByIdForUpdate", ctx, 1).
+			Return(&domain.Device{Id: 1, Name: "iPhone", Brand: "apple", State: domain.InUseState}, nil).Twice()
+		m.On("Update", ctx, &domain.Device{Id: 1, Name: "iPhone", Brand: "Apple", State: domain.InUseState, BrandId: ptr(3)}).
+			Return(nil).Twice()
+		service := device.NewService(m, device.WithBrands(catalogue, true))
+
+		_, err := service.Update(ctx, &domain.Update{Id: 1, Name: ptr("iPhone"), Brand: ptr("apple"), State: ptr(domain.InUseState)})
+		assert.NoError(t, err)
+		_, err = service.Patch(ctx, &domain.Patch{Id: 1, Brand: ptr("apple")})
+		assert.NoError(t, err)
+		m.AssertExpectations(t)
+	})
+
+	t.Run("Lets Devices In Use Be Written As An Alias", func(t *testing.T) {
+		m := new(mocks.Repository)
+		inTx(m, ctx)
+		m.On("GetByIdForUpdate", ctx, 1).
+			Return(&domain.Device{Id: 1, Name: "iPhone", Brand: "Apple", State: domain.InUseState, BrandId: ptr(3)}, nil)
+		m.On("Update", ctx, &domain.Device{Id: 1, Name: "iPhone", Brand: "Apple", State: domain.InUseState, BrandId: ptr(3)}).
+			Return(nil)
+
+		_, err := device.NewService(m, device.WithBrands(catalogue, true)).
+			Patch(ctx, &domain.Patch{Id: 1, Brand: ptr("Apple Inc")})
+		assert.NoError(t, err)
+		m.AssertExpectations(t)
+	})
+
+	t.Run("Refuses Another Brand For Devices In Use", func(t *testing.T) {
+		m := new(mocks.Repository)
+		inTx(m, ctx)
+		m.On("GetByIdForUpdate", ctx, 1).
+			Return(&domain.Device{Id: 1, Name: "iPhone", Brand: "apple", State: domain.InUseState}, nil)
+
+		_, err := device.NewService(m, device.WithBrands(catalogue, false)).
+			Update(ctx, &domain.Update{Id: 1, Name: ptr("iPhone"), Brand: ptr("Google"), State: ptr(domain.InUseState)})
+		assert.Equal(t, http.StatusForbidden, err.(*domain.Error).Status)
+		m.AssertExpectations(t)
+	})
+}
+
+// catalogue - brands by their key
+type catalogue map[string]domain.Brand
+
+func (c catalogue) Resolve(_ context.Context, brand string) (*domain.Brand, error) {
+	resolved, ok := c[domain.BrandKey(brand)]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &resolved, nil
+}
+
+// inTx - runs the unit of work of the service against the mock itself
+func inTx(m *mocks.Repository, ctx context.Context) {
+	m.On("WithTx", ctx, mock.Anything).Return(func(_ context.Context, fn func(device.Repository) error) error {
+		return fn(m)
+	})
+}
+
 func ptr[T any](v T) *T {
 	return &v
 }
+
+func TestServiceEvents(t *testing.T) {
+	ctx := context.Background()
+	bus := event.NewBus(0)
+	_, events, cancel := bus.Subscribe(0, 8)
+	defer cancel()
+	service := device.NewService(device.NewMemoryRepository(bus))
+
+	created, err := service.Create(ctx, &domain.Device{Name: "Phone", Brand: "Apple"})
+	assert.NoError(t, err)
+	_, err = service.Patch(ctx, &domain.Patch{Id: created.Id, Name: ptr("Tablet")})
+	assert.NoError(t, err)
+	_, err = service.Patch(ctx, &domain.Patch{Id: created.Id, State: ptr(domain.InUseState)})
+	assert.NoError(t, err)
+	_, err = service.Patch(ctx, &domain.Patch{Id: created.Id, State: ptr(domain.AvailableState)})
+	assert.NoError(t, err)
+	err = service.Delete(ctx, &domain.Delete{Id: created.Id})
+	assert.NoError(t, err)
+	bus.Close()
+
+	var types []string
+	for e := range events {
+		types = append(types, e.Type)
+	}
+	assert.Equal(t, []string{domain.DeviceCreated, domain.DeviceUpdated, domain.DeviceUpdated, domain.DeviceStateChanged,
+		domain.DeviceUpdated, domain.DeviceStateChanged, domain.DeviceDeleted}, types)
+}
@@ -0,0 +1,142 @@
+// Package devicev2 - the device resource as v2 of the REST API represents it. Requests and results of the shared
+// device service are mapped to and from the DTOs here, while v1 is served by the domain types themselves
+package devicev2
+
+import (
+	"github.com/ivofreitas/device-api/internal/domain"
+	"time"
+)
+
+// Device - a device with the changes its state allows, and its labels. BrandId is the brand of the catalogue the
+// brand resolved to, null for brands outside of it
+type Device struct {
+	Id           int           `json:"id" xml:"id"`
+	Name         string        `json:"name" xml:"name"`
+	Brand        string        `json:"brand" xml:"brand"`
+	BrandId      *int          `json:"brand_id" xml:"brand_id,omitempty"`
+	State        State         `json:"state" xml:"state"`
+	Labels       domain.Labels `json:"labels" xml:"labels"`
+	CreationTime time.Time     `json:"creation_time" xml:"creation_time"`
+}
+
+// State - the state of a device and what it allows
+type State struct {
+	Value domain.State `json:"value" xml:"value"`
+	// Locked - fields that cannot change while the device is in the state
+	Locked []string `json:"locked" xml:"locked>field"`
+	// Deletable - whether the device can be deleted in the state
+	Deletable bool `json:"deletable" xml:"deletable"`
+}
+
+// DeviceList - a page of devices, always, with where it lies among the matches
+type DeviceList struct {
+	Items []Device `json:"items" xml:"items>device"`
+	Page  Page     `json:"page" xml:"page"`
+}
+
+type Page struct {
+	Limit  int `json:"limit" xml:"limit"`
+	Offset int `json:"offset" xml:"offset"`
+	// Total - devices matching the query across every page
+	Total int `json:"total" xml:"total"`
+	// NextOffset - offset of the next page, null on the last one
+	NextOffset *int `json:"next_offset" xml:"next_offset,omitempty"`
+}
+
+// Create - a new device. The state defaults to available
+type Create struct {
+	Name   string        `json:"name" xml:"name" validate:"required"`
+	Brand  string        `json:"brand" xml:"brand" validate:"required"`
+	State  domain.State  `json:"state" xml:"state"`
+	Labels domain.Labels `json:"labels,omitempty" xml:"labels,omitempty" validate:"max=64,dive,keys,min=1,max=63,endkeys,max=255"`
+}
+
+// Update - replaces a device, labels included, so leaving them out removes them
+type Update struct {
+	Id     int           `param:"id" validate:"required"`
+	Name   string        `json:"name" xml:"name" validate:"required"`
+	Brand  string        `json:"brand" xml:"brand" validate:"required"`
+	State  *domain.State `json:"state" xml:"state" validate:"required"`
+	Labels domain.Labels `json:"labels" xml:"labels" validate:"max=64,dive,keys,min=1,max=63,endkeys,max=255"`
+}
+
+// Patch - changes the fields given. Labels given replace all of them
+type Patch struct {
+	Id     int           `param:"id" validate:"required"`
+	Name   *string       `json:"name,omitempty" xml:"name,omitempty"`
+	Brand  *string       `json:"brand,omitempty" xml:"brand,omitempty"`
+	State  *domain.State `json:"state,omitempty" xml:"state,omitempty"`
+	Labels domain.Labels `json:"labels,omitempty" xml:"labels,omitempty" validate:"max=64,dive,keys,min=1,max=63,endkeys,max=255"`
+}
+
+// defaultLimit - page size of lists without a limit
+const defaultLimit = 50
+
+// fromDevice - in use, a device keeps its name and brand and cannot be deleted, as the service enforces
+func fromDevice(device *domain.Device) Device {
+	state := State{Value: device.State, Locked: []string{}, Deletable: true}
+	if device.State == domain.InUseState {
+		state.Locked, state.Deletable = []string{"name", "brand"}, false
+	}
+
+	labels := device.Labels
+	if labels == nil {
+		labels = domain.Labels{}
+	}
+
+	return Device{
+		Id:           device.Id,
+		Name:         device.Name,
+		Brand:        device.Brand,
+		BrandId:      device.BrandId,
+		State:        state,
+		Labels:       labels,
+		CreationTime: device.CreationTime,
+	}
+}
+
+func fromPage(page *domain.DevicePage, query *domain.DeviceQuery) *DeviceList {
+	list := &DeviceList{
+		Items: make([]Device, 0, len(page.Items)),
+		Page:  Page{Limit: query.Limit, Offset: query.Offset, Total: page.TotalCount},
+	}
+	for i := range page.Items {
+		list.Items = append(list.Items, fromDevice(&page.Items[i]))
+	}
+	if page.HasNextPage {
+		next := query.Offset + len(page.Items)
+		list.Page.NextOffset = &next
+	}
+	return list
+}
+
+func listDevices(query *domain.DeviceQuery) *domain.ListDevices {
+	return &domain.ListDevices{
+		Name:          query.Name,
+		Brand:         query.Brand,
+		States:        query.States,
+		CreatedAfter:  query.CreatedAfter,
+		CreatedBefore: query.CreatedBefore,
+		SortBy:        query.SortBy,
+		Desc:          query.Desc,
+		Limit:         query.Limit,
+		Offset:        query.Offset,
+	}
+}
+
+func (c *Create) device() *domain.Device {
+	return &domain.Device{Name: c.Name, Brand: c.Brand, State: c.State, Labels: c.Labels}
+}
+
+// update - labels left out are removed, while nil ones would be kept by the service
+func (u *Update) update() *domain.Update {
+	labels := u.Labels
+	if labels == nil {
+		labels = domain.Labels{}
+	}
+	return &domain.Update{Id: u.Id, Name: &u.Name, Brand: &u.Brand, State: u.State, Labels: labels}
+}
+
+func (p *Patch) patch() *domain.Patch {
+	return &domain.Patch{Id: p.Id, Name: p.Name, Brand: p.Brand, State: p.State, Labels: p.Labels}
+}
@@ -0,0 +1,78 @@
+package devicev2
+
+import (
+	"context"
+	"github.com/ivofreitas/device-api/internal/api/device"
+	"github.com/ivofreitas/device-api/internal/domain"
+)
+
+// Service - the device service in the DTOs of v2
+type Service struct {
+	service *device.Service
+}
+
+func NewService(service *device.Service) *Service {
+	return &Service{service}
+}
+
+// Create - adds a new device to the inventory. The state defaults to available
+func (s *Service) Create(ctx context.Context, create *Create) (*Device, error) {
+	createdDevice, err := s.service.Create(ctx, create.device())
+	if err != nil {
+		return nil, err
+	}
+
+	result := fromDevice(createdDevice)
+	return &result, nil
+}
+
+// Update - replaces the details and labels of a device, if allowed. Devices in use keep their name and brand
+func (s *Service) Update(ctx context.Context, update *Update) (*Device, error) {
+	updatedDevice, err := s.service.Update(ctx, update.update())
+	if err != nil {
+		return nil, err
+	}
+
+	result := fromDevice(updatedDevice)
+	return &result, nil
+}
+
+// Patch - only the fields given are modified, and labels given replace all of them
+func (s *Service) Patch(ctx context.Context, patch *Patch) (*Device, error) {
+	patchedDevice, err := s.service.Patch(ctx, patch.patch())
+	if err != nil {
+		return nil, err
+	}
+
+	result := fromDevice(patchedDevice)
+	return &result, nil
+}
+
+// List - retrieves a page of the devices, narrowed by the filters. Without a limit pages hold 50 devices
+func (s *Service) List(ctx context.Context, query *domain.DeviceQuery) (*DeviceList, error) {
+	if query.Limit == 0 {
+		query.Limit = defaultLimit
+	}
+
+	page, err := s.service.List(ctx, listDevices(query))
+	if err != nil {
+		return nil, err
+	}
+	return fromPage(page, query), nil
+}
+
+// GetById - retrieves a single device by its ID
+func (s *Service) GetById(ctx context.Context, idParam *domain.GetById) (*Device, error) {
+	foundDevice, err := s.service.GetById(ctx, idParam)
+	if err != nil {
+		return nil, err
+	}
+
+	result := fromDevice(foundDevice)
+	return &result, nil
+}
+
+// Delete - removes a device from the inventory, unless it is in use
+func (s *Service) Delete(ctx context.Context, deleteParam *domain.Delete) error {
+	return s.service.Delete(ctx, deleteParam)
+}
@@ -0,0 +1,116 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/ivofreitas/device-api/internal/adapter/event"
+	"github.com/ivofreitas/device-api/internal/api/device"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"strings"
+	"time"
+)
+
+// NewHandler - serves queries and mutations over GET and POST, and subscriptions over WebSocket and SSE.
+// Operations deeper than maxDepth or more complex than maxComplexity are rejected before they run, and
+// introspection is answered only while introspectable reports true
+func NewHandler(service *device.Service, bus *event.Bus, maxDepth, maxComplexity int, keepAlive time.Duration,
+	introspectable func() bool) *handler.Server {
+	cfg := Config{Resolvers: NewResolver(service, bus)}
+	cfg.Complexity.Query.Devices = func(childComplexity int, _ *DeviceFilter, _ *DeviceSort, limit int, _ int) int {
+		return 1 + childComplexity*limit
+	}
+
+	srv := handler.New(NewExecutableSchema(cfg))
+	srv.AddTransport(transport.Websocket{KeepAlivePingInterval: keepAlive})
+	srv.AddTransport(transport.SSE{})
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+
+	srv.Use(introspectionGate{enabled: introspectable})
+	srv.Use(depthLimit{max: maxDepth})
+	srv.Use(extension.FixedComplexityLimit(maxComplexity))
+	srv.SetErrorPresenter(presentError)
+
+	return srv
+}
+
+// presentError - service errors keep their type and status as extensions
+func presentError(ctx context.Context, err error) *gqlerror.Error {
+	presented := graphql.DefaultErrorPresenter(ctx, err)
+
+	var domainErr *domain.Error
+	if errors.As(err, &domainErr) {
+		presented.Message = domainErr.Detail
+		presented.Extensions = map[string]interface{}{"code": domainErr.Type, "status": domainErr.Status}
+	}
+	return presented
+}
+
+// introspectionGate - enables introspection per operation, following enabled
+type introspectionGate struct {
+	enabled func() bool
+}
+
+func (introspectionGate) ExtensionName() string {
+	return "Introspection"
+}
+
+func (introspectionGate) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (i introspectionGate) MutateOperationContext(_ context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	rc.DisableIntrospection = !i.enabled()
+	return nil
+}
+
+// depthLimit - rejects operations nesting fields deeper than max. Introspection fields are not counted
+type depthLimit struct {
+	max int
+}
+
+func (depthLimit) ExtensionName() string {
+	return "DepthLimit"
+}
+
+func (depthLimit) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (d depthLimit) MutateOperationContext(_ context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	if depth := selectionDepth(rc.Operation.SelectionSet); depth > d.max {
+		err := gqlerror.Errorf("operation has depth %d, which exceeds the limit of %d", depth, d.max)
+		err.Extensions = map[string]interface{}{"code": "DEPTH_LIMIT_EXCEEDED"}
+		return err
+	}
+	return nil
+}
+
+func selectionDepth(set ast.SelectionSet) int {
+	depth := 0
+	for _, selection := range set {
+		var current int
+		switch s := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name, "__") {
+				continue
+			}
+			current = 1 + selectionDepth(s.SelectionSet)
+		case *ast.InlineFragment:
+			current = selectionDepth(s.SelectionSet)
+		case *ast.FragmentSpread:
+			if s.Definition != nil {
+				current = selectionDepth(s.Definition.SelectionSet)
+			}
+		}
+		depth = max(depth, current)
+	}
+	return depth
+}
@@ -0,0 +1,142 @@
+package graphql
+
+import (
+	"context"
+	"database/sql"
+	"github.com/99designs/gqlgen/client"
+	"github.com/ivofreitas/device-api/internal/adapter/event"
+	"github.com/ivofreitas/device-api/internal/api/device"
+	mocks "github.com/ivofreitas/device-api/internal/api/device/mock"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newTestClient(repository device.Repository, bus *event.Bus, maxDepth, maxComplexity int) *client.Client {
+	return client.New(NewHandler(device.NewService(repository), bus, maxDepth, maxComplexity, time.Minute,
+		func() bool { return true }))
+}
+
+func TestHandler(t *testing.T) {
+	t.Run("Devices With Filter, Sort And Pagination", func(t *testing.T) {
+		mockRepo := new(mocks.Repository)
+		mockRepo.On("List", mock.Anything, &domain.ListDevices{
+			Brand:  "Apple",
+			States: []domain.State{domain.AvailableState, domain.InUseState},
+			SortBy: "creation_time",
+			Desc:   true,
+			Limit:  1,
+			Offset: 0,
+		}).Return(&domain.DevicePage{Items: []domain.Device{{Id: 7, Name: "Phone", State: domain.InUseState}}, TotalCount: 2}, nil)
+		c := newTestClient(mockRepo, event.NewBus(1), 8, 1000)
+
+		var res struct {
+			Devices struct {
+				Items       []map[string]interface{}
+				TotalCount  int
+				HasNextPage bool
+			}
+		}
+		err := c.Post(`{
+			devices(filter: {brand: "Apple", states: [AVAILABLE, IN_USE]}, sort: {field: CREATION_TIME, direction: DESC}, limit: 1) {
+				items { id state }
+				totalCount
+				hasNextPage
+			}
+		}`, &res)
+		require.NoError(t, err)
+		assert.Equal(t, []map[string]interface{}{{"id": "7", "state": "IN_USE"}}, res.Devices.Items)
+		assert.Equal(t, 2, res.Devices.TotalCount)
+		assert.True(t, res.Devices.HasNextPage)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Missing Device Is Null", func(t *testing.T) {
+		mockRepo := new(mocks.Repository)
+		mockRepo.On("GetById", mock.Anything, 9).Return(nil, sql.ErrNoRows)
+		c := newTestClient(mockRepo, event.NewBus(1), 8, 1000)
+
+		var res struct{ Device *struct{ Id string } }
+		require.NoError(t, c.Post(`{ device(id: 9) { id } }`, &res))
+		assert.Nil(t, res.Device)
+	})
+
+	t.Run("Mutation Errors Carry The Service Error Type", func(t *testing.T) {
+		mockRepo := new(mocks.Repository)
+		mockRepo.On("WithTx", mock.Anything, mock.Anything).Return(func(_ context.Context, fn func(device.Repository) error) error {
+			return fn(mockRepo)
+		})
+		mockRepo.On("GetByIdForUpdate", mock.Anything, 3).Return(&domain.Device{Id: 3, State: domain.InUseState}, nil)
+		c := newTestClient(mockRepo, event.NewBus(1), 8, 1000)
+
+		res, err := c.RawPost(`mutation { deleteDevice(id: 3) }`)
+		require.NoError(t, err)
+		assert.JSONEq(t, `[{
+			"message": "cannot delete a device that is in use",
+			"path": ["deleteDevice"],
+			"extensions": {"code": "delete_error", "status": 403}
+		}]`, string(res.Errors))
+	})
+
+	t.Run("Rejects Operations Over The Depth Limit", func(t *testing.T) {
+		c := newTestClient(new(mocks.Repository), event.NewBus(1), 2, 1000)
+
+		res, err := c.RawPost(`query { ...page } fragment page on Query { devices { items { id } } }`)
+		require.NoError(t, err)
+		assert.Contains(t, string(res.Errors), "operation has depth 3, which exceeds the limit of 2")
+
+		res, err = c.RawPost(`{ __schema { types { fields { type { name } } } } }`)
+		require.NoError(t, err)
+		assert.Nil(t, res.Errors)
+	})
+
+	t.Run("Answers Introspection Only While Enabled", func(t *testing.T) {
+		enabled := false
+		c := client.New(NewHandler(device.NewService(new(mocks.Repository)), event.NewBus(1), 8, 1000, time.Minute,
+			func() bool { return enabled }))
+
+		res, err := c.RawPost(`{ __schema { queryType { name } } }`)
+		require.NoError(t, err)
+		assert.Contains(t, string(res.Errors), "introspection disabled")
+
+		enabled = true
+		res, err = c.RawPost(`{ __schema { queryType { name } } }`)
+		require.NoError(t, err)
+		assert.Nil(t, res.Errors)
+	})
+
+	t.Run("Rejects Operations Over The Complexity Limit", func(t *testing.T) {
+		c := newTestClient(new(mocks.Repository), event.NewBus(1), 8, 1000)
+
+		res, err := c.RawPost(`{ devices(limit: 500) { items { id name brand } } }`)
+		require.NoError(t, err)
+		assert.Contains(t, string(res.Errors), "COMPLEXITY_LIMIT_EXCEEDED")
+	})
+
+	t.Run("Device Changed Replays Filtered Events", func(t *testing.T) {
+		bus := event.NewBus(10)
+		ctx := context.Background()
+		require.NoError(t, bus.Publish(ctx, domain.Event{Type: domain.DeviceCreated, Device: domain.Device{Id: 1, Brand: "Apple"}}))
+		require.NoError(t, bus.Publish(ctx, domain.Event{Type: domain.DeviceCreated, Device: domain.Device{Id: 2, Brand: "Google"}}))
+		require.NoError(t, bus.Publish(ctx, domain.Event{Type: domain.DeviceUpdated, Device: domain.Device{Id: 1, Brand: "Apple"}}))
+		c := newTestClient(new(mocks.Repository), bus, 8, 1000)
+
+		sub := c.Websocket(`subscription { deviceChanged(filter: {brand: "Apple"}, lastEventId: 1) { id type device { id } } }`)
+		defer sub.Close()
+
+		var res struct {
+			DeviceChanged struct {
+				Id     string
+				Type   string
+				Device struct{ Id string }
+			}
+		}
+		require.NoError(t, sub.Next(&res))
+		assert.Equal(t, "3", res.DeviceChanged.Id)
+		assert.Equal(t, domain.DeviceUpdated, res.DeviceChanged.Type)
+		assert.Equal(t, "1", res.DeviceChanged.Device.Id)
+	})
+}
@@ -0,0 +1,150 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package graphql
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/ivofreitas/device-api/internal/domain"
+)
+
+type CreateDeviceInput struct {
+	Name  string       `json:"name"`
+	Brand string       `json:"brand"`
+	State domain.State `json:"state"`
+}
+
+type DeviceEventFilter struct {
+	ID    *int          `json:"id,omitempty"`
+	Brand *string       `json:"brand,omitempty"`
+	State *domain.State `json:"state,omitempty"`
+}
+
+// Conditions are combined with AND; an empty filter selects every device.
+type DeviceFilter struct {
+	Ids []int `json:"ids,omitempty"`
+	// Case-insensitive substring of the name.
+	Name          *string        `json:"name,omitempty"`
+	Brand         *string        `json:"brand,omitempty"`
+	States        []domain.State `json:"states,omitempty"`
+	CreatedAfter  *time.Time     `json:"createdAfter,omitempty"`
+	CreatedBefore *time.Time     `json:"createdBefore,omitempty"`
+}
+
+type DeviceSort struct {
+	Field     DeviceSortField `json:"field"`
+	Direction SortDirection   `json:"direction"`
+}
+
+type Mutation struct {
+}
+
+// Only the fields that are set are modified.
+type PatchDeviceInput struct {
+	Name  *string       `json:"name,omitempty"`
+	Brand *string       `json:"brand,omitempty"`
+	State *domain.State `json:"state,omitempty"`
+}
+
+type Query struct {
+}
+
+type Subscription struct {
+}
+
+type UpdateDeviceInput struct {
+	Name  string       `json:"name"`
+	Brand string       `json:"brand"`
+	State domain.State `json:"state"`
+}
+
+type DeviceSortField string
+
+const (
+	DeviceSortFieldID           DeviceSortField = "ID"
+	DeviceSortFieldName         DeviceSortField = "NAME"
+	DeviceSortFieldBrand        DeviceSortField = "BRAND"
+	DeviceSortFieldState        DeviceSortField = "STATE"
+	DeviceSortFieldCreationTime DeviceSortField = "CREATION_TIME"
+)
+
+var AllDeviceSortField = []DeviceSortField{
+	DeviceSortFieldID,
+	DeviceSortFieldName,
+	DeviceSortFieldBrand,
+	DeviceSortFieldState,
+	DeviceSortFieldCreationTime,
+}
+
+func (e DeviceSortField) IsValid() bool {
+	switch e {
+	case DeviceSortFieldID, DeviceSortFieldName, DeviceSortFieldBrand, DeviceSortFieldState, DeviceSortFieldCreationTime:
+		return true
+	}
+	return false
+}
+
+func (e DeviceSortField) String() string {
+	return string(e)
+}
+
+func (e *DeviceSortField) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = DeviceSortField(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid DeviceSortField", str)
+	}
+	return nil
+}
+
+func (e DeviceSortField) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type SortDirection string
+
+const (
+	SortDirectionAsc  SortDirection = "ASC"
+	SortDirectionDesc SortDirection = "DESC"
+)
+
+var AllSortDirection = []SortDirection{
+	SortDirectionAsc,
+	SortDirectionDesc,
+}
+
+func (e SortDirection) IsValid() bool {
+	switch e {
+	case SortDirectionAsc, SortDirectionDesc:
+		return true
+	}
+	return false
+}
+
+func (e SortDirection) String() string {
+	return string(e)
+}
+
+func (e *SortDirection) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SortDirection(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SortDirection", str)
+	}
+	return nil
+}
+
+func (e SortDirection) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
@@ -0,0 +1,27 @@
+package graphql
+
+import (
+	"github.com/ivofreitas/device-api/internal/adapter/event"
+	"github.com/ivofreitas/device-api/internal/api/device"
+)
+
+// subscriberBuffer - live events a subscription may lag behind before it is ended
+const subscriberBuffer = 64
+
+// Resolver - resolves the device schema with the same business logic as the REST and gRPC APIs
+type Resolver struct {
+	service *device.Service
+	bus     *event.Bus
+}
+
+func NewResolver(service *device.Service, bus *event.Bus) *Resolver {
+	return &Resolver{service: service, bus: bus}
+}
+
+func valueOf[T any](v *T) T {
+	if v == nil {
+		var zero T
+		return zero
+	}
+	return *v
+}
@@ -0,0 +1,137 @@
+package graphql
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.55
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/ivofreitas/device-api/internal/api/middleware"
+	"github.com/ivofreitas/device-api/internal/domain"
+)
+
+// ID is the resolver for the id field.
+func (r *deviceEventResolver) ID(ctx context.Context, obj *domain.Event) (int, error) {
+	return int(obj.Id), nil
+}
+
+// CreateDevice is the resolver for the createDevice field.
+func (r *mutationResolver) CreateDevice(ctx context.Context, input CreateDeviceInput) (*domain.Device, error) {
+	return middleware.Call(ctx, r.service.Create, &domain.Device{Name: input.Name, Brand: input.Brand, State: input.State})
+}
+
+// UpdateDevice is the resolver for the updateDevice field.
+func (r *mutationResolver) UpdateDevice(ctx context.Context, id int, input UpdateDeviceInput) (*domain.Device, error) {
+	return middleware.Call(ctx, r.service.Update, &domain.Update{Id: id, Name: &input.Name, Brand: &input.Brand, State: &input.State})
+}
+
+// PatchDevice is the resolver for the patchDevice field.
+func (r *mutationResolver) PatchDevice(ctx context.Context, id int, input PatchDeviceInput) (*domain.Device, error) {
+	return middleware.Call(ctx, r.service.Patch, &domain.Patch{Id: id, Name: input.Name, Brand: input.Brand, State: input.State})
+}
+
+// DeleteDevice is the resolver for the deleteDevice field.
+func (r *mutationResolver) DeleteDevice(ctx context.Context, id int) (bool, error) {
+	if _, err := middleware.Call(ctx, middleware.NoContent(r.service.Delete), &domain.Delete{Id: id}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Device is the resolver for the device field.
+func (r *queryResolver) Device(ctx context.Context, id int) (*domain.Device, error) {
+	device, err := middleware.Call(ctx, r.service.GetById, &domain.GetById{Id: id})
+	var domainErr *domain.Error
+	if errors.As(err, &domainErr) && domainErr.Status == http.StatusNotFound {
+		return nil, nil
+	}
+	return device, err
+}
+
+// Devices is the resolver for the devices field.
+func (r *queryResolver) Devices(ctx context.Context, filter *DeviceFilter, sort *DeviceSort, limit int, offset int) (*domain.DevicePage, error) {
+	param := &domain.ListDevices{Limit: limit, Offset: offset}
+	if filter != nil {
+		param.Ids = filter.Ids
+		param.Name = valueOf(filter.Name)
+		param.Brand = valueOf(filter.Brand)
+		param.States = filter.States
+		param.CreatedAfter = filter.CreatedAfter
+		param.CreatedBefore = filter.CreatedBefore
+	}
+	if sort != nil {
+		param.SortBy = strings.ToLower(sort.Field.String())
+		param.Desc = sort.Direction == SortDirectionDesc
+	}
+
+	return middleware.Call(ctx, r.service.List, param)
+}
+
+// DeviceChanged is the resolver for the deviceChanged field.
+func (r *subscriptionResolver) DeviceChanged(ctx context.Context, filter *DeviceEventFilter, lastEventID *int) (<-chan *domain.Event, error) {
+	eventFilter := &domain.EventFilter{LastEventId: uint64(valueOf(lastEventID))}
+	if filter != nil {
+		eventFilter.Id = valueOf(filter.ID)
+		eventFilter.Brand = valueOf(filter.Brand)
+		eventFilter.State = filter.State
+	}
+
+	replay, events, cancel := r.bus.Subscribe(eventFilter.LastEventId, subscriberBuffer)
+
+	out := make(chan *domain.Event)
+	go func() {
+		defer close(out)
+		defer cancel()
+
+		send := func(e domain.Event) bool {
+			if !eventFilter.Matches(e) {
+				return true
+			}
+			select {
+			case out <- &e:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for _, e := range replay {
+			if !send(e) {
+				return
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-events:
+				if !ok || !send(e) {
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// DeviceEvent returns DeviceEventResolver implementation.
+func (r *Resolver) DeviceEvent() DeviceEventResolver { return &deviceEventResolver{r} }
+
+// Mutation returns MutationResolver implementation.
+func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
+type deviceEventResolver struct{ *Resolver }
+type mutationResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
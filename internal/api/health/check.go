@@ -0,0 +1,39 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/ivofreitas/device-api/config/db"
+)
+
+// Check - named dependency probe executed on every readiness request
+type Check struct {
+	Name string
+	Fn   func(ctx context.Context) error
+}
+
+// Ping - checks that the database accepts connections
+func Ping(conn *sql.DB) Check {
+	return Check{
+		Name: "database",
+		Fn:   conn.PingContext,
+	}
+}
+
+// Migrations - checks that the database schema is at the version this binary expects
+func Migrations(conn *sql.DB) Check {
+	return Check{
+		Name: "migrations",
+		Fn: func(ctx context.Context) error {
+			applied, latest, err := db.Version(ctx, conn)
+			if err != nil {
+				return err
+			}
+			if applied < latest {
+				return fmt.Errorf("database at version %d, expected %d", applied, latest)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,79 @@
+package health
+
+import (
+	"context"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Handler struct {
+	checks   []Check
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+func NewHandler(timeout time.Duration, checks ...Check) *Handler {
+	return &Handler{checks: checks, timeout: timeout}
+}
+
+// Drain - makes readiness fail so load balancers stop routing traffic to the instance
+func (h *Handler) Drain() {
+	h.draining.Store(true)
+}
+
+// Liveness - reports that the process is up and serving requests
+func (h *Handler) Liveness(c echo.Context) error {
+	return c.JSON(http.StatusOK, &domain.Health{Status: domain.HealthStatusOK})
+}
+
+// Readiness - reports whether the instance can serve traffic, with the result of every dependency check
+func (h *Handler) Readiness(c echo.Context) error {
+	if h.draining.Load() {
+		return c.JSON(http.StatusServiceUnavailable, &domain.Health{Status: domain.HealthStatusDraining})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), h.timeout)
+	defer cancel()
+
+	result := &domain.Health{Status: domain.HealthStatusOK, Checks: make(map[string]domain.HealthCheck, len(h.checks))}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, check := range h.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check.Fn(ctx)
+			status := domain.HealthCheck{
+				Status:  domain.HealthStatusOK,
+				Latency: float64(time.Since(start)/time.Millisecond) / 1000,
+			}
+			if err != nil {
+				status.Status = domain.HealthStatusFail
+				status.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.Checks[check.Name] = status
+			if err != nil {
+				result.Status = domain.HealthStatusFail
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	if result.Status != domain.HealthStatusOK {
+		return c.JSON(http.StatusServiceUnavailable, result)
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
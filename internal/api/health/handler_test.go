@@ -0,0 +1,87 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadiness(t *testing.T) {
+	okCheck := Check{Name: "database", Fn: func(ctx context.Context) error { return nil }}
+	failCheck := Check{Name: "migrations", Fn: func(ctx context.Context) error { return errors.New("database at version 1, expected 2") }}
+	slowCheck := Check{Name: "database", Fn: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+
+	testCases := []struct {
+		name           string
+		checks         []Check
+		drain          bool
+		expectedStatus int
+		expected       *domain.Health
+	}{
+		{
+			name:           "All Checks Pass",
+			checks:         []Check{okCheck},
+			expectedStatus: http.StatusOK,
+			expected: &domain.Health{Status: domain.HealthStatusOK, Checks: map[string]domain.HealthCheck{
+				"database": {Status: domain.HealthStatusOK},
+			}},
+		},
+		{
+			name:           "Failing Check",
+			checks:         []Check{okCheck, failCheck},
+			expectedStatus: http.StatusServiceUnavailable,
+			expected: &domain.Health{Status: domain.HealthStatusFail, Checks: map[string]domain.HealthCheck{
+				"database":   {Status: domain.HealthStatusOK},
+				"migrations": {Status: domain.HealthStatusFail, Error: "database at version 1, expected 2"},
+			}},
+		},
+		{
+			name:           "Check Timeout",
+			checks:         []Check{slowCheck},
+			expectedStatus: http.StatusServiceUnavailable,
+			expected: &domain.Health{Status: domain.HealthStatusFail, Checks: map[string]domain.HealthCheck{
+				"database": {Status: domain.HealthStatusFail, Error: context.DeadlineExceeded.Error()},
+			}},
+		},
+		{
+			name:           "Draining",
+			checks:         []Check{okCheck},
+			drain:          true,
+			expectedStatus: http.StatusServiceUnavailable,
+			expected:       &domain.Health{Status: domain.HealthStatusDraining},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := NewHandler(10*time.Millisecond, tc.checks...)
+			if tc.drain {
+				handler.Drain()
+			}
+
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/readyz", nil), rec)
+
+			assert.NoError(t, handler.Readiness(c))
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+
+			var result domain.Health
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+			for name, check := range result.Checks {
+				check.Latency = 0
+				result.Checks[name] = check
+			}
+			assert.Equal(t, tc.expected, &result)
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"github.com/ivofreitas/device-api/internal/adapter/codec"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+)
+
+// Binder - binds path and query parameters like echo.DefaultBinder, and decodes the body with the codec of its Content-Type
+type Binder struct {
+	echo.DefaultBinder
+}
+
+func (b *Binder) Bind(i interface{}, c echo.Context) error {
+	if err := b.BindPathParams(c, i); err != nil {
+		return err
+	}
+
+	method := c.Request().Method
+	if method == http.MethodGet || method == http.MethodDelete || method == http.MethodHead {
+		if err := b.BindQueryParams(c, i); err != nil {
+			return err
+		}
+	}
+	return b.BindBody(c, i)
+}
+
+func (b *Binder) BindBody(c echo.Context, i interface{}) error {
+	req := c.Request()
+	if req.ContentLength == 0 {
+		return nil
+	}
+
+	decoder, ok := codec.ForContentType(req.Header.Get(echo.HeaderContentType))
+	if !ok {
+		return &domain.Error{
+			Type:   "unsupported_media_type",
+			Status: http.StatusUnsupportedMediaType,
+			Detail: "supported media types: " + strings.Join(codec.MediaTypes(), ", "),
+		}
+	}
+	return decoder.Decode(req.Body, i)
+}
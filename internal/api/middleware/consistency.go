@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"github.com/ivofreitas/device-api/config/db"
+	"github.com/labstack/echo/v4"
+)
+
+// ReadYourWrites - scopes a primary pin to the request, so reads issued after a write go to the primary. Later
+// requests of the same client are not pinned and may read from a lagging replica
+func ReadYourWrites(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		c.SetRequest(c.Request().WithContext(db.WithPin(c.Request().Context())))
+		return next(c)
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+	"mime"
+	"sort"
+)
+
+type byContentType struct {
+	fallback Handler
+	handlers map[string]Handler
+}
+
+// ByContentType - routes a request to the handler registered for the media type of its body, or to fallback
+func ByContentType(fallback Handler, handlers map[string]Handler) Handler {
+	return &byContentType{fallback, handlers}
+}
+
+func (h *byContentType) Handle(c echo.Context) error {
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if handler, ok := h.handlers[mediaType]; ok {
+		return handler.Handle(c)
+	}
+	return h.fallback.Handle(c)
+}
+
+// Metadata - the one of fallback, with a variant per media type
+func (h *byContentType) Metadata() Metadata {
+	mediaTypes := make([]string, 0, len(h.handlers))
+	for mediaType := range h.handlers {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	sort.Strings(mediaTypes)
+
+	metadata := h.fallback.Metadata()
+	for _, mediaType := range mediaTypes {
+		variant := h.handlers[mediaType].Metadata()
+		variant.MediaType = mediaType
+		metadata.Variants = append(metadata.Variants, variant)
+	}
+	return metadata
+}
@@ -5,75 +5,136 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/go-playground/validator/v10"
+	"github.com/ivofreitas/device-api/internal/adapter/codec"
 	"github.com/ivofreitas/device-api/internal/adapter/context"
 	"github.com/ivofreitas/device-api/internal/adapter/log"
 	"github.com/ivofreitas/device-api/internal/domain"
 	"github.com/labstack/echo/v4"
 	"net/http"
 	"reflect"
+	"strings"
 )
 
-type ServiceFn func(ctx gocontext.Context, param interface{}) (interface{}, error)
+// Func - a service method handling requests of type Req. Resp is the response body, usually a pointer or a slice
+type Func[Req, Resp any] func(ctx gocontext.Context, req *Req) (Resp, error)
 
-type Handler struct {
-	fn         ServiceFn
-	param      interface{}
+// Handler - the echo handler of a route, which also describes the route for the API docs
+type Handler interface {
+	Handle(c echo.Context) error
+	Metadata() Metadata
+}
+
+var (
+	binder   = new(Binder)
+	validate = validator.New()
+)
+
+type handler[Req, Resp any] struct {
+	fn         Func[Req, Resp]
 	httpStatus int
-	echo.Binder
-	*validator.Validate
+	// bound - whether Req has fields to bind and validate
+	bound bool
 }
 
-func NewHandler(fn ServiceFn, httpStatus int, param interface{}) *Handler {
-	return &Handler{fn, param, httpStatus, new(echo.DefaultBinder), validator.New()}
+// NewHandler - a handler binding and validating a new Req for every request, and answering with the result of fn
+// and httpStatus. Results of an empty type, like those of NoContent, are answered without a body
+func NewHandler[Req, Resp any](fn Func[Req, Resp], httpStatus int) Handler {
+	return &handler[Req, Resp]{fn: fn, httpStatus: httpStatus, bound: reflect.TypeFor[Req]().Size() > 0}
 }
 
-// Handle - Request's entry point - bind, validate and call internal business logic
-func (ctrl *Handler) Handle(c echo.Context) error {
+// NoContent - adapts a service method without a result
+func NoContent[Req any](fn func(ctx gocontext.Context, req *Req) error) Func[Req, struct{}] {
+	return func(ctx gocontext.Context, req *Req) (struct{}, error) {
+		return struct{}{}, fn(ctx, req)
+	}
+}
+
+// NoRequest - adapts a service method without parameters
+func NoRequest[Resp any](fn func(ctx gocontext.Context) (Resp, error)) Func[struct{}, Resp] {
+	return func(ctx gocontext.Context, _ *struct{}) (Resp, error) {
+		return fn(ctx)
+	}
+}
+
+// Call - validates req with the same rules as the REST handlers and calls fn, for the APIs that bind their own requests
+func Call[Req, Resp any](ctx gocontext.Context, fn Func[Req, Resp], req *Req) (Resp, error) {
+	if err := validateRequest(req); err != nil {
+		var zero Resp
+		return zero, err
+	}
+	return fn(ctx, req)
+}
+
+// Handle - Request's entry point - negotiate the response codec, bind, validate and call internal business logic
+func (ctrl *handler[Req, Resp]) Handle(c echo.Context) error {
 
 	ctx := c.Request().Context()
 	httpLog := context.Get(ctx, log.HTTPKey).(*log.HTTP)
 
-	if ctrl.param != nil {
-		ctrl.param = reflect.New(reflect.TypeOf(ctrl.param).Elem()).Interface()
-		if err := ctrl.bind(c); err != nil {
+	varyAccept(c.Response().Header())
+	encoder, ok := codec.Negotiate(c.Request().Header.Get(echo.HeaderAccept))
+	if !ok {
+		responseErr := &domain.Error{
+			Type:   "not_acceptable",
+			Status: http.StatusNotAcceptable,
+			Detail: "supported media types: " + strings.Join(codec.MediaTypes(), ", "),
+		}
+		httpLog.Error = responseErr.Error()
+		return encode(c, codec.JSON, responseErr.Status, responseErr)
+	}
+
+	req := new(Req)
+	if ctrl.bound {
+		if err := bind(c, req); err != nil {
 			var responseErr *domain.Error
 			errors.As(err, &responseErr)
 			httpLog.Error = responseErr.Error()
-			return c.JSON(http.StatusBadRequest, responseErr)
+			return encode(c, encoder, responseErr.Status, responseErr)
 		}
 
-		if err := ctrl.validate(); err != nil {
+		if err := validateRequest(req); err != nil {
 			var responseErr *domain.Error
 			errors.As(err, &responseErr)
 			httpLog.Error = responseErr.Error()
-			return c.JSON(http.StatusBadRequest, responseErr)
+			return encode(c, encoder, http.StatusBadRequest, responseErr)
 		}
 
-		b, _ := json.Marshal(ctrl.param)
+		b, _ := json.Marshal(req)
 		httpLog.Request.Param = string(b)
 	}
 
-	result, err := ctrl.fn(ctx, ctrl.param)
+	result, err := ctrl.fn(ctx, req)
 	if err != nil {
 		var responseErr *domain.Error
 		if errors.As(err, &responseErr) {
-			return c.JSON(responseErr.Status, responseErr)
+			return encode(c, encoder, responseErr.Status, responseErr)
 		}
 
 		httpLog.Error = err.Error()
-		return c.JSON(http.StatusInternalServerError, err)
+		return encode(c, encoder, http.StatusInternalServerError, err)
 	}
 
-	if result != nil {
-		httpLog.Response.Body = result
-		return c.JSON(ctrl.httpStatus, result)
+	if reflect.TypeFor[Resp]().Size() == 0 {
+		return c.NoContent(ctrl.httpStatus)
 	}
 
-	return c.JSON(ctrl.httpStatus, nil)
+	httpLog.Response.Body = result
+	return encode(c, encoder, ctrl.httpStatus, result)
 }
 
-func (ctrl *Handler) bind(c echo.Context) error {
-	if err := ctrl.Bind(ctrl.param, c); err != nil {
+func encode(c echo.Context, encoder codec.Codec, status int, v interface{}) error {
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, encoder.ContentType())
+	res.WriteHeader(status)
+	return encoder.Encode(res, v)
+}
+
+func bind(c echo.Context, req interface{}) error {
+	if err := binder.Bind(req, c); err != nil {
+		var responseErr *domain.Error
+		if errors.As(err, &responseErr) {
+			return responseErr
+		}
 		return &domain.Error{
 			Type:   "bind_error",
 			Status: http.StatusBadRequest,
@@ -83,8 +144,8 @@ func (ctrl *Handler) bind(c echo.Context) error {
 	return nil
 }
 
-func (ctrl *Handler) validate() error {
-	if err := ctrl.Struct(ctrl.param); err != nil {
+func validateRequest(req interface{}) error {
+	if err := validate.Struct(req); err != nil {
 		return &domain.Error{
 			Type:   "validate_error",
 			Status: http.StatusBadRequest,
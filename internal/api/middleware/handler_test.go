@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	gocontext "context"
+	"fmt"
+	"github.com/ivofreitas/device-api/internal/adapter/log"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func serve(handler Handler, method, body string, header map[string]string) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.Add(method, "/devices/:id", handler.Handle)
+
+	req := httptest.NewRequest(method, "/devices/7", strings.NewReader(body))
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	req = req.WithContext(log.InitParams(req.Context()))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler(t *testing.T) {
+	echoDevice := func(_ gocontext.Context, update *domain.Update) (*domain.Device, error) {
+		return &domain.Device{Id: update.Id, Name: *update.Name, Brand: *update.Brand, State: *update.State}, nil
+	}
+
+	t.Run("Decodes XML And Encodes MessagePack", func(t *testing.T) {
+		rec := serve(NewHandler(echoDevice, http.StatusOK), http.MethodPut,
+			`<Update><name>Phone</name><brand>Apple</brand><state>inactive</state></Update>`,
+			map[string]string{echo.HeaderContentType: echo.MIMEApplicationXML, echo.HeaderAccept: "application/msgpack"})
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/msgpack", rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, echo.HeaderAccept, rec.Header().Get(echo.HeaderVary))
+
+		var res map[string]interface{}
+		require.NoError(t, msgpack.Unmarshal(rec.Body.Bytes(), &res))
+		assert.EqualValues(t, 7, res["id"])
+		assert.Equal(t, "inactive", res["state"])
+	})
+
+	t.Run("Not Acceptable", func(t *testing.T) {
+		rec := serve(NewHandler(echoDevice, http.StatusOK), http.MethodPut, `{}`,
+			map[string]string{echo.HeaderContentType: echo.MIMEApplicationJSON, echo.HeaderAccept: "text/html"})
+
+		assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"type":"not_acceptable"`)
+	})
+
+	t.Run("Unsupported Media Type Is Reported In The Accepted Encoding", func(t *testing.T) {
+		rec := serve(NewHandler(echoDevice, http.StatusOK), http.MethodPut, `name=Phone`,
+			map[string]string{echo.HeaderContentType: echo.MIMEApplicationForm, echo.HeaderAccept: echo.MIMEApplicationXML})
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+		assert.Contains(t, rec.Body.String(), "<type>unsupported_media_type</type>")
+	})
+
+	t.Run("Binds Each Request Into Its Own Parameter", func(t *testing.T) {
+		handler := NewHandler(echoDevice, http.StatusOK)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(name string) {
+				defer wg.Done()
+				rec := serve(handler, http.MethodPut, `{"name":"`+name+`","brand":"Apple","state":"available"}`,
+					map[string]string{echo.HeaderContentType: echo.MIMEApplicationJSON})
+
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Contains(t, rec.Body.String(), `"name":"`+name+`"`)
+			}(fmt.Sprint("Phone ", i))
+		}
+		wg.Wait()
+	})
+
+	t.Run("Answers No Content Without A Response", func(t *testing.T) {
+		var deleted int
+		rec := serve(NewHandler(NoContent(func(_ gocontext.Context, param *domain.Delete) error {
+			deleted = param.Id
+			return nil
+		}), http.StatusNoContent), http.MethodDelete, ``, nil)
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Empty(t, rec.Body.String())
+		assert.Equal(t, 7, deleted)
+	})
+}
+
+func TestCall(t *testing.T) {
+	var called bool
+	getById := func(_ gocontext.Context, param *domain.GetById) (*domain.Device, error) {
+		called = true
+		return &domain.Device{Id: param.Id}, nil
+	}
+
+	t.Run("Calls With A Valid Request", func(t *testing.T) {
+		device, err := Call(gocontext.Background(), getById, &domain.GetById{Id: 7})
+		require.NoError(t, err)
+		assert.Equal(t, 7, device.Id)
+	})
+
+	t.Run("Rejects An Invalid Request Without Calling", func(t *testing.T) {
+		called = false
+		_, err := Call(gocontext.Background(), getById, &domain.GetById{})
+
+		var responseErr *domain.Error
+		require.ErrorAs(t, err, &responseErr)
+		assert.Equal(t, "validate_error", responseErr.Type)
+		assert.Equal(t, http.StatusBadRequest, responseErr.Status)
+		assert.False(t, called)
+	})
+}
+
+func TestMetadata(t *testing.T) {
+	t.Run("Describes Params And Body", func(t *testing.T) {
+		metadata := NewHandler(func(gocontext.Context, *domain.Update) (*domain.Device, error) { return nil, nil }, http.StatusOK).Metadata()
+
+		assert.Equal(t, reflect.TypeFor[domain.Update](), metadata.Request)
+		assert.Equal(t, reflect.TypeFor[*domain.Device](), metadata.Response)
+		assert.Equal(t, http.StatusOK, metadata.Status)
+		require.Len(t, metadata.Params, 1)
+		assert.Equal(t, "id", metadata.Params[0].Name)
+		assert.Equal(t, "path", metadata.Params[0].In)
+		assert.True(t, metadata.Params[0].Required)
+		require.Len(t, metadata.Body, 4)
+		assert.Equal(t, "Name", metadata.Body[0].Name)
+	})
+
+	t.Run("Describes Optional Query Params", func(t *testing.T) {
+		metadata := NewHandler(func(gocontext.Context, *domain.DeviceQuery) ([]domain.Device, error) { return nil, nil }, http.StatusOK).Metadata()
+
+		require.Len(t, metadata.Params, 9)
+		assert.Equal(t, "state", metadata.Params[2].Name)
+		assert.Equal(t, "query", metadata.Params[2].In)
+		assert.False(t, metadata.Params[2].Required)
+		assert.Empty(t, metadata.Body)
+	})
+
+	t.Run("Has Neither Request Nor Response", func(t *testing.T) {
+		metadata := NewHandler(NoRequest(func(gocontext.Context) (struct{}, error) { return struct{}{}, nil }), http.StatusNoContent).Metadata()
+
+		assert.Nil(t, metadata.Request)
+		assert.Nil(t, metadata.Response)
+	})
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Metadata - what a route takes and returns, derived from the types of its handler
+type Metadata struct {
+	// Request - the struct bound from the path, query and body, nil without any
+	Request reflect.Type
+	// Response - the type of the body answered, nil without one
+	Response reflect.Type
+	Status   int
+	// Params - the path and query params of Request
+	Params []Param
+	// Body - the fields of Request bound from the body
+	Body []reflect.StructField
+	// MediaType - the only media type of the body, empty when every codec reads it
+	MediaType string
+	// ContentType - the media type of the response, empty when it is negotiated among the codecs
+	ContentType string
+	// Variants - the other handlers of the route, picked by the media type of the body
+	Variants []Metadata
+}
+
+// Param - a field of the request bound from the path or the query
+type Param struct {
+	Name string
+	// In - path or query
+	In       string
+	Type     reflect.Type
+	Required bool
+	Field    reflect.StructField
+}
+
+func (ctrl *handler[Req, Resp]) Metadata() Metadata {
+	metadata := Metadata{Status: ctrl.httpStatus}
+	if ctrl.bound {
+		metadata.Request = reflect.TypeFor[Req]()
+		metadata.Params, metadata.Body = fields(metadata.Request)
+	}
+	if response := reflect.TypeFor[Resp](); response.Size() > 0 {
+		metadata.Response = response
+	}
+	return metadata
+}
+
+// fields - param tags name path params, query tags query params, and the other exported fields are the body,
+// unless their json tag is -
+func fields(t reflect.Type) ([]Param, []reflect.StructField) {
+	var params []Param
+	var body []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		required := strings.Contains(","+field.Tag.Get("validate")+",", ",required,")
+		switch {
+		case field.Tag.Get("param") != "":
+			params = append(params, Param{Name: field.Tag.Get("param"), In: "path", Type: field.Type, Required: true, Field: field})
+		case field.Tag.Get("query") != "":
+			params = append(params, Param{Name: field.Tag.Get("query"), In: "query", Type: field.Type, Required: required, Field: field})
+		case field.Tag.Get("json") != "-":
+			body = append(body, field)
+		}
+	}
+	return params, body
+}
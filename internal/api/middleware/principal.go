@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"github.com/ivofreitas/device-api/internal/adapter/certs"
+	"github.com/ivofreitas/device-api/internal/adapter/context"
+	"github.com/ivofreitas/device-api/internal/adapter/log"
+	"github.com/labstack/echo/v4"
+)
+
+// ClientCertificate - makes the subject of a verified client certificate the principal of the request
+func ClientCertificate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		if subject, ok := certs.Subject(req.TLS); ok {
+			c.SetRequest(req.WithContext(context.WithPrincipal(req.Context(), subject)))
+			context.Get(req.Context(), log.HTTPKey).(*log.HTTP).Request.Principal = subject
+		}
+		return next(c)
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/ivofreitas/device-api/internal/adapter/context"
+	"github.com/ivofreitas/device-api/internal/adapter/log"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientCertificate(t *testing.T) {
+	principal := func(state *tls.ConnectionState) (string, bool) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.TLS = state
+		req = req.WithContext(log.InitParams(req.Context()))
+
+		var subject string
+		var ok bool
+		c := echo.New().NewContext(req, httptest.NewRecorder())
+		_ = ClientCertificate(func(c echo.Context) error {
+			subject, ok = context.Principal(c.Request().Context())
+			return nil
+		})(c)
+		return subject, ok
+	}
+
+	subject, ok := principal(&tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{
+		{Subject: pkix.Name{CommonName: "agent-7", Organization: []string{"Devices"}}},
+	}}})
+	assert.True(t, ok)
+	assert.Equal(t, "CN=agent-7,O=Devices", subject)
+
+	_, ok = principal(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "forged"}}}})
+	assert.False(t, ok, "unverified certificates are not principals")
+
+	_, ok = principal(nil)
+	assert.False(t, ok)
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/ivofreitas/device-api/internal/adapter/codec"
+	"github.com/ivofreitas/device-api/internal/adapter/context"
+	"github.com/ivofreitas/device-api/internal/adapter/log"
+	"github.com/ivofreitas/device-api/internal/adapter/ratelimit"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/labstack/echo/v4"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Client identities a RateLimiter can key buckets by
+const (
+	KeySubject = "subject"
+	KeyIP      = "ip"
+)
+
+// RateLimiter - token bucket per client and route. Routes are matched by method and route pattern,
+// e.g. "POST /v1/devices" or "* /v1/devices/:id", and fall back to a default limit when it is set
+type RateLimiter struct {
+	store  ratelimit.Store
+	limits atomic.Pointer[limits]
+}
+
+type limits struct {
+	fallback *ratelimit.Limit
+	routes   map[string]ratelimit.Limit
+	keys     []string
+}
+
+// NewRateLimiter - keys are the client identities tried in order, the client IP being the last resort. Only
+// authenticated identities count, as anything a client merely sends could be changed to get a fresh bucket
+func NewRateLimiter(store ratelimit.Store, fallback *ratelimit.Limit, routes map[string]ratelimit.Limit,
+	keys []string) (*RateLimiter, error) {
+	l := &RateLimiter{store: store}
+	if err := l.SetLimits(fallback, routes, keys); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// SetLimits - replaces the limits of the requests to come, keeping the buckets. Without a fallback or
+// routes nothing is limited
+func (l *RateLimiter) SetLimits(fallback *ratelimit.Limit, routes map[string]ratelimit.Limit, keys []string) error {
+	for _, key := range keys {
+		if key != KeySubject && key != KeyIP {
+			return fmt.Errorf("unknown rate limit key %q", key)
+		}
+	}
+	l.limits.Store(&limits{fallback: fallback, routes: routes, keys: keys})
+	return nil
+}
+
+// Handle - a failing store lets requests through, so the limiter cannot take the API down with it
+func (l *RateLimiter) Handle(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		limits := l.limits.Load()
+		limit, ok := limits.limit(req.Method, c.Path())
+		if !ok {
+			return next(c)
+		}
+
+		route := req.Method + " " + c.Path()
+		res, err := l.store.Take(req.Context(), route+" "+limits.client(c), limit)
+		if err != nil {
+			log.NewEntry().WithError(err).Warn("Rate limit store failed, letting the request through")
+			return next(c)
+		}
+
+		header := c.Response().Header()
+		header.Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		header.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		header.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+		header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%s", limit.Requests, ceilSeconds(limit.Period)))
+		if res.Allowed {
+			return next(c)
+		}
+
+		header.Set(echo.HeaderRetryAfter, ceilSeconds(res.RetryAfter))
+		responseErr := &domain.Error{
+			Type:   "rate_limited",
+			Status: http.StatusTooManyRequests,
+			Detail: fmt.Sprintf("rate limit of %s exceeded for %s", limit, route),
+		}
+		httpLog := context.Get(req.Context(), log.HTTPKey).(*log.HTTP)
+		httpLog.Error = responseErr.Error()
+
+		encoder, ok := codec.Negotiate(req.Header.Get(echo.HeaderAccept))
+		if !ok {
+			encoder = codec.JSON
+		}
+		return encode(c, encoder, responseErr.Status, responseErr)
+	}
+}
+
+func (l *limits) limit(method, path string) (ratelimit.Limit, bool) {
+	if limit, ok := l.routes[method+" "+path]; ok {
+		return limit, true
+	}
+	if limit, ok := l.routes["* "+path]; ok {
+		return limit, true
+	}
+	if l.fallback != nil {
+		return *l.fallback, true
+	}
+	return ratelimit.Limit{}, false
+}
+
+// client - the IP is the one Echo's IPExtractor reports
+func (l *limits) client(c echo.Context) string {
+	for _, key := range l.keys {
+		switch key {
+		case KeySubject:
+			if subject, ok := context.Principal(c.Request().Context()); ok {
+				return "sub:" + subject
+			}
+		case KeyIP:
+			return "ip:" + c.RealIP()
+		}
+	}
+	return "ip:" + c.RealIP()
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
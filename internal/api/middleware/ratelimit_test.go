@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"github.com/ivofreitas/device-api/internal/adapter/context"
+	"github.com/ivofreitas/device-api/internal/adapter/log"
+	"github.com/ivofreitas/device-api/internal/adapter/ratelimit"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter, err := NewRateLimiter(ratelimit.NewMemory(), nil,
+		map[string]ratelimit.Limit{"POST /devices": {Requests: 2, Period: time.Minute}},
+		[]string{KeySubject, KeyIP})
+	require.NoError(t, err)
+
+	e := echo.New()
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.POST("/devices", ok, limiter.Handle)
+	e.GET("/devices", ok, limiter.Handle)
+
+	// request - made by an authenticated subject, when subject is set
+	request := func(method, subject string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/devices", nil)
+		ctx := log.InitParams(req.Context())
+		if subject != "" {
+			ctx = context.WithPrincipal(ctx, subject)
+		}
+		req = req.WithContext(ctx)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Limits Each Client Per Route", func(t *testing.T) {
+		rec := request(http.MethodPost, "alpha")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "2;w=60", rec.Header().Get("RateLimit-Policy"))
+
+		assert.Equal(t, http.StatusOK, request(http.MethodPost, "alpha").Code)
+
+		rec = request(http.MethodPost, "alpha")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "30", rec.Header().Get(echo.HeaderRetryAfter))
+		assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+		assert.Contains(t, rec.Body.String(), `"type":"rate_limited"`)
+
+		assert.Equal(t, http.StatusOK, request(http.MethodPost, "beta").Code)
+		assert.Equal(t, http.StatusOK, request(http.MethodPost, "").Code)
+	})
+
+	t.Run("Does Not Key By API Keys", func(t *testing.T) {
+		limiter, err := NewRateLimiter(ratelimit.NewMemory(), nil,
+			map[string]ratelimit.Limit{"POST /devices": {Requests: 2, Period: time.Minute}}, []string{KeySubject, KeyIP})
+		require.NoError(t, err)
+		e := echo.New()
+		e.POST("/devices", ok, limiter.Handle)
+
+		codes := make([]int, 0, 3)
+		for _, apiKey := range []string{"first", "second", "third"} {
+			req := httptest.NewRequest(http.MethodPost, "/devices", nil)
+			req.Header.Set("X-API-Key", apiKey)
+			req = req.WithContext(log.InitParams(req.Context()))
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			codes = append(codes, rec.Code)
+		}
+		assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes,
+			"rotating the header keeps the bucket of the client IP")
+	})
+
+	t.Run("Routes Without A Limit Are Not Limited", func(t *testing.T) {
+		rec := request(http.MethodGet, "alpha")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("RateLimit-Limit"))
+	})
+
+	t.Run("Applies New Limits To The Requests To Come", func(t *testing.T) {
+		fallback := &ratelimit.Limit{Requests: 5, Period: time.Minute}
+		require.NoError(t, limiter.SetLimits(fallback, nil, []string{KeySubject, KeyIP}))
+		defer func() {
+			require.NoError(t, limiter.SetLimits(nil, map[string]ratelimit.Limit{"POST /devices": {Requests: 2, Period: time.Minute}},
+				[]string{KeySubject, KeyIP}))
+		}()
+
+		rec := request(http.MethodGet, "gamma")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "5", rec.Header().Get("RateLimit-Limit"))
+
+		require.NoError(t, limiter.SetLimits(nil, nil, nil))
+		rec = request(http.MethodGet, "gamma")
+		assert.Empty(t, rec.Header().Get("RateLimit-Limit"))
+		assert.Error(t, limiter.SetLimits(nil, nil, []string{"cookie"}))
+	})
+
+	t.Run("Rejects Unknown Keys", func(t *testing.T) {
+		_, err := NewRateLimiter(ratelimit.NewMemory(), nil, nil, []string{"cookie"})
+		assert.Error(t, err)
+	})
+}
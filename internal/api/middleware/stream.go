@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ivofreitas/device-api/internal/adapter/context"
+	"github.com/ivofreitas/device-api/internal/adapter/event"
+	"github.com/ivofreitas/device-api/internal/adapter/log"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// subscriberBuffer - live events a client may lag behind before it is disconnected
+const subscriberBuffer = 64
+
+type EventStream struct {
+	bus       *event.Bus
+	heartbeat time.Duration
+	echo.Binder
+}
+
+func NewEventStream(bus *event.Bus, heartbeat time.Duration) *EventStream {
+	return &EventStream{bus, heartbeat, new(echo.DefaultBinder)}
+}
+
+// Metadata - the stream is documented as a response of events, one per message
+func (s *EventStream) Metadata() Metadata {
+	request := reflect.TypeFor[domain.EventFilter]()
+	params, _ := fields(request)
+	return Metadata{
+		Request:     request,
+		Response:    reflect.TypeFor[domain.Event](),
+		Status:      http.StatusOK,
+		Params:      params,
+		ContentType: "text/event-stream",
+	}
+}
+
+// Handle - Server-Sent Events stream of device.created, device.updated, device.state_changed and device.deleted events.
+// Reconnecting clients resume after the Last-Event-ID header from a bounded replay buffer
+func (s *EventStream) Handle(c echo.Context) error {
+	httpLog := context.Get(c.Request().Context(), log.HTTPKey).(*log.HTTP)
+
+	filter := new(domain.EventFilter)
+	if err := s.Bind(filter, c); err != nil {
+		responseErr := &domain.Error{Type: "bind_error", Status: http.StatusBadRequest, Detail: err.Error()}
+		httpLog.Error = responseErr.Error()
+		return c.JSON(http.StatusBadRequest, responseErr)
+	}
+
+	if lastEventId := c.Request().Header.Get("Last-Event-ID"); lastEventId != "" {
+		id, err := strconv.ParseUint(lastEventId, 10, 64)
+		if err != nil {
+			responseErr := &domain.Error{Type: "bind_error", Status: http.StatusBadRequest, Detail: "invalid Last-Event-ID header"}
+			httpLog.Error = responseErr.Error()
+			return c.JSON(http.StatusBadRequest, responseErr)
+		}
+		filter.LastEventId = id
+	}
+
+	replay, events, cancel := s.bus.Subscribe(filter.LastEventId, subscriberBuffer)
+	defer cancel()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	for _, e := range replay {
+		if err := s.write(res, filter, e); err != nil {
+			return nil
+		}
+	}
+
+	heartbeat := time.NewTicker(s.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := s.write(res, filter, e); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+func (s *EventStream) write(res *echo.Response, filter *domain.EventFilter, e domain.Event) error {
+	if !filter.Matches(e) {
+		return nil
+	}
+
+	data, err := json.Marshal(&e)
+	if err != nil {
+		return err
+	}
+
+	if _, err = fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", e.Id, e.Type, data); err != nil {
+		return err
+	}
+	res.Flush()
+
+	return nil
+}
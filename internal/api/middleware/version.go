@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/ivofreitas/device-api/internal/adapter/context"
+	"github.com/ivofreitas/device-api/internal/adapter/log"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/labstack/echo/v4"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// vendorType - the vendor media type of an API version, a JSON representation of it
+var vendorType = regexp.MustCompile(`^application/vnd\.device\.v(\d+)\+json$`)
+
+// VendorMediaType - application/vnd.device.v2+json for version 2
+func VendorMediaType(version int) string {
+	return fmt.Sprintf("application/vnd.device.v%d+json", version)
+}
+
+// ResolveVersion - routes the unversioned paths of the resources, like /devices/7, to the version asked for with the
+// vendor media type in Accept, or to the first version of the resource, which is the default. Versions a resource
+// does not have are left to Version to refuse. As the version depends on Accept, the responses vary by it. It is meant
+// to run before routing
+func ResolveVersion(resources map[string][]int) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			resource, _, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/"), "/")
+			versions, ok := resources[resource]
+			if !ok {
+				return next(c)
+			}
+
+			version := versions[0]
+			if requested, ok := requestedVersion(req.Header.Get(echo.HeaderAccept)); ok && slices.Contains(versions, requested) {
+				version = requested
+			}
+
+			varyAccept(c.Response().Header())
+			prefix := "/v" + strconv.Itoa(version)
+			req.URL.Path = prefix + req.URL.Path
+			if req.URL.RawPath != "" {
+				req.URL.RawPath = prefix + req.URL.RawPath
+			}
+			return next(c)
+		}
+	}
+}
+
+// Version - serves the routes of an API version. Requests asking for the vendor media type of another version are
+// refused with 406. The vendor type of the version is negotiated as JSON, and JSON responses are labelled with it
+func Version(version int) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			accept := req.Header.Get(echo.HeaderAccept)
+			requested, ok := requestedVersion(accept)
+			if !ok {
+				return next(c)
+			}
+
+			res := c.Response()
+			if requested != version {
+				responseErr := &domain.Error{
+					Type:   "not_acceptable",
+					Status: http.StatusNotAcceptable,
+					Detail: fmt.Sprintf("%s serves %s, not %s", req.URL.Path, VendorMediaType(version), VendorMediaType(requested)),
+				}
+				if httpLog, ok := context.Get(req.Context(), log.HTTPKey).(*log.HTTP); ok {
+					httpLog.Error = responseErr.Error()
+				}
+				varyAccept(res.Header())
+				return c.JSON(responseErr.Status, responseErr)
+			}
+
+			req.Header.Set(echo.HeaderAccept, vendorAsJSON(accept))
+			res.Before(func() {
+				if mediaType, _, _ := mime.ParseMediaType(res.Header().Get(echo.HeaderContentType)); mediaType == echo.MIMEApplicationJSON {
+					res.Header().Set(echo.HeaderContentType, VendorMediaType(version))
+				}
+			})
+			return next(c)
+		}
+	}
+}
+
+// Deprecation - announces on every response that the routes are deprecated since deprecatedAt (RFC 9745), and
+// stop being served at sunset (RFC 8594). Zero times are left out
+func Deprecation(deprecatedAt, sunset time.Time) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Response().Header()
+			if !deprecatedAt.IsZero() {
+				header.Set("Deprecation", "@"+strconv.FormatInt(deprecatedAt.Unix(), 10))
+			}
+			if !sunset.IsZero() {
+				header.Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			}
+			return next(c)
+		}
+	}
+}
+
+// varyAccept - adds Accept to the Vary header, unless it is already there
+func varyAccept(header http.Header) {
+	for _, value := range header.Values(echo.HeaderVary) {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), echo.HeaderAccept) {
+				return
+			}
+		}
+	}
+	header.Add(echo.HeaderVary, echo.HeaderAccept)
+}
+
+// requestedVersion - the version of the most preferred vendor media type in an Accept header
+func requestedVersion(accept string) (int, bool) {
+	version, preference := 0, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		match := vendorType.FindStringSubmatch(mediaType)
+		if match == nil {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q > preference {
+			version, _ = strconv.Atoi(match[1])
+			preference = q
+		}
+	}
+	return version, preference > 0
+}
+
+// vendorAsJSON - the Accept header with the vendor media types replaced by application/json, keeping their q-values
+func vendorAsJSON(accept string) string {
+	parts := strings.Split(accept, ",")
+	for i, part := range parts {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || !vendorType.MatchString(mediaType) {
+			continue
+		}
+		parts[i] = mime.FormatMediaType(echo.MIMEApplicationJSON, params)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"github.com/ivofreitas/device-api/internal/adapter/log"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVersion(t *testing.T) {
+	e := echo.New()
+	e.Pre(ResolveVersion(map[string][]int{"devices": {1, 2}}))
+	for _, version := range []int{1, 2} {
+		e.Group("/v"+strconv.Itoa(version)+"/devices", Version(version)).GET("/:id", func(c echo.Context) error {
+			return c.JSON(http.StatusOK, map[string]string{"path": c.Path(), "accept": c.Request().Header.Get(echo.HeaderAccept)})
+		})
+	}
+
+	request := func(target, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if accept != "" {
+			req.Header.Set(echo.HeaderAccept, accept)
+		}
+		req = req.WithContext(log.InitParams(req.Context()))
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Routes Unversioned Paths To The First Version", func(t *testing.T) {
+		rec := request("/devices/7", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"path":"/v1/devices/:id"`)
+		assert.Equal(t, echo.MIMEApplicationJSON, rec.Header().Get(echo.HeaderContentType))
+	})
+
+	t.Run("Routes Unversioned Paths To The Version Asked For", func(t *testing.T) {
+		rec := request("/devices/7", "application/xml;q=0.5, application/vnd.device.v2+json")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"path":"/v2/devices/:id"`)
+		assert.Contains(t, rec.Body.String(), `"accept":"application/xml;q=0.5,application/json"`)
+		assert.Equal(t, "application/vnd.device.v2+json", rec.Header().Get(echo.HeaderContentType))
+	})
+
+	t.Run("Varies Rewritten Responses By Accept", func(t *testing.T) {
+		for _, accept := range []string{"", "application/vnd.device.v2+json"} {
+			assert.Equal(t, []string{echo.HeaderAccept}, request("/devices/7", accept).Header().Values(echo.HeaderVary))
+		}
+		assert.Empty(t, request("/v1/devices/7", "").Header().Values(echo.HeaderVary))
+	})
+
+	t.Run("Refuses The Media Type Of Another Version", func(t *testing.T) {
+		rec := request("/v1/devices/7", "application/vnd.device.v2+json")
+		assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"type":"not_acceptable"`)
+		assert.Equal(t, echo.HeaderAccept, rec.Header().Get(echo.HeaderVary))
+
+		rec = request("/devices/7", "application/vnd.device.v3+json")
+		assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+		assert.Equal(t, []string{echo.HeaderAccept}, rec.Header().Values(echo.HeaderVary))
+	})
+
+	t.Run("Leaves Other Resources Alone", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, request("/gadgets/7", "application/vnd.device.v2+json").Code)
+	})
+}
+
+func TestDeprecation(t *testing.T) {
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+
+	t.Run("Announces The Deprecation And Sunset", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/v1/devices", nil), rec)
+		deprecatedAt := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+		_ = Deprecation(deprecatedAt, deprecatedAt.AddDate(0, 6, 0))(ok)(c)
+
+		assert.Equal(t, "@1792368000", rec.Header().Get("Deprecation"))
+		assert.Equal(t, "Mon, 19 Apr 2027 00:00:00 GMT", rec.Header().Get("Sunset"))
+	})
+
+	t.Run("Leaves Out Zero Times", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/v1/devices", nil), rec)
+		_ = Deprecation(time.Time{}, time.Time{})(ok)(c)
+
+		assert.Empty(t, rec.Header().Get("Deprecation"))
+		assert.Empty(t, rec.Header().Get("Sunset"))
+	})
+}
@@ -0,0 +1,137 @@
+package openapi_test
+
+import (
+	gocontext "context"
+	"github.com/ivofreitas/device-api/internal/adapter/log"
+	"github.com/ivofreitas/device-api/internal/api/middleware"
+	"github.com/ivofreitas/device-api/internal/api/openapi"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func getDevice(_ gocontext.Context, req *domain.GetById) (*domain.Device, error) {
+	return &domain.Device{Id: req.Id, Name: "Phone", Brand: "Apple", State: domain.AvailableState}, nil
+}
+
+func patchDevice(_ gocontext.Context, req *domain.Patch) (*domain.Device, error) {
+	return &domain.Device{Id: req.Id}, nil
+}
+
+func mergePatchDevice(_ gocontext.Context, req *domain.MergePatch) (*domain.Device, error) {
+	return &domain.Device{Id: req.Id}, nil
+}
+
+func serve(spec *openapi.Spec, validation openapi.Validation, method, path, target, body string, handler middleware.Handler) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.Use(spec.Validator(validation))
+	e.Add(method, path, handler.Handle)
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req = req.WithContext(log.InitParams(req.Context()))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSpec(t *testing.T) {
+	t.Run("Documents A Route From The Types Of Its Handler", func(t *testing.T) {
+		spec := openapi.NewSpec(openapi.Info{Title: "Test", Version: "1.0"})
+		spec.Add(http.MethodGet, "/devices/:id", middleware.NewHandler(getDevice, http.StatusOK).Metadata(),
+			openapi.Doc{Id: "getDevice", Params: map[string]string{"id": "Device id"}})
+
+		document := spec.Document()
+		operation := document.Paths["/devices/{id}"]["get"]
+		require.NotNil(t, operation)
+		assert.Equal(t, "getDevice", operation.OperationId)
+
+		require.Len(t, operation.Parameters, 1)
+		assert.Equal(t, "id", operation.Parameters[0].Name)
+		assert.Equal(t, "path", operation.Parameters[0].In)
+		assert.True(t, operation.Parameters[0].Required)
+		assert.Equal(t, openapi.Types{"integer"}, operation.Parameters[0].Schema.Type)
+
+		assert.Equal(t, "#/components/schemas/Device", operation.Responses["200"].Content[echo.MIMEApplicationJSON].Schema.Ref)
+		assert.Contains(t, operation.Responses, "400")
+		assert.Contains(t, operation.Responses, "500")
+		assert.Contains(t, operation.Responses, "default")
+
+		device := document.Components.Schemas["Device"]
+		require.NotNil(t, device)
+		assert.ElementsMatch(t, []string{"id", "name", "brand", "state", "creation_time"}, device.Required)
+		assert.Equal(t, []string{"available", "in-use", "inactive"}, device.Properties["state"].Enum)
+	})
+
+	t.Run("Documents A Body For Each Content Type", func(t *testing.T) {
+		spec := openapi.NewSpec(openapi.Info{Title: "Test", Version: "1.0"})
+		handler := middleware.ByContentType(middleware.NewHandler(patchDevice, http.StatusOK), map[string]middleware.Handler{
+			"application/merge-patch+json": middleware.NewHandler(mergePatchDevice, http.StatusOK),
+		})
+		spec.Add(http.MethodPatch, "/devices/:id", handler.Metadata(), openapi.Doc{})
+
+		operation := spec.Document().Paths["/devices/{id}"]["patch"]
+		require.NotNil(t, operation.RequestBody)
+		assert.Equal(t, openapi.Types{"object"}, operation.RequestBody.Content["application/merge-patch+json"].Schema.Type)
+		assert.Contains(t, operation.RequestBody.Content[echo.MIMEApplicationJSON].Schema.Properties, "name")
+		assert.Nil(t, operation.Responses["200"].Content[echo.MIMEApplicationJSON].Schema.OneOf)
+		assert.Contains(t, operation.Responses, "415")
+	})
+}
+
+func TestValidator(t *testing.T) {
+	newSpec := func(handler middleware.Handler) *openapi.Spec {
+		spec := openapi.NewSpec(openapi.Info{Title: "Test", Version: "1.0"})
+		spec.Add(http.MethodGet, "/devices/:id", handler.Metadata(), openapi.Doc{})
+		return spec
+	}
+
+	t.Run("Passes Valid Requests And Responses", func(t *testing.T) {
+		handler := middleware.NewHandler(getDevice, http.StatusOK)
+		rec := serve(newSpec(handler), openapi.Validation{Requests: true, Responses: true},
+			http.MethodGet, "/devices/:id", "/devices/7", "", handler)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"id":7`)
+	})
+
+	t.Run("Rejects Params Of The Wrong Type", func(t *testing.T) {
+		handler := middleware.NewHandler(getDevice, http.StatusOK)
+		rec := serve(newSpec(handler), openapi.Validation{Requests: true},
+			http.MethodGet, "/devices/:id", "/devices/seven", "", handler)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"type":"invalid_request"`)
+		assert.Contains(t, rec.Body.String(), "path param id")
+	})
+
+	t.Run("Rejects Bodies Out Of The Enum", func(t *testing.T) {
+		handler := middleware.NewHandler(patchDevice, http.StatusOK)
+		spec := openapi.NewSpec(openapi.Info{Title: "Test", Version: "1.0"})
+		spec.Add(http.MethodPatch, "/devices/:id", handler.Metadata(), openapi.Doc{})
+
+		rec := serve(spec, openapi.Validation{Requests: true},
+			http.MethodPatch, "/devices/:id", "/devices/7", `{"state":"lost"}`, handler)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `body/state: \"lost\" is not one of available, in-use, inactive`)
+	})
+
+	t.Run("Replaces Responses Out Of The Spec", func(t *testing.T) {
+		spec := newSpec(middleware.NewHandler(getDevice, http.StatusOK))
+		rec := serve(spec, openapi.Validation{Responses: true}, http.MethodGet, "/devices/:id", "/devices/7", "",
+			middleware.NewHandler(func(_ gocontext.Context, req *domain.GetById) (map[string]int, error) {
+				return map[string]int{"id": req.Id}, nil
+			}, http.StatusOK))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"type":"invalid_response"`)
+		assert.Contains(t, rec.Body.String(), "response: missing name")
+	})
+}
@@ -0,0 +1,193 @@
+package openapi
+
+import (
+	"encoding/json"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// known - schemas of the types whose JSON does not follow their fields
+func known(t reflect.Type) (*Schema, bool) {
+	switch t {
+	case reflect.TypeFor[time.Time]():
+		return &Schema{Type: Types{"string"}, Format: "date-time"}, true
+	case reflect.TypeFor[json.RawMessage]():
+		return &Schema{}, true
+	case reflect.TypeFor[domain.State]():
+		var states []string
+		for state := domain.AvailableState; state <= domain.InactiveState; state++ {
+			states = append(states, state.String())
+		}
+		return &Schema{Type: Types{"string"}, Enum: states}, true
+	case reflect.TypeFor[domain.MergePatch]():
+		return &Schema{Type: Types{"object"}, Description: "RFC 7396 merge patch of the device"}, true
+	case reflect.TypeFor[domain.JSONPatch]():
+		return &Schema{Type: Types{"array"}, Description: "RFC 6902 operations, applied in order", Items: &Schema{
+			Type:     Types{"object"},
+			Required: []string{"op", "path"},
+			Properties: map[string]*Schema{
+				"op":    {Type: Types{"string"}, Enum: []string{"add", "remove", "replace", "move", "copy", "test"}},
+				"path":  {Type: Types{"string"}},
+				"from":  {Type: Types{"string"}},
+				"value": {},
+			},
+		}}, true
+	default:
+		return nil, false
+	}
+}
+
+// schema - the schema of the JSON of t. Named structs of responses are components, while those of requests
+// are inlined, since clients send only some of their fields: the ones validated as required
+func (s *Spec) schema(t reflect.Type, request bool) *Schema {
+	if schema, ok := known(t); ok {
+		return schema
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return s.schema(t.Elem(), request)
+	case reflect.Bool:
+		return &Schema{Type: Types{"boolean"}}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: Types{"integer"}, Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: Types{"integer"}, Format: "int32"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: Types{"number"}}
+	case reflect.String:
+		return &Schema{Type: Types{"string"}}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: Types{"array"}, Items: s.schema(t.Elem(), request)}
+	case reflect.Map:
+		return &Schema{Type: Types{"object"}, AdditionalProperties: s.schema(t.Elem(), request)}
+	case reflect.Struct:
+		if request || t.Name() == "" {
+			return s.object(fieldsOf(t), request)
+		}
+		return s.component(t)
+	default:
+		return &Schema{}
+	}
+}
+
+// component - a reference to the schema of a named struct, which is added to the components on first use
+func (s *Spec) component(t reflect.Type) *Schema {
+	name, ok := s.components[t]
+	if !ok {
+		name = t.Name()
+		if _, taken := s.document.Components.Schemas[name]; taken {
+			name = path.Base(t.PkgPath()) + "." + name
+		}
+		s.components[t] = name
+		// Registered before its fields, so recursive types end in a reference
+		s.document.Components.Schemas[name] = &Schema{}
+		*s.document.Components.Schemas[name] = *s.object(fieldsOf(t), false)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// object - required are the fields validated as required in requests, and those never omitted in responses
+func (s *Spec) object(fields []reflect.StructField, request bool) *Schema {
+	object := &Schema{Type: Types{"object"}, Properties: map[string]*Schema{}}
+	for _, field := range fields {
+		name, omitEmpty := jsonName(field)
+		if name == "" {
+			continue
+		}
+
+		schema := s.field(field, request)
+		if field.Type.Kind() == reflect.Pointer && (request && !required(field) || !request && !omitEmpty) {
+			schema = nullable(schema)
+		}
+		object.Properties[name] = schema
+
+		if request && required(field) || !request && !omitEmpty {
+			object.Required = append(object.Required, name)
+		}
+	}
+	return object
+}
+
+// field - the schema of the type of a field, narrowed by its validate tag
+func (s *Spec) field(field reflect.StructField, request bool) *Schema {
+	schema := s.schema(field.Type, request)
+
+	target := schema
+	for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "dive":
+			if target.Items == nil {
+				return schema
+			}
+			target = target.Items
+		case "oneof":
+			target.Enum = strings.Fields(value)
+		case "min", "max":
+			bound, err := strconv.ParseFloat(value, 64)
+			if err != nil || !target.Type.Has("integer") && !target.Type.Has("number") {
+				continue
+			}
+			if key == "min" {
+				target.Minimum = &bound
+			} else {
+				target.Maximum = &bound
+			}
+		case "url", "http_url":
+			target.Format = "uri"
+		}
+	}
+	return schema
+}
+
+// fieldsOf - the fields of the JSON of a struct, with those of embedded structs
+func fieldsOf(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			fields = append(fields, fieldsOf(field.Type)...)
+			continue
+		}
+		if field.IsExported() {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// jsonName - the name of a field in JSON, empty when it is left out
+func jsonName(field reflect.StructField) (name string, omitEmpty bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, strings.Contains(","+options+",", ",omitempty,")
+}
+
+func required(field reflect.StructField) bool {
+	rules, _, _ := strings.Cut(field.Tag.Get("validate"), ",dive")
+	return strings.Contains(","+rules+",", ",required,")
+}
+
+// nullable - the schema also matching null
+func nullable(schema *Schema) *Schema {
+	switch {
+	case schema.Ref != "":
+		return &Schema{OneOf: []*Schema{schema, {Type: Types{"null"}}}}
+	case len(schema.Type) == 0:
+		return schema
+	default:
+		schema.Type = append(schema.Type, "null")
+		return schema
+	}
+}
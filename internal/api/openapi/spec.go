@@ -0,0 +1,259 @@
+package openapi
+
+import (
+	"github.com/ivofreitas/device-api/internal/adapter/codec"
+	"github.com/ivofreitas/device-api/internal/api/middleware"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Spec - an OpenAPI 3.1 document built from the routes as they are registered and from the types of their
+// handlers. Routes are added before the server starts, and the document is read-only afterwards
+type Spec struct {
+	document Document
+	// components - names of the component schemas of named structs
+	components map[reflect.Type]string
+	// operations - by method and echo path, as matched by the router
+	operations map[string]*Operation
+}
+
+func NewSpec(info Info) *Spec {
+	return &Spec{
+		document: Document{
+			OpenAPI:    "3.1.0",
+			Info:       info,
+			Paths:      map[string]PathItem{},
+			Components: Components{Schemas: map[string]*Schema{}},
+		},
+		components: map[reflect.Type]string{},
+		operations: map[string]*Operation{},
+	}
+}
+
+// Doc - what the types of a handler do not tell about its route
+type Doc struct {
+	Id          string
+	Summary     string
+	Description string
+	Tags        []string
+	// Params - descriptions of the params by name
+	Params map[string]string
+	// Success - description of the success response
+	Success string
+	// Errors - descriptions of the errors of the route by status, besides those of every handler
+	Errors map[int]string
+	// MediaTypes - further media types of the negotiated responses, like the vendor type of the API version
+	MediaTypes []string
+	Deprecated bool
+}
+
+// Add - documents a route from the metadata of its handler. Path is in the syntax of echo
+func (s *Spec) Add(method, path string, metadata middleware.Metadata, doc Doc) {
+	operation := &Operation{
+		OperationId: doc.Id,
+		Summary:     doc.Summary,
+		Description: doc.Description,
+		Tags:        doc.Tags,
+		Responses:   map[string]*Response{},
+		Deprecated:  doc.Deprecated,
+	}
+	negotiated := append(codec.Preferred(), doc.MediaTypes...)
+	json := append([]string{echo.MIMEApplicationJSON}, doc.MediaTypes...)
+
+	errors := map[int]string{http.StatusInternalServerError: "Internal server error"}
+	for _, m := range append([]middleware.Metadata{metadata}, metadata.Variants...) {
+		if m.ContentType == "" {
+			errors[http.StatusNotAcceptable] = "Unsupported Accept media type"
+		}
+		if m.Request != nil {
+			errors[http.StatusBadRequest] = "Invalid request"
+		}
+
+		for _, param := range m.Params {
+			if !hasParam(operation.Parameters, param) {
+				operation.Parameters = append(operation.Parameters, &Parameter{
+					Name:        param.Name,
+					In:          param.In,
+					Description: doc.Params[param.Name],
+					Required:    param.Required,
+					Schema:      s.field(param.Field, true),
+				})
+			}
+		}
+
+		if body, required := s.body(m); body != nil {
+			errors[http.StatusUnsupportedMediaType] = "Unsupported Content-Type"
+			if operation.RequestBody == nil {
+				operation.RequestBody = &RequestBody{Content: map[string]*MediaType{}}
+			}
+			operation.RequestBody.Required = operation.RequestBody.Required || required
+			for _, mediaType := range mediaTypes(m.MediaType, codec.Preferred()) {
+				operation.RequestBody.Content[mediaType] = &MediaType{Schema: body}
+			}
+		}
+
+		s.respond(operation, m, doc.Success, negotiated)
+	}
+
+	for status, description := range doc.Errors {
+		errors[status] = description
+	}
+	for status, description := range errors {
+		// Handlers answer errors in the negotiated media type, and streams in JSON
+		if metadata.ContentType == "" {
+			operation.Responses[strconv.Itoa(status)] = s.Response(description, reflect.TypeFor[domain.Error](), negotiated...)
+		} else {
+			operation.Responses[strconv.Itoa(status)] = s.Response(description, reflect.TypeFor[domain.Error](), json...)
+		}
+	}
+	operation.Responses["default"] = s.Response("Unexpected error", reflect.TypeFor[domain.Error](), json...)
+
+	s.AddOperation(method, path, operation)
+}
+
+// AddOperation - documents a route whose handler has no metadata. Path is in the syntax of echo, and
+// operations without a default response get the one of errors of routing and middleware
+func (s *Spec) AddOperation(method, path string, operation *Operation) {
+	if _, ok := operation.Responses["default"]; !ok {
+		operation.Responses["default"] = s.Response("Unexpected error", reflect.TypeFor[domain.Error]())
+	}
+
+	item, ok := s.document.Paths[openAPIPath(path)]
+	if !ok {
+		item = PathItem{}
+		s.document.Paths[openAPIPath(path)] = item
+	}
+	item[strings.ToLower(method)] = operation
+	s.operations[method+" "+path] = operation
+}
+
+// Response - a response with a body of type t in the media types, application/json when none is given
+func (s *Spec) Response(description string, t reflect.Type, mediaTypes ...string) *Response {
+	if len(mediaTypes) == 0 {
+		mediaTypes = []string{echo.MIMEApplicationJSON}
+	}
+
+	response := &Response{Description: description, Content: map[string]*MediaType{}}
+	schema := s.schema(t, false)
+	// Lists without any item are nil slices, which are encoded as null
+	if t.Kind() == reflect.Slice {
+		schema = nullable(schema)
+	}
+	for _, mediaType := range mediaTypes {
+		response.Content[mediaType] = &MediaType{Schema: schema}
+	}
+	return response
+}
+
+func (s *Spec) Document() *Document {
+	return &s.document
+}
+
+// Handle - serves the document
+func (s *Spec) Handle(c echo.Context) error {
+	return c.JSON(http.StatusOK, &s.document)
+}
+
+// operation - the operation of a route matched by echo
+func (s *Spec) operation(method, path string) (*Operation, bool) {
+	operation, ok := s.operations[method+" "+path]
+	return operation, ok
+}
+
+// body - the schema of the body of a request, and whether it is required
+func (s *Spec) body(m middleware.Metadata) (*Schema, bool) {
+	if m.Request == nil {
+		return nil, false
+	}
+	if schema, ok := known(m.Request); ok {
+		return schema, true
+	}
+	if len(m.Body) == 0 {
+		return nil, false
+	}
+
+	schema := s.object(m.Body, true)
+	return schema, len(schema.Required) > 0
+}
+
+// respond - adds the success response of a handler in its media type, or in the negotiated ones. Handlers of a
+// route answering different types with the same status are documented as one of them
+func (s *Spec) respond(operation *Operation, m middleware.Metadata, description string, negotiated []string) {
+	if description == "" {
+		description = http.StatusText(m.Status)
+	}
+
+	status := strconv.Itoa(m.Status)
+	if m.Response == nil {
+		if _, ok := operation.Responses[status]; !ok {
+			operation.Responses[status] = &Response{Description: description}
+		}
+		return
+	}
+
+	response := s.Response(description, m.Response, mediaTypes(m.ContentType, negotiated)...)
+	existing, ok := operation.Responses[status]
+	if !ok || len(existing.Content) == 0 {
+		operation.Responses[status] = response
+		return
+	}
+	for _, content := range existing.Content {
+		schema := response.Content[mediaTypes(m.ContentType, negotiated)[0]].Schema
+		if reflect.DeepEqual(content.Schema, schema) || slices.ContainsFunc(content.Schema.OneOf, func(option *Schema) bool {
+			return reflect.DeepEqual(option, schema)
+		}) {
+			return
+		}
+		break
+	}
+
+	// Media types share their schema, so it is merged once
+	var merged *Schema
+	for mediaType, content := range response.Content {
+		current, ok := existing.Content[mediaType]
+		if !ok {
+			existing.Content[mediaType] = content
+			continue
+		}
+		if merged == nil {
+			merged = &Schema{OneOf: []*Schema{current.Schema, content.Schema}}
+			if current.Schema.OneOf != nil && current.Schema.Ref == "" && len(current.Schema.Type) == 0 {
+				merged.OneOf = append(current.Schema.OneOf, content.Schema)
+			}
+		}
+		current.Schema = merged
+	}
+}
+
+// mediaTypes - the only one given, or the negotiated ones
+func mediaTypes(mediaType string, negotiated []string) []string {
+	if mediaType != "" {
+		return []string{mediaType}
+	}
+	return negotiated
+}
+
+func hasParam(params []*Parameter, param middleware.Param) bool {
+	for _, p := range params {
+		if p.Name == param.Name && p.In == param.In {
+			return true
+		}
+	}
+	return false
+}
+
+// openAPIPath - /v1/devices/:id becomes /v1/devices/{id}
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,387 @@
+package openapi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/ivofreitas/device-api/internal/adapter/context"
+	"github.com/ivofreitas/device-api/internal/adapter/log"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/labstack/echo/v4"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validation - what the validator checks against the spec
+type Validation struct {
+	Requests  bool
+	Responses bool
+}
+
+// Validator - rejects requests that do not match the spec with 400, and answers 500 instead of responses that do
+// not match it. Bodies are validated when they are JSON, and routes missing from the spec are left alone.
+// It is meant to be used after routing, so echo knows the route of the request
+func (s *Spec) Validator(validation Validation) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			operation, ok := s.operation(c.Request().Method, c.Path())
+			if !ok {
+				return next(c)
+			}
+
+			if validation.Requests {
+				if err := s.validateRequest(c, operation); err != nil {
+					responseErr := &domain.Error{Type: "invalid_request", Status: http.StatusBadRequest, Detail: err.Error()}
+					setError(c, responseErr)
+					return c.JSON(responseErr.Status, responseErr)
+				}
+			}
+			if !validation.Responses {
+				return next(c)
+			}
+
+			res := c.Response()
+			recorder := &recorder{ResponseWriter: res.Writer}
+			res.Writer = recorder
+			// Restored even on panics, so their error is answered
+			defer func() { res.Writer = recorder.ResponseWriter }()
+			err := next(c)
+			res.Writer = recorder.ResponseWriter
+			if !recorder.buffered {
+				return err
+			}
+
+			if validationErr := s.validateResponse(c, operation, recorder); validationErr != nil {
+				responseErr := &domain.Error{Type: "invalid_response", Status: http.StatusInternalServerError, Detail: validationErr.Error()}
+				setError(c, responseErr)
+				res.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+				res.Header().Del(echo.HeaderContentLength)
+				res.Status = responseErr.Status
+				res.Writer.WriteHeader(responseErr.Status)
+				_ = json.NewEncoder(res.Writer).Encode(responseErr)
+				return err
+			}
+
+			res.Writer.WriteHeader(recorder.status)
+			_, _ = res.Writer.Write(recorder.body.Bytes())
+			return err
+		}
+	}
+}
+
+func (s *Spec) validateRequest(c echo.Context, operation *Operation) error {
+	req := c.Request()
+	for _, param := range operation.Parameters {
+		var values []string
+		switch param.In {
+		case "path":
+			if value := c.Param(param.Name); value != "" {
+				values = []string{value}
+			}
+		case "query":
+			values = c.QueryParams()[param.Name]
+		case "header":
+			values = req.Header.Values(param.Name)
+		}
+
+		at := param.In + " param " + param.Name
+		if len(values) == 0 {
+			if param.Required {
+				return fmt.Errorf("%s: missing", at)
+			}
+			continue
+		}
+
+		value, err := s.paramValue(param.Schema, values)
+		if err != nil {
+			return fmt.Errorf("%s: %w", at, err)
+		}
+		if err := s.validate(param.Schema, value, at); err != nil {
+			return err
+		}
+	}
+
+	if operation.RequestBody == nil {
+		return nil
+	}
+	if req.ContentLength == 0 {
+		if operation.RequestBody.Required {
+			return fmt.Errorf("body: missing")
+		}
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType))
+	content, ok := operation.RequestBody.Content[mediaType]
+	if !ok || content.Schema == nil || !isJSON(mediaType) {
+		return nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return fmt.Errorf("body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	value, err := decode(body)
+	if err != nil {
+		return fmt.Errorf("body: not valid JSON: %w", err)
+	}
+	return s.validate(content.Schema, value, "body")
+}
+
+// validateResponse - responses of undocumented statuses are validated against the default response
+func (s *Spec) validateResponse(c echo.Context, operation *Operation, recorder *recorder) error {
+	response, ok := operation.Responses[strconv.Itoa(recorder.status)]
+	if !ok {
+		if response, ok = operation.Responses["default"]; !ok {
+			return fmt.Errorf("response: status %d is not documented", recorder.status)
+		}
+	}
+	if len(response.Content) == 0 || c.Request().Method == http.MethodHead {
+		return nil
+	}
+	if recorder.body.Len() == 0 {
+		return fmt.Errorf("response: status %d is documented with a body", recorder.status)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(recorder.Header().Get(echo.HeaderContentType))
+	content, ok := response.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("response: %s is not documented for status %d", mediaType, recorder.status)
+	}
+	if content.Schema == nil || !isJSON(mediaType) {
+		return nil
+	}
+
+	value, err := decode(recorder.body.Bytes())
+	if err != nil {
+		return fmt.Errorf("response: not valid JSON: %w", err)
+	}
+	return s.validate(content.Schema, value, "response")
+}
+
+// validate - checks a decoded JSON value against a schema, naming the invalid value after at
+func (s *Spec) validate(schema *Schema, value interface{}, at string) error {
+	if schema.Ref != "" {
+		return s.validate(s.resolve(schema), value, at)
+	}
+
+	if schema.OneOf != nil {
+		matches := 0
+		for _, option := range schema.OneOf {
+			if s.validate(option, value, at) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fmt.Errorf("%s: matches %d of its schemas instead of one", at, matches)
+		}
+		return nil
+	}
+
+	valueType := typeOf(value)
+	if len(schema.Type) > 0 && !schema.Type.Has(valueType) && !(valueType == "integer" && schema.Type.Has("number")) {
+		return fmt.Errorf("%s: expected %s, got %s", at, strings.Join(schema.Type, " or "), valueType)
+	}
+
+	switch v := value.(type) {
+	case string:
+		if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, v) {
+			return fmt.Errorf("%s: %q is not one of %s", at, v, strings.Join(schema.Enum, ", "))
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				return fmt.Errorf("%s: %q is not an RFC 3339 date-time", at, v)
+			}
+		}
+	case json.Number:
+		number, _ := v.Float64()
+		if schema.Minimum != nil && number < *schema.Minimum {
+			return fmt.Errorf("%s: %s is less than %v", at, v, *schema.Minimum)
+		}
+		if schema.Maximum != nil && number > *schema.Maximum {
+			return fmt.Errorf("%s: %s is greater than %v", at, v, *schema.Maximum)
+		}
+	case []interface{}:
+		if schema.Items != nil {
+			for i, item := range v {
+				if err := s.validate(schema.Items, item, fmt.Sprintf("%s/%d", at, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range schema.Required {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s: missing %s", at, name)
+			}
+		}
+
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := schema.Properties[name]
+			if !ok {
+				property = schema.AdditionalProperties
+			}
+			if property == nil {
+				continue
+			}
+			if err := s.validate(property, v[name], at+"/"+name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// paramValue - the value of a param as it would be in JSON, so it is validated like bodies are
+func (s *Spec) paramValue(schema *Schema, values []string) (interface{}, error) {
+	schema = s.resolve(schema)
+	if schema.Type.Has("array") && schema.Items != nil {
+		items := make([]interface{}, 0, len(values))
+		for _, value := range values {
+			item, err := s.paramValue(schema.Items, []string{value})
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+
+	value := values[0]
+	switch {
+	case schema.Type.Has("integer"):
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, fmt.Errorf("%q is not an integer", value)
+		}
+		return json.Number(value), nil
+	case schema.Type.Has("number"):
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return nil, fmt.Errorf("%q is not a number", value)
+		}
+		return json.Number(value), nil
+	case schema.Type.Has("boolean"):
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", value)
+		}
+		return b, nil
+	default:
+		return value, nil
+	}
+}
+
+// resolve - the component a schema refers to
+func (s *Spec) resolve(schema *Schema) *Schema {
+	for schema.Ref != "" {
+		resolved, ok := s.document.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+		if !ok {
+			return &Schema{}
+		}
+		schema = resolved
+	}
+	return schema
+}
+
+func typeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			return "number"
+		}
+		return "integer"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func decode(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// isJSON - application/json, and structured syntax suffixes like application/merge-patch+json
+func isJSON(mediaType string) bool {
+	return mediaType == echo.MIMEApplicationJSON || strings.HasSuffix(mediaType, "+json")
+}
+
+func setError(c echo.Context, responseErr *domain.Error) {
+	if httpLog, ok := context.Get(c.Request().Context(), log.HTTPKey).(*log.HTTP); ok {
+		httpLog.Error = responseErr.Error()
+	}
+}
+
+// recorder - holds responses back until they are validated. Streams pass through, since they never end
+type recorder struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	buffered bool
+	decided  bool
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.decided {
+		return
+	}
+	r.decided = true
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header().Get(echo.HeaderContentType))
+	if mediaType == "text/event-stream" {
+		r.ResponseWriter.WriteHeader(status)
+		return
+	}
+	r.status, r.buffered = status, true
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if !r.decided {
+		r.WriteHeader(http.StatusOK)
+	}
+	if r.buffered {
+		return r.body.Write(b)
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) Flush() {
+	if !r.buffered {
+		_ = http.NewResponseController(r.ResponseWriter).Flush()
+	}
+}
+
+func (r *recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(r.ResponseWriter).Hijack()
+}
+
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
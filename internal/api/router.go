@@ -1,43 +1,583 @@
 package api
 
 import (
+	"expvar"
+	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/ivofreitas/device-api/config"
 	"github.com/ivofreitas/device-api/config/db"
-	_ "github.com/ivofreitas/device-api/docs"
+	"github.com/ivofreitas/device-api/internal/adapter/cache"
+	"github.com/ivofreitas/device-api/internal/adapter/event"
+	"github.com/ivofreitas/device-api/internal/adapter/log"
+	"github.com/ivofreitas/device-api/internal/adapter/outbox"
+	"github.com/ivofreitas/device-api/internal/api/brand"
 	"github.com/ivofreitas/device-api/internal/api/device"
+	devicev2 "github.com/ivofreitas/device-api/internal/api/device/v2"
+	"github.com/ivofreitas/device-api/internal/api/graphql"
+	"github.com/ivofreitas/device-api/internal/api/health"
 	"github.com/ivofreitas/device-api/internal/api/middleware"
+	"github.com/ivofreitas/device-api/internal/api/openapi"
+	"github.com/ivofreitas/device-api/internal/api/webhook"
 	"github.com/ivofreitas/device-api/internal/domain"
 	"github.com/labstack/echo/v4"
 	"github.com/swaggo/echo-swagger"
 	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"time"
 )
 
-func register(echo *echo.Echo) {
-	deviceGroup(echo)
-	swaggerGroup(echo)
-}
-
-func swaggerGroup(echo *echo.Echo) {
-	echo.GET("/swagger/*", echoSwagger.WrapHandler)
-}
-
-func deviceGroup(echo *echo.Echo) {
-	deviceServ := device.NewService(device.NewRepository(db.NewPostgresConnection()))
-	createHdl := middleware.NewHandler(deviceServ.Create, http.StatusCreated, &domain.Device{})
-	updateHdl := middleware.NewHandler(deviceServ.Update, http.StatusOK, &domain.Update{})
-	patchHdl := middleware.NewHandler(deviceServ.Patch, http.StatusOK, &domain.Patch{})
-	getAllHdl := middleware.NewHandler(deviceServ.GetAll, http.StatusOK, nil)
-	getByIdHdl := middleware.NewHandler(deviceServ.GetById, http.StatusOK, &domain.GetById{})
-	getByBrandHdl := middleware.NewHandler(deviceServ.GetByBrand, http.StatusOK, &domain.GetByBrand{})
-	getByStateHdl := middleware.NewHandler(deviceServ.GetByState, http.StatusOK, &domain.GetByState{})
-	deleteHdl := middleware.NewHandler(deviceServ.Delete, http.StatusNoContent, &domain.Delete{})
-
-	group := echo.Group("v1/devices")
-	group.POST("", createHdl.Handle)
-	group.PUT("/:id", updateHdl.Handle)
-	group.PATCH("/:id", patchHdl.Handle)
-	group.GET("", getAllHdl.Handle)
-	group.GET("/:id", getByIdHdl.Handle)
-	group.GET("/brand/:brand", getByBrandHdl.Handle)
-	group.GET("/state/:state", getByStateHdl.Handle)
-	group.DELETE("/:id", deleteHdl.Handle)
+var deviceCacheMetrics = cache.NewMetrics("device_cache")
+
+// unknownBrand - the error of the routes writing devices when BRANDS_STRICT is on
+var unknownBrand = map[int]string{http.StatusUnprocessableEntity: "Brand not in the catalogue"}
+
+// versions - the API versions of every resource. Unversioned paths, /devices/7, are served in the version asked for
+// with the vendor media type in Accept, or in the first one
+var versions = map[string][]int{"devices": {1, 2}, "webhooks": {1}, "brands": {1}}
+
+// register - rateLimit guards the API routes, while probes, metrics and docs are never limited
+func register(echo *echo.Echo, cluster *db.Cluster, healthHdl *health.Handler, bus *event.Bus, deviceServ *device.Service,
+	devices device.Repository, rateLimit echo.MiddlewareFunc) {
+	env := config.GetEnv()
+	// Reloading the config turns the docs on and off
+	docEnabled := func() bool { return config.GetEnv().Doc.Enabled }
+
+	spec := newSpec()
+	healthGroup(echo, spec, healthHdl)
+	deviceGroup(echo, env, spec, deviceServ, bus, rateLimit)
+	deviceV2Group(echo, spec, deviceServ, rateLimit)
+	webhookGroup(echo, spec, cluster, rateLimit)
+	brandGroup(echo, spec, cluster, devices, rateLimit)
+	graphqlGroup(echo, env, docEnabled, deviceServ, bus, rateLimit)
+	debugGroup(echo, env)
+	swaggerGroup(echo, spec, docEnabled)
+	validate(echo, spec, openapi.Validation{Requests: env.Doc.ValidateRequests, Responses: env.Doc.ValidateResponses})
+}
+
+// NewHandler - the REST and GraphQL APIs over a device repository, with the middleware and error handling of the
+// server. Webhooks, rate limits and dependency checks need the database and are left out, which makes it the
+// handler of in-process test servers. Those hold their responses to the OpenAPI spec. The handler is configured by
+// env alone, which is never reloaded
+func NewHandler(env *config.Env, repository device.Repository, bus *event.Bus) *echo.Echo {
+	log.Init(env.Log.Level)
+	docEnabled := func() bool { return env.Doc.Enabled }
+
+	e := newEcho(env)
+	spec := newSpec()
+	unlimited := func(next echo.HandlerFunc) echo.HandlerFunc { return next }
+	deviceServ := device.NewService(repository)
+	healthGroup(e, spec, health.NewHandler(env.Server.ReadinessTimeout))
+	deviceGroup(e, env, spec, deviceServ, bus, unlimited)
+	deviceV2Group(e, spec, deviceServ, unlimited)
+	graphqlGroup(e, env, docEnabled, deviceServ, bus, unlimited)
+	debugGroup(e, env)
+	swaggerGroup(e, spec, docEnabled)
+	validate(e, spec, openapi.Validation{Requests: env.Doc.ValidateRequests, Responses: true})
+	return e
+}
+
+func newSpec() *openapi.Spec {
+	return openapi.NewSpec(openapi.Info{
+		Title: "Device API",
+		Description: "Manages a device inventory. Resources are served under /v1 and /v2, or without a version under " +
+			"the one asked for with Accept: application/vnd.device.v2+json, v1 by default",
+		Version: "2.0",
+	})
+}
+
+// validate - checks requests and responses against the spec, which every route is registered in by then
+func validate(e *echo.Echo, spec *openapi.Spec, validation openapi.Validation) {
+	if validation.Requests || validation.Responses {
+		e.Use(spec.Validator(validation))
+	}
+}
+
+// routes - registers the handlers of an API version on a group and documents them in the spec, so neither can miss
+// a route
+type routes struct {
+	group  *echo.Group
+	prefix string
+	spec   *openapi.Spec
+	// mediaType - the vendor media type of the version
+	mediaType string
+	// m - middleware of the routes added, besides that of the group
+	m          []echo.MiddlewareFunc
+	deprecated bool
+}
+
+func newRoutes(e *echo.Echo, spec *openapi.Spec, version int, resource string, m ...echo.MiddlewareFunc) routes {
+	prefix := "/v" + strconv.Itoa(version) + resource
+	return routes{
+		group:     e.Group(prefix, append([]echo.MiddlewareFunc{middleware.Version(version)}, m...)...),
+		prefix:    prefix,
+		spec:      spec,
+		mediaType: middleware.VendorMediaType(version),
+	}
+}
+
+// deprecate - routes added through the result announce their deprecation and sunset, and are documented as deprecated
+func (r routes) deprecate(deprecatedAt, sunset time.Time) routes {
+	r.m = append(slices.Clone(r.m), middleware.Deprecation(deprecatedAt, sunset))
+	r.deprecated = true
+	return r
+}
+
+func (r routes) add(method, path string, handler middleware.Handler, doc openapi.Doc) {
+	r.group.Add(method, path, handler.Handle, r.m...)
+	doc.MediaTypes = append(doc.MediaTypes, r.mediaType)
+	doc.Deprecated = doc.Deprecated || r.deprecated
+	r.spec.Add(method, r.prefix+path, handler.Metadata(), doc)
+}
+
+// debugGroup - the expvar metrics publish the command line, flags and secrets given on it included, so they are
+// only served with DEBUG_VARS_ENABLED, meant for instances whose port is not reachable from outside
+func debugGroup(e *echo.Echo, env *config.Env) {
+	if !env.Server.DebugVars {
+		return
+	}
+	e.GET("/debug/vars", echo.WrapHandler(expvar.Handler()))
+}
+
+func healthGroup(echo *echo.Echo, spec *openapi.Spec, healthHdl *health.Handler) {
+	echo.GET("/healthz", healthHdl.Liveness)
+	echo.GET("/readyz", healthHdl.Readiness)
+
+	healthType := reflect.TypeFor[domain.Health]()
+	spec.AddOperation(http.MethodGet, "/healthz", &openapi.Operation{
+		OperationId: "liveness",
+		Summary:     "Liveness probe",
+		Description: "Reports that the process is up and serving requests",
+		Tags:        []string{"Health"},
+		Responses:   map[string]*openapi.Response{"200": spec.Response("Process alive", healthType)},
+	})
+	spec.AddOperation(http.MethodGet, "/readyz", &openapi.Operation{
+		OperationId: "readiness",
+		Summary:     "Readiness probe",
+		Description: "Reports whether the instance can serve traffic, with the result of every dependency check",
+		Tags:        []string{"Health"},
+		Responses: map[string]*openapi.Response{
+			"200": spec.Response("Ready", healthType),
+			"503": spec.Response("Not ready or shutting down", healthType),
+		},
+	})
+}
+
+// swaggerGroup - the API explorers, Swagger UI for REST and GraphiQL for GraphQL, and the OpenAPI 3.1 spec, which
+// Swagger UI shows. enabled is checked on every request, so reloading the config turns them on and off
+func swaggerGroup(e *echo.Echo, spec *openapi.Spec, enabled func() bool) {
+	docEnabled := onlyIf(enabled)
+	e.GET("/openapi.json", spec.Handle, docEnabled)
+	// Without the swag defaults, doc.json and doc.yaml, that nothing serves
+	noDefaults := func(c *echoSwagger.Config) { c.URLs = nil }
+	e.GET("/swagger/*", echoSwagger.EchoWrapHandler(noDefaults, echoSwagger.URL("/openapi.json")), docEnabled)
+	e.GET("/graphiql", echo.WrapHandler(playground.Handler("GraphiQL", "/graphql")), docEnabled)
+}
+
+// onlyIf - routes not found while enabled reports false
+func onlyIf(enabled func() bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !enabled() {
+				return echo.ErrNotFound
+			}
+			return next(c)
+		}
+	}
+}
+
+func graphqlGroup(e *echo.Echo, env *config.Env, docEnabled func() bool, deviceServ *device.Service, bus *event.Bus,
+	rateLimit echo.MiddlewareFunc) {
+	// Introspection documents the schema, so like the explorers it follows docEnabled on every operation
+	srv := graphql.NewHandler(deviceServ, bus, env.GraphQL.MaxDepth, env.GraphQL.MaxComplexity, env.Events.Heartbeat,
+		docEnabled)
+	e.Any("/graphql", echo.WrapHandler(srv), rateLimit)
+}
+
+// deviceRepository - the devices of the cluster, cached with CACHE_ENABLED
+func deviceRepository(cluster *db.Cluster) device.Repository {
+	env := config.GetEnv()
+
+	repository := device.NewRepository(cluster, outbox.NewWriter())
+	if env.Cache.Enabled {
+		repository = device.NewCachedRepository(repository, cache.NewLRU(env.Cache.Size, deviceCacheMetrics), env.Cache.TTL,
+			env.Cache.StatsTTL)
+	}
+	return repository
+}
+
+// deviceService - the business logic shared by the REST and gRPC APIs
+func deviceService(cluster *db.Cluster, repository device.Repository) *device.Service {
+	brands := brand.NewRepository(cluster.Primary(), outbox.NewWriter(), nil)
+	return device.NewService(repository, device.WithBrands(brands, config.GetEnv().Brands.Strict))
+}
+
+func deviceGroup(echo *echo.Echo, env *config.Env, spec *openapi.Spec, deviceServ *device.Service, bus *event.Bus,
+	rateLimit echo.MiddlewareFunc) {
+	createHdl := middleware.NewHandler(deviceServ.Create, http.StatusCreated)
+	updateHdl := middleware.NewHandler(deviceServ.Update, http.StatusOK)
+	patchHdl := middleware.ByContentType(middleware.NewHandler(deviceServ.Patch, http.StatusOK), map[string]middleware.Handler{
+		"application/merge-patch+json": middleware.NewHandler(deviceServ.MergePatch, http.StatusOK),
+		"application/json-patch+json":  middleware.NewHandler(deviceServ.JSONPatch, http.StatusOK),
+	})
+	getAllHdl := middleware.NewHandler(middleware.NoRequest(deviceServ.GetAll), http.StatusOK)
+	getByIdHdl := middleware.NewHandler(deviceServ.GetById, http.StatusOK)
+	getByBrandHdl := middleware.NewHandler(deviceServ.GetByBrand, http.StatusOK)
+	getByStateHdl := middleware.NewHandler(deviceServ.GetByState, http.StatusOK)
+	deleteHdl := middleware.NewHandler(middleware.NoContent(deviceServ.Delete), http.StatusNoContent)
+	eventsHdl := middleware.NewEventStream(bus, env.Events.Heartbeat)
+	statsHdl := middleware.NewHandler(deviceServ.Stats, http.StatusOK)
+	searchHdl := middleware.NewHandler(deviceServ.Search, http.StatusOK)
+
+	tags := []string{"Device"}
+	id := map[string]string{"id": "Device ID"}
+	// v2 supersedes every route but the event stream, the stats and the search
+	group := newRoutes(echo, spec, 1, "/devices", rateLimit)
+	deprecated := group.deprecate(env.API.V1Deprecation, env.API.V1Sunset)
+	deprecated.add(http.MethodPost, "", createHdl, openapi.Doc{
+		Id:          "createDevice",
+		Summary:     "Create a new device",
+		Description: "Adds a new device to the inventory",
+		Tags:        tags,
+		Success:     "Created device",
+		Errors:      unknownBrand,
+	})
+	deprecated.add(http.MethodPut, "/:id", updateHdl, openapi.Doc{
+		Id:          "updateDevice",
+		Summary:     "Update an existing device",
+		Description: "Replaces the details of a device, if allowed. Devices in use keep their name and brand",
+		Tags:        tags,
+		Params:      id,
+		Success:     "Updated device",
+		Errors: map[int]string{
+			http.StatusForbidden:           "Forbidden update",
+			http.StatusNotFound:            "Device not found",
+			http.StatusUnprocessableEntity: "Brand not in the catalogue",
+		},
+	})
+	deprecated.add(http.MethodPatch, "/:id", patchHdl, openapi.Doc{
+		Id:      "patchDevice",
+		Summary: "Partially update an existing device",
+		Description: "Only the fields given are modified. With `Content-Type: application/merge-patch+json` the body " +
+			"is an RFC 7396 merge patch, and with `application/json-patch+json` a list of RFC 6902 operations, " +
+			"applied to the current device",
+		Tags:    tags,
+		Params:  id,
+		Success: "Updated device",
+		Errors: map[int]string{
+			http.StatusForbidden:           "Forbidden update",
+			http.StatusNotFound:            "Device not found",
+			http.StatusConflict:            "JSON Patch test operation failed",
+			http.StatusUnprocessableEntity: "Patched document is not a valid device, or its brand is not in the catalogue",
+		},
+	})
+	deprecated.add(http.MethodGet, "", getAllHdl, openapi.Doc{
+		Id:          "listDevices",
+		Summary:     "Get all devices",
+		Description: "Retrieves a list of all devices",
+		Tags:        tags,
+		Success:     "List of devices",
+	})
+	group.add(http.MethodGet, "/events", eventsHdl, openapi.Doc{
+		Id:      "streamDeviceEvents",
+		Summary: "Stream device change events",
+		Description: "Server-Sent Events stream of device.created, device.updated, device.state_changed and " +
+			"device.deleted events. Reconnecting clients resume after the Last-Event-ID header from a bounded replay buffer",
+		Tags: tags,
+		Params: map[string]string{
+			"id":            "Only events of this device",
+			"brand":         "Only events of devices of this brand",
+			"state":         "Only events of devices in this state",
+			"last_event_id": "Resume after this event id, like the Last-Event-ID header",
+		},
+		Success: "Event stream",
+	})
+	group.add(http.MethodGet, "/stats", statsHdl, openapi.Doc{
+		Id:      "getDeviceStats",
+		Summary: "Get device statistics",
+		Description: "Counts the devices matching the filters of the list, grouped by any combination of brand, state " +
+			"and one time bucket of the creation time. Counts may lag writes by a few seconds when cached",
+		Tags: tags,
+		Params: map[string]string{
+			"name":           "Name contains, ignoring case",
+			"brand":          "Device brand",
+			"state":          "Device states",
+			"created_after":  "Created after, RFC 3339",
+			"created_before": "Created before, RFC 3339",
+			"group_by":       "Dimensions to group by: brand, state, day, week or month",
+		},
+		Success: "Device counts",
+	})
+	group.add(http.MethodGet, "/search", searchHdl, openapi.Doc{
+		Id:      "searchDevices",
+		Summary: "Search devices",
+		Description: "Finds the devices whose name or brand match the words of the query, or resemble it to tolerate " +
+			"typos, best first. Matched words are highlighted, and brands resembling the query are suggested",
+		Tags: tags,
+		Params: map[string]string{
+			"q":     "Search query",
+			"limit": "Results, 20 by default and up to 100",
+		},
+		Success: "Ranked results and brand suggestions",
+	})
+	deprecated.add(http.MethodGet, "/:id", getByIdHdl, openapi.Doc{
+		Id:          "getDevice",
+		Summary:     "Get a device by ID",
+		Description: "Retrieves a single device by its ID",
+		Tags:        tags,
+		Params:      id,
+		Success:     "Device details",
+		Errors:      map[int]string{http.StatusNotFound: "Device not found"},
+	})
+	deprecated.add(http.MethodGet, "/brand/:brand", getByBrandHdl, openapi.Doc{
+		Id:          "getDevicesByBrand",
+		Summary:     "Get devices by brand",
+		Description: "Retrieves every device of a brand",
+		Tags:        tags,
+		Params:      map[string]string{"brand": "Device brand"},
+		Success:     "Devices of the brand",
+	})
+	deprecated.add(http.MethodGet, "/state/:state", getByStateHdl, openapi.Doc{
+		Id:          "getDevicesByState",
+		Summary:     "Get devices by state",
+		Description: "Retrieves every device in a state",
+		Tags:        tags,
+		Params:      map[string]string{"state": "Device state"},
+		Success:     "Devices in the state",
+	})
+	deprecated.add(http.MethodDelete, "/:id", deleteHdl, openapi.Doc{
+		Id:          "deleteDevice",
+		Summary:     "Delete a device",
+		Description: "Removes a device from the inventory",
+		Tags:        tags,
+		Params:      id,
+		Success:     "No content",
+		Errors:      map[int]string{http.StatusForbidden: "Cannot delete device in use", http.StatusNotFound: "Device not found"},
+	})
+}
+
+// deviceV2Group - the device routes of v2, served by the same service in the DTOs of v2. Brand and state listings
+// are filters of the list, and events are still only streamed in v1
+func deviceV2Group(echo *echo.Echo, spec *openapi.Spec, deviceServ *device.Service, rateLimit echo.MiddlewareFunc) {
+	v2Serv := devicev2.NewService(deviceServ)
+	createHdl := middleware.NewHandler(v2Serv.Create, http.StatusCreated)
+	updateHdl := middleware.NewHandler(v2Serv.Update, http.StatusOK)
+	patchHdl := middleware.NewHandler(v2Serv.Patch, http.StatusOK)
+	listHdl := middleware.NewHandler(v2Serv.List, http.StatusOK)
+	getByIdHdl := middleware.NewHandler(v2Serv.GetById, http.StatusOK)
+	deleteHdl := middleware.NewHandler(middleware.NoContent(v2Serv.Delete), http.StatusNoContent)
+
+	tags := []string{"Device v2"}
+	id := map[string]string{"id": "Device ID"}
+	notFound := map[int]string{http.StatusNotFound: "Device not found"}
+	group := newRoutes(echo, spec, 2, "/devices", rateLimit)
+	group.add(http.MethodPost, "", createHdl, openapi.Doc{
+		Id:          "createDeviceV2",
+		Summary:     "Create a new device",
+		Description: "Adds a new device to the inventory. The state defaults to available",
+		Tags:        tags,
+		Success:     "Created device",
+		Errors:      unknownBrand,
+	})
+	group.add(http.MethodPut, "/:id", updateHdl, openapi.Doc{
+		Id:          "updateDeviceV2",
+		Summary:     "Replace a device",
+		Description: "Replaces the details and labels of a device, if allowed. Devices in use keep their name and brand",
+		Tags:        tags,
+		Params:      id,
+		Success:     "Updated device",
+		Errors: map[int]string{
+			http.StatusForbidden:           "Forbidden update",
+			http.StatusNotFound:            "Device not found",
+			http.StatusUnprocessableEntity: "Brand not in the catalogue",
+		},
+	})
+	group.add(http.MethodPatch, "/:id", patchHdl, openapi.Doc{
+		Id:          "patchDeviceV2",
+		Summary:     "Partially update a device",
+		Description: "Only the fields given are modified, and labels given replace all of them",
+		Tags:        tags,
+		Params:      id,
+		Success:     "Updated device",
+		Errors: map[int]string{
+			http.StatusForbidden:           "Forbidden update",
+			http.StatusNotFound:            "Device not found",
+			http.StatusUnprocessableEntity: "Brand not in the catalogue",
+		},
+	})
+	group.add(http.MethodGet, "", listHdl, openapi.Doc{
+		Id:          "listDevicesV2",
+		Summary:     "List devices",
+		Description: "Retrieves a page of the devices, narrowed by the filters. Without a limit pages hold 50 devices",
+		Tags:        tags,
+		Params: map[string]string{
+			"name":           "Name contains, ignoring case",
+			"brand":          "Device brand",
+			"state":          "Device states",
+			"created_after":  "Created after, RFC 3339",
+			"created_before": "Created before, RFC 3339",
+			"sort_by":        "Sort field",
+			"desc":           "Sort descending",
+			"limit":          "Page size, up to 500",
+			"offset":         "Devices to skip",
+		},
+		Success: "Page of devices",
+	})
+	group.add(http.MethodGet, "/:id", getByIdHdl, openapi.Doc{
+		Id:          "getDeviceV2",
+		Summary:     "Get a device by ID",
+		Description: "Retrieves a single device by its ID",
+		Tags:        tags,
+		Params:      id,
+		Success:     "Device details",
+		Errors:      notFound,
+	})
+	group.add(http.MethodDelete, "/:id", deleteHdl, openapi.Doc{
+		Id:          "deleteDeviceV2",
+		Summary:     "Delete a device",
+		Description: "Removes a device from the inventory, unless it is in use",
+		Tags:        tags,
+		Params:      id,
+		Success:     "No content",
+		Errors:      map[int]string{http.StatusForbidden: "Cannot delete device in use", http.StatusNotFound: "Device not found"},
+	})
+}
+
+func webhookGroup(echo *echo.Echo, spec *openapi.Spec, cluster *db.Cluster, rateLimit echo.MiddlewareFunc) {
+	webhookServ := webhook.NewService(webhook.NewRepository(cluster.Primary()))
+	createHdl := middleware.NewHandler(webhookServ.Create, http.StatusCreated)
+	updateHdl := middleware.NewHandler(webhookServ.Update, http.StatusOK)
+	getAllHdl := middleware.NewHandler(middleware.NoRequest(webhookServ.GetAll), http.StatusOK)
+	getByIdHdl := middleware.NewHandler(webhookServ.GetById, http.StatusOK)
+	deleteHdl := middleware.NewHandler(middleware.NoContent(webhookServ.Delete), http.StatusNoContent)
+	getDeliveriesHdl := middleware.NewHandler(webhookServ.GetDeliveries, http.StatusOK)
+	retryDeliveryHdl := middleware.NewHandler(webhookServ.RetryDelivery, http.StatusOK)
+
+	tags := []string{"Webhook"}
+	id := map[string]string{"id": "Webhook ID"}
+	notFound := map[int]string{http.StatusNotFound: "Webhook not found"}
+	group := newRoutes(echo, spec, 1, "/webhooks", rateLimit)
+	group.add(http.MethodPost, "", createHdl, openapi.Doc{
+		Id:      "createWebhook",
+		Summary: "Subscribe a webhook",
+		Description: "Registers a URL to be notified of device events. Deliveries are signed with HMAC-SHA256 using " +
+			"the returned secret, which is generated when not provided and is only shown in this response",
+		Tags:    tags,
+		Success: "Created subscription",
+	})
+	group.add(http.MethodPut, "/:id", updateHdl, openapi.Doc{
+		Id:          "updateWebhook",
+		Summary:     "Update a webhook subscription",
+		Description: "Replaces the subscription settings. The secret is rotated only when a new one is provided",
+		Tags:        tags,
+		Params:      id,
+		Success:     "Updated subscription",
+		Errors:      notFound,
+	})
+	group.add(http.MethodGet, "", getAllHdl, openapi.Doc{
+		Id:          "listWebhooks",
+		Summary:     "Get all webhook subscriptions",
+		Description: "Retrieves every subscription, without secrets",
+		Tags:        tags,
+		Success:     "List of subscriptions",
+	})
+	group.add(http.MethodGet, "/:id", getByIdHdl, openapi.Doc{
+		Id:          "getWebhook",
+		Summary:     "Get a webhook subscription by ID",
+		Description: "Retrieves a single subscription, without its secret",
+		Tags:        tags,
+		Params:      id,
+		Success:     "Subscription details",
+		Errors:      notFound,
+	})
+	group.add(http.MethodDelete, "/:id", deleteHdl, openapi.Doc{
+		Id:          "deleteWebhook",
+		Summary:     "Delete a webhook subscription",
+		Description: "Removes a subscription together with its delivery log",
+		Tags:        tags,
+		Params:      id,
+		Success:     "No content",
+		Errors:      notFound,
+	})
+	group.add(http.MethodGet, "/:id/deliveries", getDeliveriesHdl, openapi.Doc{
+		Id:          "listWebhookDeliveries",
+		Summary:     "Get the delivery log of a webhook subscription",
+		Description: "Retrieves the latest deliveries with their status, attempts and last error",
+		Tags:        tags,
+		Params:      map[string]string{"id": "Webhook ID", "status": "Delivery status", "limit": "Maximum number of deliveries, 50 by default"},
+		Success:     "Delivery log",
+		Errors:      notFound,
+	})
+	group.add(http.MethodPost, "/:id/deliveries/:delivery_id/retry", retryDeliveryHdl, openapi.Doc{
+		Id:          "retryWebhookDelivery",
+		Summary:     "Retry a dead-lettered delivery",
+		Description: "Moves a dead delivery back to pending with a fresh attempt budget",
+		Tags:        tags,
+		Params:      map[string]string{"id": "Webhook ID", "delivery_id": "Delivery ID"},
+		Success:     "Rescheduled delivery",
+		Errors:      map[int]string{http.StatusNotFound: "Dead delivery not found"},
+	})
+}
+
+func brandGroup(echo *echo.Echo, spec *openapi.Spec, cluster *db.Cluster, devices device.Repository,
+	rateLimit echo.MiddlewareFunc) {
+	// Renames bypass the device repository, so they drop the devices from its cache themselves
+	var devicesCache brand.Cache
+	if invalidator, ok := devices.(device.Invalidator); ok {
+		devicesCache = invalidator
+	}
+	brandServ := brand.NewService(brand.NewRepository(cluster.Primary(), outbox.NewWriter(), devicesCache))
+	createHdl := middleware.NewHandler(brandServ.Create, http.StatusCreated)
+	updateHdl := middleware.NewHandler(brandServ.Update, http.StatusOK)
+	getAllHdl := middleware.NewHandler(middleware.NoRequest(brandServ.GetAll), http.StatusOK)
+	getByIdHdl := middleware.NewHandler(brandServ.GetById, http.StatusOK)
+	deleteHdl := middleware.NewHandler(middleware.NoContent(brandServ.Delete), http.StatusNoContent)
+
+	tags := []string{"Brand"}
+	id := map[string]string{"id": "Brand ID"}
+	notFound := map[int]string{http.StatusNotFound: "Brand not found"}
+	group := newRoutes(echo, spec, 1, "/brands", rateLimit)
+	group.add(http.MethodPost, "", createHdl, openapi.Doc{
+		Id:      "createBrand",
+		Summary: "Add a brand to the catalogue",
+		Description: "Adds a brand with the aliases it is also written as. Names and aliases match in any case and " +
+			"spacing, and must not match another brand",
+		Tags:    tags,
+		Success: "Created brand",
+		Errors:  map[int]string{http.StatusConflict: "Name or alias of another brand"},
+	})
+	group.add(http.MethodPut, "/:id", updateHdl, openapi.Doc{
+		Id:          "updateBrand",
+		Summary:     "Update a brand of the catalogue",
+		Description: "Replaces the name and aliases of a brand. Devices of the brand are renamed along with it",
+		Tags:        tags,
+		Params:      id,
+		Success:     "Updated brand",
+		Errors:      map[int]string{http.StatusNotFound: "Brand not found", http.StatusConflict: "Name or alias of another brand"},
+	})
+	group.add(http.MethodGet, "", getAllHdl, openapi.Doc{
+		Id:          "listBrands",
+		Summary:     "Get the brand catalogue",
+		Description: "Retrieves every brand with its aliases, ordered by name",
+		Tags:        tags,
+		Success:     "List of brands",
+	})
+	group.add(http.MethodGet, "/:id", getByIdHdl, openapi.Doc{
+		Id:          "getBrand",
+		Summary:     "Get a brand by ID",
+		Description: "Retrieves a single brand with its aliases",
+		Tags:        tags,
+		Params:      id,
+		Success:     "Brand details",
+		Errors:      notFound,
+	})
+	group.add(http.MethodDelete, "/:id", deleteHdl, openapi.Doc{
+		Id:          "deleteBrand",
+		Summary:     "Delete a brand of the catalogue",
+		Description: "Removes a brand no device resolves to",
+		Tags:        tags,
+		Params:      id,
+		Success:     "No content",
+		Errors:      map[int]string{http.StatusNotFound: "Brand not found", http.StatusConflict: "Brand of devices"},
+	})
 }
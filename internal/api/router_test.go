@@ -0,0 +1,331 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ivofreitas/device-api/config"
+	"github.com/ivofreitas/device-api/internal/adapter/event"
+	"github.com/ivofreitas/device-api/internal/adapter/log"
+	"github.com/ivofreitas/device-api/internal/api"
+	"github.com/ivofreitas/device-api/internal/api/device"
+	mocks "github.com/ivofreitas/device-api/internal/api/device/mock"
+	"github.com/ivofreitas/device-api/internal/api/openapi"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the HTTP tests")
+
+// env - the defaults, with v1 deprecated as in the deployment config, so the goldens show its headers
+func env() *config.Env {
+	env := config.Defaults()
+	env.API.V1Deprecation = time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	env.API.V1Sunset = time.Date(2027, 4, 19, 0, 0, 0, 0, time.UTC)
+	return env
+}
+
+// seeded - a repository holding a device of each state, with fixed ids and creation times
+func seeded(t *testing.T) device.Repository {
+	ctx := context.Background()
+	repository := device.NewMemoryRepository()
+	for i, d := range []domain.Device{
+		{Name: "Galaxy S24", Brand: "Samsung", State: domain.InUseState},
+		{Name: "Pixel 9", Brand: "Google", State: domain.AvailableState},
+		{Name: "Galaxy Tab", Brand: "Samsung", State: domain.InactiveState},
+	} {
+		created, err := repository.Create(ctx, &d)
+		require.NoError(t, err)
+		created.CreationTime = time.Date(2025, 1, 2+i, 10, 0, 0, 0, time.UTC)
+		require.NoError(t, repository.Update(ctx, created))
+	}
+	return repository
+}
+
+func TestHTTP(t *testing.T) {
+	log.Init("")
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	failing := func(t *testing.T) device.Repository {
+		repository := new(mocks.Repository)
+		repository.On("GetAll", mock.Anything).Return(nil, errors.New("connection refused"))
+		repository.On("GetById", mock.Anything, 1).Run(func(mock.Arguments) { panic("repository bug") })
+		return repository
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		accept      string
+		body        string
+		repository  func(t *testing.T) device.Repository
+		status      int
+		// golden - file under testdata/http, named after the test unless set
+		golden string
+	}{
+		{name: "Get All Devices", method: http.MethodGet, target: "/v1/devices", status: http.StatusOK},
+		{name: "Get All Devices With A Trailing Slash", method: http.MethodGet, target: "/v1/devices/",
+			status: http.StatusOK, golden: "get_all_devices"},
+		{name: "Get All Devices Without Any", method: http.MethodGet, target: "/v1/devices",
+			repository: func(*testing.T) device.Repository { return device.NewMemoryRepository() }, status: http.StatusOK},
+		{name: "Get Device", method: http.MethodGet, target: "/v1/devices/1", status: http.StatusOK},
+		{name: "Get Device As XML", method: http.MethodGet, target: "/v1/devices/1", accept: "application/xml",
+			status: http.StatusOK},
+		{name: "Get Device In An Unsupported Media Type", method: http.MethodGet, target: "/v1/devices/1",
+			accept: "text/csv", status: http.StatusNotAcceptable},
+		{name: "Get Missing Device", method: http.MethodGet, target: "/v1/devices/42", status: http.StatusNotFound},
+		{name: "Get Device With A Non-Numeric Id", method: http.MethodGet, target: "/v1/devices/abc",
+			status: http.StatusBadRequest},
+		{name: "Get Devices By Brand", method: http.MethodGet, target: "/v1/devices/brand/Samsung", status: http.StatusOK},
+		{name: "Get Devices By State", method: http.MethodGet, target: "/v1/devices/state/available", status: http.StatusOK},
+		{name: "Get Devices By State With A Trailing Slash", method: http.MethodGet, target: "/v1/devices/state/available/",
+			status: http.StatusOK, golden: "get_devices_by_state"},
+		{name: "Get Devices By An Invalid State", method: http.MethodGet, target: "/v1/devices/state/broken",
+			status: http.StatusBadRequest},
+		{name: "Get Device Stats", method: http.MethodGet, target: "/v1/devices/stats", status: http.StatusOK},
+		{name: "Get Device Stats By Brand And State", method: http.MethodGet,
+			target: "/v1/devices/stats?group_by=brand&group_by=state", status: http.StatusOK},
+		{name: "Get Device Stats Of A Brand By Day", method: http.MethodGet,
+			target: "/v1/devices/stats?brand=Samsung&group_by=day", status: http.StatusOK},
+		{name: "Get Device Stats By Two Time Buckets", method: http.MethodGet,
+			target: "/v1/devices/stats?group_by=day&group_by=week", status: http.StatusBadRequest},
+		{name: "Get Device Stats By An Unknown Dimension", method: http.MethodGet,
+			target: "/v1/devices/stats?group_by=color", status: http.StatusBadRequest},
+		{name: "Search Devices", method: http.MethodGet, target: "/v1/devices/search?q=galaxy+tab", status: http.StatusOK},
+		{name: "Search Devices With A Typo", method: http.MethodGet, target: "/v1/devices/search?q=Samsng&limit=1",
+			status: http.StatusOK},
+		{name: "Search Devices Without A Query", method: http.MethodGet, target: "/v1/devices/search",
+			status: http.StatusBadRequest},
+		{name: "Create Device", method: http.MethodPost, target: "/v1/devices", contentType: "application/json",
+			body: `{"name":"Pixel 9 Pro","brand":"Google","state":"available"}`, status: http.StatusCreated},
+		{name: "Create Device With Bad JSON", method: http.MethodPost, target: "/v1/devices",
+			contentType: "application/json", body: `{"name":`, status: http.StatusBadRequest},
+		{name: "Create Device With An Invalid State", method: http.MethodPost, target: "/v1/devices",
+			contentType: "application/json", body: `{"name":"Pixel 9 Pro","brand":"Google","state":"broken"}`,
+			status: http.StatusBadRequest},
+		{name: "Create Device In An Unsupported Media Type", method: http.MethodPost, target: "/v1/devices",
+			contentType: "text/plain", body: `Pixel 9 Pro`, status: http.StatusUnsupportedMediaType},
+		{name: "Update Device", method: http.MethodPut, target: "/v1/devices/2", contentType: "application/json",
+			body: `{"name":"Pixel 9a","brand":"Google","state":"inactive"}`, status: http.StatusOK},
+		{name: "Update Name Of A Device In Use", method: http.MethodPut, target: "/v1/devices/1",
+			contentType: "application/json", body: `{"name":"Galaxy S25","brand":"Samsung","state":"in-use"}`,
+			status: http.StatusForbidden},
+		{name: "Update Missing Device", method: http.MethodPut, target: "/v1/devices/42", contentType: "application/json",
+			body: `{"name":"Pixel 9a","brand":"Google","state":"inactive"}`, status: http.StatusNotFound},
+		{name: "Patch Device", method: http.MethodPatch, target: "/v1/devices/2", contentType: "application/json",
+			body: `{"state":"in-use"}`, status: http.StatusOK},
+		{name: "Merge Patch Device", method: http.MethodPatch, target: "/v1/devices/2",
+			contentType: "application/merge-patch+json", body: `{"name":"Pixel 9a"}`, status: http.StatusOK},
+		{name: "JSON Patch With A Failing Test", method: http.MethodPatch, target: "/v1/devices/1",
+			contentType: "application/json-patch+json",
+			body:        `[{"op":"test","path":"/state","value":"available"},{"op":"replace","path":"/state","value":"in-use"}]`,
+			status:      http.StatusConflict},
+		{name: "Delete Device", method: http.MethodDelete, target: "/v1/devices/2", status: http.StatusNoContent},
+		{name: "Delete Device In Use", method: http.MethodDelete, target: "/v1/devices/1", status: http.StatusForbidden},
+		{name: "Get Device Without A Version", method: http.MethodGet, target: "/devices/1", status: http.StatusOK,
+			golden: "get_device"},
+		{name: "Get Device With The V2 Media Type", method: http.MethodGet, target: "/devices/1",
+			accept: "application/vnd.device.v2+json", status: http.StatusOK},
+		{name: "Get Device With An Unknown Version", method: http.MethodGet, target: "/devices/1",
+			accept: "application/vnd.device.v3+json", status: http.StatusNotAcceptable},
+		{name: "Get V1 Device With The V2 Media Type", method: http.MethodGet, target: "/v1/devices/1",
+			accept: "application/vnd.device.v2+json", status: http.StatusNotAcceptable},
+		{name: "Get Device V2", method: http.MethodGet, target: "/v2/devices/1", status: http.StatusOK},
+		{name: "Get Device V2 As XML", method: http.MethodGet, target: "/v2/devices/2", accept: "application/xml",
+			status: http.StatusOK},
+		{name: "Get Devices V2", method: http.MethodGet, target: "/v2/devices?brand=Samsung&sort_by=name&limit=1",
+			status: http.StatusOK},
+		{name: "Get Devices V2 By Several States", method: http.MethodGet, target: "/v2/devices?state=in-use&state=inactive",
+			status: http.StatusOK},
+		{name: "Get Devices V2 With An Invalid State", method: http.MethodGet, target: "/v2/devices?state=broken",
+			status: http.StatusBadRequest},
+		{name: "Get Devices V2 With A Limit Too High", method: http.MethodGet, target: "/v2/devices?limit=1000",
+			status: http.StatusBadRequest},
+		{name: "Create Device V2", method: http.MethodPost, target: "/v2/devices", contentType: "application/json",
+			body:   `{"name":"Pixel 9 Pro","brand":"Google","labels":{"team":"mobile","floor":"3"}}`,
+			status: http.StatusCreated},
+		{name: "Create Device V2 With An Empty Label", method: http.MethodPost, target: "/v2/devices",
+			contentType: "application/json", body: `{"name":"Pixel 9 Pro","brand":"Google","labels":{"":"mobile"}}`,
+			status: http.StatusBadRequest},
+		{name: "Update Device V2", method: http.MethodPut, target: "/v2/devices/2", contentType: "application/json",
+			body:   `{"name":"Pixel 9a","brand":"Google","state":"inactive","labels":{"team":"mobile"}}`,
+			status: http.StatusOK},
+		{name: "Patch Device V2", method: http.MethodPatch, target: "/v2/devices/1", contentType: "application/json",
+			body: `{"labels":{"owner":"ana"}}`, status: http.StatusOK},
+		{name: "Delete Device V2 In Use", method: http.MethodDelete, target: "/v2/devices/1",
+			status: http.StatusForbidden},
+		{name: "Unknown Path", method: http.MethodGet, target: "/v1/gadgets", status: http.StatusNotFound},
+		{name: "Unknown Path Of A Head Request", method: http.MethodHead, target: "/v1/gadgets", status: http.StatusNotFound},
+		{name: "Get OpenAPI Spec", method: http.MethodGet, target: "/openapi.json", status: http.StatusOK},
+		{name: "Method Without A Route", method: http.MethodPost, target: "/healthz", status: http.StatusMethodNotAllowed},
+		{name: "Repository Failure", method: http.MethodGet, target: "/v1/devices", repository: failing,
+			status: http.StatusInternalServerError},
+		{name: "Repository Panic", method: http.MethodGet, target: "/v1/devices/1", repository: failing,
+			status: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := seeded
+			if tt.repository != nil {
+				repository = tt.repository
+			}
+			handler := api.NewHandler(env(), repository(t), event.NewBus(0))
+
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+			golden := tt.golden
+			if golden == "" {
+				golden = goldenName(tt.name)
+			}
+			assertGolden(t, golden, response(t, rec))
+		})
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var out bytes.Buffer
+	log.Init("")
+	log.SetOutput(&out)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	handler := api.NewHandler(env(), seeded(t), event.NewBus(0))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/devices/1", nil))
+
+	var entry struct {
+		Level string   `json:"level"`
+		HTTP  log.HTTP `json:"http"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+
+	assert.Equal(t, "info", entry.Level)
+	assert.Equal(t, "[GET] /v1/devices/1", entry.HTTP.Request.Route)
+	assert.JSONEq(t, `{"Id":1}`, entry.HTTP.Request.Param)
+	assert.Equal(t, http.StatusOK, entry.HTTP.Response.Status)
+	assert.NotNil(t, entry.HTTP.Response.Body)
+	assert.Empty(t, entry.HTTP.Error)
+}
+
+// TestOpenAPI - fails when a route is registered without being documented, or the spec documents a route
+// that is not registered
+func TestOpenAPI(t *testing.T) {
+	handler := api.NewHandler(env(), seeded(t), event.NewBus(0))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var document openapi.Document
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &document))
+	assert.Equal(t, "3.1.0", document.OpenAPI)
+
+	// The explorers, GraphQL and the debug routes are not part of the REST API
+	undocumented := regexp.MustCompile(`^/(openapi\.json|swagger/|graphiql|graphql|debug/)`)
+	param := regexp.MustCompile(`:(\w+)`)
+	var routes []string
+	for _, route := range handler.Routes() {
+		if route.Method == echo.RouteNotFound || undocumented.MatchString(route.Path) {
+			continue
+		}
+		routes = append(routes, route.Method+" "+param.ReplaceAllString(route.Path, "{$1}"))
+	}
+
+	var operations []string
+	for path, item := range document.Paths {
+		for method := range item {
+			operations = append(operations, strings.ToUpper(method)+" "+path)
+		}
+	}
+	assert.ElementsMatch(t, routes, operations)
+
+	// Swagger UI shows this spec, the only one there is
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/swagger/index.html", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `url: "\/openapi.json"`)
+	assert.NotContains(t, rec.Body.String(), "doc.json")
+}
+
+// response - status, content type, version headers and body, with JSON indented and creation times of new devices replaced
+func response(t *testing.T, rec *httptest.ResponseRecorder) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%d %s\n", rec.Code, http.StatusText(rec.Code))
+	if contentType := rec.Header().Get("Content-Type"); contentType != "" {
+		fmt.Fprintf(&b, "Content-Type: %s\n", contentType)
+	}
+	for _, header := range []string{"Deprecation", "Sunset"} {
+		if value := rec.Header().Get(header); value != "" {
+			fmt.Fprintf(&b, "%s: %s\n", header, value)
+		}
+	}
+	b.WriteString("\n")
+
+	body := rec.Body.Bytes()
+	if mediaType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type")); strings.HasSuffix(mediaType, "json") {
+		var indented bytes.Buffer
+		require.NoError(t, json.Indent(&indented, body, "", "  "))
+		body = indented.Bytes()
+	}
+	b.Write(bytes.TrimSpace(recent(body)))
+	b.WriteString("\n")
+	return b.Bytes()
+}
+
+var creationTime = regexp.MustCompile(`"creation_time": "([^"]+)"`)
+
+// recent - creation times of devices created by the request change with every run
+func recent(body []byte) []byte {
+	return creationTime.ReplaceAllFunc(body, func(match []byte) []byte {
+		created, err := time.Parse(time.RFC3339Nano, string(creationTime.FindSubmatch(match)[1]))
+		if err != nil || time.Since(created) > time.Minute {
+			return match
+		}
+		return []byte(`"creation_time": "<now>"`)
+	})
+}
+
+func goldenName(test string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "_", "-", "_").Replace(test))
+}
+
+// assertGolden - compares with testdata/http/name.golden, rewriting it instead with -update
+func assertGolden(t *testing.T, name string, actual []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", "http", name+".golden")
+	if *update {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, actual, 0o644))
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err, "run go test ./internal/api -update to create the golden files")
+	assert.Equal(t, string(expected), string(actual))
+}
@@ -0,0 +1,195 @@
+package rpc
+
+import (
+	"context"
+	"github.com/ivofreitas/device-api/internal/adapter/event"
+	"github.com/ivofreitas/device-api/internal/api/device"
+	"github.com/ivofreitas/device-api/internal/api/middleware"
+	"github.com/ivofreitas/device-api/internal/domain"
+	devicev1 "github.com/ivofreitas/device-api/proto/device/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"net/http"
+)
+
+// subscriberBuffer - live events a watcher may lag behind before it is disconnected
+const subscriberBuffer = 64
+
+// DeviceServer - translates device.v1 messages to the parameters of device.Service and back
+type DeviceServer struct {
+	devicev1.UnimplementedDeviceServiceServer
+	service *device.Service
+	bus     *event.Bus
+}
+
+func NewDeviceServer(service *device.Service, bus *event.Bus) *DeviceServer {
+	return &DeviceServer{service: service, bus: bus}
+}
+
+func (s *DeviceServer) CreateDevice(ctx context.Context, req *devicev1.CreateDeviceRequest) (*devicev1.Device, error) {
+	param := &domain.Device{Name: req.Name, Brand: req.Brand}
+	if req.State != devicev1.State_STATE_UNSPECIFIED {
+		state, err := stateFromProto(req.State)
+		if err != nil {
+			return nil, err
+		}
+		param.State = state
+	}
+
+	return callDevice(ctx, s.service.Create, param)
+}
+
+func (s *DeviceServer) UpdateDevice(ctx context.Context, req *devicev1.UpdateDeviceRequest) (*devicev1.Device, error) {
+	state, err := stateFromProto(req.State)
+	if err != nil {
+		return nil, err
+	}
+
+	return callDevice(ctx, s.service.Update, &domain.Update{Id: int(req.Id), Name: &req.Name, Brand: &req.Brand, State: &state})
+}
+
+func (s *DeviceServer) PatchDevice(ctx context.Context, req *devicev1.PatchDeviceRequest) (*devicev1.Device, error) {
+	param := &domain.Patch{Id: int(req.Id), Name: req.Name, Brand: req.Brand}
+	if req.State != nil {
+		state, err := stateFromProto(*req.State)
+		if err != nil {
+			return nil, err
+		}
+		param.State = &state
+	}
+
+	return callDevice(ctx, s.service.Patch, param)
+}
+
+func (s *DeviceServer) GetDevice(ctx context.Context, req *devicev1.GetDeviceRequest) (*devicev1.Device, error) {
+	return callDevice(ctx, s.service.GetById, &domain.GetById{Id: int(req.Id)})
+}
+
+func (s *DeviceServer) ListDevices(ctx context.Context, req *devicev1.ListDevicesRequest) (*devicev1.ListDevicesResponse, error) {
+	var devices []domain.Device
+	var err error
+
+	switch filter := req.Filter.(type) {
+	case *devicev1.ListDevicesRequest_Brand:
+		devices, err = middleware.Call(ctx, s.service.GetByBrand, &domain.GetByBrand{Brand: filter.Brand})
+	case *devicev1.ListDevicesRequest_State:
+		state, stateErr := stateFromProto(filter.State)
+		if stateErr != nil {
+			return nil, stateErr
+		}
+		devices, err = middleware.Call(ctx, s.service.GetByState, &domain.GetByState{State: state})
+	default:
+		devices, err = middleware.Call(ctx, middleware.NoRequest(s.service.GetAll), &struct{}{})
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	res := &devicev1.ListDevicesResponse{Devices: make([]*devicev1.Device, 0, len(devices))}
+	for i := range devices {
+		res.Devices = append(res.Devices, deviceToProto(&devices[i]))
+	}
+	return res, nil
+}
+
+func (s *DeviceServer) DeleteDevice(ctx context.Context, req *devicev1.DeleteDeviceRequest) (*devicev1.DeleteDeviceResponse, error) {
+	if _, err := middleware.Call(ctx, middleware.NoContent(s.service.Delete), &domain.Delete{Id: int(req.Id)}); err != nil {
+		return nil, toStatus(err)
+	}
+	return &devicev1.DeleteDeviceResponse{}, nil
+}
+
+// WatchDevices - streams until the client goes away, the server shuts down or the client falls too far behind.
+// In the latter cases the call ends with Unavailable and the client resumes with the last event id it received
+func (s *DeviceServer) WatchDevices(req *devicev1.WatchDevicesRequest, stream grpc.ServerStreamingServer[devicev1.DeviceEvent]) error {
+	filter := &domain.EventFilter{Id: int(req.GetId()), Brand: req.GetBrand(), LastEventId: req.LastEventId}
+	if req.State != nil {
+		state, err := stateFromProto(*req.State)
+		if err != nil {
+			return err
+		}
+		filter.State = &state
+	}
+
+	replay, events, cancel := s.bus.Subscribe(filter.LastEventId, subscriberBuffer)
+	defer cancel()
+
+	for _, e := range replay {
+		if err := send(stream, filter, e); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e, ok := <-events:
+			if !ok {
+				return status.Error(codes.Unavailable, "event stream closed, resume with the last event id")
+			}
+			if err := send(stream, filter, e); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func send(stream grpc.ServerStreamingServer[devicev1.DeviceEvent], filter *domain.EventFilter, e domain.Event) error {
+	if !filter.Matches(e) {
+		return nil
+	}
+	return stream.Send(&devicev1.DeviceEvent{
+		Id:     e.Id,
+		Type:   e.Type,
+		Device: deviceToProto(&e.Device),
+		Time:   timestamppb.New(e.Time),
+	})
+}
+
+// callDevice - calls a service method that returns a single device and maps its errors
+func callDevice[Req any](ctx context.Context, fn middleware.Func[Req, *domain.Device], req *Req) (*devicev1.Device, error) {
+	result, err := middleware.Call(ctx, fn, req)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return deviceToProto(result), nil
+}
+
+func deviceToProto(d *domain.Device) *devicev1.Device {
+	return &devicev1.Device{
+		Id:           int64(d.Id),
+		Name:         d.Name,
+		Brand:        d.Brand,
+		State:        stateToProto(d.State),
+		CreationTime: timestamppb.New(d.CreationTime),
+	}
+}
+
+func stateToProto(state domain.State) devicev1.State {
+	switch state {
+	case domain.AvailableState:
+		return devicev1.State_STATE_AVAILABLE
+	case domain.InUseState:
+		return devicev1.State_STATE_IN_USE
+	case domain.InactiveState:
+		return devicev1.State_STATE_INACTIVE
+	default:
+		return devicev1.State_STATE_UNSPECIFIED
+	}
+}
+
+func stateFromProto(state devicev1.State) (domain.State, error) {
+	switch state {
+	case devicev1.State_STATE_AVAILABLE:
+		return domain.AvailableState, nil
+	case devicev1.State_STATE_IN_USE:
+		return domain.InUseState, nil
+	case devicev1.State_STATE_INACTIVE:
+		return domain.InactiveState, nil
+	default:
+		return 0, toStatus(&domain.Error{Type: "bind_error", Status: http.StatusBadRequest, Detail: "not a valid state: " + state.String()})
+	}
+}
@@ -0,0 +1,148 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"github.com/ivofreitas/device-api/internal/adapter/event"
+	"github.com/ivofreitas/device-api/internal/api/device"
+	mocks "github.com/ivofreitas/device-api/internal/api/device/mock"
+	"github.com/ivofreitas/device-api/internal/domain"
+	devicev1 "github.com/ivofreitas/device-api/proto/device/v1"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, repository device.Repository, bus *event.Bus) (devicev1.DeviceServiceClient, *Server, *grpc.ClientConn) {
+	lis := bufconn.Listen(1 << 20)
+	server := NewServer(device.NewService(repository), bus, nil, logrus.NewEntry(logrus.New()))
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(func() { server.Shutdown(context.Background()) })
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return devicev1.NewDeviceServiceClient(conn), server, conn
+}
+
+func TestDeviceServer(t *testing.T) {
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("Get Device", func(t *testing.T) {
+		mockRepo := new(mocks.Repository)
+		mockRepo.On("GetById", mock.Anything, 1).
+			Return(&domain.Device{Id: 1, Name: "Phone", Brand: "Apple", State: domain.InUseState, CreationTime: created}, nil)
+		client, _, _ := newTestClient(t, mockRepo, event.NewBus(1))
+
+		res, err := client.GetDevice(context.Background(), &devicev1.GetDeviceRequest{Id: 1})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), res.Id)
+		assert.Equal(t, "Phone", res.Name)
+		assert.Equal(t, devicev1.State_STATE_IN_USE, res.State)
+		assert.Equal(t, created, res.CreationTime.AsTime())
+	})
+
+	t.Run("Maps Domain Errors To Status Codes", func(t *testing.T) {
+		mockRepo := new(mocks.Repository)
+		mockRepo.On("GetById", mock.Anything, 2).Return(nil, sql.ErrNoRows)
+		mockRepo.On("WithTx", mock.Anything, mock.Anything).Return(func(_ context.Context, fn func(device.Repository) error) error {
+			return fn(mockRepo)
+		})
+		mockRepo.On("GetByIdForUpdate", mock.Anything, 3).Return(&domain.Device{Id: 3, State: domain.InUseState}, nil)
+		client, _, _ := newTestClient(t, mockRepo, event.NewBus(1))
+
+		_, err := client.GetDevice(context.Background(), &devicev1.GetDeviceRequest{Id: 2})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		assert.Equal(t, "not_found", reason(err))
+
+		_, err = client.DeleteDevice(context.Background(), &devicev1.DeleteDeviceRequest{Id: 3})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+		assert.Equal(t, "delete_error", reason(err))
+
+		_, err = client.GetDevice(context.Background(), &devicev1.GetDeviceRequest{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Equal(t, "validate_error", reason(err))
+
+		_, err = client.UpdateDevice(context.Background(), &devicev1.UpdateDeviceRequest{Id: 3, Name: "Phone", Brand: "Apple"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("List Devices By State", func(t *testing.T) {
+		mockRepo := new(mocks.Repository)
+		mockRepo.On("GetByState", mock.Anything, domain.InactiveState).
+			Return([]domain.Device{{Id: 4, State: domain.InactiveState}}, nil)
+		client, _, _ := newTestClient(t, mockRepo, event.NewBus(1))
+
+		res, err := client.ListDevices(context.Background(), &devicev1.ListDevicesRequest{
+			Filter: &devicev1.ListDevicesRequest_State{State: devicev1.State_STATE_INACTIVE},
+		})
+		require.NoError(t, err)
+		require.Len(t, res.Devices, 1)
+		assert.Equal(t, int64(4), res.Devices[0].Id)
+	})
+
+	t.Run("Watch Devices Replays Filtered Events Until The Bus Closes", func(t *testing.T) {
+		bus := event.NewBus(10)
+		client, _, _ := newTestClient(t, new(mocks.Repository), bus)
+
+		ctx := context.Background()
+		require.NoError(t, bus.Publish(ctx, domain.Event{Type: domain.DeviceCreated, Device: domain.Device{Id: 1, Brand: "Apple"}}))
+		require.NoError(t, bus.Publish(ctx, domain.Event{Type: domain.DeviceCreated, Device: domain.Device{Id: 2, Brand: "Google"}}))
+		require.NoError(t, bus.Publish(ctx, domain.Event{Type: domain.DeviceUpdated, Device: domain.Device{Id: 1, Brand: "Apple"}}))
+
+		stream, err := client.WatchDevices(ctx, &devicev1.WatchDevicesRequest{Brand: ptr("Apple"), LastEventId: 1})
+		require.NoError(t, err)
+
+		e, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, uint64(3), e.Id)
+		assert.Equal(t, domain.DeviceUpdated, e.Type)
+
+		bus.Close()
+		_, err = stream.Recv()
+		assert.NotEqual(t, io.EOF, err)
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+
+	t.Run("Health Reports Not Serving Once Drained", func(t *testing.T) {
+		_, server, conn := newTestClient(t, new(mocks.Repository), event.NewBus(1))
+		health := healthpb.NewHealthClient(conn)
+
+		res, err := health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "device.v1.DeviceService"})
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+
+		server.Drain()
+		res, err = health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "device.v1.DeviceService"})
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, res.Status)
+	})
+}
+
+func reason(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+	return ""
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
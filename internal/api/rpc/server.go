@@ -0,0 +1,163 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/ivofreitas/device-api/config/db"
+	"github.com/ivofreitas/device-api/internal/adapter/certs"
+	adaptercontext "github.com/ivofreitas/device-api/internal/adapter/context"
+	"github.com/ivofreitas/device-api/internal/adapter/event"
+	"github.com/ivofreitas/device-api/internal/api/device"
+	devicev1 "github.com/ivofreitas/device-api/proto/device/v1"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"net"
+	"runtime/debug"
+	"time"
+)
+
+// Server - gRPC counterpart of the REST API, serving device.v1.DeviceService plus the health and reflection services
+type Server struct {
+	grpc   *grpc.Server
+	health *health.Server
+	logger *logrus.Entry
+}
+
+// NewServer - tlsConfig is optional, without it the server speaks plaintext
+func NewServer(service *device.Service, bus *event.Bus, tlsConfig *tls.Config, logger *logrus.Entry) *Server {
+	s := &Server{health: health.NewServer(), logger: logger}
+	options := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(s.logUnary, readYourWritesUnary, principalUnary, s.recoverUnary),
+		grpc.ChainStreamInterceptor(s.logStream, readYourWritesStream, principalStream, s.recoverStream),
+	}
+	if tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	s.grpc = grpc.NewServer(options...)
+
+	devicev1.RegisterDeviceServiceServer(s.grpc, NewDeviceServer(service, bus))
+	healthpb.RegisterHealthServer(s.grpc, s.health)
+	reflection.Register(s.grpc)
+
+	s.health.SetServingStatus(devicev1.DeviceService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	return s
+}
+
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpc.Serve(lis)
+}
+
+// Drain - reports every service as not serving, so clients move to other instances before the shutdown
+func (s *Server) Drain() {
+	s.health.Shutdown()
+}
+
+// Shutdown - waits for in-flight calls to finish, cancelling them once ctx is done
+func (s *Server) Shutdown(ctx context.Context) {
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.grpc.Stop()
+		<-stopped
+	}
+}
+
+func (s *Server) logUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	res, err := handler(ctx, req)
+	s.log(info.FullMethod, start, err)
+	return res, err
+}
+
+func (s *Server) logStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	s.log(info.FullMethod, start, err)
+	return err
+}
+
+func (s *Server) log(method string, start time.Time, err error) {
+	entry := s.logger.WithField("grpc", logrus.Fields{
+		"method":  method,
+		"code":    status.Code(err).String(),
+		"latency": float64(time.Since(start)/time.Millisecond) / 1000,
+	})
+
+	if err != nil && status.Code(err) == codes.Internal {
+		entry.WithError(err).Error()
+		return
+	}
+	entry.Info()
+}
+
+func (s *Server) recoverUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res interface{}, err error) {
+	defer s.recover(&err)
+	return handler(ctx, req)
+}
+
+func (s *Server) recoverStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer s.recover(&err)
+	return handler(srv, ss)
+}
+
+func (s *Server) recover(err *error) {
+	if r := recover(); r != nil {
+		s.logger.Errorf("gRPC handler panicked: %v\n%s", r, debug.Stack())
+		*err = status.Error(codes.Internal, "internal server error")
+	}
+}
+
+// readYourWritesUnary - scopes a primary pin to the call, like middleware.ReadYourWrites does for requests
+func readYourWritesUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(db.WithPin(ctx), req)
+}
+
+func readYourWritesStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &serverStream{ServerStream: ss, ctx: db.WithPin(ss.Context())})
+}
+
+// principalUnary - makes the subject of a verified client certificate the principal of the call
+func principalUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(withPrincipal(ctx), req)
+}
+
+func principalStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &serverStream{ServerStream: ss, ctx: withPrincipal(ss.Context())})
+}
+
+func withPrincipal(ctx context.Context) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return ctx
+	}
+	if subject, ok := certs.Subject(&info.State); ok {
+		return adaptercontext.WithPrincipal(ctx, subject)
+	}
+	return ctx
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,54 @@
+package rpc
+
+import (
+	"errors"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+)
+
+// errorDomain - domain of the ErrorInfo detail carrying domain.Error.Type
+const errorDomain = "device-api"
+
+// toStatus - maps domain.Error.Status to the matching gRPC code and keeps its type as an ErrorInfo reason
+func toStatus(err error) error {
+	var domainErr *domain.Error
+	if !errors.As(err, &domainErr) {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	st := status.New(code(domainErr.Status), domainErr.Detail)
+	if detailed, detailErr := st.WithDetails(&errdetails.ErrorInfo{Reason: domainErr.Type, Domain: errorDomain}); detailErr == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+func code(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	default:
+		return codes.Internal
+	}
+}
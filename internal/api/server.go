@@ -2,15 +2,29 @@ package api
 
 import (
 	gocontext "context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"github.com/ivofreitas/device-api/config"
+	"github.com/ivofreitas/device-api/config/db"
+	"github.com/ivofreitas/device-api/internal/adapter/backoff"
+	"github.com/ivofreitas/device-api/internal/adapter/certs"
 	"github.com/ivofreitas/device-api/internal/adapter/context"
+	"github.com/ivofreitas/device-api/internal/adapter/event"
 	"github.com/ivofreitas/device-api/internal/adapter/log"
+	"github.com/ivofreitas/device-api/internal/adapter/outbox"
+	"github.com/ivofreitas/device-api/internal/adapter/ratelimit"
+	"github.com/ivofreitas/device-api/internal/api/device"
+	"github.com/ivofreitas/device-api/internal/api/health"
 	"github.com/ivofreitas/device-api/internal/api/middleware"
+	"github.com/ivofreitas/device-api/internal/api/rpc"
+	"github.com/ivofreitas/device-api/internal/api/webhook"
 	"github.com/ivofreitas/device-api/internal/domain"
 	"github.com/labstack/echo/v4"
 	echomiddleware "github.com/labstack/echo/v4/middleware"
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
+	"net"
 	nethttp "net/http"
 	"os"
 	"os/signal"
@@ -20,12 +34,17 @@ import (
 
 type Server struct {
 	echo   *echo.Echo
+	grpc   *rpc.Server
+	db     *db.Cluster
+	health *health.Handler
+	bus    *event.Bus
 	logger *logrus.Entry
 	signal chan struct{}
+	cancel gocontext.CancelFunc
 }
 
 func NewServer() *Server {
-	log.Init()
+	log.Init(config.GetEnv().Log.Level)
 
 	return &Server{
 		logger: log.NewEntry(),
@@ -40,56 +59,309 @@ func (s *Server) Run() {
 }
 
 func (s *Server) start() {
-	go s.watchStop()
-
 	env := config.GetEnv()
 
+	var ctx gocontext.Context
+	ctx, s.cancel = gocontext.WithCancel(gocontext.Background())
+
+	s.bus = event.NewBus(env.Events.ReplaySize)
+
 	s.initHttp()
+	s.initDatabase(ctx)
+	devices := deviceRepository(s.db)
+	s.initWorkers(ctx, devices)
+	s.watchConfig(ctx)
+	tlsConfig := s.initTLS(ctx)
 
 	s.logger.Infof("Server is starting in port %s.", env.Server.Port)
 
-	register(s.echo)
+	s.health = health.NewHandler(env.Server.ReadinessTimeout, health.Ping(s.db.Primary()), health.Migrations(s.db.Primary()))
+	deviceServ := deviceService(s.db, devices)
+	register(s.echo, s.db, s.health, s.bus, deviceServ, devices, s.rateLimit())
 
 	addr := fmt.Sprintf(":%s", env.Server.Port)
 	go func() {
-		if err := s.echo.Start(addr); err != nil {
+		var err error
+		if tlsConfig != nil {
+			s.echo.TLSServer.Addr = addr
+			s.echo.TLSServer.TLSConfig = tlsConfig
+			err = s.echo.StartServer(s.echo.TLSServer)
+		} else {
+			err = s.echo.Start(addr)
+		}
+		if err != nil && !errors.Is(err, nethttp.ErrServerClosed) {
 			s.logger.WithError(err).Fatal("Shutting down the server now")
 		}
 	}()
+
+	s.initGrpc(deviceServ, tlsConfig)
+
+	// Only watched once everything it stops is set. Until then a signal ends the process right away, there being
+	// nothing to drain yet
+	go s.watchStop()
+}
+
+// initTLS - TLS configuration of both servers, nil when TLS_CERT_FILE is not set.
+// The certificates are reloaded whenever their files change
+func (s *Server) initTLS(ctx gocontext.Context) *tls.Config {
+	env := config.GetEnv().Server
+	if env.TLSCertFile == "" {
+		return nil
+	}
+
+	// Validated on load
+	minVersion, _ := certs.ParseVersion(env.TLSMinVersion)
+	clientAuth, _ := certs.ParseClientAuth(env.TLSClientAuth)
+
+	reloader, err := certs.NewReloader(env.TLSCertFile, env.TLSKeyFile, env.TLSClientCAFile, s.logger.WithField("worker", "tls"))
+	if err != nil {
+		s.logger.WithError(err).Fatal("Loading the TLS certificates failed")
+	}
+	go func() {
+		if err := reloader.Watch(ctx); err != nil {
+			s.logger.WithError(err).Error("TLS certificates will not be reloaded")
+		}
+	}()
+
+	return reloader.Config(minVersion, clientAuth)
+}
+
+func (s *Server) initGrpc(deviceServ *device.Service, tlsConfig *tls.Config) {
+	env := config.GetEnv()
+
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", env.Server.GRPCPort))
+	if err != nil {
+		s.logger.WithError(err).Fatal("Listening for gRPC failed")
+	}
+
+	s.grpc = rpc.NewServer(deviceServ, s.bus, tlsConfig, s.logger.WithField("server", "grpc"))
+	s.logger.Infof("gRPC server is starting in port %s.", env.Server.GRPCPort)
+
+	go func() {
+		if err := s.grpc.Serve(lis); err != nil {
+			s.logger.WithError(err).Fatal("Shutting down the gRPC server now")
+		}
+	}()
 }
 
 func (s *Server) watchStop() {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
+
+	s.logger.Info("Server is draining...")
+	s.health.Drain()
+	s.grpc.Drain()
+	time.Sleep(config.GetEnv().Server.ShutdownDelay)
+
 	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), 5*time.Second)
 	defer cancel()
 
 	s.logger.Info("Server is stopping...")
 
-	err := s.echo.Shutdown(ctx)
-	if err != nil {
+	// Closing the bus ends the event streams of both servers, which would otherwise hold their shutdown
+	s.bus.Close()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.Shutdown(ctx)
+		close(stopped)
+	}()
+
+	if err := s.echo.Shutdown(ctx); err != nil {
+		s.logger.Errorln(err)
+	}
+	<-stopped
+
+	s.cancel()
+
+	if err := s.db.Close(); err != nil {
 		s.logger.Errorln(err)
 	}
 
 	close(s.signal)
 }
 
+func (s *Server) initDatabase(ctx gocontext.Context) {
+	env := config.GetEnv()
+
+	s.db = db.NewPostgresCluster()
+	go s.db.Monitor(ctx, env.Database.ReplicaCheckInterval, env.Server.ReadinessTimeout)
+
+	if !env.Database.Migrate {
+		return
+	}
+	if err := db.Migrate(ctx, s.db.Primary()); err != nil {
+		s.logger.WithError(err).Fatal("Database migration failed")
+	}
+}
+
+func (s *Server) initWorkers(ctx gocontext.Context, devices device.Repository) {
+	env := config.GetEnv()
+
+	dispatcher := webhook.NewDispatcher(
+		s.db.Primary(),
+		&nethttp.Client{Timeout: env.Webhooks.Timeout},
+		backoff.Backoff{Initial: env.Webhooks.RetryInitial, Max: env.Webhooks.RetryMax, Multiplier: 2, Jitter: true},
+		env.Webhooks.MaxAttempts,
+		env.Webhooks.BatchSize,
+		env.Webhooks.PollInterval,
+		s.logger.WithField("worker", "webhook"),
+	)
+	go dispatcher.Run(ctx)
+
+	// Followers are fed every event, rather than by the relay, which only relays the events it claims. The device
+	// cache follows them too, as other instances and processes write devices around it
+	var publishers, followers []event.Publisher
+	if invalidator, ok := devices.(device.Invalidator); ok {
+		followers = append(followers, invalidator)
+	}
+	for _, name := range env.Outbox.Publishers {
+		switch name {
+		case "bus":
+			followers = append(followers, s.bus)
+		case "webhook":
+			publishers = append(publishers, webhook.NewPublisher(s.db.Primary()))
+		case "stdout":
+			publishers = append(publishers, event.NewSink(os.Stdout))
+		case "file":
+			sink, err := event.NewFileSink(env.Outbox.File)
+			if err != nil {
+				s.logger.WithError(err).Fatal("Opening the outbox file sink failed")
+			}
+			publishers = append(publishers, sink)
+		default:
+			s.logger.Fatalf("Unknown outbox publisher %q", name)
+		}
+	}
+
+	if len(followers) > 0 {
+		logger := s.logger.WithField("worker", "outbox-follower")
+		listener := db.NewListener(func(_ pq.ListenerEventType, err error) {
+			if err != nil {
+				logger.WithError(err).Warn("Outbox listener connection failed")
+			}
+		})
+		go outbox.NewFollower(s.db.Primary(), listener, logger, followers...).Run(ctx)
+	}
+
+	relay := outbox.NewRelay(s.db.Primary(), env.Outbox.BatchSize, env.Outbox.PollInterval, env.Outbox.Retention,
+		s.logger.WithField("worker", "outbox"), publishers...)
+	go relay.Run(ctx)
+}
+
+// rateLimit - middleware of the API routes, enforcing nothing unless RATE_LIMIT_ENABLED is set.
+// The limits follow config reloads, while the store is kept until the next start
+func (s *Server) rateLimit() echo.MiddlewareFunc {
+	env := config.GetEnv().RateLimit
+
+	var store ratelimit.Store
+	switch env.Store {
+	case "memory":
+		store = ratelimit.NewMemory()
+	case "postgres":
+		store = ratelimit.NewPostgres(s.db.Primary())
+	default:
+		s.logger.Fatalf("Unknown rate limit store %q", env.Store)
+	}
+
+	// Without keys there is nothing to reject, limits and keys are set right after
+	limiter, _ := middleware.NewRateLimiter(store, nil, nil, nil)
+	setLimits := func(env config.RateLimit) error {
+		if !env.Enabled {
+			return limiter.SetLimits(nil, nil, nil)
+		}
+
+		var fallback *ratelimit.Limit
+		if env.Default != "" {
+			limit, err := ratelimit.ParseLimit(env.Default)
+			if err != nil {
+				return err
+			}
+			fallback = &limit
+		}
+		routes, err := ratelimit.ParseRoutes(env.Routes)
+		if err != nil {
+			return err
+		}
+		return limiter.SetLimits(fallback, routes, env.Keys)
+	}
+
+	if err := setLimits(env); err != nil {
+		s.logger.WithError(err).Fatal("Invalid rate limits")
+	}
+	config.OnReload(func(env *config.Env) {
+		if err := setLimits(env.RateLimit); err != nil {
+			s.logger.WithError(err).Error("Applying the reloaded rate limits failed")
+		}
+	})
+	return limiter.Handle
+}
+
+// watchConfig - applies the safe keys of the config on SIGHUP or when its file changes
+func (s *Server) watchConfig(ctx gocontext.Context) {
+	config.OnReload(func(env *config.Env) {
+		if err := log.SetLevel(env.Log.Level); err != nil {
+			s.logger.WithError(err).Error("Applying the reloaded log level failed")
+		}
+	})
+	go func() {
+		if err := config.Watch(ctx, s.logger.WithField("worker", "config")); err != nil {
+			s.logger.WithError(err).Error("Config will not be reloaded")
+		}
+	}()
+}
+
 func (s *Server) initHttp() {
-	s.echo = echo.New()
-	s.echo.Use(middleware.Logger)
-	s.echo.Use(echomiddleware.Recover())
-	s.echo.Pre(echomiddleware.RemoveTrailingSlash())
-	s.echo.HTTPErrorHandler = func(err error, c echo.Context) {
+	s.echo = newEcho(config.GetEnv())
+}
+
+// ipExtractor - the client IP is the address of the connection, unless it is one of the trusted proxies,
+// whose X-Forwarded-For is followed back to the first address that is not
+func ipExtractor(trustedProxies []string) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, proxy := range trustedProxies {
+		if _, ipRange, err := net.ParseCIDR(proxy); err == nil {
+			options = append(options, echo.TrustIPRange(ipRange))
+		}
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+// newEcho - Echo with the middleware and error handling shared by the server and NewHandler
+func newEcho(env *config.Env) *echo.Echo {
+	e := echo.New()
+	e.IPExtractor = ipExtractor(env.Server.TrustedProxies)
+	e.Use(middleware.Logger)
+	e.Use(echomiddleware.Recover())
+	e.Use(middleware.ReadYourWrites)
+	e.Use(middleware.ClientCertificate)
+	e.Pre(echomiddleware.RemoveTrailingSlash())
+	e.Pre(middleware.ResolveVersion(versions))
+	// Errors of handlers are responses already, so these are the errors of routing and middleware
+	e.HTTPErrorHandler = func(err error, c echo.Context) {
 		if c.Response().Committed {
 			return
 		}
 
 		responseErr := &domain.Error{
-			Type:   "Path not found",
-			Status: c.Response().Status,
+			Type:   nethttp.StatusText(nethttp.StatusInternalServerError),
+			Status: nethttp.StatusInternalServerError,
 			Detail: err.Error(),
 		}
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			responseErr.Status = httpErr.Code
+			responseErr.Type = nethttp.StatusText(httpErr.Code)
+			responseErr.Detail = fmt.Sprint(httpErr.Message)
+		}
+		if responseErr.Status == nethttp.StatusNotFound {
+			responseErr.Type = "Path not found"
+		}
 		httpLog := context.Get(c.Request().Context(), log.HTTPKey).(*log.HTTP)
 		httpLog.Error = err.Error()
 
@@ -99,7 +371,8 @@ func (s *Server) initHttp() {
 			err = c.JSON(responseErr.Status, responseErr)
 		}
 		if err != nil {
-			s.echo.Logger.Error(err)
+			e.Logger.Error(err)
 		}
 	}
+	return e
 }
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIPExtractor(t *testing.T) {
+	t.Run("Ignores Forwarded Addresses Without Trusted Proxies", func(t *testing.T) {
+		extract := ipExtractor(nil)
+		req := httptest.NewRequest("GET", "/v1/devices", nil)
+		req.RemoteAddr = "10.0.0.5:41000"
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		req.Header.Set("X-Real-IP", "203.0.113.9")
+		assert.Equal(t, "10.0.0.5", extract(req))
+	})
+
+	t.Run("Follows Forwarded Addresses Through Trusted Proxies", func(t *testing.T) {
+		extract := ipExtractor([]string{"10.0.0.0/24"})
+		req := httptest.NewRequest("GET", "/v1/devices", nil)
+		req.RemoteAddr = "10.0.0.5:41000"
+		req.Header.Set("X-Forwarded-For", "198.51.100.3, 203.0.113.7, 10.0.0.4")
+		assert.Equal(t, "203.0.113.7", extract(req), "the address the last trusted proxy was reached from")
+	})
+
+	t.Run("Ignores Forwarded Addresses Of Untrusted Peers", func(t *testing.T) {
+		extract := ipExtractor([]string{"10.0.0.0/24"})
+		req := httptest.NewRequest("GET", "/v1/devices", nil)
+		req.RemoteAddr = "192.168.1.20:41000"
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		assert.Equal(t, "192.168.1.20", extract(req))
+	})
+}
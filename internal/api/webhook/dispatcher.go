@@ -0,0 +1,239 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/ivofreitas/device-api/internal/adapter/backoff"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// claimMargin - time a claimed delivery is left to its dispatcher on top of the request timeout. A delivery whose
+// dispatcher stopped before recording the outcome is claimed again once the claim expires
+const claimMargin = 30 * time.Second
+
+// Dispatcher - sends pending deliveries, retrying failures with exponential backoff until
+// they run out of attempts and are dead-lettered
+type Dispatcher struct {
+	queue       queue
+	client      *http.Client
+	backoff     backoff.Backoff
+	maxAttempts int
+	batchSize   int
+	interval    time.Duration
+	logger      *logrus.Entry
+}
+
+func NewDispatcher(db *sql.DB, client *http.Client, policy backoff.Backoff, maxAttempts, batchSize int,
+	interval time.Duration, logger *logrus.Entry) *Dispatcher {
+	return &Dispatcher{
+		queue:       &postgresQueue{db: db},
+		client:      client,
+		backoff:     policy,
+		maxAttempts: maxAttempts,
+		batchSize:   batchSize,
+		interval:    interval,
+		logger:      logger,
+	}
+}
+
+type pendingDelivery struct {
+	id        int64
+	eventType string
+	payload   []byte
+	// attempts - made so far, the one of the claim included
+	attempts int
+	url      string
+	secret   string
+}
+
+// outcome - the result of an attempt to send a delivery
+type outcome struct {
+	id             int64
+	attempts       int
+	status         string
+	responseStatus int
+	err            error
+	// retryIn - wait before the next attempt of a pending delivery
+	retryIn time.Duration
+}
+
+// queue - the deliveries the dispatcher sends
+type queue interface {
+	// claim - the oldest due delivery with attempts left, counted as an attempt and left to the caller for the
+	// lease. sql.ErrNoRows when none is due
+	claim(ctx context.Context, lease time.Duration, maxAttempts int) (*pendingDelivery, error)
+	// exhaust - dead-letters the due deliveries without attempts left, whose last claim expired before its outcome
+	// was recorded, and returns their ids
+	exhaust(ctx context.Context, maxAttempts int) ([]int64, error)
+	// record - stores the outcome of an attempt, unless the delivery has been claimed again since
+	record(ctx context.Context, outcome outcome) error
+}
+
+// Run - polls for due deliveries until ctx is done. Full batches are followed immediately by the next poll
+func (d *Dispatcher) Run(ctx context.Context) {
+	for {
+		sent, err := d.dispatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			d.logger.WithError(err).Error("Webhook dispatch failed")
+		}
+
+		if sent == d.batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.interval):
+		}
+	}
+}
+
+// dispatch - sends up to a batch of due deliveries one by one. Each is claimed before it is sent and its outcome
+// recorded after, so no transaction or row lock is held during the request and outcomes are recorded apart
+func (d *Dispatcher) dispatch(ctx context.Context) (int, error) {
+	exhausted, err := d.queue.exhaust(ctx, d.maxAttempts)
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range exhausted {
+		d.logger.Warnf("Webhook delivery %d dead-lettered after %d attempts, the last without an outcome", id, d.maxAttempts)
+	}
+
+	lease := d.client.Timeout + claimMargin
+	for sent := 0; sent < d.batchSize; sent++ {
+		delivery, err := d.queue.claim(ctx, lease, d.maxAttempts)
+		if errors.Is(err, sql.ErrNoRows) {
+			return sent, nil
+		}
+		if err != nil {
+			return sent, err
+		}
+
+		status, sendErr := d.send(ctx, *delivery)
+		if err = d.queue.record(ctx, d.outcome(*delivery, status, sendErr)); err != nil {
+			d.logger.WithError(err).Errorf("Recording webhook delivery %d failed, it is sent again once its claim expires",
+				delivery.id)
+		}
+	}
+	return d.batchSize, nil
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery pendingDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.url, bytes.NewReader(delivery.payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.eventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.id, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.secret, time.Now(), delivery.payload))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected response status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// outcome - a failed attempt is retried after a backoff growing with the attempts, or dead-lettered after the last
+func (d *Dispatcher) outcome(delivery pendingDelivery, status int, sendErr error) outcome {
+	result := outcome{id: delivery.id, attempts: delivery.attempts, status: domain.DeliveryDelivered, responseStatus: status}
+	if sendErr == nil {
+		return result
+	}
+
+	result.status, result.err = domain.DeliveryPending, sendErr
+	result.retryIn = d.backoff.Duration(delivery.attempts - 1)
+	if delivery.attempts >= d.maxAttempts {
+		result.status = domain.DeliveryDead
+		d.logger.WithError(sendErr).Warnf("Webhook delivery %d dead-lettered after %d attempts", delivery.id, delivery.attempts)
+	}
+	return result
+}
+
+type postgresQueue struct {
+	db *sql.DB
+}
+
+// claim - skips the deliveries claimed by other instances, and moves the next attempt past the lease so the
+// delivery is only claimed again when the claim expires
+func (q *postgresQueue) claim(ctx context.Context, lease time.Duration, maxAttempts int) (*pendingDelivery, error) {
+	query := `
+			UPDATE devices_schema.webhook_deliveries d
+			SET attempts = d.attempts + 1, next_attempt_at = CURRENT_TIMESTAMP + $1 * INTERVAL '1 millisecond'
+			FROM devices_schema.webhooks w
+			WHERE w.id = d.webhook_id AND d.id = (
+				SELECT id FROM devices_schema.webhook_deliveries
+				WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP AND attempts < $2
+				ORDER BY id
+				LIMIT 1
+				FOR UPDATE SKIP LOCKED)
+			RETURNING d.id, d.event_type, d.payload, d.attempts, w.url, w.secret`
+	var delivery pendingDelivery
+	err := q.db.QueryRowContext(ctx, query, lease.Milliseconds(), maxAttempts).Scan(&delivery.id, &delivery.eventType,
+		&delivery.payload, &delivery.attempts, &delivery.url, &delivery.secret)
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// exhaust - skips the deliveries claimed by other instances, which are dead-lettered by the first to get them
+func (q *postgresQueue) exhaust(ctx context.Context, maxAttempts int) ([]int64, error) {
+	query := `
+			UPDATE devices_schema.webhook_deliveries
+			SET status = 'dead', last_error = 'no outcome recorded for the last attempt'
+			WHERE id IN (
+				SELECT id FROM devices_schema.webhook_deliveries
+				WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP AND attempts >= $1
+				FOR UPDATE SKIP LOCKED)
+			RETURNING id`
+	rows, err := q.db.QueryContext(ctx, query, maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (q *postgresQueue) record(ctx context.Context, outcome outcome) error {
+	responseStatus := sql.NullInt64{Int64: int64(outcome.responseStatus), Valid: outcome.responseStatus != 0}
+	var lastError sql.NullString
+	if outcome.err != nil {
+		lastError = sql.NullString{String: outcome.err.Error(), Valid: true}
+	}
+
+	query := `
+			UPDATE devices_schema.webhook_deliveries
+			SET status = $1, response_status = $2, last_error = $3,
+				next_attempt_at = CURRENT_TIMESTAMP + $4 * INTERVAL '1 millisecond',
+				delivered_at = CASE WHEN $1 = 'delivered' THEN CURRENT_TIMESTAMP END
+			WHERE id = $5 AND attempts = $6 AND status = 'pending'`
+	_, err := q.db.ExecContext(ctx, query, outcome.status, responseStatus, lastError, outcome.retryIn.Milliseconds(),
+		outcome.id, outcome.attempts)
+	return err
+}
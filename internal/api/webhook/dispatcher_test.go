@@ -0,0 +1,154 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/ivofreitas/device-api/internal/adapter/backoff"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDispatcher(t *testing.T) {
+	statuses := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statuses[r.URL.Path])
+	}))
+	defer server.Close()
+
+	policy := backoff.Backoff{Initial: time.Second, Max: time.Minute, Multiplier: 2}
+	newDispatcher := func(queue *fakeQueue) *Dispatcher {
+		logger := logrus.New()
+		logger.SetOutput(io.Discard)
+		client := server.Client()
+		client.Timeout = 5 * time.Second
+		return &Dispatcher{queue: queue, client: client, backoff: policy, maxAttempts: 3, batchSize: 10,
+			logger: logrus.NewEntry(logger)}
+	}
+	delivery := func(id int64, path string, attempts int) pendingDelivery {
+		return pendingDelivery{id: id, eventType: domain.DeviceUpdated, payload: []byte(`{}`), attempts: attempts,
+			url: server.URL + path, secret: "secret"}
+	}
+	statuses["/ok"], statuses["/down"] = http.StatusNoContent, http.StatusServiceUnavailable
+
+	t.Run("Records Delivered Responses", func(t *testing.T) {
+		queue := &fakeQueue{due: []pendingDelivery{delivery(1, "/ok", 1)}}
+		sent, err := newDispatcher(queue).dispatch(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sent)
+		assert.Equal(t, []outcome{{id: 1, attempts: 1, status: domain.DeliveryDelivered, responseStatus: http.StatusNoContent}},
+			queue.recorded)
+	})
+
+	t.Run("Retries Failures With A Growing Backoff", func(t *testing.T) {
+		queue := &fakeQueue{due: []pendingDelivery{delivery(1, "/down", 1), delivery(2, "/down", 2)}}
+		_, err := newDispatcher(queue).dispatch(context.Background())
+		assert.NoError(t, err)
+
+		assert.Len(t, queue.recorded, 2)
+		for i, retryIn := range []time.Duration{time.Second, 2 * time.Second} {
+			assert.Equal(t, domain.DeliveryPending, queue.recorded[i].status)
+			assert.Equal(t, http.StatusServiceUnavailable, queue.recorded[i].responseStatus)
+			assert.EqualError(t, queue.recorded[i].err, "unexpected response status 503")
+			assert.Equal(t, retryIn, queue.recorded[i].retryIn)
+		}
+	})
+
+	t.Run("Dead-Letters The Last Attempt", func(t *testing.T) {
+		queue := &fakeQueue{due: []pendingDelivery{delivery(1, "/down", 3)}}
+		_, err := newDispatcher(queue).dispatch(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, domain.DeliveryDead, queue.recorded[0].status)
+	})
+
+	t.Run("Claims Deliveries For Longer Than A Request", func(t *testing.T) {
+		queue := &fakeQueue{due: []pendingDelivery{delivery(1, "/ok", 1)}}
+		_, _ = newDispatcher(queue).dispatch(context.Background())
+		assert.Equal(t, 5*time.Second+claimMargin, queue.lease)
+	})
+
+	t.Run("Keeps Sending When An Outcome Is Not Recorded", func(t *testing.T) {
+		queue := &fakeQueue{
+			due:       []pendingDelivery{delivery(1, "/ok", 1), delivery(2, "/ok", 1)},
+			recordErr: map[int64]error{1: errors.New("connection reset")},
+		}
+		sent, err := newDispatcher(queue).dispatch(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 2, sent)
+		assert.Equal(t, int64(2), queue.recorded[0].id)
+	})
+
+	t.Run("Stops At The Batch Size", func(t *testing.T) {
+		queue := &fakeQueue{}
+		for id := int64(1); id <= 12; id++ {
+			queue.due = append(queue.due, delivery(id, "/ok", 1))
+		}
+		sent, err := newDispatcher(queue).dispatch(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 10, sent)
+		assert.Len(t, queue.due, 2)
+	})
+
+	t.Run("Dead-Letters Deliveries Without Attempts Left Before Claiming", func(t *testing.T) {
+		queue := &fakeQueue{exhausted: []int64{4}, due: []pendingDelivery{delivery(1, "/ok", 1)}}
+		sent, err := newDispatcher(queue).dispatch(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sent)
+		assert.Empty(t, queue.exhausted)
+		assert.Equal(t, 3, queue.maxAttempts)
+	})
+
+	t.Run("Reports Failing Claims", func(t *testing.T) {
+		queue := &fakeQueue{claimErr: errors.New("connection refused")}
+		sent, err := newDispatcher(queue).dispatch(context.Background())
+		assert.Error(t, err)
+		assert.Zero(t, sent)
+	})
+}
+
+// fakeQueue - hands out the due deliveries in order and keeps the recorded outcomes
+type fakeQueue struct {
+	due []pendingDelivery
+	// exhausted - due deliveries without attempts left
+	exhausted   []int64
+	lease       time.Duration
+	maxAttempts int
+	claimErr    error
+	recordErr   map[int64]error
+	recorded    []outcome
+}
+
+func (q *fakeQueue) claim(_ context.Context, lease time.Duration, maxAttempts int) (*pendingDelivery, error) {
+	if q.claimErr != nil {
+		return nil, q.claimErr
+	}
+	if len(q.due) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	if len(q.exhausted) > 0 {
+		return nil, errors.New("claimed before the deliveries without attempts left were dead-lettered")
+	}
+	delivery := q.due[0]
+	q.due, q.lease, q.maxAttempts = q.due[1:], lease, maxAttempts
+	return &delivery, nil
+}
+
+func (q *fakeQueue) exhaust(context.Context, int) ([]int64, error) {
+	exhausted := q.exhausted
+	q.exhausted = nil
+	return exhausted, nil
+}
+
+func (q *fakeQueue) record(_ context.Context, outcome outcome) error {
+	if err := q.recordErr[outcome.id]; err != nil {
+		return err
+	}
+	q.recorded = append(q.recorded, outcome)
+	return nil
+}
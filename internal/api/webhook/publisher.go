@@ -0,0 +1,75 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/lib/pq"
+	"slices"
+)
+
+// Publisher - turns device events into pending deliveries of every matching subscription.
+// A republished event does not create a second delivery for the same subscription
+type Publisher struct {
+	db *sql.DB
+}
+
+func NewPublisher(db *sql.DB) *Publisher {
+	return &Publisher{db: db}
+}
+
+func (p *Publisher) Publish(ctx context.Context, e domain.Event) error {
+	subscribers, err := p.subscribers(ctx, e)
+	if err != nil || len(subscribers) == 0 {
+		return err
+	}
+
+	payload, err := json.Marshal(&e)
+	if err != nil {
+		return err
+	}
+
+	query := `
+			INSERT INTO devices_schema.webhook_deliveries (webhook_id, event_id, event_type, payload)
+			SELECT unnest($1::int[]), $2, $3, $4
+			ON CONFLICT (webhook_id, event_id) DO NOTHING`
+	_, err = p.db.ExecContext(ctx, query, pq.Array(subscribers), int64(e.Id), e.Type, payload)
+	return err
+}
+
+// subscribers - the ids of the active subscriptions matching the event
+func (p *Publisher) subscribers(ctx context.Context, e domain.Event) ([]int64, error) {
+	query := `SELECT ` + webhookColumns + ` FROM devices_schema.webhooks WHERE active`
+	rows, err := p.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		if matches(webhook, e) {
+			ids = append(ids, int64(webhook.Id))
+		}
+	}
+	return ids, rows.Err()
+}
+
+// matches - an empty list of event types subscribes to all of them, and a nil brand or state to any
+func matches(webhook *domain.Webhook, e domain.Event) bool {
+	switch {
+	case len(webhook.EventTypes) > 0 && !slices.Contains(webhook.EventTypes, e.Type):
+		return false
+	case webhook.Brand != nil && *webhook.Brand != e.Device.Brand:
+		return false
+	case webhook.State != nil && *webhook.State != e.Device.State:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"context"
+	"github.com/ivofreitas/device-api/config/db/dbtest"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMatches(t *testing.T) {
+	samsung, inUse := "Samsung", domain.InUseState
+	e := domain.Event{Type: domain.DeviceStateChanged, Device: domain.Device{Brand: "Samsung", State: domain.InUseState}}
+
+	testCases := []struct {
+		name    string
+		webhook domain.Webhook
+		matches bool
+	}{
+		{"Without Filters", domain.Webhook{}, true},
+		{"Of The Event Type", domain.Webhook{EventTypes: []string{domain.DeviceCreated, domain.DeviceStateChanged}}, true},
+		{"Of Other Event Types", domain.Webhook{EventTypes: []string{domain.DeviceCreated}}, false},
+		{"Of The Brand And State", domain.Webhook{Brand: &samsung, State: &inUse}, true},
+		{"Of Another Brand", domain.Webhook{Brand: ptr("Apple")}, false},
+		{"Of Another State", domain.Webhook{State: ptr(domain.AvailableState)}, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.matches, matches(&tc.webhook, e))
+		})
+	}
+}
+
+func TestPublisher(t *testing.T) {
+	conn := dbtest.Open(t)
+	ctx := context.Background()
+	repository := NewRepository(conn)
+
+	all, err := repository.Create(ctx, &domain.Webhook{URL: "https://example.com/all", Secret: "s", EventTypes: []string{}, Active: true})
+	assert.NoError(t, err)
+	_, err = repository.Create(ctx, &domain.Webhook{URL: "https://example.com/apple", Secret: "s", EventTypes: []string{},
+		Brand: ptr("Apple"), Active: true})
+	assert.NoError(t, err)
+	_, err = repository.Create(ctx, &domain.Webhook{URL: "https://example.com/off", Secret: "s", EventTypes: []string{}})
+	assert.NoError(t, err)
+
+	e := domain.Event{Id: 7, Type: domain.DeviceCreated, Device: domain.Device{Id: 1, Brand: "Samsung"}}
+	publisher := NewPublisher(conn)
+	assert.NoError(t, publisher.Publish(ctx, e))
+	assert.NoError(t, publisher.Publish(ctx, e), "republishing")
+
+	deliveries, err := repository.GetDeliveries(ctx, all.Id, "", 10)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, domain.DeliveryPending, deliveries[0].Status)
+
+	var total int
+	assert.NoError(t, conn.QueryRow(`SELECT count(*) FROM devices_schema.webhook_deliveries`).Scan(&total))
+	assert.Equal(t, 1, total, "only the active subscription matching the event")
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
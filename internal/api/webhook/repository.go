@@ -0,0 +1,147 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/lib/pq"
+)
+
+type Repository interface {
+	Create(ctx context.Context, webhook *domain.Webhook) (*domain.Webhook, error)
+	Update(ctx context.Context, webhook *domain.Webhook) (*domain.Webhook, error)
+	GetAll(ctx context.Context) ([]domain.Webhook, error)
+	GetById(ctx context.Context, id int) (*domain.Webhook, error)
+	Delete(ctx context.Context, id int) error
+	GetDeliveries(ctx context.Context, webhookId int, status string, limit int) ([]domain.Delivery, error)
+	RetryDelivery(ctx context.Context, webhookId int, deliveryId int64) (*domain.Delivery, error)
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+// webhookColumns - the secret is only ever returned when a subscription is created
+const webhookColumns = `id, url, event_types, brand, state, active, creation_time`
+
+const deliveryColumns = `id, webhook_id, event_type, payload, status, attempts, last_error, response_status,
+			next_attempt_at, creation_time, delivered_at`
+
+func (r *repository) Create(ctx context.Context, webhook *domain.Webhook) (*domain.Webhook, error) {
+	query := `
+			INSERT INTO devices_schema.webhooks (url, secret, event_types, brand, state, active)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING ` + webhookColumns
+	createdWebhook, err := scanWebhook(r.db.QueryRowContext(ctx, query, webhook.URL, webhook.Secret,
+		pq.Array(webhook.EventTypes), webhook.Brand, webhook.State, webhook.Active))
+	if err != nil {
+		return nil, err
+	}
+	createdWebhook.Secret = webhook.Secret
+	return createdWebhook, nil
+}
+
+func (r *repository) Update(ctx context.Context, webhook *domain.Webhook) (*domain.Webhook, error) {
+	query := `
+			UPDATE devices_schema.webhooks
+			SET url = $1, secret = COALESCE(NULLIF($2::text, ''), secret), event_types = $3, brand = $4, state = $5, active = $6
+			WHERE id = $7
+			RETURNING ` + webhookColumns
+	return scanWebhook(r.db.QueryRowContext(ctx, query, webhook.URL, webhook.Secret,
+		pq.Array(webhook.EventTypes), webhook.Brand, webhook.State, webhook.Active, webhook.Id))
+}
+
+func (r *repository) GetAll(ctx context.Context) ([]domain.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM devices_schema.webhooks ORDER BY id`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []domain.Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+func (r *repository) GetById(ctx context.Context, id int) (*domain.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM devices_schema.webhooks WHERE id = $1`
+	return scanWebhook(r.db.QueryRowContext(ctx, query, id))
+}
+
+func (r *repository) Delete(ctx context.Context, id int) error {
+	query := `DELETE FROM devices_schema.webhooks WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (r *repository) GetDeliveries(ctx context.Context, webhookId int, status string, limit int) ([]domain.Delivery, error) {
+	query := `
+			SELECT ` + deliveryColumns + `
+			FROM devices_schema.webhook_deliveries
+			WHERE webhook_id = $1 AND ($2::text = '' OR status = $2)
+			ORDER BY id DESC
+			LIMIT $3`
+	rows, err := r.db.QueryContext(ctx, query, webhookId, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []domain.Delivery
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// RetryDelivery - moves a dead delivery back to pending with a fresh attempt budget
+func (r *repository) RetryDelivery(ctx context.Context, webhookId int, deliveryId int64) (*domain.Delivery, error) {
+	query := `
+			UPDATE devices_schema.webhook_deliveries
+			SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND webhook_id = $2 AND status = 'dead'
+			RETURNING ` + deliveryColumns
+	return scanDelivery(r.db.QueryRowContext(ctx, query, deliveryId, webhookId))
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWebhook(row scanner) (*domain.Webhook, error) {
+	var webhook domain.Webhook
+	err := row.Scan(&webhook.Id, &webhook.URL, pq.Array(&webhook.EventTypes), &webhook.Brand, &webhook.State,
+		&webhook.Active, &webhook.CreationTime)
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func scanDelivery(row scanner) (*domain.Delivery, error) {
+	var delivery domain.Delivery
+	var payload []byte
+	err := row.Scan(&delivery.Id, &delivery.WebhookId, &delivery.EventType, &payload, &delivery.Status,
+		&delivery.Attempts, &delivery.LastError, &delivery.ResponseStatus, &delivery.NextAttemptAt,
+		&delivery.CreationTime, &delivery.DeliveredAt)
+	if err != nil {
+		return nil, err
+	}
+	delivery.Payload = payload
+	return &delivery, nil
+}
@@ -0,0 +1,162 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/ivofreitas/device-api/config/db/dbtest"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRepository(t *testing.T) {
+	conn := dbtest.Open(t)
+	ctx := context.Background()
+	repository := NewRepository(conn)
+
+	created, err := repository.Create(ctx, &domain.Webhook{URL: "https://example.com", Secret: "first",
+		EventTypes: []string{domain.DeviceCreated}, Active: true})
+	require.NoError(t, err)
+
+	t.Run("Only Returns The Secret On Creation", func(t *testing.T) {
+		assert.Equal(t, "first", created.Secret)
+		stored, err := repository.GetById(ctx, created.Id)
+		assert.NoError(t, err)
+		assert.Empty(t, stored.Secret)
+		assert.Equal(t, []string{domain.DeviceCreated}, stored.EventTypes)
+	})
+
+	t.Run("Keeps The Secret Unless A New One Is Given", func(t *testing.T) {
+		_, err := repository.Update(ctx, &domain.Webhook{Id: created.Id, URL: "https://example.com/v2", EventTypes: []string{},
+			Active: true})
+		assert.NoError(t, err)
+
+		var secret string
+		assert.NoError(t, conn.QueryRow(`SELECT secret FROM devices_schema.webhooks WHERE id = $1`, created.Id).Scan(&secret))
+		assert.Equal(t, "first", secret)
+	})
+
+	t.Run("Filters The Delivery Log By Status", func(t *testing.T) {
+		insertDelivery(t, conn, created.Id, 1, domain.DeliveryDelivered)
+		dead := insertDelivery(t, conn, created.Id, 2, domain.DeliveryDead)
+
+		deliveries, err := repository.GetDeliveries(ctx, created.Id, domain.DeliveryDead, 10)
+		assert.NoError(t, err)
+		assert.Len(t, deliveries, 1)
+		assert.Equal(t, dead, deliveries[0].Id)
+
+		deliveries, err = repository.GetDeliveries(ctx, created.Id, "", 10)
+		assert.NoError(t, err)
+		assert.Len(t, deliveries, 2)
+	})
+
+	t.Run("Only Retries Dead Deliveries", func(t *testing.T) {
+		delivered := insertDelivery(t, conn, created.Id, 3, domain.DeliveryDelivered)
+		_, err := repository.RetryDelivery(ctx, created.Id, delivered)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+
+		dead := insertDelivery(t, conn, created.Id, 4, domain.DeliveryDead)
+		retried, err := repository.RetryDelivery(ctx, created.Id, dead)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.DeliveryPending, retried.Status)
+		assert.Zero(t, retried.Attempts)
+	})
+
+	t.Run("Deletes Subscriptions With Their Deliveries", func(t *testing.T) {
+		assert.NoError(t, repository.Delete(ctx, created.Id))
+		_, err := repository.GetById(ctx, created.Id)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+
+		var total int
+		assert.NoError(t, conn.QueryRow(`SELECT count(*) FROM devices_schema.webhook_deliveries`).Scan(&total))
+		assert.Zero(t, total)
+	})
+}
+
+func TestPostgresQueue(t *testing.T) {
+	conn := dbtest.Open(t)
+	ctx := context.Background()
+	queue := &postgresQueue{db: conn}
+
+	webhook, err := NewRepository(conn).Create(ctx, &domain.Webhook{URL: "https://example.com", Secret: "s",
+		EventTypes: []string{}, Active: true})
+	require.NoError(t, err)
+	first := insertDelivery(t, conn, webhook.Id, 1, domain.DeliveryPending)
+	second := insertDelivery(t, conn, webhook.Id, 2, domain.DeliveryPending)
+
+	t.Run("Claims The Oldest Due Delivery Once Until The Lease Expires", func(t *testing.T) {
+		claimed, err := queue.claim(ctx, time.Hour, 3)
+		require.NoError(t, err)
+		assert.Equal(t, first, claimed.id)
+		assert.Equal(t, 1, claimed.attempts)
+		assert.Equal(t, "https://example.com", claimed.url)
+		assert.Equal(t, "s", claimed.secret)
+
+		claimed, err = queue.claim(ctx, time.Hour, 3)
+		require.NoError(t, err)
+		assert.Equal(t, second, claimed.id)
+
+		_, err = queue.claim(ctx, time.Hour, 3)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("Records Outcomes Of The Latest Claim Only", func(t *testing.T) {
+		stale := outcome{id: first, attempts: 0, status: domain.DeliveryDelivered, responseStatus: 200}
+		assert.NoError(t, queue.record(ctx, stale))
+		assert.Equal(t, domain.DeliveryPending, deliveryStatus(t, conn, first))
+
+		assert.NoError(t, queue.record(ctx, outcome{id: first, attempts: 1, status: domain.DeliveryDelivered, responseStatus: 200}))
+		assert.Equal(t, domain.DeliveryDelivered, deliveryStatus(t, conn, first))
+	})
+
+	t.Run("Makes Failed Deliveries Due After The Backoff", func(t *testing.T) {
+		failed := outcome{id: second, attempts: 1, status: domain.DeliveryPending, responseStatus: 503,
+			err: errors.New("unexpected response status 503")}
+		assert.NoError(t, queue.record(ctx, failed))
+
+		claimed, err := queue.claim(ctx, time.Hour, 3)
+		require.NoError(t, err)
+		assert.Equal(t, second, claimed.id)
+		assert.Equal(t, 2, claimed.attempts)
+
+		assert.NoError(t, queue.record(ctx, outcome{id: second, attempts: 2, status: domain.DeliveryDead, err: failed.err}))
+		assert.Equal(t, domain.DeliveryDead, deliveryStatus(t, conn, second))
+		_, err = queue.claim(ctx, time.Hour, 3)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("Dead-Letters Deliveries Whose Last Claim Expired", func(t *testing.T) {
+		last := insertDelivery(t, conn, webhook.Id, 3, domain.DeliveryPending)
+		_, err := conn.ExecContext(ctx, `UPDATE devices_schema.webhook_deliveries SET attempts = 3 WHERE id = $1`, last)
+		require.NoError(t, err)
+
+		_, err = queue.claim(ctx, time.Hour, 3)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+
+		exhausted, err := queue.exhaust(ctx, 3)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{last}, exhausted)
+		assert.Equal(t, domain.DeliveryDead, deliveryStatus(t, conn, last))
+	})
+}
+
+func insertDelivery(t *testing.T, conn *sql.DB, webhookId int, eventId int64, status string) int64 {
+	t.Helper()
+	var id int64
+	query := `
+			INSERT INTO devices_schema.webhook_deliveries (webhook_id, event_id, event_type, payload, status)
+			VALUES ($1, $2, 'device.created', '{}', $3)
+			RETURNING id`
+	require.NoError(t, conn.QueryRow(query, webhookId, eventId, status).Scan(&id))
+	return id
+}
+
+func deliveryStatus(t *testing.T, conn *sql.DB, id int64) string {
+	t.Helper()
+	var status string
+	require.NoError(t, conn.QueryRow(`SELECT status FROM devices_schema.webhook_deliveries WHERE id = $1`, id).Scan(&status))
+	return status
+}
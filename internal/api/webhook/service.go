@@ -0,0 +1,158 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"net/http"
+)
+
+// defaultDeliveryLimit - deliveries returned by the delivery log when no limit is given
+const defaultDeliveryLimit = 50
+
+type Service struct {
+	repository Repository
+}
+
+func NewService(repository Repository) *Service {
+	return &Service{repository}
+}
+
+// Create - registers a URL to be notified of device events. Deliveries are signed with HMAC-SHA256 using the returned
+// secret, which is generated when not provided and is only shown in this response
+func (s *Service) Create(ctx context.Context, create *domain.CreateWebhook) (*domain.Webhook, error) {
+	webhook := &domain.Webhook{
+		URL:        create.URL,
+		Secret:     create.Secret,
+		EventTypes: eventTypes(create.EventTypes),
+		Brand:      create.Brand,
+		State:      create.State,
+		Active:     create.Active == nil || *create.Active,
+	}
+
+	if webhook.Secret == "" {
+		secret, err := newSecret()
+		if err != nil {
+			return nil, &domain.Error{Type: "create_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+		}
+		webhook.Secret = secret
+	}
+
+	createdWebhook, err := s.repository.Create(ctx, webhook)
+	if err != nil {
+		return nil, &domain.Error{Type: "create_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+
+	return createdWebhook, nil
+}
+
+// Update - replaces the subscription settings. The secret is rotated only when a new one is provided
+func (s *Service) Update(ctx context.Context, update *domain.UpdateWebhook) (*domain.Webhook, error) {
+	webhook := &domain.Webhook{
+		Id:         update.Id,
+		URL:        update.URL,
+		Secret:     update.Secret,
+		EventTypes: eventTypes(update.EventTypes),
+		Brand:      update.Brand,
+		State:      update.State,
+		Active:     update.Active,
+	}
+
+	updatedWebhook, err := s.repository.Update(ctx, webhook)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &domain.Error{Type: "not_found", Status: http.StatusNotFound, Detail: "webhook not found"}
+		}
+		return nil, &domain.Error{Type: "update_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+
+	return updatedWebhook, nil
+}
+
+// GetAll - retrieves every subscription, without secrets
+func (s *Service) GetAll(ctx context.Context) ([]domain.Webhook, error) {
+	webhooks, err := s.repository.GetAll(ctx)
+	if err != nil {
+		return nil, &domain.Error{Type: "fetch_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+	return webhooks, nil
+}
+
+// GetById - retrieves a single subscription, without its secret
+func (s *Service) GetById(ctx context.Context, idParam *domain.GetWebhook) (*domain.Webhook, error) {
+	webhook, err := s.repository.GetById(ctx, idParam.Id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &domain.Error{Type: "not_found", Status: http.StatusNotFound, Detail: "webhook not found"}
+		}
+		return nil, &domain.Error{Type: "get_by_id_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+	return webhook, nil
+}
+
+// Delete - removes a subscription together with its delivery log
+func (s *Service) Delete(ctx context.Context, deleteParam *domain.DeleteWebhook) error {
+	if _, err := s.repository.GetById(ctx, deleteParam.Id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &domain.Error{Type: "not_found", Status: http.StatusNotFound, Detail: "webhook not found"}
+		}
+		return &domain.Error{Type: "delete_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+
+	if err := s.repository.Delete(ctx, deleteParam.Id); err != nil {
+		return &domain.Error{Type: "delete_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+	return nil
+}
+
+// GetDeliveries - retrieves the latest deliveries with their status, attempts and last error
+func (s *Service) GetDeliveries(ctx context.Context, deliveriesParam *domain.GetDeliveries) ([]domain.Delivery, error) {
+	if _, err := s.repository.GetById(ctx, deliveriesParam.Id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &domain.Error{Type: "not_found", Status: http.StatusNotFound, Detail: "webhook not found"}
+		}
+		return nil, &domain.Error{Type: "fetch_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+
+	limit := deliveriesParam.Limit
+	if limit == 0 {
+		limit = defaultDeliveryLimit
+	}
+
+	deliveries, err := s.repository.GetDeliveries(ctx, deliveriesParam.Id, deliveriesParam.Status, limit)
+	if err != nil {
+		return nil, &domain.Error{Type: "fetch_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+	return deliveries, nil
+}
+
+// RetryDelivery - moves a dead delivery back to pending with a fresh attempt budget
+func (s *Service) RetryDelivery(ctx context.Context, retryParam *domain.RetryDelivery) (*domain.Delivery, error) {
+	delivery, err := s.repository.RetryDelivery(ctx, retryParam.Id, retryParam.DeliveryId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &domain.Error{Type: "not_found", Status: http.StatusNotFound, Detail: "dead delivery not found"}
+		}
+		return nil, &domain.Error{Type: "retry_error", Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+	return delivery, nil
+}
+
+// eventTypes - an empty list subscribes to every event type
+func eventTypes(types []string) []string {
+	if types == nil {
+		return []string{}
+	}
+	return types
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
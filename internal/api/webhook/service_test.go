@@ -0,0 +1,85 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Generates A Secret And Subscribes To Every Event Type", func(t *testing.T) {
+		repository := &fakeRepository{}
+		created, err := NewService(repository).Create(ctx, &domain.CreateWebhook{URL: "https://example.com"})
+		assert.NoError(t, err)
+		assert.Len(t, created.Secret, 64)
+		assert.Equal(t, []string{}, created.EventTypes)
+		assert.True(t, created.Active)
+	})
+
+	t.Run("Keeps The Secret Given", func(t *testing.T) {
+		created, err := NewService(&fakeRepository{}).Create(ctx, &domain.CreateWebhook{URL: "https://example.com", Secret: "s"})
+		assert.NoError(t, err)
+		assert.Equal(t, "s", created.Secret)
+	})
+
+	t.Run("Answers Not Found For Missing Subscriptions", func(t *testing.T) {
+		service := NewService(&fakeRepository{err: sql.ErrNoRows})
+		notFound := func(err error) {
+			assert.Equal(t, http.StatusNotFound, err.(*domain.Error).Status)
+		}
+
+		_, err := service.Update(ctx, &domain.UpdateWebhook{Id: 9, URL: "https://example.com"})
+		notFound(err)
+		_, err = service.GetById(ctx, &domain.GetWebhook{Id: 9})
+		notFound(err)
+		notFound(service.Delete(ctx, &domain.DeleteWebhook{Id: 9}))
+		_, err = service.GetDeliveries(ctx, &domain.GetDeliveries{Id: 9})
+		notFound(err)
+		_, err = service.RetryDelivery(ctx, &domain.RetryDelivery{Id: 9, DeliveryId: 1})
+		notFound(err)
+	})
+
+	t.Run("Limits The Delivery Log By Default", func(t *testing.T) {
+		repository := &fakeRepository{}
+		_, err := NewService(repository).GetDeliveries(ctx, &domain.GetDeliveries{Id: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, defaultDeliveryLimit, repository.limit)
+	})
+}
+
+// fakeRepository - stores nothing, failing every call with err when set
+type fakeRepository struct {
+	Repository
+	err   error
+	limit int
+}
+
+func (r *fakeRepository) Create(_ context.Context, webhook *domain.Webhook) (*domain.Webhook, error) {
+	return webhook, r.err
+}
+
+func (r *fakeRepository) Update(_ context.Context, webhook *domain.Webhook) (*domain.Webhook, error) {
+	return webhook, r.err
+}
+
+func (r *fakeRepository) GetById(_ context.Context, id int) (*domain.Webhook, error) {
+	return &domain.Webhook{Id: id}, r.err
+}
+
+func (r *fakeRepository) Delete(context.Context, int) error {
+	return r.err
+}
+
+func (r *fakeRepository) GetDeliveries(_ context.Context, _ int, _ string, limit int) ([]domain.Delivery, error) {
+	r.limit = limit
+	return nil, r.err
+}
+
+func (r *fakeRepository) RetryDelivery(context.Context, int, int64) (*domain.Delivery, error) {
+	return nil, r.err
+}
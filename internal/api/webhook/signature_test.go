@@ -0,0 +1,52 @@
+package webhook
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSignature(t *testing.T) {
+	body := []byte(`{"type":"device.created"}`)
+	now := time.Unix(1700000000, 0)
+	header := Sign("secret", now, body)
+
+	assert.Equal(t, "t=1700000000,v1=", header[:16])
+	assert.True(t, Verify("secret", header, body, 0))
+	assert.False(t, Verify("other", header, body, 0))
+	assert.False(t, Verify("secret", header, []byte(`{}`), 0))
+	assert.False(t, Verify("secret", header, body, time.Minute))
+	assert.True(t, Verify("secret", Sign("secret", time.Now(), body), body, time.Minute))
+	assert.False(t, Verify("secret", "garbage", body, 0))
+}
+
+func TestSend(t *testing.T) {
+	var received *http.Request
+	var receivedBody []byte
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	dispatcher := &Dispatcher{client: server.Client()}
+	delivery := pendingDelivery{id: 7, eventType: "device.deleted", payload: []byte(`{"id":1}`), url: server.URL, secret: "secret"}
+
+	code, err := dispatcher.send(context.Background(), delivery)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "7", received.Header.Get(HeaderDelivery))
+	assert.Equal(t, "device.deleted", received.Header.Get(HeaderEvent))
+	assert.True(t, Verify("secret", received.Header.Get(HeaderSignature), receivedBody, time.Minute))
+
+	status = http.StatusBadGateway
+	code, err = dispatcher.send(context.Background(), delivery)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusBadGateway, code)
+}
@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// Brand - a brand of the catalogue. Device brands written as its name or one of its aliases resolve to it
+type Brand struct {
+	Id           int       `json:"id" xml:"id"`
+	Name         string    `json:"name" xml:"name"`
+	Aliases      []string  `json:"aliases" xml:"aliases>alias"`
+	CreationTime time.Time `json:"creation_time" xml:"creation_time"`
+}
+
+type CreateBrand struct {
+	Name    string   `json:"name" xml:"name" validate:"required,max=255"`
+	Aliases []string `json:"aliases,omitempty" xml:"aliases>alias,omitempty" validate:"dive,required,max=255"`
+}
+
+type UpdateBrand struct {
+	Id      int      `param:"id" validate:"required"`
+	Name    string   `json:"name" xml:"name" validate:"required,max=255"`
+	Aliases []string `json:"aliases,omitempty" xml:"aliases>alias,omitempty" validate:"dive,required,max=255"`
+}
+
+type GetBrand struct {
+	Id int `param:"id" validate:"required"`
+}
+
+type DeleteBrand struct {
+	Id int `param:"id" validate:"required"`
+}
+
+// BrandNormalization - outcome of merging the free-text brands of devices into the catalogue
+type BrandNormalization struct {
+	BrandsCreated  int `json:"brands_created"`
+	DevicesUpdated int `json:"devices_updated"`
+}
+
+// BrandKey - the form brands are matched in, so "Apple", " apple " and "APPLE" are the same brand
+func BrandKey(brand string) string {
+	return strings.ToLower(strings.Join(strings.Fields(brand), " "))
+}
@@ -1,8 +1,14 @@
 package domain
 
 import (
+	"database/sql/driver"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"sort"
 	"time"
 )
 
@@ -68,12 +74,113 @@ func (s *State) MarshalJSON() ([]byte, error) {
 	return json.Marshal(s.String())
 }
 
+// MarshalText - keeps the state a string in XML, which only looks for value receivers on non-addressable values
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *State) UnmarshalText(text []byte) error {
+	return s.UnmarshalParam(string(text))
+}
+
+// MarshalCBOR - cbor does not fall back to encoding.TextMarshaler, so the state is encoded as a text string here
+func (s State) MarshalCBOR() ([]byte, error) {
+	return cbor.Marshal(s.String())
+}
+
+func (s *State) UnmarshalCBOR(data []byte) error {
+	var stateStr string
+	if err := cbor.Unmarshal(data, &stateStr); err != nil {
+		return err
+	}
+	return s.UnmarshalParam(stateStr)
+}
+
+// EncodeMsgpack - msgpack writes text marshalers as binary, so the state is encoded as a string here
+func (s State) EncodeMsgpack(enc *msgpack.Encoder) error {
+	return enc.EncodeString(s.String())
+}
+
+func (s *State) DecodeMsgpack(dec *msgpack.Decoder) error {
+	stateStr, err := dec.DecodeString()
+	if err != nil {
+		return err
+	}
+	return s.UnmarshalParam(stateStr)
+}
+
+// Labels - key/value pairs attached to a device. They are stored as a JSON object, and written as label elements
+// in XML, which has no maps
+type Labels map[string]string
+
+// Value - nil labels are stored as an empty object
+func (l Labels) Value() (driver.Value, error) {
+	if l == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(l)
+}
+
+func (l *Labels) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	case nil:
+		*l = Labels{}
+		return nil
+	default:
+		return errors.New("labels: unsupported column type")
+	}
+}
+
+type xmlLabel struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+func (l Labels) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	keys := make([]string, 0, len(l))
+	for key := range l {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	labels := make([]xmlLabel, 0, len(l))
+	for _, key := range keys {
+		labels = append(labels, xmlLabel{Name: key, Value: l[key]})
+	}
+	return enc.EncodeElement(struct {
+		Labels []xmlLabel `xml:"label"`
+	}{labels}, start)
+}
+
+func (l *Labels) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	var labels struct {
+		Labels []xmlLabel `xml:"label"`
+	}
+	if err := dec.DecodeElement(&labels, &start); err != nil {
+		return err
+	}
+
+	*l = make(Labels, len(labels.Labels))
+	for _, label := range labels.Labels {
+		(*l)[label.Name] = label.Value
+	}
+	return nil
+}
+
+// Device - labels and the brand id are left out of the representations of v1, which the domain types are the DTOs of
 type Device struct {
-	Id           int       `json:"id"`
-	Name         string    `json:"name"`
-	Brand        string    `json:"brand"`
-	State        State     `json:"state"`
-	CreationTime time.Time `json:"creation_time"`
+	Id           int       `json:"id" xml:"id"`
+	Name         string    `json:"name" xml:"name"`
+	Brand        string    `json:"brand" xml:"brand"`
+	State        State     `json:"state" xml:"state"`
+	CreationTime time.Time `json:"creation_time" xml:"creation_time"`
+	Labels       Labels    `json:"-" xml:"-"`
+	// BrandId - the brand of the catalogue the brand was resolved to, nil for brands outside of it
+	BrandId *int `json:"-" xml:"-"`
 }
 
 type GetById struct {
@@ -90,20 +197,148 @@ type GetByState struct {
 
 type Update struct {
 	Id           int       `param:"id" validate:"required"`
-	Name         *string   `json:"name" validate:"required"`
-	Brand        *string   `json:"brand" validate:"required"`
-	State        *State    `json:"state" validate:"required"`
-	CreationTime time.Time `json:"creation_time"`
+	Name         *string   `json:"name" xml:"name" validate:"required"`
+	Brand        *string   `json:"brand" xml:"brand" validate:"required"`
+	State        *State    `json:"state" xml:"state" validate:"required"`
+	CreationTime time.Time `json:"creation_time" xml:"creation_time"`
+	// Labels - replace those of the device unless nil
+	Labels Labels `json:"-" xml:"-"`
 }
 
 type Patch struct {
 	Id           int       `param:"id" validate:"required"`
-	Name         *string   `json:"name,omitempty"`
-	Brand        *string   `json:"brand,omitempty"`
-	State        *State    `json:"state,omitempty"`
-	CreationTime time.Time `json:"creation_time"`
+	Name         *string   `json:"name,omitempty" xml:"name,omitempty"`
+	Brand        *string   `json:"brand,omitempty" xml:"brand,omitempty"`
+	State        *State    `json:"state,omitempty" xml:"state,omitempty"`
+	CreationTime time.Time `json:"creation_time" xml:"creation_time"`
+	// Labels - replace those of the device unless nil
+	Labels Labels `json:"-" xml:"-"`
+}
+
+// MergePatch - RFC 7396 document merged into the JSON representation of a device
+type MergePatch struct {
+	Id       int             `param:"id" validate:"required"`
+	Document json.RawMessage `validate:"required"`
+}
+
+// UnmarshalJSON - keeps the whole body, since the document is only applied to the stored device
+func (p *MergePatch) UnmarshalJSON(data []byte) error {
+	p.Document = append(p.Document[:0], data...)
+	return nil
+}
+
+// JSONPatch - RFC 6902 operations applied in order to the JSON representation of a device
+type JSONPatch struct {
+	Id         int             `param:"id" validate:"required"`
+	Operations json.RawMessage `validate:"required"`
+}
+
+func (p *JSONPatch) UnmarshalJSON(data []byte) error {
+	p.Operations = append(p.Operations[:0], data...)
+	return nil
 }
 
 type Delete struct {
 	Id int `param:"id" validate:"required"`
 }
+
+// ListDevices - conditions are combined with AND, and results are ordered by SortBy and then id
+type ListDevices struct {
+	Ids           []int      `json:"ids,omitempty"`
+	Name          string     `json:"name,omitempty"`
+	Brand         string     `json:"brand,omitempty"`
+	States        []State    `json:"states,omitempty"`
+	CreatedAfter  *time.Time `json:"created_after,omitempty"`
+	CreatedBefore *time.Time `json:"created_before,omitempty"`
+	SortBy        string     `json:"sort_by,omitempty" validate:"omitempty,oneof=id name brand state creation_time"`
+	Desc          bool       `json:"desc,omitempty"`
+	Limit         int        `json:"limit" validate:"min=1,max=500"`
+	Offset        int        `json:"offset" validate:"min=0"`
+}
+
+// DeviceQuery - query of a page of the device list, narrowed by the filters
+type DeviceQuery struct {
+	Name          string     `query:"name" json:"name,omitempty"`
+	Brand         string     `query:"brand" json:"brand,omitempty"`
+	States        []State    `query:"state" json:"states,omitempty"`
+	CreatedAfter  *time.Time `query:"created_after" json:"created_after,omitempty"`
+	CreatedBefore *time.Time `query:"created_before" json:"created_before,omitempty"`
+	SortBy        string     `query:"sort_by" json:"sort_by,omitempty" validate:"omitempty,oneof=id name brand state creation_time"`
+	Desc          bool       `query:"desc" json:"desc,omitempty"`
+	Limit         int        `query:"limit" json:"limit,omitempty" validate:"omitempty,min=1,max=500"`
+	Offset        int        `query:"offset" json:"offset,omitempty" validate:"min=0"`
+}
+
+type DevicePage struct {
+	Items       []Device `json:"items" xml:"items>device"`
+	TotalCount  int      `json:"total_count" xml:"total_count"`
+	HasNextPage bool     `json:"has_next_page" xml:"has_next_page"`
+}
+
+// Dimensions devices are counted by. Day, week and month bucket the creation time, in UTC, and weeks start on Monday
+const (
+	ByBrand = "brand"
+	ByState = "state"
+	ByDay   = "day"
+	ByWeek  = "week"
+	ByMonth = "month"
+)
+
+// GetStats - query of the device statistics. Filters are those of the list, and devices are counted in a group
+// for every combination of the GroupBy dimensions, at most one of them a time bucket
+type GetStats struct {
+	Name          string     `query:"name" json:"name,omitempty"`
+	Brand         string     `query:"brand" json:"brand,omitempty"`
+	States        []State    `query:"state" json:"states,omitempty"`
+	CreatedAfter  *time.Time `query:"created_after" json:"created_after,omitempty"`
+	CreatedBefore *time.Time `query:"created_before" json:"created_before,omitempty"`
+	GroupBy       []string   `query:"group_by" json:"group_by,omitempty" validate:"unique,dive,oneof=brand state day week month"`
+}
+
+// CountDevices - counts the devices matching the filter by the dimensions, in their order
+type CountDevices struct {
+	Filter  ListDevices `json:"filter"`
+	GroupBy []string    `json:"group_by,omitempty"`
+}
+
+// DeviceCount - the devices of a group, which only sets the dimensions it is grouped by
+type DeviceCount struct {
+	Brand *string `json:"brand,omitempty" xml:"brand,omitempty"`
+	State *State  `json:"state,omitempty" xml:"state,omitempty"`
+	// Period - start of the time bucket
+	Period *time.Time `json:"period,omitempty" xml:"period,omitempty"`
+	Count  int        `json:"count" xml:"count"`
+}
+
+// DeviceStats - the groups are ordered by their dimensions, and add up to the total
+type DeviceStats struct {
+	Total   int           `json:"total" xml:"total"`
+	GroupBy []string      `json:"group_by" xml:"group_by>dimension"`
+	Groups  []DeviceCount `json:"groups" xml:"groups>group"`
+}
+
+// SearchDevices - Q is matched against the words of name and brand, and by similarity to tolerate typos
+type SearchDevices struct {
+	Q     string `query:"q" json:"q" validate:"required,max=200"`
+	Limit int    `query:"limit" json:"limit,omitempty" validate:"omitempty,min=1,max=100"`
+}
+
+// DeviceMatch - a device found by a search. Scores only compare the results of the same search
+type DeviceMatch struct {
+	Device     Device     `json:"device" xml:"device"`
+	Score      float64    `json:"score" xml:"score"`
+	Highlights Highlights `json:"highlights" xml:"highlights"`
+}
+
+// Highlights - name and brand, HTML-escaped, with the words matching the query wrapped in <mark> tags
+type Highlights struct {
+	Name  string `json:"name" xml:"name"`
+	Brand string `json:"brand" xml:"brand"`
+}
+
+// DeviceSearch - results by descending score, and known brands resembling the query without being in it, for
+// queries mistyping them
+type DeviceSearch struct {
+	Results     []DeviceMatch `json:"results" xml:"results>match"`
+	Suggestions []string      `json:"suggestions" xml:"suggestions>brand"`
+}
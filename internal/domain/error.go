@@ -5,9 +5,9 @@ import (
 )
 
 type Error struct {
-	Type   string `json:"type"`
-	Status int    `json:"status"`
-	Detail string `json:"detail"`
+	Type   string `json:"type" xml:"type"`
+	Status int    `json:"status" xml:"status"`
+	Detail string `json:"detail" xml:"detail"`
 }
 
 func (e Error) Error() string {
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"time"
+)
+
+const (
+	DeviceCreated      = "device.created"
+	DeviceUpdated      = "device.updated"
+	DeviceStateChanged = "device.state_changed"
+	DeviceDeleted      = "device.deleted"
+)
+
+type Event struct {
+	Id     uint64    `json:"id"`
+	Type   string    `json:"type"`
+	Device Device    `json:"device"`
+	Time   time.Time `json:"time"`
+}
+
+type EventFilter struct {
+	Id          int    `query:"id"`
+	Brand       string `query:"brand"`
+	State       *State `query:"state"`
+	LastEventId uint64 `query:"last_event_id"`
+}
+
+// Matches - reports whether the event concerns a device selected by the filter
+func (f *EventFilter) Matches(event Event) bool {
+	if f.Id != 0 && event.Device.Id != f.Id {
+		return false
+	}
+	if f.Brand != "" && event.Device.Brand != f.Brand {
+		return false
+	}
+	if f.State != nil && event.Device.State != *f.State {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,18 @@
+package domain
+
+const (
+	HealthStatusOK       = "ok"
+	HealthStatusFail     = "fail"
+	HealthStatusDraining = "draining"
+)
+
+type Health struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+type HealthCheck struct {
+	Status  string  `json:"status"`
+	Latency float64 `json:"latency"`
+	Error   string  `json:"error,omitempty"`
+}
@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+type Webhook struct {
+	Id           int       `json:"id" xml:"id"`
+	URL          string    `json:"url" xml:"url"`
+	Secret       string    `json:"secret,omitempty" xml:"secret,omitempty"`
+	EventTypes   []string  `json:"event_types" xml:"event_types>event_type"`
+	Brand        *string   `json:"brand,omitempty" xml:"brand,omitempty"`
+	State        *State    `json:"state,omitempty" xml:"state,omitempty"`
+	Active       bool      `json:"active" xml:"active"`
+	CreationTime time.Time `json:"creation_time" xml:"creation_time"`
+}
+
+type Delivery struct {
+	Id             int64           `json:"id" xml:"id"`
+	WebhookId      int             `json:"webhook_id" xml:"webhook_id"`
+	EventType      string          `json:"event_type" xml:"event_type"`
+	Payload        json.RawMessage `json:"payload" xml:"payload"`
+	Status         string          `json:"status" xml:"status"`
+	Attempts       int             `json:"attempts" xml:"attempts"`
+	LastError      *string         `json:"last_error,omitempty" xml:"last_error,omitempty"`
+	ResponseStatus *int            `json:"response_status,omitempty" xml:"response_status,omitempty"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at" xml:"next_attempt_at"`
+	CreationTime   time.Time       `json:"creation_time" xml:"creation_time"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty" xml:"delivered_at,omitempty"`
+}
+
+type CreateWebhook struct {
+	URL        string   `json:"url" xml:"url" validate:"required,http_url"`
+	Secret     string   `json:"secret,omitempty" xml:"secret,omitempty"`
+	EventTypes []string `json:"event_types" xml:"event_types>event_type" validate:"dive,oneof=device.created device.updated device.state_changed device.deleted"`
+	Brand      *string  `json:"brand,omitempty" xml:"brand,omitempty"`
+	State      *State   `json:"state,omitempty" xml:"state,omitempty"`
+	Active     *bool    `json:"active,omitempty" xml:"active,omitempty"`
+}
+
+type UpdateWebhook struct {
+	Id         int      `param:"id" validate:"required"`
+	URL        string   `json:"url" xml:"url" validate:"required,http_url"`
+	Secret     string   `json:"secret,omitempty" xml:"secret,omitempty"`
+	EventTypes []string `json:"event_types" xml:"event_types>event_type" validate:"dive,oneof=device.created device.updated device.state_changed device.deleted"`
+	Brand      *string  `json:"brand,omitempty" xml:"brand,omitempty"`
+	State      *State   `json:"state,omitempty" xml:"state,omitempty"`
+	Active     bool     `json:"active" xml:"active"`
+}
+
+type GetWebhook struct {
+	Id int `param:"id" validate:"required"`
+}
+
+type DeleteWebhook struct {
+	Id int `param:"id" validate:"required"`
+}
+
+type GetDeliveries struct {
+	Id     int    `param:"id" validate:"required"`
+	Status string `query:"status" validate:"omitempty,oneof=pending delivered dead"`
+	Limit  int    `query:"limit" validate:"omitempty,min=1,max=500"`
+}
+
+type RetryDelivery struct {
+	Id         int   `param:"id" validate:"required"`
+	DeliveryId int64 `param:"delivery_id" validate:"required"`
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"net/http"
+)
+
+// Auth - adds credentials to every request, retries included
+type Auth interface {
+	Authenticate(req *http.Request) error
+}
+
+// AuthFunc - e.g. to sign requests or fetch short-lived tokens
+type AuthFunc func(req *http.Request) error
+
+func (f AuthFunc) Authenticate(req *http.Request) error {
+	return f(req)
+}
+
+// APIKey - sends the key in header, X-API-Key when empty
+func APIKey(header, key string) Auth {
+	if header == "" {
+		header = "X-API-Key"
+	}
+	return AuthFunc(func(req *http.Request) error {
+		req.Header.Set(header, key)
+		return nil
+	})
+}
+
+// BearerToken - sends the token in the Authorization header
+func BearerToken(token string) Auth {
+	return AuthFunc(func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
@@ -0,0 +1,206 @@
+// Package client - typed Go client of the Device API
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Retry - requests are attempted up to MaxAttempts times, waiting Backoff in between, or Retry-After when the
+// server asks for longer. 429 responses are retried for every method, as the request was not processed, while
+// 5xx responses and transport errors are only retried for idempotent methods
+type Retry struct {
+	MaxAttempts int
+	Backoff     Backoff
+}
+
+// DefaultRetry - three attempts, waiting up to 200ms and then 400ms
+var DefaultRetry = Retry{MaxAttempts: 3, Backoff: Backoff{Initial: 200 * time.Millisecond, Max: 5 * time.Second, Multiplier: 2, Jitter: true}}
+
+// NoRetry - a single attempt
+var NoRetry = Retry{MaxAttempts: 1}
+
+// Client - safe for concurrent use
+type Client struct {
+	baseURL   string
+	http      *http.Client
+	auth      Auth
+	retry     Retry
+	userAgent string
+}
+
+type Option func(*Client)
+
+// WithHTTPClient - e.g. for timeouts, proxies or client certificates. The default has a 30s timeout
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.http = httpClient }
+}
+
+func WithAuth(auth Auth) Option {
+	return func(c *Client) { c.auth = auth }
+}
+
+func WithRetry(retry Retry) Option {
+	return func(c *Client) { c.retry = retry }
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// New - baseURL is the server root, e.g. https://devices.example.com
+func New(baseURL string, options ...Option) (*Client, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q", baseURL)
+	}
+
+	c := &Client{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		http:      &http.Client{Timeout: 30 * time.Second},
+		retry:     DefaultRetry,
+		userAgent: "device-api-client",
+	}
+	for _, option := range options {
+		option(c)
+	}
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
+	}
+	return c, nil
+}
+
+// do - body is encoded as JSON unless it is a []byte already, and out is decoded from JSON when not nil.
+// Error responses are returned as *Error
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, contentType string, body, out interface{}) error {
+	var payload []byte
+	switch body := body.(type) {
+	case nil:
+	case []byte:
+		payload = body
+	default:
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		res, err := c.send(ctx, method, target, contentType, payload)
+		if err == nil && res.StatusCode < http.StatusBadRequest {
+			defer res.Body.Close()
+			if out == nil || res.StatusCode == http.StatusNoContent {
+				return nil
+			}
+			return json.NewDecoder(res.Body).Decode(out)
+		}
+
+		var wait time.Duration
+		if err == nil {
+			err = decodeError(res)
+			wait = retryAfter(res)
+		}
+		if attempt+1 >= c.retry.MaxAttempts || !retryable(ctx, method, res) {
+			return err
+		}
+
+		if delay := c.retry.Backoff.Duration(attempt); delay > wait {
+			wait = delay
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, target, contentType string, payload []byte) (*http.Response, error) {
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if payload != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.auth != nil {
+		if err = c.auth.Authenticate(req); err != nil {
+			return nil, err
+		}
+	}
+	return c.http.Do(req)
+}
+
+// retryable - res is nil after a transport error
+func retryable(ctx context.Context, method string, res *http.Response) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if res != nil && res.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if res != nil && res.StatusCode < http.StatusInternalServerError {
+		return false
+	}
+
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryAfter - only the delay-seconds form is sent by the API
+func retryAfter(res *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(res.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// decodeError - responses not in the Error shape, e.g. from a proxy, keep their body as the detail
+func decodeError(res *http.Response) error {
+	defer res.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	responseErr := new(Error)
+	if err = json.Unmarshal(data, responseErr); err != nil || responseErr.Type == "" {
+		responseErr = &Error{Type: http.StatusText(res.StatusCode), Detail: strings.TrimSpace(string(data))}
+	}
+	responseErr.Status = res.StatusCode
+	return responseErr
+}
+
+// IsStatus - reports whether err is an error response with the given status
+func IsStatus(err error, status int) bool {
+	var responseErr *Error
+	return errors.As(err, &responseErr) && responseErr.Status == status
+}
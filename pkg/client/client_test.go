@@ -0,0 +1,227 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var fastRetry = Retry{MaxAttempts: 3, Backoff: Backoff{Initial: time.Millisecond, Max: time.Millisecond}}
+
+func newTestClient(t *testing.T, handler http.HandlerFunc, options ...Option) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	c, err := New(server.URL, append([]Option{WithRetry(fastRetry)}, options...)...)
+	require.NoError(t, err)
+	return c
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestDevices(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("Sends Typed Requests And Decodes Devices", func(t *testing.T) {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "/v1/devices", r.URL.Path)
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			assert.Equal(t, "secret", r.Header.Get("X-API-Key"))
+			body, _ := io.ReadAll(r.Body)
+			assert.JSONEq(t, `{"name":"Galaxy S24","brand":"Samsung","state":"in-use"}`, string(body))
+
+			writeJSON(w, http.StatusCreated, Device{Id: 7, Name: "Galaxy S24", Brand: "Samsung", State: InUse, CreationTime: created})
+		}, WithAuth(APIKey("", "secret")))
+
+		device, err := c.CreateDevice(ctx, NewDevice{Name: "Galaxy S24", Brand: "Samsung", State: InUse})
+		require.NoError(t, err)
+		assert.Equal(t, &Device{Id: 7, Name: "Galaxy S24", Brand: "Samsung", State: InUse, CreationTime: created}, device)
+	})
+
+	t.Run("Decodes Error Responses", func(t *testing.T) {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusNotFound, Error{Type: "not_found", Status: http.StatusNotFound, Detail: "device not found"})
+		})
+
+		_, err := c.GetDevice(ctx, 9)
+		var responseErr *Error
+		require.True(t, errors.As(err, &responseErr))
+		assert.Equal(t, &Error{Type: "not_found", Status: http.StatusNotFound, Detail: "device not found"}, responseErr)
+		assert.True(t, IsStatus(err, http.StatusNotFound))
+	})
+
+	t.Run("Keeps Other Error Bodies As The Detail", func(t *testing.T) {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "upstream gone", http.StatusBadGateway)
+		}, WithRetry(NoRetry))
+
+		err := c.DeleteDevice(ctx, 1)
+		assert.Equal(t, &Error{Type: "Bad Gateway", Status: http.StatusBadGateway, Detail: "upstream gone"}, err)
+	})
+
+	t.Run("Sends Patch Documents With Their Media Types", func(t *testing.T) {
+		var contentTypes []string
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			contentTypes = append(contentTypes, r.Header.Get("Content-Type"))
+			writeJSON(w, http.StatusOK, Device{Id: 1})
+		})
+
+		_, err := c.MergePatchDevice(ctx, 1, []byte(`{"state":"inactive"}`))
+		require.NoError(t, err)
+		_, err = c.JSONPatchDevice(ctx, 1, []PatchOperation{{Op: "replace", Path: "/state", Value: "inactive"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"application/merge-patch+json", "application/json-patch+json"}, contentTypes)
+	})
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Retries Idempotent Requests On 5xx", func(t *testing.T) {
+		var attempts atomic.Int32
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if attempts.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			writeJSON(w, http.StatusOK, Device{Id: 1})
+		})
+
+		device, err := c.GetDevice(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 1, device.Id)
+		assert.Equal(t, int32(3), attempts.Load())
+	})
+
+	t.Run("Does Not Retry Creates On 5xx", func(t *testing.T) {
+		var attempts atomic.Int32
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+
+		_, err := c.CreateDevice(ctx, NewDevice{Name: "a", Brand: "b"})
+		assert.True(t, IsStatus(err, http.StatusInternalServerError))
+		assert.Equal(t, int32(1), attempts.Load())
+	})
+
+	t.Run("Retries Every Method On 429", func(t *testing.T) {
+		var attempts atomic.Int32
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if attempts.Add(1) == 1 {
+				w.Header().Set("Retry-After", "0")
+				writeJSON(w, http.StatusTooManyRequests, Error{Type: "rate_limited", Status: http.StatusTooManyRequests})
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			assert.NotEmpty(t, body)
+			writeJSON(w, http.StatusCreated, Device{Id: 2})
+		})
+
+		device, err := c.CreateDevice(ctx, NewDevice{Name: "a", Brand: "b"})
+		require.NoError(t, err)
+		assert.Equal(t, 2, device.Id)
+		assert.Equal(t, int32(2), attempts.Load())
+	})
+
+	t.Run("Gives Up After The Last Attempt", func(t *testing.T) {
+		var attempts atomic.Int32
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			w.WriteHeader(http.StatusBadGateway)
+		})
+
+		_, err := c.GetWebhooks(ctx)
+		assert.True(t, IsStatus(err, http.StatusBadGateway))
+		assert.Equal(t, int32(3), attempts.Load())
+	})
+}
+
+func TestPagination(t *testing.T) {
+	var queries []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2/devices", r.URL.Path)
+		queries = append(queries, r.URL.RawQuery)
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+		items := []map[string]interface{}{}
+		for id := offset + 1; id <= offset+limit && id <= 5; id++ {
+			items = append(items, map[string]interface{}{"id": id, "brand": "Samsung", "state": map[string]string{"value": "in-use"}})
+		}
+		page := map[string]interface{}{"limit": limit, "offset": offset, "total": 5, "next_offset": nil}
+		if next := offset + len(items); next < 5 {
+			page["next_offset"] = next
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "page": page})
+	})
+
+	var ids []int
+	for device, err := range c.Devices(context.Background(), DeviceQuery{Brand: "Samsung", States: []State{InUse}, Limit: 2}) {
+		require.NoError(t, err)
+		ids = append(ids, device.Id)
+		assert.Equal(t, InUse, device.State)
+	}
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, ids)
+	assert.Equal(t, []string{
+		"brand=Samsung&limit=2&state=in-use",
+		"brand=Samsung&limit=2&offset=2&state=in-use",
+		"brand=Samsung&limit=2&offset=4&state=in-use",
+	}, queries)
+
+	t.Run("Stops When The Caller Breaks", func(t *testing.T) {
+		queries = nil
+		for device := range c.Devices(context.Background(), DeviceQuery{Limit: 2}) {
+			if device.Id == 2 {
+				break
+			}
+		}
+		assert.Len(t, queries, 1)
+	})
+}
+
+func TestEvents(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "brand=Samsung", r.URL.RawQuery)
+		assert.Equal(t, "41", r.Header.Get("Last-Event-ID"))
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, ": heartbeat\n\n")
+		for id := 42; id <= 43; id++ {
+			data, _ := json.Marshal(Event{Id: uint64(id), Type: DeviceStateChanged, Device: Device{Id: 1, State: Inactive}})
+			_, _ = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, DeviceStateChanged, data)
+		}
+	})
+
+	stream, err := c.Events(context.Background(), EventFilter{Brand: "Samsung", LastEventId: 41})
+	require.NoError(t, err)
+	defer stream.Close()
+
+	event, err := stream.Next()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(42), event.Id)
+	assert.Equal(t, Inactive, event.Device.State)
+
+	_, err = stream.Next()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(43), stream.LastEventId())
+
+	_, err = stream.Next()
+	assert.Equal(t, io.EOF, err)
+}
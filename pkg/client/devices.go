@@ -0,0 +1,166 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DefaultPageSize - page size of ListDevices and Devices when the query has no limit
+const DefaultPageSize = 100
+
+func devicePath(id int) string {
+	return "/v1/devices/" + strconv.Itoa(id)
+}
+
+func (c *Client) CreateDevice(ctx context.Context, device NewDevice) (*Device, error) {
+	created := new(Device)
+	if err := c.do(ctx, http.MethodPost, "/v1/devices", nil, "application/json", device, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (c *Client) GetDevice(ctx context.Context, id int) (*Device, error) {
+	device := new(Device)
+	if err := c.do(ctx, http.MethodGet, devicePath(id), nil, "", nil, device); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// UpdateDevice - replaces the name, brand and state of a device
+func (c *Client) UpdateDevice(ctx context.Context, id int, device NewDevice) (*Device, error) {
+	updated := new(Device)
+	if err := c.do(ctx, http.MethodPut, devicePath(id), nil, "application/json", device, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (c *Client) PatchDevice(ctx context.Context, id int, patch DevicePatch) (*Device, error) {
+	return c.patch(ctx, id, "application/json", patch)
+}
+
+// MergePatchDevice - applies an RFC 7396 JSON Merge Patch document
+func (c *Client) MergePatchDevice(ctx context.Context, id int, document []byte) (*Device, error) {
+	return c.patch(ctx, id, "application/merge-patch+json", document)
+}
+
+// JSONPatchDevice - applies RFC 6902 operations atomically. A failed test operation is a 409 *Error
+func (c *Client) JSONPatchDevice(ctx context.Context, id int, operations []PatchOperation) (*Device, error) {
+	return c.patch(ctx, id, "application/json-patch+json", operations)
+}
+
+func (c *Client) patch(ctx context.Context, id int, contentType string, body interface{}) (*Device, error) {
+	patched := new(Device)
+	if err := c.do(ctx, http.MethodPatch, devicePath(id), nil, contentType, body, patched); err != nil {
+		return nil, err
+	}
+	return patched, nil
+}
+
+func (c *Client) DeleteDevice(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, devicePath(id), nil, "", nil, nil)
+}
+
+func (c *Client) GetDevices(ctx context.Context) ([]Device, error) {
+	var devices []Device
+	if err := c.do(ctx, http.MethodGet, "/v1/devices", nil, "", nil, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+func (c *Client) GetDevicesByBrand(ctx context.Context, brand string) ([]Device, error) {
+	var devices []Device
+	if err := c.do(ctx, http.MethodGet, "/v1/devices/brand/"+url.PathEscape(brand), nil, "", nil, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+func (c *Client) GetDevicesByState(ctx context.Context, state State) ([]Device, error) {
+	var devices []Device
+	if err := c.do(ctx, http.MethodGet, "/v1/devices/state/"+state.String(), nil, "", nil, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// ListDevices - one page of the devices matching the query, of DefaultPageSize devices without a limit. Pages are
+// the ones of GET /v2/devices, as the list of v1 has neither filters nor pages
+func (c *Client) ListDevices(ctx context.Context, query DeviceQuery) (*DevicePage, error) {
+	if query.Limit == 0 {
+		query.Limit = DefaultPageSize
+	}
+	list := new(deviceList)
+	if err := c.do(ctx, http.MethodGet, "/v2/devices", deviceValues(query), "", nil, list); err != nil {
+		return nil, err
+	}
+
+	page := &DevicePage{Items: make([]Device, 0, len(list.Items)), TotalCount: list.Page.Total,
+		HasNextPage: list.Page.NextOffset != nil}
+	for _, item := range list.Items {
+		page.Items = append(page.Items, Device{Id: item.Id, Name: item.Name, Brand: item.Brand, State: item.State.Value,
+			CreationTime: item.CreationTime, Labels: item.Labels, BrandId: item.BrandId})
+	}
+	return page, nil
+}
+
+// Devices - iterates over the devices matching the query page by page, starting at its offset and fetching
+// pages of its limit. Iteration stops after the first error, which is yielded with a zero device
+func (c *Client) Devices(ctx context.Context, query DeviceQuery) iter.Seq2[Device, error] {
+	return func(yield func(Device, error) bool) {
+		for {
+			page, err := c.ListDevices(ctx, query)
+			if err != nil {
+				yield(Device{}, err)
+				return
+			}
+			for _, device := range page.Items {
+				if !yield(device, nil) {
+					return
+				}
+			}
+			if !page.HasNextPage || len(page.Items) == 0 {
+				return
+			}
+			query.Offset += len(page.Items)
+		}
+	}
+}
+
+func deviceValues(query DeviceQuery) url.Values {
+	values := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	set("name", query.Name)
+	set("brand", query.Brand)
+	for _, state := range query.States {
+		values.Add("state", state.String())
+	}
+	if query.CreatedAfter != nil {
+		set("created_after", query.CreatedAfter.Format(time.RFC3339Nano))
+	}
+	if query.CreatedBefore != nil {
+		set("created_before", query.CreatedBefore.Format(time.RFC3339Nano))
+	}
+	set("sort_by", query.SortBy)
+	if query.Desc {
+		set("desc", "true")
+	}
+	if query.Limit != 0 {
+		set("limit", strconv.Itoa(query.Limit))
+	}
+	if query.Offset != 0 {
+		set("offset", strconv.Itoa(query.Offset))
+	}
+	return values
+}
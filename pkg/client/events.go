@@ -0,0 +1,105 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// EventStream - device change events, read one at a time with Next
+type EventStream struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+	lastId  uint64
+}
+
+// Events - opens the Server-Sent Events stream of the devices selected by the filter, resuming after
+// filter.LastEventId when it is set. The stream ends when ctx is done or Close is called. A dropped stream can
+// be resumed by opening another one after LastEventId, within the replay buffer of the server
+func (c *Client) Events(ctx context.Context, filter EventFilter) (*EventStream, error) {
+	values := url.Values{}
+	if filter.Id != 0 {
+		values.Set("id", strconv.Itoa(filter.Id))
+	}
+	if filter.Brand != "" {
+		values.Set("brand", filter.Brand)
+	}
+	if filter.State != nil {
+		values.Set("state", filter.State.String())
+	}
+	target := c.baseURL + "/v1/devices/events"
+	if len(values) > 0 {
+		target += "?" + values.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("User-Agent", c.userAgent)
+	if filter.LastEventId != 0 {
+		req.Header.Set("Last-Event-ID", strconv.FormatUint(filter.LastEventId, 10))
+	}
+	if c.auth != nil {
+		if err = c.auth.Authenticate(req); err != nil {
+			return nil, err
+		}
+	}
+
+	// The stream outlives any request timeout of the HTTP client
+	streaming := *c.http
+	streaming.Timeout = 0
+	res, err := streaming.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= http.StatusBadRequest {
+		return nil, decodeError(res)
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	return &EventStream{body: res.Body, scanner: scanner, lastId: filter.LastEventId}, nil
+}
+
+// Next - blocks until the next event, returning io.EOF once the stream ends
+func (s *EventStream) Next() (Event, error) {
+	var data strings.Builder
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+		switch {
+		case line == "":
+			if data.Len() == 0 {
+				continue
+			}
+			var event Event
+			if err := json.Unmarshal([]byte(data.String()), &event); err != nil {
+				return Event{}, err
+			}
+			s.lastId = event.Id
+			return event, nil
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+
+	if err := s.scanner.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
+
+// LastEventId - id of the last event read, to resume after
+func (s *EventStream) LastEventId() uint64 {
+	return s.lastId
+}
+
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"github.com/ivofreitas/device-api/internal/adapter/backoff"
+	"github.com/ivofreitas/device-api/internal/domain"
+	"time"
+)
+
+// The API types are the ones of the server, so they cannot drift from it
+type (
+	Device        = domain.Device
+	State         = domain.State
+	DevicePage    = domain.DevicePage
+	DeviceQuery   = domain.DeviceQuery
+	Event         = domain.Event
+	EventFilter   = domain.EventFilter
+	Webhook       = domain.Webhook
+	CreateWebhook = domain.CreateWebhook
+	Delivery      = domain.Delivery
+	Error         = domain.Error
+	Backoff       = backoff.Backoff
+)
+
+const (
+	Available = domain.AvailableState
+	InUse     = domain.InUseState
+	Inactive  = domain.InactiveState
+)
+
+const (
+	DeviceCreated      = domain.DeviceCreated
+	DeviceUpdated      = domain.DeviceUpdated
+	DeviceStateChanged = domain.DeviceStateChanged
+	DeviceDeleted      = domain.DeviceDeleted
+)
+
+const (
+	DeliveryPending   = domain.DeliveryPending
+	DeliveryDelivered = domain.DeliveryDelivered
+	DeliveryDead      = domain.DeliveryDead
+)
+
+// ParseState - reads available, in-use and inactive
+func ParseState(state string) (State, error) {
+	return domain.ParseState(state)
+}
+
+// NewDevice - body of CreateDevice and UpdateDevice. The domain requests also carry the id, which is a path param
+type NewDevice struct {
+	Name  string `json:"name"`
+	Brand string `json:"brand"`
+	State State  `json:"state"`
+}
+
+// DevicePatch - body of PatchDevice, only the fields set are changed
+type DevicePatch struct {
+	Name  *string `json:"name,omitempty"`
+	Brand *string `json:"brand,omitempty"`
+	State *State  `json:"state,omitempty"`
+}
+
+// PatchOperation - RFC 6902 operation of JSONPatchDevice
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// UpdateWebhook - body of UpdateWebhook, replacing the whole subscription
+type UpdateWebhook struct {
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret,omitempty"`
+	EventTypes []string `json:"event_types"`
+	Brand      *string  `json:"brand,omitempty"`
+	State      *State   `json:"state,omitempty"`
+	Active     bool     `json:"active"`
+}
+
+// deviceList - a page of GET /v2/devices, whose devices describe their state and carry their labels
+type deviceList struct {
+	Items []struct {
+		Id      int    `json:"id"`
+		Name    string `json:"name"`
+		Brand   string `json:"brand"`
+		BrandId *int   `json:"brand_id"`
+		State   struct {
+			Value State `json:"value"`
+		} `json:"state"`
+		Labels       domain.Labels `json:"labels"`
+		CreationTime time.Time     `json:"creation_time"`
+	} `json:"items"`
+	Page struct {
+		Total      int  `json:"total"`
+		NextOffset *int `json:"next_offset"`
+	} `json:"page"`
+}
+
+// DeliveryQuery - filters of GetDeliveries, the server returning up to 50 deliveries without a limit
+type DeliveryQuery struct {
+	Status string
+	Limit  int
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+func webhookPath(id int) string {
+	return "/v1/webhooks/" + strconv.Itoa(id)
+}
+
+// CreateWebhook - the returned subscription holds the signing secret, which is not returned again
+func (c *Client) CreateWebhook(ctx context.Context, webhook CreateWebhook) (*Webhook, error) {
+	created := new(Webhook)
+	if err := c.do(ctx, http.MethodPost, "/v1/webhooks", nil, "application/json", webhook, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (c *Client) UpdateWebhook(ctx context.Context, id int, webhook UpdateWebhook) (*Webhook, error) {
+	updated := new(Webhook)
+	if err := c.do(ctx, http.MethodPut, webhookPath(id), nil, "application/json", webhook, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (c *Client) GetWebhooks(ctx context.Context) ([]Webhook, error) {
+	var webhooks []Webhook
+	if err := c.do(ctx, http.MethodGet, "/v1/webhooks", nil, "", nil, &webhooks); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (c *Client) GetWebhook(ctx context.Context, id int) (*Webhook, error) {
+	webhook := new(Webhook)
+	if err := c.do(ctx, http.MethodGet, webhookPath(id), nil, "", nil, webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, webhookPath(id), nil, "", nil, nil)
+}
+
+// GetDeliveries - the delivery log of a subscription, most recent first
+func (c *Client) GetDeliveries(ctx context.Context, id int, query DeliveryQuery) ([]Delivery, error) {
+	values := url.Values{}
+	if query.Status != "" {
+		values.Set("status", query.Status)
+	}
+	if query.Limit != 0 {
+		values.Set("limit", strconv.Itoa(query.Limit))
+	}
+
+	var deliveries []Delivery
+	if err := c.do(ctx, http.MethodGet, webhookPath(id)+"/deliveries", values, "", nil, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// RetryDelivery - schedules a dead-lettered delivery again
+func (c *Client) RetryDelivery(ctx context.Context, id int, deliveryId int64) (*Delivery, error) {
+	delivery := new(Delivery)
+	path := webhookPath(id) + "/deliveries/" + strconv.FormatInt(deliveryId, 10) + "/retry"
+	if err := c.do(ctx, http.MethodPost, path, nil, "", nil, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
@@ -0,0 +1,196 @@
+// Package devicetest - an in-process device API for the integration tests of its consumers, serving the real
+// routes, validation and error bodies over an in-memory store instead of Postgres
+package devicetest
+
+import (
+	"context"
+	"github.com/ivofreitas/device-api/config"
+	"github.com/ivofreitas/device-api/internal/adapter/event"
+	"github.com/ivofreitas/device-api/internal/adapter/log"
+	"github.com/ivofreitas/device-api/internal/api"
+	"github.com/ivofreitas/device-api/internal/api/device"
+	"github.com/ivofreitas/device-api/pkg/client"
+	"github.com/labstack/echo/v4"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Server - the REST and GraphQL APIs listening on a local port. Webhooks and rate limits are not served
+type Server struct {
+	// URL - base URL of the server, e.g. http://127.0.0.1:40213
+	URL string
+
+	t          testing.TB
+	repository device.Repository
+	bus        *event.Bus
+	server     *httptest.Server
+
+	mu     sync.Mutex
+	faults []*Fault
+}
+
+// Fault - makes the requests it matches slow, fail, or both
+type Fault struct {
+	// Method - matches every method when empty
+	Method string
+	// Path - the route as registered, e.g. /v1/devices/:id, or a request path like /v1/devices/42.
+	// Matches every path when empty
+	Path string
+	// Latency - delay before the request is handled or fails
+	Latency time.Duration
+	// Error - response to the request instead of the handler one
+	Error *client.Error
+	// Times - requests the fault applies to before it is removed, every one when 0
+	Times int
+}
+
+// NewServer - starts an empty server, closed when the test ends. It is configured by the defaults of the API alone,
+// whatever the environment of the test. Request logs are discarded until the test ends
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	env := config.Defaults()
+	log.Init(env.Log.Level)
+	output := log.Output()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(output) })
+
+	s := &Server{t: t, bus: event.NewBus(env.Events.ReplaySize)}
+	s.repository = device.NewMemoryRepository(s.bus)
+
+	handler := api.NewHandler(env, s.repository, s.bus)
+	handler.Use(s.inject)
+	s.server = httptest.NewServer(handler)
+	s.URL = s.server.URL
+
+	t.Cleanup(func() {
+		// Closing the bus ends the event streams, which would otherwise keep the server from closing
+		s.bus.Close()
+		s.server.Close()
+	})
+	return s
+}
+
+// Client - an API client of the server, not retrying unless options say otherwise
+func (s *Server) Client(options ...client.Option) *client.Client {
+	c, err := client.New(s.URL, append([]client.Option{client.WithRetry(client.NoRetry)}, options...)...)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return c
+}
+
+// Seed - stores devices as they are given, except for their ids, and returns them with the ids they were given.
+// A device without a creation time is created now. The test fails when a device cannot be stored
+func (s *Server) Seed(devices ...client.Device) []client.Device {
+	s.t.Helper()
+
+	ctx := context.Background()
+	seeded := make([]client.Device, 0, len(devices))
+	for _, seed := range devices {
+		created, err := s.repository.Create(ctx, &seed)
+		if err != nil {
+			s.t.Fatalf("seeding %+v: %v", seed, err)
+		}
+		if !seed.CreationTime.IsZero() {
+			created.CreationTime = seed.CreationTime
+			if err = s.repository.Update(ctx, created); err != nil {
+				s.t.Fatalf("seeding %+v: %v", seed, err)
+			}
+			if created, err = s.repository.GetById(ctx, created.Id); err != nil {
+				s.t.Fatalf("seeding %+v: %v", seed, err)
+			}
+		}
+		seeded = append(seeded, *created)
+	}
+	return seeded
+}
+
+// Devices - every stored device, ordered by id
+func (s *Server) Devices() []client.Device {
+	s.t.Helper()
+
+	devices, err := s.repository.GetAll(context.Background())
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return devices
+}
+
+// Inject - applies the fault to the requests it matches from now on. Faults are checked in the order they were
+// injected, and the first match applies
+func (s *Server) Inject(fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &fault)
+}
+
+// Delay - slows down the matching requests
+func (s *Server) Delay(method, path string, latency time.Duration) {
+	s.Inject(Fault{Method: method, Path: path, Latency: latency})
+}
+
+// Fail - fails the matching requests with the status, e.g. 503
+func (s *Server) Fail(method, path string, status int) {
+	s.FailWith(method, path, &client.Error{Type: "injected_fault", Status: status, Detail: http.StatusText(status)})
+}
+
+// FailWith - fails the matching requests with the error, as the API would
+func (s *Server) FailWith(method, path string, err *client.Error) {
+	s.Inject(Fault{Method: method, Path: path, Error: err})
+}
+
+// ClearFaults - removes every fault
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// inject - runs after routing, when the route of the request is known
+func (s *Server) inject(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		fault := s.match(c.Request().Method, c.Path(), c.Request().URL.Path)
+		if fault == nil {
+			return next(c)
+		}
+
+		if fault.Latency > 0 {
+			timer := time.NewTimer(fault.Latency)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-c.Request().Context().Done():
+				return c.Request().Context().Err()
+			}
+		}
+		if fault.Error != nil {
+			return c.JSON(fault.Error.Status, fault.Error)
+		}
+		return next(c)
+	}
+}
+
+func (s *Server) match(method, route, path string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, fault := range s.faults {
+		if (fault.Method != "" && fault.Method != method) || (fault.Path != "" && fault.Path != route && fault.Path != path) {
+			continue
+		}
+		if fault.Times > 0 {
+			if fault.Times--; fault.Times == 0 {
+				s.faults = slices.Delete(s.faults, i, i+1)
+			}
+		}
+		matched := *fault
+		return &matched
+	}
+	return nil
+}
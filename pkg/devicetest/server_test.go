@@ -0,0 +1,116 @@
+package devicetest
+
+import (
+	"context"
+	"github.com/ivofreitas/device-api/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("Serves Seeded Devices", func(t *testing.T) {
+		s := NewServer(t)
+		seeded := s.Seed(
+			client.Device{Name: "Galaxy S24", Brand: "Samsung", State: client.InUse, CreationTime: created},
+			client.Device{Name: "Pixel 9", Brand: "Google"},
+		)
+		require.Len(t, seeded, 2)
+		assert.Equal(t, client.Device{Id: 1, Name: "Galaxy S24", Brand: "Samsung", State: client.InUse, CreationTime: created}, seeded[0])
+		assert.Equal(t, 2, seeded[1].Id)
+
+		devices, err := s.Client().GetDevices(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, seeded, devices)
+
+		page, err := s.Client().ListDevices(ctx, client.DeviceQuery{Brand: "Samsung"})
+		require.NoError(t, err)
+		require.Len(t, page.Items, 1)
+		assert.Equal(t, seeded[0].Id, page.Items[0].Id)
+		assert.Equal(t, client.InUse, page.Items[0].State)
+		assert.Equal(t, 1, page.TotalCount)
+		assert.False(t, page.HasNextPage)
+	})
+
+	t.Run("Applies The Rules Of The API", func(t *testing.T) {
+		s := NewServer(t)
+		seeded := s.Seed(client.Device{Name: "Galaxy S24", Brand: "Samsung", State: client.InUse})
+		c := s.Client()
+
+		_, err := c.UpdateDevice(ctx, seeded[0].Id, client.NewDevice{Name: "Galaxy S25", Brand: "Samsung", State: client.InUse})
+		assert.True(t, client.IsStatus(err, http.StatusForbidden))
+
+		_, err = c.GetDevice(ctx, 99)
+		assert.Equal(t, &client.Error{Type: "not_found", Status: http.StatusNotFound, Detail: "device not found"}, err)
+
+		device, err := c.CreateDevice(ctx, client.NewDevice{Name: "Pixel 9", Brand: "Google"})
+		require.NoError(t, err)
+		assert.Equal(t, []client.Device{seeded[0], *device}, s.Devices())
+	})
+
+	t.Run("Fails Matching Requests With Errors", func(t *testing.T) {
+		s := NewServer(t)
+		s.Seed(client.Device{Name: "Galaxy S24", Brand: "Samsung"})
+		conflict := &client.Error{Type: "test_failed", Status: http.StatusConflict, Detail: "testing state failed"}
+		s.FailWith(http.MethodPatch, "/v1/devices/:id", conflict)
+		s.Fail(http.MethodGet, "/v1/devices/1", http.StatusServiceUnavailable)
+		c := s.Client()
+
+		_, err := c.JSONPatchDevice(ctx, 1, []client.PatchOperation{{Op: "replace", Path: "/state", Value: "in-use"}})
+		assert.Equal(t, conflict, err)
+		_, err = c.GetDevice(ctx, 1)
+		assert.True(t, client.IsStatus(err, http.StatusServiceUnavailable))
+		_, err = c.GetDevices(ctx)
+		assert.NoError(t, err)
+
+		s.ClearFaults()
+		_, err = c.GetDevice(ctx, 1)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Removes Faults After Their Times", func(t *testing.T) {
+		s := NewServer(t)
+		s.Seed(client.Device{Name: "Galaxy S24", Brand: "Samsung"})
+		s.Inject(Fault{Path: "/v1/devices/:id", Error: &client.Error{Type: "injected_fault", Status: http.StatusBadGateway}, Times: 2})
+
+		_, err := s.Client(client.WithRetry(client.Retry{MaxAttempts: 3, Backoff: client.Backoff{Initial: time.Millisecond}})).
+			GetDevice(ctx, 1)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Delays Matching Requests", func(t *testing.T) {
+		s := NewServer(t)
+		s.Delay("", "", 50*time.Millisecond)
+
+		start := time.Now()
+		_, err := s.Client().GetDevices(ctx)
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+		timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, err = s.Client().GetDevices(timeout)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("Streams Device Events", func(t *testing.T) {
+		s := NewServer(t)
+		c := s.Client()
+		stream, err := c.Events(ctx, client.EventFilter{})
+		require.NoError(t, err)
+		defer stream.Close()
+
+		device, err := c.CreateDevice(ctx, client.NewDevice{Name: "Pixel 9", Brand: "Google"})
+		require.NoError(t, err)
+
+		event, err := stream.Next()
+		require.NoError(t, err)
+		assert.Equal(t, client.DeviceCreated, event.Type)
+		assert.Equal(t, *device, event.Device)
+	})
+}